@@ -10,6 +10,20 @@ type Node interface {
 	String() string
 }
 
+// Span is where a node starts in the source, counting from 1, and how many
+// columns of that line it covers. It is zero for a node that was not parsed
+// from source.
+type Span struct {
+	Line   int
+	Column int
+	Length int
+}
+
+// Pos returns the span of the node, for the parser to fill in
+func (s *Span) Pos() *Span {
+	return s
+}
+
 // ===== Types =====
 
 // Type represents a type expression
@@ -54,6 +68,16 @@ func (r *RefType) String() string {
 	return "&" + r.Elem.String()
 }
 
+// DynType represents dyn Trait, a trait object used behind a reference
+type DynType struct {
+	Trait *TypePath
+}
+
+func (d *DynType) typeNode() {}
+func (d *DynType) String() string {
+	return "dyn " + d.Trait.String()
+}
+
 // PtrType represents *T (unsafe raw pointer)
 type PtrType struct {
 	Elem Type
@@ -100,6 +124,28 @@ func (t *TupleType) String() string {
 	return "(" + strings.Join(elems, ", ") + ")"
 }
 
+// FuncType represents fn(T1, T2) R, a pointer to a function. Return is
+// nil for a function that returns nothing.
+type FuncType struct {
+	Params []Type
+	Return Type
+}
+
+func (f *FuncType) typeNode() {}
+func (f *FuncType) String() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+
+	s := "fn(" + strings.Join(params, ", ") + ")"
+	if f.Return != nil {
+		s += " " + f.Return.String()
+	}
+
+	return s
+}
+
 // VoidType represents void
 type VoidType struct{}
 
@@ -114,10 +160,12 @@ func (v *VoidType) String() string {
 type Expr interface {
 	Node
 	exprNode()
+	Pos() *Span
 }
 
 // Ident represents an identifier
 type Ident struct {
+	Span
 	Name string
 }
 
@@ -126,8 +174,20 @@ func (i *Ident) String() string {
 	return i.Name
 }
 
+// PathExpr represents a qualified path such as Color::Red or Option::Some
+type PathExpr struct {
+	Span
+	Segments []string
+}
+
+func (p *PathExpr) exprNode() {}
+func (p *PathExpr) String() string {
+	return strings.Join(p.Segments, "::")
+}
+
 // IntLit represents an integer literal
 type IntLit struct {
+	Span
 	Value string // "123", "0xFF", etc.
 }
 
@@ -138,6 +198,7 @@ func (i *IntLit) String() string {
 
 // FloatLit represents a float literal
 type FloatLit struct {
+	Span
 	Value string
 }
 
@@ -148,6 +209,7 @@ func (f *FloatLit) String() string {
 
 // CharLit represents a char literal
 type CharLit struct {
+	Span
 	Value string
 }
 
@@ -158,6 +220,7 @@ func (c *CharLit) String() string {
 
 // StringLit represents a string literal
 type StringLit struct {
+	Span
 	Value string
 }
 
@@ -168,6 +231,7 @@ func (s *StringLit) String() string {
 
 // BoolLit represents true/false
 type BoolLit struct {
+	Span
 	Value bool
 }
 
@@ -181,7 +245,9 @@ func (b *BoolLit) String() string {
 }
 
 // NilLit represents nil
-type NilLit struct{}
+type NilLit struct {
+	Span
+}
 
 func (n *NilLit) exprNode() {}
 func (n *NilLit) String() string {
@@ -190,6 +256,7 @@ func (n *NilLit) String() string {
 
 // BinaryExpr represents binary operations
 type BinaryExpr struct {
+	Span
 	Left  Expr
 	Op    string
 	Right Expr
@@ -202,6 +269,7 @@ func (b *BinaryExpr) String() string {
 
 // UnaryExpr represents unary operations
 type UnaryExpr struct {
+	Span
 	Op   string
 	Expr Expr
 }
@@ -218,6 +286,7 @@ func (u *UnaryExpr) String() string {
 
 // CallExpr represents function calls
 type CallExpr struct {
+	Span
 	Callee Expr
 	Args   []Expr
 }
@@ -234,6 +303,7 @@ func (c *CallExpr) String() string {
 
 // IndexExpr represents array/slice indexing
 type IndexExpr struct {
+	Span
 	Expr  Expr
 	Index Expr
 }
@@ -245,6 +315,7 @@ func (i *IndexExpr) String() string {
 
 // FieldExpr represents field access
 type FieldExpr struct {
+	Span
 	Expr  Expr
 	Field string
 }
@@ -256,6 +327,7 @@ func (f *FieldExpr) String() string {
 
 // PropagateExpr represents ? operator
 type PropagateExpr struct {
+	Span
 	Expr Expr
 }
 
@@ -264,8 +336,35 @@ func (p *PropagateExpr) String() string {
 	return p.Expr.String() + "?"
 }
 
+// CastExpr is an explicit conversion, x as T
+type CastExpr struct {
+	Span
+	Expr Expr
+	Type Type
+	From Type // Type of Expr, filled in by the checker
+}
+
+func (c *CastExpr) exprNode() {}
+func (c *CastExpr) String() string {
+	return fmt.Sprintf("(%s as %s)", c.Expr.String(), c.Type.String())
+}
+
+// ConvExpr is an implicit widening conversion inserted by the checker
+type ConvExpr struct {
+	Span
+	Expr Expr
+	From Type
+	To   Type
+}
+
+func (c *ConvExpr) exprNode() {}
+func (c *ConvExpr) String() string {
+	return fmt.Sprintf("(%s as %s)", c.Expr.String(), c.To.String())
+}
+
 // StructExpr represents struct literal
 type StructExpr struct {
+	Span
 	Type  Type
 	Inits []FieldInit
 }
@@ -287,6 +386,7 @@ func (s *StructExpr) String() string {
 
 // ArrayExpr represents array literal
 type ArrayExpr struct {
+	Span
 	Elems []Expr
 }
 
@@ -302,6 +402,7 @@ func (a *ArrayExpr) String() string {
 
 // TupleExpr represents tuple literal
 type TupleExpr struct {
+	Span
 	Elems []Expr
 }
 
@@ -312,6 +413,11 @@ func (t *TupleExpr) String() string {
 		elems[i] = e.String()
 	}
 
+	// A single element needs its comma to stay a tuple
+	if len(elems) == 1 {
+		return "(" + elems[0] + ",)"
+	}
+
 	return "(" + strings.Join(elems, ", ") + ")"
 }
 
@@ -321,10 +427,12 @@ func (t *TupleExpr) String() string {
 type Stmt interface {
 	Node
 	stmtNode()
+	Pos() *Span
 }
 
 // LetStmt represents let binding
 type LetStmt struct {
+	Span
 	Mut   bool
 	Name  string
 	Type  Type // nil if inferred
@@ -346,8 +454,21 @@ func (l *LetStmt) String() string {
 	return fmt.Sprintf("let %s%s%s = %s", mut, l.Name, typ, l.Value.String())
 }
 
+// LetTupleStmt represents a destructuring binding: let (a, b) = value
+type LetTupleStmt struct {
+	Span
+	Names []string
+	Value Expr
+}
+
+func (l *LetTupleStmt) stmtNode() {}
+func (l *LetTupleStmt) String() string {
+	return fmt.Sprintf("let (%s) = %s", strings.Join(l.Names, ", "), l.Value.String())
+}
+
 // AssignStmt represents assignment
 type AssignStmt struct {
+	Span
 	Target Expr
 	Op     string // "=" or "+=", etc.
 	Value  Expr
@@ -360,6 +481,7 @@ func (a *AssignStmt) String() string {
 
 // ExprStmt represents expression statement
 type ExprStmt struct {
+	Span
 	Expr Expr
 }
 
@@ -370,6 +492,7 @@ func (e *ExprStmt) String() string {
 
 // ReturnStmt represents return
 type ReturnStmt struct {
+	Span
 	Value Expr // nil for bare return
 }
 
@@ -384,6 +507,7 @@ func (r *ReturnStmt) String() string {
 
 // IfStmt represents if/else
 type IfStmt struct {
+	Span
 	Cond Expr
 	Then *Block
 	Else Stmt // nil, *Block, or *IfStmt
@@ -399,8 +523,22 @@ func (i *IfStmt) String() string {
 	return s
 }
 
+// GuardStmt represents guard cond else { ... }. The else block runs when
+// cond is false and must not fall through.
+type GuardStmt struct {
+	Span
+	Cond Expr
+	Else *Block
+}
+
+func (g *GuardStmt) stmtNode() {}
+func (g *GuardStmt) String() string {
+	return fmt.Sprintf("guard %s else %s", g.Cond.String(), g.Else.String())
+}
+
 // WhileStmt represents while loop
 type WhileStmt struct {
+	Span
 	Cond Expr
 	Body *Block
 }
@@ -412,6 +550,7 @@ func (w *WhileStmt) String() string {
 
 // ForStmt represents for loop
 type ForStmt struct {
+	Span
 	Key  string // empty if not used
 	Val  string
 	Iter Expr
@@ -428,7 +567,9 @@ func (f *ForStmt) String() string {
 }
 
 // BreakStmt represents break
-type BreakStmt struct{}
+type BreakStmt struct {
+	Span
+}
 
 func (b *BreakStmt) stmtNode() {}
 func (b *BreakStmt) String() string {
@@ -436,7 +577,9 @@ func (b *BreakStmt) String() string {
 }
 
 // ContinueStmt represents continue
-type ContinueStmt struct{}
+type ContinueStmt struct {
+	Span
+}
 
 func (c *ContinueStmt) stmtNode() {}
 func (c *ContinueStmt) String() string {
@@ -445,6 +588,7 @@ func (c *ContinueStmt) String() string {
 
 // DeferStmt represents defer
 type DeferStmt struct {
+	Span
 	Expr Expr
 }
 
@@ -455,6 +599,7 @@ func (d *DeferStmt) String() string {
 
 // ShortDecl represents := declaration
 type ShortDecl struct {
+	Span
 	Name  string
 	Value Expr
 }
@@ -466,6 +611,7 @@ func (s *ShortDecl) String() string {
 
 // ConstStmt represents block-level const statement
 type ConstStmt struct {
+	Span
 	Name  string
 	Type  Type
 	Value Expr
@@ -478,6 +624,7 @@ func (c *ConstStmt) String() string {
 
 // UnsafeBlock represents unsafe { }
 type UnsafeBlock struct {
+	Span
 	Body *Block
 }
 
@@ -486,8 +633,21 @@ func (u *UnsafeBlock) String() string {
 	return "unsafe " + u.Body.String()
 }
 
+// DeclStmt represents a fn or struct declared inside a block. It is visible
+// from its declaration to the end of the block.
+type DeclStmt struct {
+	Span
+	Decl Decl // *FuncDecl or *StructDecl
+}
+
+func (d *DeclStmt) stmtNode() {}
+func (d *DeclStmt) String() string {
+	return d.Decl.String()
+}
+
 // Block represents a block of statements
 type Block struct {
+	Span
 	Stmts []Stmt
 }
 
@@ -507,10 +667,27 @@ func (b *Block) String() string {
 type Decl interface {
 	Node
 	declNode()
+	Pos() *Span
+}
+
+// Attribute represents #[name(arg, ...)] written before an item
+type Attribute struct {
+	Name string
+	Args []string
+}
+
+func (a *Attribute) String() string {
+	if len(a.Args) == 0 {
+		return fmt.Sprintf("#[%s]", a.Name)
+	}
+
+	return fmt.Sprintf("#[%s(%s)]", a.Name, strings.Join(a.Args, ", "))
 }
 
 // UseDecl represents use/import
 type UseDecl struct {
+	Span
+	Attrs []*Attribute
 	Path  []string
 	Alias string // empty if no alias
 }
@@ -527,6 +704,7 @@ func (u *UseDecl) String() string {
 
 // ConstDecl represents const declaration
 type ConstDecl struct {
+	Span
 	Name  string
 	Type  Type
 	Value Expr
@@ -539,6 +717,7 @@ func (c *ConstDecl) String() string {
 
 // TypeAlias represents type alias
 type TypeAlias struct {
+	Span
 	Name string
 	Type Type
 }
@@ -550,6 +729,7 @@ func (t *TypeAlias) String() string {
 
 // StructDecl represents struct definition
 type StructDecl struct {
+	Span
 	Pub     bool
 	Name    string
 	TParams []string // Generic type parameters
@@ -583,6 +763,7 @@ func (s *StructDecl) String() string {
 
 // EnumDecl represents enum definition
 type EnumDecl struct {
+	Span
 	Pub      bool
 	Name     string
 	TParams  []string
@@ -592,6 +773,7 @@ type EnumDecl struct {
 type Variant struct {
 	Name  string
 	Types []Type // nil if no payload
+	Value Expr   // explicit discriminant, nil if none
 }
 
 func (e *EnumDecl) declNode() {}
@@ -611,6 +793,7 @@ func (e *EnumDecl) String() string {
 
 // TraitDecl represents trait definition
 type TraitDecl struct {
+	Span
 	Pub     bool
 	Name    string
 	TParams []string
@@ -640,6 +823,8 @@ func (t *TraitDecl) String() string {
 
 // ImplBlock represents impl block
 type ImplBlock struct {
+	Span
+	Attrs []*Attribute
 	Trait *TypePath // nil if inherent impl
 	For   Type
 	Fns   []*FuncDecl
@@ -656,7 +841,10 @@ func (i *ImplBlock) String() string {
 
 // FuncDecl represents function declaration
 type FuncDecl struct {
+	Span
+	Attrs      []*Attribute
 	Pub        bool
+	Extern     bool // extern fn: implemented outside yarlang, has no body
 	Name       string
 	TParams    []string
 	Params     []Param
@@ -665,9 +853,10 @@ type FuncDecl struct {
 }
 
 type Param struct {
-	Mut  bool
-	Name string
-	Type Type
+	Mut      bool
+	Name     string
+	Type     Type
+	Variadic bool // name ...T collects the remaining arguments into a []T
 }
 
 func (f *FuncDecl) declNode() {}
@@ -684,7 +873,12 @@ func (f *FuncDecl) String() string {
 			mut = "mut "
 		}
 
-		params[i] = fmt.Sprintf("%s%s %s", mut, p.Name, p.Type.String())
+		variadic := ""
+		if p.Variadic {
+			variadic = "..."
+		}
+
+		params[i] = fmt.Sprintf("%s%s %s%s", mut, p.Name, variadic, p.Type.String())
 	}
 
 	tparams := ""
@@ -697,6 +891,10 @@ func (f *FuncDecl) String() string {
 		ret = f.ReturnType.String()
 	}
 
+	if f.Extern {
+		pub += "extern "
+	}
+
 	return fmt.Sprintf("%sfn %s%s(%s) %s", pub, f.Name, tparams, strings.Join(params, ", "), ret)
 }
 
@@ -0,0 +1,246 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format prints a file as source that parses back to the same tree. Where
+// String abbreviates declarations, Format writes out every body, one
+// statement to a line.
+func Format(f *File) string {
+	pr := &printer{}
+
+	if len(f.Module) > 0 {
+		pr.line("module " + strings.Join(f.Module, "::"))
+	}
+
+	for _, item := range f.Items {
+		pr.decl(item)
+	}
+
+	return pr.out.String()
+}
+
+type printer struct {
+	out    strings.Builder
+	indent int
+}
+
+func (pr *printer) line(s string) {
+	pr.out.WriteString(strings.Repeat("\t", pr.indent))
+	pr.out.WriteString(s)
+	pr.out.WriteString("\n")
+}
+
+func (pr *printer) decl(d Decl) {
+	switch d := d.(type) {
+	case *FuncDecl:
+		pr.attrs(d.Attrs)
+
+		if d.Extern || d.Body == nil {
+			pr.line(signature(d))
+			return
+		}
+
+		pr.line(signature(d) + " " + pr.block(d.Body))
+	case *ImplBlock:
+		pr.attrs(d.Attrs)
+
+		head := "impl " + d.For.String()
+		if d.Trait != nil {
+			head = fmt.Sprintf("impl %s for %s", d.Trait.String(), d.For.String())
+		}
+
+		pr.line(head + " {")
+		pr.indent++
+
+		for _, fn := range d.Fns {
+			pr.decl(fn)
+		}
+
+		pr.indent--
+		pr.line("}")
+	case *UseDecl:
+		pr.attrs(d.Attrs)
+		pr.line(d.String())
+	case *EnumDecl:
+		variants := make([]string, len(d.Variants))
+		for i, v := range d.Variants {
+			variants[i] = v.Name
+
+			if len(v.Types) > 0 {
+				variants[i] += "(" + types(v.Types) + ")"
+			}
+
+			if v.Value != nil {
+				variants[i] += " = " + v.Value.String()
+			}
+		}
+
+		pr.line(fmt.Sprintf("%senum %s%s { %s }", pubPrefix(d.Pub), d.Name, tparams(d.TParams), strings.Join(variants, ", ")))
+	case *TraitDecl:
+		pr.line(fmt.Sprintf("%strait %s%s {", pubPrefix(d.Pub), d.Name, tparams(d.TParams)))
+		pr.indent++
+
+		for _, sig := range d.Sigs {
+			s := fmt.Sprintf("fn %s(%s)", sig.Name, params(sig.Params))
+			if sig.Return != nil {
+				s += " " + sig.Return.String()
+			}
+
+			pr.line(s + ";")
+		}
+
+		pr.indent--
+		pr.line("}")
+	default:
+		// Structs, constants and aliases print in full already
+		pr.line(d.String())
+	}
+}
+
+func (pr *printer) attrs(attrs []*Attribute) {
+	for _, a := range attrs {
+		pr.line(a.String())
+	}
+}
+
+// block returns b from its opening brace to its closing one, each statement
+// on a line of its own one level in
+func (pr *printer) block(b *Block) string {
+	if len(b.Stmts) == 0 {
+		return "{\n" + strings.Repeat("\t", pr.indent) + "}"
+	}
+
+	inner := &printer{indent: pr.indent + 1}
+	for _, s := range b.Stmts {
+		inner.stmt(s)
+	}
+
+	return "{\n" + inner.out.String() + strings.Repeat("\t", pr.indent) + "}"
+}
+
+func (pr *printer) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *Block:
+		pr.line(pr.block(s))
+	case *IfStmt:
+		pr.line(pr.ifStmt(s))
+	case *GuardStmt:
+		pr.line(fmt.Sprintf("guard %s else %s", s.Cond.String(), pr.block(s.Else)))
+	case *WhileStmt:
+		pr.line(fmt.Sprintf("while %s %s", cond(s.Cond), pr.block(s.Body)))
+	case *ForStmt:
+		vars := s.Val
+		if s.Key != "" {
+			vars = s.Key + ", " + s.Val
+		}
+
+		pr.line(fmt.Sprintf("for %s in %s %s", vars, cond(s.Iter), pr.block(s.Body)))
+	case *UnsafeBlock:
+		pr.line("unsafe " + pr.block(s.Body))
+	case *DeclStmt:
+		pr.decl(s.Decl)
+	default:
+		pr.line(s.String())
+	}
+}
+
+func (pr *printer) ifStmt(s *IfStmt) string {
+	out := fmt.Sprintf("if %s %s", cond(s.Cond), pr.block(s.Then))
+
+	switch e := s.Else.(type) {
+	case *Block:
+		out += " else " + pr.block(e)
+	case *IfStmt:
+		out += " else " + pr.ifStmt(e)
+	}
+
+	return out
+}
+
+// cond prints the condition before a block. A struct literal there has to
+// be in parentheses, or its brace would open the block.
+func cond(e Expr) string {
+	for base := e; ; {
+		switch b := base.(type) {
+		case *StructExpr:
+			return "(" + e.String() + ")"
+		case *FieldExpr:
+			base = b.Expr
+		case *CallExpr:
+			base = b.Callee
+		case *IndexExpr:
+			base = b.Expr
+		case *PropagateExpr:
+			base = b.Expr
+		default:
+			return e.String()
+		}
+	}
+}
+
+// signature prints a function up to its body
+func signature(f *FuncDecl) string {
+	s := fmt.Sprintf("fn %s%s(%s)", f.Name, tparams(f.TParams), params(f.Params))
+	if f.ReturnType != nil {
+		s += " " + f.ReturnType.String()
+	}
+
+	if f.Extern {
+		s = "extern " + s
+	}
+
+	return pubPrefix(f.Pub) + s
+}
+
+func params(ps []Param) string {
+	out := make([]string, len(ps))
+
+	for i, p := range ps {
+		if p.Mut {
+			out[i] = "mut "
+		}
+
+		// &self and &mut self carry their spelling as the name and no type
+		if p.Type == nil {
+			out[i] += p.Name
+			continue
+		}
+
+		variadic := ""
+		if p.Variadic {
+			variadic = "..."
+		}
+
+		out[i] += fmt.Sprintf("%s %s%s", p.Name, variadic, p.Type.String())
+	}
+
+	return strings.Join(out, ", ")
+}
+
+func types(ts []Type) string {
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = t.String()
+	}
+
+	return strings.Join(out, ", ")
+}
+
+func tparams(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	return "<" + strings.Join(names, ", ") + ">"
+}
+
+func pubPrefix(pub bool) string {
+	if pub {
+		return "pub "
+	}
+
+	return ""
+}
@@ -0,0 +1,106 @@
+package ast
+
+// Inspect traverses the AST rooted at node in depth-first order, calling f
+// for each node. If f returns false, the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	// Expressions
+	case *BinaryExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *UnaryExpr:
+		Inspect(n.Expr, f)
+	case *CallExpr:
+		Inspect(n.Callee, f)
+
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case *IndexExpr:
+		Inspect(n.Expr, f)
+		Inspect(n.Index, f)
+	case *FieldExpr:
+		Inspect(n.Expr, f)
+	case *PropagateExpr:
+		Inspect(n.Expr, f)
+	case *ConvExpr:
+		Inspect(n.Expr, f)
+	case *StructExpr:
+		for _, init := range n.Inits {
+			Inspect(init.Val, f)
+		}
+	case *ArrayExpr:
+		for _, e := range n.Elems {
+			Inspect(e, f)
+		}
+	case *TupleExpr:
+		for _, e := range n.Elems {
+			Inspect(e, f)
+		}
+
+	// Statements
+	case *LetStmt:
+		Inspect(n.Value, f)
+	case *LetTupleStmt:
+		Inspect(n.Value, f)
+	case *AssignStmt:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *ExprStmt:
+		Inspect(n.Expr, f)
+	case *ReturnStmt:
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+	case *IfStmt:
+		Inspect(n.Cond, f)
+		Inspect(n.Then, f)
+
+		if n.Else != nil {
+			Inspect(n.Else, f)
+		}
+	case *GuardStmt:
+		Inspect(n.Cond, f)
+		Inspect(n.Else, f)
+	case *WhileStmt:
+		Inspect(n.Cond, f)
+		Inspect(n.Body, f)
+	case *ForStmt:
+		Inspect(n.Iter, f)
+		Inspect(n.Body, f)
+	case *DeferStmt:
+		Inspect(n.Expr, f)
+	case *ShortDecl:
+		Inspect(n.Value, f)
+	case *ConstStmt:
+		Inspect(n.Value, f)
+	case *UnsafeBlock:
+		Inspect(n.Body, f)
+	case *DeclStmt:
+		Inspect(n.Decl, f)
+	case *Block:
+		for _, s := range n.Stmts {
+			Inspect(s, f)
+		}
+
+	// Declarations
+	case *FuncDecl:
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *ImplBlock:
+		for _, fn := range n.Fns {
+			Inspect(fn, f)
+		}
+	case *ConstDecl:
+		Inspect(n.Value, f)
+	case *File:
+		for _, item := range n.Items {
+			Inspect(item, f)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+// manyFunctions generates n functions that each call the one before, with
+// locals, borrows and a struct to check
+func manyFunctions(n int) string {
+	var b strings.Builder
+
+	b.WriteString("struct Acc { total: i64, count: i32 }\n\n")
+	b.WriteString("fn f0(a &mut Acc, n i32) i64 {\n\treturn a.total\n}\n\n")
+
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&b, "fn f%d(a &mut Acc, n i32) i64 {\n", i)
+		b.WriteString("\tlet mut x = n * 2\n")
+		b.WriteString("\tif x > 10 {\n\t\tx -= 1\n\t}\n")
+		b.WriteString("\ta.count += x\n")
+		b.WriteString("\ta.total += x as i64\n")
+		fmt.Fprintf(&b, "\treturn f%d(a, x) + 1\n", i-1)
+		b.WriteString("}\n\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkCheckManyFunctions(b *testing.B) {
+	p := parser.New(lexer.New(manyFunctions(2000)))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %v", p.Errors()[:1])
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := NewChecker().CheckFile(file); err != nil {
+			b.Fatalf("checker error: %v", err)
+		}
+	}
+}
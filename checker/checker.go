@@ -2,9 +2,13 @@ package checker
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/consteval"
+	"github.com/yarlson/yarlang/diag"
 	"github.com/yarlson/yarlang/types"
 )
 
@@ -16,98 +20,515 @@ const (
 	MutBorrow
 )
 
+// loan is an active borrow of a variable. A loan with a nil holder was
+// taken by a temporary and ends with the statement that created it; a held
+// loan lives until the holder's last use in the block that declared it.
+type loan struct {
+	target *types.Symbol
+	kind   BorrowState
+	holder *types.Symbol
+	block  *ast.Block // block the holder was declared in
+}
+
 // Checker performs semantic analysis
 type Checker struct {
-	env     *types.Env
-	errors  []string
-	moved   map[*types.Symbol]bool        // Track moved variables by symbol pointer (scope-aware)
-	borrows map[*types.Symbol]BorrowState // Track borrow state
+	env    *types.Env
+	errors []diag.Diagnostic
+	moved  map[*types.Symbol]bool  // Track moved variables by symbol pointer (scope-aware)
+	looped map[*types.Symbol]bool  // Variables reported as moved by an earlier iteration of a loop
+	loans  []*loan                 // Active borrows, expired as references die
+	blocks []*ast.Block            // Blocks being checked, innermost last
+	ret    types.Type              // Return type of the function being checked
+	consts map[*types.Symbol]int64 // Values of integer constants
+	unsafe int                     // Depth of unsafe blocks around the code being checked
+	types  map[ast.Expr]types.Type // Type of each checked expression, for lowering
+	made   []*ast.CallExpr         // Vec::new() and Map::new() calls in the function being checked
+	annots map[ast.Type]types.Type // Type of each annotation already resolved
+	at     ast.Span                // Node being checked, where diagnostics point
+
+	warnings    []diag.Diagnostic
+	used        map[*types.Symbol]bool // Locals that have been read
+	bindings    []binding              // Locals to warn about if never read
+	imports     map[string]*use        // Imported modules by the name they are used under
+	importOrder []string
+	levels      map[string]Level // Lint levels in effect for the item being checked
+	locale      string           // Language of the messages
 }
 
 func NewChecker() *Checker {
+	levels := make(map[string]Level, len(lints))
+	for name, level := range lints {
+		levels[name] = level
+	}
+
 	return &Checker{
-		env:     types.NewEnv(),
-		errors:  []string{},
-		moved:   make(map[*types.Symbol]bool),
-		borrows: make(map[*types.Symbol]BorrowState),
+		env:    types.NewEnv(),
+		moved:  make(map[*types.Symbol]bool),
+		looped: make(map[*types.Symbol]bool),
+		consts: make(map[*types.Symbol]int64),
+		types:  make(map[ast.Expr]types.Type),
+		annots: make(map[ast.Type]types.Type),
+
+		used:    make(map[*types.Symbol]bool),
+		imports: make(map[string]*use),
+		levels:  levels,
+		locale:  diag.DefaultLocale,
+	}
+}
+
+// SetLocale sets the language of the messages, as diag.Locale picks it.
+// Codes are the same in every language.
+func (c *Checker) SetLocale(locale string) {
+	c.locale = locale
+}
+
+// message renders the message of code in the checker's language
+func (c *Checker) message(code diag.Code, args ...any) string {
+	return diag.Message(c.locale, code, args...)
+}
+
+// error records msg as the error of code, with notes to show under it
+func (c *Checker) error(code diag.Code, msg string, notes ...string) {
+	c.errors = append(c.errors, diag.Diagnostic{
+		Severity: diag.SeverityError,
+		Code:     code,
+		Message:  msg,
+		Notes:    notes,
+		Line:     c.at.Line,
+		Column:   c.at.Column,
+		Length:   c.at.Length,
+	})
+}
+
+// enter makes the diagnostics reported until the returned function is
+// called point at node. A missing node, or one that was not parsed from
+// source, leaves them pointing at the node around it.
+func (c *Checker) enter(node interface{ Pos() *ast.Span }) func() {
+	outer := c.at
+	if node != nil && node.Pos().Line > 0 {
+		c.at = *node.Pos()
 	}
+
+	return func() { c.at = outer }
+}
+
+// errorf records the error of code with its message filled in from args
+func (c *Checker) errorf(code diag.Code, args ...any) {
+	c.error(code, c.message(code, args...))
 }
 
-func (c *Checker) error(msg string) {
-	c.errors = append(c.errors, msg)
+// Errors returns the errors of the last check
+func (c *Checker) Errors() []string {
+	out := make([]string, len(c.errors))
+	for i, d := range c.errors {
+		out[i] = fmt.Sprintf("%s: %s", d.Code, d.Summary())
+	}
+
+	return out
 }
 
 func (c *Checker) CheckFile(file *ast.File) error {
+	// Declare constants and enums without payloads first, in order, so that
+	// types can use them as array lengths. Then declare the other types, then
+	// every function and method signature, so that bodies can refer to items
+	// declared later in the file.
+	early := make(map[ast.Decl]bool)
+
+	c.checkRecursiveTypes(file.Items)
+
+	for _, decl := range file.Items {
+		leave := c.enter(decl)
+
+		switch d := decl.(type) {
+		case *ast.ConstDecl:
+			c.checkConst(d.Name, d.Type, &d.Value)
+			early[decl] = true
+		case *ast.EnumDecl:
+			if !hasPayload(d) {
+				c.checkEnumDecl(d)
+				early[decl] = true
+			}
+		}
+
+		leave()
+	}
+
+	for _, decl := range file.Items {
+		switch decl.(type) {
+		case *ast.FuncDecl, *ast.ImplBlock:
+		default:
+			if !early[decl] {
+				c.checkDecl(decl)
+			}
+		}
+	}
+
+	for _, decl := range file.Items {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			leave := c.enter(d)
+			c.env.Define(d.Name, c.funcType(d), false)
+			leave()
+		case *ast.ImplBlock:
+			leave := c.enter(d)
+			c.declareImpl(d)
+			leave()
+		}
+	}
+
 	// Check all declarations
 	for _, decl := range file.Items {
-		c.checkDecl(decl)
+		switch decl.(type) {
+		case *ast.FuncDecl, *ast.ImplBlock:
+			c.checkDecl(decl)
+		}
 	}
 
+	c.warnUnused()
+
 	if len(c.errors) > 0 {
-		return fmt.Errorf("type errors: %v", c.errors)
+		return fmt.Errorf("type errors: %v", c.Errors())
 	}
 
 	return nil
 }
 
 func (c *Checker) checkDecl(decl ast.Decl) {
+	defer c.enter(decl)()
+
 	switch d := decl.(type) {
 	case *ast.FuncDecl:
-		c.checkFuncDecl(d)
+		// An extern function has only the signature declared up front
+		if !d.Extern {
+			c.withLints(d.Attrs, func() { c.checkFuncDecl(d, nil) })
+		}
+
+		if d.Name == "main" {
+			c.checkMain(d)
+		}
+	case *ast.ImplBlock:
+		c.withLints(d.Attrs, func() { c.checkImplBlock(d) })
 	case *ast.StructDecl:
 		c.checkStructDecl(d)
 	case *ast.EnumDecl:
 		c.checkEnumDecl(d)
+	case *ast.TraitDecl:
+		c.checkTraitDecl(d)
+	case *ast.UseDecl:
+		c.withLints(d.Attrs, func() { c.checkUseDecl(d) })
 	// ... other decls
 	default:
-		c.error(fmt.Sprintf("unknown declaration type: %T", decl))
+		c.errorf(diag.UnknownDecl, decl)
 	}
 }
 
-func (c *Checker) checkFuncDecl(fn *ast.FuncDecl) {
-	// Build function type
+// funcType builds the signature of fn. A self parameter is not part of the
+// signature; it is described by the method's receiver instead.
+func (c *Checker) funcType(fn *ast.FuncDecl) *types.FuncType {
+	sig := c.signature(fn.Params, fn.ReturnType)
+	sig.Extern = fn.Extern
+
+	return sig
+}
+
+func (c *Checker) signature(params []ast.Param, ret ast.Type) *types.FuncType {
 	paramTypes := []types.Type{}
-	for _, param := range fn.Params {
-		paramTypes = append(paramTypes, c.resolveType(param.Type))
+	variadic := false
+
+	for _, param := range params {
+		if isSelfParam(param) {
+			continue
+		}
+
+		paramTypes = append(paramTypes, c.paramType(param))
+		variadic = param.Variadic
 	}
 
 	var returnType types.Type = &types.PrimitiveType{Name: "void", Kind: types.Void}
-	if fn.ReturnType != nil {
-		returnType = c.resolveType(fn.ReturnType)
+	if ret != nil {
+		returnType = c.resolveType(ret)
 	}
 
-	funcType := &types.FuncType{
-		Params: paramTypes,
-		Return: returnType,
+	return &types.FuncType{
+		Params:   paramTypes,
+		Return:   returnType,
+		Variadic: variadic,
 	}
+}
 
-	// Register function in environment
-	c.env.Define(fn.Name, funcType, false)
+// paramType is the type a parameter has inside the function body. A
+// variadic parameter ...T is a []T.
+func (c *Checker) paramType(param ast.Param) types.Type {
+	typ := c.resolveType(param.Type)
+	if param.Variadic {
+		return &types.SliceType{Elem: typ}
+	}
+
+	return typ
+}
+
+func isSelfParam(param ast.Param) bool {
+	return param.Name == "&self" || param.Name == "&mut self"
+}
+
+// receiverOf reports how a function with params takes self
+func receiverOf(params []ast.Param) types.Receiver {
+	for _, param := range params {
+		switch param.Name {
+		case "&self":
+			return types.RefReceiver
+		case "&mut self":
+			return types.MutRefReceiver
+		}
+	}
+
+	return types.NoReceiver
+}
+
+// declareImpl registers the methods of an impl block on its receiver type
+func (c *Checker) declareImpl(impl *ast.ImplBlock) {
+	recv := c.resolveType(impl.For)
+
+	if impl.Trait != nil {
+		c.env.DefineImpl(impl.Trait.Path[len(impl.Trait.Path)-1], recv)
+	}
+
+	// Self names the receiver type in method signatures
+	c.env.PushScope()
+	defer c.env.PopScope()
+
+	c.env.Define("Self", recv, false)
+
+	for _, fn := range impl.Fns {
+		leave := c.enter(fn)
+
+		m := &types.Method{Name: fn.Name, Receiver: receiverOf(fn.Params), Type: c.funcType(fn)}
+		if !c.env.DefineMethod(recv, m) {
+			c.errorf(diag.DuplicateMethod, fn.Name, recv.String())
+		}
+
+		leave()
+	}
+}
+
+func (c *Checker) checkImplBlock(impl *ast.ImplBlock) {
+	recv := c.resolveType(impl.For)
 
-	// Push new scope for function body
 	c.env.PushScope()
 	defer c.env.PopScope()
 
+	c.env.Define("Self", recv, false)
+
+	if impl.Trait != nil {
+		c.checkTraitImpl(impl, recv)
+	}
+
+	for _, fn := range impl.Fns {
+		c.checkFuncDecl(fn, recv)
+	}
+}
+
+// checkTraitImpl verifies that impl provides exactly the methods of its
+// trait, each with the trait's receiver, parameter and return types
+func (c *Checker) checkTraitImpl(impl *ast.ImplBlock, recv types.Type) {
+	name := impl.Trait.Path[len(impl.Trait.Path)-1]
+	where := fmt.Sprintf("impl %s for %s", impl.Trait.String(), recv.String())
+
+	typ, _, ok := c.env.Lookup(name)
+	if !ok {
+		c.errorf(diag.ImplUndefinedTrait, where, name)
+		return
+	}
+
+	trait, ok := typ.(*types.TraitType)
+	if !ok {
+		c.errorf(diag.ImplNotTrait, where, name)
+		return
+	}
+
+	provided := make(map[string]bool)
+
+	for _, fn := range impl.Fns {
+		leave := c.enter(fn)
+		provided[fn.Name] = true
+
+		want := trait.Method(fn.Name)
+		if want == nil {
+			c.errorf(diag.NotTraitMember, where, fn.Name, trait.Name)
+			leave()
+
+			continue
+		}
+
+		want = selfMethod(want, trait.Self, recv)
+
+		got := &types.Method{Name: fn.Name, Receiver: receiverOf(fn.Params), Type: c.funcType(fn)}
+		if !methodMatches(want, got) {
+			c.errorf(diag.TraitSignature, where, fn.Name, got.String(), want.String())
+		}
+
+		leave()
+	}
+
+	for _, m := range trait.Methods {
+		if !provided[m.Name] {
+			c.errorf(diag.MissingTraitMethod, where, m.String())
+		}
+	}
+}
+
+// selfMethod returns m with recv in place of self, the trait's Self
+func selfMethod(m *types.Method, self *types.TypeVar, recv types.Type) *types.Method {
+	typ := *m.Type
+	typ.Params = make([]types.Type, len(m.Type.Params))
+
+	for i, p := range m.Type.Params {
+		typ.Params[i] = substitute(p, self, recv)
+	}
+
+	typ.Return = substitute(m.Type.Return, self, recv)
+
+	return &types.Method{Name: m.Name, Receiver: m.Receiver, Type: &typ}
+}
+
+// substitute returns t with every occurrence of v replaced by with
+func substitute(t types.Type, v *types.TypeVar, with types.Type) types.Type {
+	switch t := t.(type) {
+	case *types.TypeVar:
+		if t == v {
+			return with
+		}
+	case *types.RefType:
+		return &types.RefType{Mut: t.Mut, Elem: substitute(t.Elem, v, with)}
+	case *types.PtrType:
+		return &types.PtrType{Elem: substitute(t.Elem, v, with)}
+	case *types.SliceType:
+		return &types.SliceType{Elem: substitute(t.Elem, v, with)}
+	case *types.ArrayType:
+		return &types.ArrayType{Elem: substitute(t.Elem, v, with), Len: t.Len}
+	case *types.OptionType:
+		return &types.OptionType{Elem: substitute(t.Elem, v, with)}
+	case *types.ResultType:
+		return &types.ResultType{Ok: substitute(t.Ok, v, with), Err: substitute(t.Err, v, with)}
+	case *types.VecType:
+		return &types.VecType{Elem: substitute(t.Elem, v, with)}
+	case *types.MapType:
+		return &types.MapType{Key: substitute(t.Key, v, with), Value: substitute(t.Value, v, with)}
+	}
+
+	return t
+}
+
+// methodMatches reports whether got implements want. Trait types that
+// mention a type parameter accept any type.
+func methodMatches(want, got *types.Method) bool {
+	if want.Receiver != got.Receiver || want.Type.Variadic != got.Type.Variadic ||
+		len(want.Type.Params) != len(got.Type.Params) {
+		return false
+	}
+
+	for i, p := range want.Type.Params {
+		if !containsTypeVar(p) && !types.TypesEqual(p, got.Type.Params[i]) {
+			return false
+		}
+	}
+
+	ret := want.Type.Return
+
+	return containsTypeVar(ret) || types.TypesEqual(ret, got.Type.Return)
+}
+
+// checkFuncDecl checks a function body. recv is the receiver type for
+// methods and nil for free functions.
+// checkMain checks that main has a signature the C entry point can call:
+// its result, if any, is the process's exit status
+func (c *Checker) checkMain(fn *ast.FuncDecl) {
+	sig := c.funcType(fn)
+
+	prim, ok := sig.Return.(*types.PrimitiveType)
+	if len(sig.Params) > 0 || !ok || (prim.Kind != types.Void && prim.Kind != types.Int32) {
+		c.errorf(diag.MainSignature, sig.String())
+	}
+}
+
+func (c *Checker) checkFuncDecl(fn *ast.FuncDecl, recv types.Type) {
+	defer c.enter(fn)()
+
+	// Push new scope for function body
+	c.env.PushFuncScope()
+	defer c.env.PopScope()
+
+	c.loans = nil
+	c.ret = c.funcType(fn).Return
+
+	outer := c.made
+	c.made = nil
+
+	defer func() { c.made = outer }()
+
 	// Add parameters to scope
-	for _, param := range fn.Params {
-		typ := c.resolveType(param.Type)
-		c.env.Define(param.Name, typ, param.Mut)
+	for i, param := range fn.Params {
+		if isSelfParam(param) {
+			if recv == nil {
+				c.errorf(diag.SelfOutsideImpl, fn.Name)
+				continue
+			}
+
+			c.env.Define("self", &types.RefType{Mut: param.Name == "&mut self", Elem: recv}, false)
+
+			continue
+		}
+
+		if param.Variadic && i != len(fn.Params)-1 {
+			c.errorf(diag.VariadicNotLast, param.Name, fn.Name)
+		}
+
+		c.bind(param.Name, c.paramType(param), param.Mut, c.message(diag.DescParam, param.Name, fn.Name))
 	}
 
 	// Check body
 	c.checkBlock(fn.Body)
+	c.checkCollectionElems()
+
+	if prim, ok := c.ret.(*types.PrimitiveType); !(ok && prim.Kind == types.Void) && !terminates(fn.Body) {
+		c.errorf(diag.MissingReturn, fn.Name)
+	}
 }
 
 func (c *Checker) checkBlock(block *ast.Block) {
-	for _, stmt := range block.Stmts {
+	c.env.PushScope()
+	defer c.env.PopScope()
+
+	c.blocks = append(c.blocks, block)
+	defer func() { c.blocks = c.blocks[:len(c.blocks)-1] }()
+
+	dead := false
+
+	for i, stmt := range block.Stmts {
 		c.checkStmt(stmt)
+		c.expireLoans(block, block.Stmts[i+1:])
+
+		if !dead && i+1 < len(block.Stmts) && terminates(stmt) {
+			leave := c.enter(block.Stmts[i+1])
+			c.lint(c.levels["dead_code"], "dead_code",
+				diag.UnreachableCode, summary(stmt))
+			leave()
+
+			dead = true
+		}
 	}
 }
 
 func (c *Checker) checkStmt(stmt ast.Stmt) types.Type {
+	defer c.enter(stmt)()
+
 	switch s := stmt.(type) {
 	case *ast.LetStmt:
 		return c.checkLetStmt(s)
+	case *ast.LetTupleStmt:
+		return c.checkLetTupleStmt(s)
+	case *ast.ShortDecl:
+		return c.checkShortDecl(s)
 	case *ast.AssignStmt:
 		return c.checkAssignStmt(s)
 	case *ast.ReturnStmt:
@@ -116,44 +537,131 @@ func (c *Checker) checkStmt(stmt ast.Stmt) types.Type {
 		return c.checkExpr(s.Expr)
 	case *ast.IfStmt:
 		return c.checkIfStmt(s)
+	case *ast.GuardStmt:
+		return c.checkGuardStmt(s)
+	case *ast.WhileStmt:
+		return c.checkWhileStmt(s)
+	case *ast.ForStmt:
+		return c.checkForStmt(s)
+	case *ast.BreakStmt, *ast.ContinueStmt:
+		return nil
+	case *ast.DeferStmt:
+		c.checkDeferStmt(s)
+		return nil
+	case *ast.DeclStmt:
+		c.checkDeclStmt(s)
+		return nil
+	case *ast.ConstStmt:
+		c.checkConst(s.Name, s.Type, &s.Value)
+		return nil
+	case *ast.Block:
+		c.checkBlock(s)
+		return nil
+	case *ast.UnsafeBlock:
+		c.unsafe++
+		c.checkBlock(s.Body)
+		c.unsafe--
+
+		return nil
 	// ... other stmts
 	default:
-		c.error(fmt.Sprintf("unknown statement type: %T", stmt))
+		c.errorf(diag.UnknownStmt, stmt)
 		return nil
 	}
 }
 
+// checkDeclStmt checks a fn or struct declared in a block. A nested function
+// is defined before its body is checked so that it can recurse; it may read
+// the enclosing function's locals but not assign to them.
+func (c *Checker) checkDeclStmt(stmt *ast.DeclStmt) {
+	switch d := stmt.Decl.(type) {
+	case *ast.FuncDecl:
+		c.env.Define(d.Name, c.funcType(d), false)
+
+		// A function declared in an unsafe block is not itself unsafe
+		ret, loans, unsafe := c.ret, c.loans, c.unsafe
+		c.unsafe = 0
+		c.checkFuncDecl(d, nil)
+		c.ret, c.loans, c.unsafe = ret, loans, unsafe
+	case *ast.StructDecl:
+		c.checkStructDecl(d)
+	default:
+		c.errorf(diag.UnsupportedLocalDecl, stmt.Decl)
+	}
+}
+
 func (c *Checker) checkLetStmt(let *ast.LetStmt) types.Type {
 	// Check value expression
+	firstLoan := len(c.loans)
 	valueType := c.checkExpr(let.Value)
 
 	// If value is an identifier of Move type, mark it as moved
-	if ident, ok := let.Value.(*ast.Ident); ok {
-		if !types.IsCopy(valueType) {
-			// Look up the symbol and mark it as moved
-			sym, ok := c.env.LookupSymbol(ident.Name)
-			if ok {
-				c.moved[sym] = true
-			}
-		}
-	}
+	c.moveIfOwned(let.Value, valueType)
 
 	// If type annotation present, check compatibility
+	finalType := valueType
 	if let.Type != nil {
-		declaredType := c.resolveType(let.Type)
-		if !types.TypesEqual(valueType, declaredType) {
-			c.error(fmt.Sprintf("type mismatch: expected %s, got %s",
-				declaredType.String(), valueType.String()))
+		finalType = c.resolveType(let.Type)
+		if !c.coerce(&let.Value, valueType, finalType) {
+			c.errorf(diag.TypeMismatch, finalType.String(), valueType.String())
 		}
+	} else if v, err := consteval.Eval(let.Value, noConsts); err == nil && !consteval.Fits(v, valueType.String()) {
+		// Literals default to i32 when nothing else gives them a type
+		c.errorf(diag.ConstantOverflow, v, valueType.String())
 	}
 
-	// Define variable
-	finalType := valueType
-	if let.Type != nil {
-		finalType = c.resolveType(let.Type)
+	c.bind(let.Name, finalType, let.Mut, c.message(diag.DescVariable, let.Name))
+
+	// Borrows taken by the initializer, or copied out of another reference,
+	// are now held by the new binding
+	if sym, ok := c.env.LookupSymbol(let.Name); ok {
+		c.holdLoans(sym, let.Value, firstLoan, c.currentBlock())
+	}
+
+	return nil
+}
+
+// checkShortDecl checks x := value, which declares an immutable variable
+// like let but may not redeclare a name of the same scope
+func (c *Checker) checkShortDecl(decl *ast.ShortDecl) types.Type {
+	if c.env.DeclaredHere(decl.Name) {
+		c.errorf(diag.AlreadyDeclared, decl.Name)
+	}
+
+	let := &ast.LetStmt{Name: decl.Name, Value: decl.Value}
+	c.checkLetStmt(let)
+	decl.Value = let.Value
+
+	return nil
+}
+
+func (c *Checker) checkLetTupleStmt(let *ast.LetTupleStmt) types.Type {
+	valueType := c.checkExpr(let.Value)
+	c.moveIfOwned(let.Value, valueType)
+
+	tuple, ok := valueType.(*types.TupleType)
+	if !ok {
+		c.errorf(diag.CannotDestructure, valueType.String(), len(let.Names))
+
+		for _, name := range let.Names {
+			c.bind(name, c.env.NewTypeVar(), false, c.message(diag.DescVariable, name))
+		}
+
+		return nil
+	}
+
+	if len(tuple.Elems) != len(let.Names) {
+		c.errorf(diag.CannotDestructure, tuple.String(), len(let.Names))
 	}
 
-	c.env.Define(let.Name, finalType, let.Mut)
+	for i, name := range let.Names {
+		var typ types.Type = c.env.NewTypeVar()
+		if i < len(tuple.Elems) {
+			typ = tuple.Elems[i]
+		}
+
+		c.bind(name, typ, false, c.message(diag.DescVariable, name))
+	}
 
 	return nil
 }
@@ -163,247 +671,1426 @@ func (c *Checker) checkAssignStmt(assign *ast.AssignStmt) types.Type {
 	if ident, ok := assign.Target.(*ast.Ident); ok {
 		typ, mut, ok := c.env.Lookup(ident.Name)
 		if !ok {
-			c.error(fmt.Sprintf("undefined variable: %s", ident.Name))
+			c.errorf(diag.UndefinedVariable, ident.Name)
 			return nil
 		}
 
 		if !mut {
-			c.error(fmt.Sprintf("cannot assign to immutable variable: %s", ident.Name))
+			c.errorf(diag.AssignImmutable, ident.Name)
+		} else if c.env.Captured(ident.Name) {
+			c.errorf(diag.AssignCaptured, ident.Name)
 		}
 
 		// Check value type matches
+		firstLoan := len(c.loans)
+		valueType := c.checkExpr(assign.Value)
+		c.moveIfOwned(assign.Value, valueType)
+
+		if sym, ok := c.env.LookupSymbol(ident.Name); ok {
+			c.holdLoans(sym, assign.Value, firstLoan, nil)
+
+			// Assigning a fresh value makes a moved-from variable usable again
+			if assign.Op == "=" {
+				delete(c.moved, sym)
+			}
+		}
+
+		if !c.coerce(&assign.Value, valueType, typ) {
+			c.errorf(diag.TypeMismatch, typ.String(), valueType.String())
+		}
+
+		c.checkAssignOp(assign.Op, typ)
+	}
+
+	if field, ok := assign.Target.(*ast.FieldExpr); ok {
+		typ := c.checkFieldExpr(field)
+		c.checkFieldAssign(field)
+		valueType := c.checkExpr(assign.Value)
+		c.moveIfOwned(assign.Value, valueType)
+
+		if !containsTypeVar(typ) && !c.coerce(&assign.Value, valueType, typ) {
+			c.errorf(diag.TypeMismatch, typ.String(), valueType.String())
+		}
+
+		c.checkAssignOp(assign.Op, typ)
+	}
+
+	// Writing through *r reads r, which must be a mutable reference
+	if deref, ok := assign.Target.(*ast.UnaryExpr); ok && deref.Op == "*" {
+		typ := c.checkExpr(deref)
+
+		if ref, ok := c.types[deref.Expr].(*types.RefType); ok && !ref.Mut {
+			c.errorf(diag.AssignThroughShared, "*"+deref.Expr.String(), deref.Expr.String())
+		}
+
+		valueType := c.checkExpr(assign.Value)
+		c.moveIfOwned(assign.Value, valueType)
+
+		if !containsTypeVar(typ) && !c.coerce(&assign.Value, valueType, typ) {
+			c.errorf(diag.TypeMismatch, typ.String(), valueType.String())
+		}
+
+		c.checkAssignOp(assign.Op, typ)
+	}
+
+	if index, ok := assign.Target.(*ast.IndexExpr); ok {
+		typ := c.checkIndexExpr(index)
 		valueType := c.checkExpr(assign.Value)
-		if !types.TypesEqual(typ, valueType) {
-			c.error(fmt.Sprintf("type mismatch: expected %s, got %s",
-				typ.String(), valueType.String()))
+		c.moveIfOwned(assign.Value, valueType)
+
+		if !containsTypeVar(typ) && !c.coerce(&assign.Value, valueType, typ) {
+			c.errorf(diag.TypeMismatch, typ.String(), valueType.String())
+		}
+
+		c.checkAssignOp(assign.Op, typ)
+	}
+
+	return nil
+}
+
+// checkAssignOp checks that the operator of a compound assignment applies
+// to the type of its target. A str can only be extended with +=.
+func (c *Checker) checkAssignOp(op string, typ types.Type) {
+	if op != "=" && op != "+=" && types.IsString(typ) {
+		c.errorf(diag.StrOperator, strings.TrimSuffix(op, "="))
+	}
+}
+
+// checkIndexExpr resolves an element of an array, a slice or a str,
+// looking through references
+func (c *Checker) checkIndexExpr(index *ast.IndexExpr) types.Type {
+	objType := c.checkExpr(index.Expr)
+	if ref, ok := objType.(*types.RefType); ok {
+		objType = ref.Elem
+	}
+
+	indexType := c.checkExpr(index.Index)
+	if !types.IsInteger(indexType) && !containsTypeVar(indexType) {
+		c.errorf(diag.IndexNotInteger, indexType.String())
+	}
+
+	switch t := objType.(type) {
+	case *types.ArrayType:
+		c.checkConstIndex(index, t.Len)
+		return t.Elem
+	case *types.SliceType:
+		c.checkConstIndex(index, -1)
+		return t.Elem
+	default:
+		if types.IsString(objType) {
+			c.checkConstIndex(index, -1)
+			return &types.PrimitiveType{Name: "u8", Kind: types.UInt8}
+		}
+
+		if !containsTypeVar(objType) {
+			c.errorf(diag.CannotIndex, objType.String())
+		}
+
+		return c.env.NewTypeVar()
+	}
+}
+
+// checkFieldExpr resolves a field of a struct value, looking through
+// references
+func (c *Checker) checkFieldExpr(field *ast.FieldExpr) types.Type {
+	objType := c.checkExpr(field.Expr)
+	if ref, ok := objType.(*types.RefType); ok {
+		objType = ref.Elem
+	}
+
+	switch t := objType.(type) {
+	case *types.StructType:
+		if typ, ok := t.Fields[field.Field]; ok {
+			return typ
+		}
+
+		names := make([]string, 0, len(t.Fields))
+		for name := range t.Fields {
+			names = append(names, name)
+		}
+
+		var notes []string
+		if guess := suggest(field.Field, names); guess != "" {
+			notes = append(notes, c.message(diag.DidYouMean, guess))
 		}
+
+		c.error(diag.NoField, c.message(diag.NoField, field.Field, t.Name), notes...)
+	case *types.TupleType:
+		if i, err := strconv.Atoi(field.Field); err == nil && i < len(t.Elems) {
+			return t.Elems[i]
+		}
+
+		c.errorf(diag.NoTupleElement, field.Field, t.String())
+	case *types.TypeVar:
+		// Generic values are not checked until instantiation
+	default:
+		c.errorf(diag.TypeHasNoField, objType.String(), field.Field)
+	}
+
+	return c.env.NewTypeVar()
+}
+
+// checkFieldAssign checks that the variable a field assignment writes
+// through is mutable and not borrowed. Fields, array elements and
+// dereferences lead back to it, unless a reference or a slice on the way
+// decides where the write goes. It runs after the target is checked, so
+// the type of each part of the target is known.
+func (c *Checker) checkFieldAssign(field *ast.FieldExpr) {
+	base := field.Expr
+	for {
+		switch t := c.types[base].(type) {
+		case *types.RefType:
+			if !t.Mut {
+				c.errorf(diag.AssignThroughShared, field.String(), base.String())
+			}
+
+			return
+		case *types.PtrType, *types.SliceType:
+			return
+		}
+
+		switch e := base.(type) {
+		case *ast.FieldExpr:
+			base = e.Expr
+			continue
+		case *ast.IndexExpr:
+			base = e.Expr
+			continue
+		case *ast.UnaryExpr:
+			if e.Op == "*" {
+				base = e.Expr
+				continue
+			}
+		}
+
+		break
+	}
+
+	ident, ok := base.(*ast.Ident)
+	if !ok {
+		return
+	}
+
+	sym, ok := c.env.LookupSymbol(ident.Name)
+	if !ok {
+		return
+	}
+
+	if !sym.Mut {
+		c.errorf(diag.AssignFieldImmutable, field.String())
+	}
+
+	if c.borrowState(sym) != NotBorrowed {
+		c.errorf(diag.AssignBorrowed, field.String(), ident.Name)
+	}
+}
+
+func (c *Checker) checkReturnStmt(ret *ast.ReturnStmt) types.Type {
+	if ret.Value != nil {
+		valueType := c.checkExpr(ret.Value)
+
+		if c.ret != nil && !containsTypeVar(valueType) && !c.coerce(&ret.Value, valueType, c.ret) {
+			c.errorf(diag.ReturnMismatch, c.ret.String(), valueType.String())
+		}
+
+		return valueType
+	}
+
+	return &types.PrimitiveType{Name: "void", Kind: types.Void}
+}
+
+// checkDeferStmt checks that a call is deferred. Its arguments are checked
+// where the defer appears, since that is where they are evaluated.
+func (c *Checker) checkDeferStmt(stmt *ast.DeferStmt) {
+	if _, ok := stmt.Expr.(*ast.CallExpr); !ok {
+		c.errorf(diag.DeferNotCall, stmt.Expr.String())
+	}
+
+	c.checkExpr(stmt.Expr)
+}
+
+func (c *Checker) checkIfStmt(ifStmt *ast.IfStmt) types.Type {
+	// Check condition is bool
+	condType := c.checkExpr(ifStmt.Cond)
+
+	boolType := &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	if !types.TypesEqual(condType, boolType) {
+		c.errorf(diag.IfCondition, condType.String())
+	}
+
+	// Each branch starts from the moves made before the if; afterwards a
+	// value is moved if any branch that falls through moved it
+	before := c.moved
+
+	c.moved = copyMoves(before)
+	c.checkBlock(ifStmt.Then)
+	thenMoved, thenDiverges := c.moved, terminates(ifStmt.Then)
+
+	elseMoved, elseDiverges := before, false
+
+	// Check else block if present
+	if ifStmt.Else != nil {
+		c.moved = copyMoves(before)
+		c.checkStmt(ifStmt.Else)
+		elseMoved, elseDiverges = c.moved, terminates(ifStmt.Else)
+	}
+
+	c.moved = copyMoves(before)
+
+	if !thenDiverges {
+		for sym := range thenMoved {
+			c.moved[sym] = true
+		}
+	}
+
+	if !elseDiverges {
+		for sym := range elseMoved {
+			c.moved[sym] = true
+		}
+	}
+
+	// Reassignment in every fallthrough branch restores the value
+	for sym := range before {
+		if (thenDiverges || !thenMoved[sym]) && (elseDiverges || !elseMoved[sym]) {
+			delete(c.moved, sym)
+		}
+	}
+
+	return nil
+}
+
+// checkGuardStmt checks guard cond else { ... }. Control only continues past
+// the guard when cond holds, so the else block must leave the enclosing
+// function or loop, and its moves do not affect the code after it.
+func (c *Checker) checkGuardStmt(guard *ast.GuardStmt) types.Type {
+	condType := c.checkExpr(guard.Cond)
+
+	boolType := &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	if !types.TypesEqual(condType, boolType) {
+		c.errorf(diag.GuardCondition, condType.String())
+	}
+
+	before := c.moved
+	c.moved = copyMoves(before)
+	c.checkBlock(guard.Else)
+	c.moved = before
+
+	if !terminates(guard.Else) {
+		c.errorf(diag.GuardElse)
+	}
+
+	return nil
+}
+
+func (c *Checker) checkWhileStmt(while *ast.WhileStmt) types.Type {
+	condType := c.checkExpr(while.Cond)
+
+	boolType := &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	if !types.TypesEqual(condType, boolType) {
+		c.errorf(diag.WhileCondition, condType.String())
+	}
+
+	before := copyMoves(c.moved)
+	c.checkBlock(while.Body)
+	c.checkLoopMoves(while.Body, before)
+
+	return nil
+}
+
+// checkForStmt checks a loop over a range a..b, over the elements of an
+// array or slice, or over the entries of a Map, whose key and value are the
+// loop's key and value. The loop variables are scoped to the loop.
+func (c *Checker) checkForStmt(loop *ast.ForStmt) types.Type {
+	var elem types.Type
+
+	var key types.Type = &types.PrimitiveType{Name: "usize", Kind: types.USize}
+
+	if rng, ok := loop.Iter.(*ast.BinaryExpr); ok && rng.Op == ".." {
+		elem = c.checkBinaryExpr(rng)
+		if !types.IsInteger(elem) {
+			c.errorf(diag.RangeBounds, elem.String())
+		}
+	} else {
+		iterType := c.checkExpr(loop.Iter)
+
+		switch t := iterType.(type) {
+		case *types.ArrayType:
+			elem = t.Elem
+		case *types.SliceType:
+			elem = t.Elem
+		case *types.MapType:
+			key, elem = t.Key, t.Value
+		default:
+			c.errorf(diag.CannotIterate, iterType.String())
+			elem = c.env.NewTypeVar()
+		}
+	}
+
+	c.env.PushScope()
+	defer c.env.PopScope()
+
+	if loop.Key != "" {
+		c.env.Define(loop.Key, key, false)
+	}
+
+	c.env.Define(loop.Val, elem, false)
+
+	before := copyMoves(c.moved)
+	c.checkBlock(loop.Body)
+	c.checkLoopMoves(loop.Body, before)
+
+	return nil
+}
+
+// checkLoopMoves reports each variable from outside a loop that the loop's
+// body moves, given the moves made before it, and leaves moved when it
+// falls through. The next iteration would use it after the move, unless it
+// assigns the variable before anything else reads it.
+func (c *Checker) checkLoopMoves(body *ast.Block, before map[*types.Symbol]bool) {
+	if n := len(body.Stmts); n > 0 {
+		switch body.Stmts[n-1].(type) {
+		case *ast.BreakStmt, *ast.ReturnStmt:
+			return
+		}
+	}
+
+	var names []string
+
+	for sym := range c.moved {
+		if before[sym] || c.looped[sym] {
+			continue
+		}
+
+		// Variables declared in the body are fresh on each iteration
+		if outer, ok := c.env.LookupSymbol(sym.Name); !ok || outer != sym {
+			continue
+		}
+
+		if assignsFirst(body.Stmts, sym.Name) {
+			continue
+		}
+
+		c.looped[sym] = true
+		names = append(names, sym.Name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		c.errorf(diag.MovedInLoop, name)
+	}
+}
+
+// assignsFirst reports whether the first of stmts to mention name assigns
+// it a value that does not mention name itself
+func assignsFirst(stmts []ast.Stmt, name string) bool {
+	for _, stmt := range stmts {
+		if !mentions(stmt, name) {
+			continue
+		}
+
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || assign.Op != "=" {
+			return false
+		}
+
+		target, ok := assign.Target.(*ast.Ident)
+
+		return ok && target.Name == name && !mentions(assign.Value, name)
+	}
+
+	return false
+}
+
+func copyMoves(moved map[*types.Symbol]bool) map[*types.Symbol]bool {
+	out := make(map[*types.Symbol]bool, len(moved))
+	for sym := range moved {
+		out[sym] = true
+	}
+
+	return out
+}
+
+// moveIfOwned marks a variable as moved when it is used by value and its
+// type is not Copy
+func (c *Checker) moveIfOwned(value ast.Expr, valueType types.Type) {
+	ident, ok := value.(*ast.Ident)
+	if !ok || valueType == nil || types.IsCopy(valueType) {
+		return
+	}
+
+	if sym, ok := c.env.LookupSymbol(ident.Name); ok {
+		c.moved[sym] = true
+	}
+}
+
+// terminates reports whether control never falls through the end of stmt:
+// every path through it returns, breaks, continues, panics or loops
+// forever. Missing returns, guard blocks, moves in if branches and dead
+// code are all judged by it.
+func terminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.BreakStmt, *ast.ContinueStmt:
+		return true
+	case *ast.Block:
+		for _, st := range s.Stmts {
+			if terminates(st) {
+				return true
+			}
+		}
+
+		return false
+	case *ast.UnsafeBlock:
+		return terminates(s.Body)
+	case *ast.IfStmt:
+		return s.Else != nil && terminates(s.Then) && terminates(s.Else)
+	case *ast.WhileStmt:
+		// while true only exits through a break
+		lit, ok := s.Cond.(*ast.BoolLit)
+		return ok && lit.Value && !breaks(s.Body)
+	case *ast.ExprStmt:
+		call, ok := s.Expr.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+
+		ident, ok := call.Callee.(*ast.Ident)
+
+		return ok && ident.Name == "panic"
+	default:
+		return false
+	}
+}
+
+// breaks reports whether body contains a break that leaves the enclosing
+// loop; breaks inside nested loops belong to those loops
+func breaks(body *ast.Block) bool {
+	found := false
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BreakStmt:
+			found = true
+		case *ast.WhileStmt, *ast.ForStmt:
+			return false
+		}
+
+		return !found
+	})
+
+	return found
+}
+
+// Types gives the type the checker found for each expression, so that
+// lowering can use it instead of guessing from the syntax
+func (c *Checker) Types() map[ast.Expr]types.Type {
+	return c.types
+}
+
+func (c *Checker) checkExpr(expr ast.Expr) types.Type {
+	defer c.enter(expr)()
+
+	typ := c.inferExpr(expr)
+	c.types[expr] = typ
+
+	return typ
+}
+
+func (c *Checker) inferExpr(expr ast.Expr) types.Type {
+	switch e := expr.(type) {
+	case *ast.IntLit:
+		return &types.PrimitiveType{Name: "i32", Kind: types.Int32}
+	case *ast.ConvExpr:
+		return c.resolveType(e.To)
+	case *ast.CastExpr:
+		return c.checkCastExpr(e)
+	case *ast.FloatLit:
+		return &types.PrimitiveType{Name: "f64", Kind: types.Float64}
+	case *ast.BoolLit:
+		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	case *ast.StringLit:
+		return &types.StringType{}
+	case *ast.NilLit:
+		// nil can be any pointer type, return a type var for now
+		return c.env.NewTypeVar()
+	case *ast.Ident:
+		c.read(e.Name)
+
+		// Look up the symbol
+		sym, ok := c.env.LookupSymbol(e.Name)
+		if !ok && e.Name == "None" && c.prelude("Option") {
+			return &types.OptionType{Elem: c.env.NewTypeVar()}
+		}
+
+		if !ok {
+			c.errorf(diag.UndefinedVariable, e.Name)
+			return c.env.NewTypeVar()
+		}
+
+		// Check if moved
+		if c.moved[sym] {
+			c.errorf(diag.UseAfterMove, e.Name)
+			return c.env.NewTypeVar()
+		}
+
+		// If type is not Copy and not borrowed, mark as moved
+		// (simplified: only track in assignments)
+		return sym.Type
+	case *ast.BinaryExpr:
+		return c.checkBinaryExpr(e)
+	case *ast.UnaryExpr:
+		return c.checkUnaryExpr(e)
+	case *ast.CallExpr:
+		return c.checkCallExpr(e)
+	case *ast.StructExpr:
+		return c.checkStructExpr(e)
+	case *ast.FieldExpr:
+		return c.checkFieldExpr(e)
+	case *ast.IndexExpr:
+		return c.checkIndexExpr(e)
+	case *ast.PathExpr:
+		if typ, ok := c.checkPreludePath(e, nil); ok {
+			return typ
+		}
+
+		return c.checkPathExpr(e, nil)
+	case *ast.PropagateExpr:
+		return c.checkPropagateExpr(e)
+	case *ast.TupleExpr:
+		elems := make([]types.Type, len(e.Elems))
+		for i, elem := range e.Elems {
+			elems[i] = c.checkExpr(elem)
+			c.moveIfOwned(elem, elems[i])
+		}
+
+		return &types.TupleType{Elems: elems}
+	case *ast.ArrayExpr:
+		return c.checkArrayExpr(e)
+	// ... other exprs
+	default:
+		c.errorf(diag.UnknownExpr, expr)
+		return c.env.NewTypeVar()
+	}
+}
+
+// checkArrayExpr types an array literal by its first element, which the
+// others must convert to. An empty literal takes its element type from
+// where it is used.
+func (c *Checker) checkArrayExpr(arr *ast.ArrayExpr) types.Type {
+	if len(arr.Elems) == 0 {
+		return &types.ArrayType{Elem: c.env.NewTypeVar()}
+	}
+
+	elem := c.checkExpr(arr.Elems[0])
+	c.moveIfOwned(arr.Elems[0], elem)
+
+	for i := 1; i < len(arr.Elems); i++ {
+		typ := c.checkExpr(arr.Elems[i])
+		c.moveIfOwned(arr.Elems[i], typ)
+
+		if !containsTypeVar(elem) && !c.coerce(&arr.Elems[i], typ, elem) {
+			c.errorf(diag.TypeMismatch, elem.String(), typ.String())
+		}
+	}
+
+	return &types.ArrayType{Elem: elem, Len: len(arr.Elems)}
+}
+
+func (c *Checker) checkBinaryExpr(bin *ast.BinaryExpr) types.Type {
+	leftType := c.checkExpr(bin.Left)
+	rightType := c.checkExpr(bin.Right)
+
+	// Offsetting a raw pointer by an integer yields a pointer of the same type
+	if _, ok := leftType.(*types.PtrType); ok && (bin.Op == "+" || bin.Op == "-") && types.IsInteger(rightType) {
+		c.requireUnsafe("pointer arithmetic")
+		return leftType
+	}
+
+	// Mixed numeric operands meet at the wider type when one widens to the
+	// other without loss; anything else needs an explicit cast. A literal
+	// constant takes the type of the other operand first, so x + 1 keeps
+	// the type of x.
+	if types.IsNumeric(leftType) && types.IsNumeric(rightType) && !types.TypesEqual(leftType, rightType) {
+		switch {
+		case isConstant(bin.Right) && c.coerce(&bin.Right, rightType, leftType):
+			rightType = leftType
+		case isConstant(bin.Left) && c.coerce(&bin.Left, leftType, rightType):
+			leftType = rightType
+		case c.coerce(&bin.Left, leftType, rightType):
+			leftType = rightType
+		case c.coerce(&bin.Right, rightType, leftType):
+			rightType = leftType
+		default:
+			c.errorf(diag.MixedOperands, opVerb(bin.Op), leftType.String(), rightType.String())
+			rightType = leftType
+		}
+	}
+
+	// Check types match
+	if !types.TypesEqual(leftType, rightType) {
+		c.errorf(diag.BinaryMismatch, leftType.String(), rightType.String())
+	}
+
+	// Strings can only be joined and compared
+	if types.IsString(leftType) && bin.Op != "+" && !isComparison(bin.Op) {
+		c.errorf(diag.StrOperator, bin.Op)
+	}
+
+	// Arithmetic operators return same type
+	if bin.Op == "+" || bin.Op == "-" || bin.Op == "*" || bin.Op == "/" || bin.Op == "%" {
+		if types.IsInteger(leftType) {
+			c.checkConstArith(bin, leftType)
+		}
+
+		return leftType
+	}
+
+	// Comparison operators return bool
+	if isComparison(bin.Op) {
+		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	}
+
+	// Logical operators return bool
+	if bin.Op == "&&" || bin.Op == "||" {
+		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	}
+
+	return leftType
+}
+
+// opVerb names what a binary operator does, for diagnostics
+func opVerb(op string) string {
+	switch op {
+	case "+":
+		return "add"
+	case "-":
+		return "subtract"
+	case "*":
+		return "multiply"
+	case "/", "%":
+		return "divide"
+	case "==", "!=", "<", ">", "<=", ">=":
+		return "compare"
+	default:
+		return "apply " + op + " to"
+	}
+}
+
+// isComparison reports whether op compares its operands
+func isComparison(op string) bool {
+	return opVerb(op) == "compare"
+}
+
+// checkCastExpr checks x as T, recording the type of x for lowering
+func (c *Checker) checkCastExpr(cast *ast.CastExpr) types.Type {
+	from := c.checkExpr(cast.Expr)
+	to := c.resolveType(cast.Type)
+
+	if !types.Casts(from, to) {
+		c.errorf(diag.InvalidCast, from.String(), to.String())
+		return to
+	}
+
+	cast.From = typeExpr(from)
+
+	return to
+}
+
+func (c *Checker) checkUnaryExpr(un *ast.UnaryExpr) types.Type {
+	exprType := c.checkExpr(un.Expr)
+
+	if un.Op == "&" {
+		// Shared borrow
+		if ident, ok := un.Expr.(*ast.Ident); ok {
+			// Look up the symbol
+			sym, ok := c.env.LookupSymbol(ident.Name)
+			if ok {
+				// Check not exclusively borrowed
+				if c.borrowState(sym) == MutBorrow {
+					c.errorf(diag.SharedWhileMutable, ident.Name)
+				}
+
+				c.loans = append(c.loans, &loan{target: sym, kind: SharedBorrow})
+			}
+		}
+
+		return &types.RefType{Mut: false, Elem: exprType}
+	}
+
+	if un.Op == "&mut" {
+		// Exclusive borrow
+		if ident, ok := un.Expr.(*ast.Ident); ok {
+			// Look up the symbol
+			sym, ok := c.env.LookupSymbol(ident.Name)
+			if ok {
+				// Check not borrowed at all
+				if c.borrowState(sym) != NotBorrowed {
+					c.errorf(diag.MutableWhileBorrowed, ident.Name)
+				}
+
+				c.loans = append(c.loans, &loan{target: sym, kind: MutBorrow})
+			}
+		}
+
+		return &types.RefType{Mut: true, Elem: exprType}
+	}
+
+	if un.Op == "*" {
+		// Dereference
+		if refType, ok := exprType.(*types.RefType); ok {
+			return refType.Elem
+		}
+
+		if ptrType, ok := exprType.(*types.PtrType); ok {
+			c.requireUnsafe("dereference of raw pointer")
+			return ptrType.Elem
+		}
+
+		c.errorf(diag.DerefNonPointer)
+	}
+
+	return exprType
+}
+
+// requireUnsafe reports op unless it is inside an unsafe block
+func (c *Checker) requireUnsafe(op string) {
+	if c.unsafe == 0 {
+		c.errorf(diag.NeedsUnsafe, op)
+	}
+}
+
+// borrowState returns the strongest active borrow of sym
+func (c *Checker) borrowState(sym *types.Symbol) BorrowState {
+	state := NotBorrowed
+
+	for _, ln := range c.loans {
+		if ln.target == sym && ln.kind > state {
+			state = ln.kind
+		}
+	}
+
+	return state
+}
+
+// holdLoans makes holder keep alive the loans taken since firstLoan, and
+// shares any loans held by references that value copies. block is where the
+// holder was declared; nil means it is unknown (an assignment), in which case
+// the loans live as long as the function body needs the holder. A number or
+// a str cannot refer to what was borrowed, so such a holder keeps none.
+func (c *Checker) holdLoans(holder *types.Symbol, value ast.Expr, firstLoan int, block *ast.Block) {
+	switch holder.Type.(type) {
+	case *types.PrimitiveType, *types.StringType:
+		return
+	}
+
+	if block == nil && len(c.blocks) > 0 {
+		block = c.blocks[0]
+	}
+
+	for _, ln := range c.loans[firstLoan:] {
+		if ln.holder == nil {
+			ln.holder = holder
+			ln.block = block
+		}
+	}
+
+	for _, ln := range c.loans[:firstLoan] {
+		if ln.holder != nil && ln.holder != holder && mentions(value, ln.holder.Name) {
+			c.loans = append(c.loans, &loan{target: ln.target, kind: ln.kind, holder: holder, block: block})
+		}
+	}
+}
+
+func (c *Checker) currentBlock() *ast.Block {
+	if len(c.blocks) == 0 {
+		return nil
+	}
+
+	return c.blocks[len(c.blocks)-1]
+}
+
+// expireLoans ends temporary loans at the end of a statement, and loans
+// whose holder was declared in block and is not mentioned in rest
+func (c *Checker) expireLoans(block *ast.Block, rest []ast.Stmt) {
+	live := c.loans[:0]
+
+	for _, ln := range c.loans {
+		if ln.holder == nil {
+			continue
+		}
+
+		if ln.block == block && !mentionsAny(rest, ln.holder.Name) {
+			continue
+		}
+
+		live = append(live, ln)
+	}
+
+	c.loans = live
+}
+
+// mentions reports whether node refers to an identifier called name
+func mentions(node ast.Node, name string) bool {
+	found := false
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+
+		return !found
+	})
+
+	return found
+}
+
+func mentionsAny(stmts []ast.Stmt, name string) bool {
+	for _, stmt := range stmts {
+		if mentions(stmt, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Checker) checkCallExpr(call *ast.CallExpr) types.Type {
+	// Extract function name from callee
+	var funcName string
+	switch callee := call.Callee.(type) {
+	case *ast.Ident:
+		funcName = callee.Name
+		c.read(funcName)
+
+		if typ, ok := c.checkVariant(callee.Name, call); ok {
+			return typ
+		}
+	case *ast.PathExpr:
+		if _, ok := c.enumOf(callee); ok {
+			return c.checkPathExpr(callee, call)
+		}
+
+		if typ, ok := c.checkPreludePath(callee, call); ok {
+			return typ
+		}
+
+		if typ, ok := c.checkCollectionPath(callee, call); ok {
+			return typ
+		}
+
+		if typ, ok := c.checkOSPath(callee, call); ok {
+			return typ
+		}
+
+		c.read(callee.Segments[0])
+
+		// For module paths like std::io::println, just use the last segment,
+		// unless it names a function of a loaded standard library module
+		funcName = callee.Segments[len(callee.Segments)-1]
+		if name, ok := c.stdFunc(callee); ok {
+			funcName = name
+		}
+	case *ast.FieldExpr:
+		// Calls on a value resolve to a method of its type
+		if c.isValueExpr(callee.Expr) {
+			return c.checkMethodCall(call, callee)
+		}
+
+		// For module paths like std::io::println, just use the field name
+		funcName = callee.Field
+	default:
+		c.errorf(diag.InvalidCall, call.Callee)
+		return c.env.NewTypeVar()
+	}
+
+	// Look up function
+	funcType, _, ok := c.env.Lookup(funcName)
+	if !ok {
+		c.errorf(diag.UndefinedFunction, funcName)
+		return c.env.NewTypeVar()
+	}
+
+	// Check if it's actually a function type
+	fn, ok := funcType.(*types.FuncType)
+	if !ok {
+		c.errorf(diag.NotAFunction, funcName)
+		return c.env.NewTypeVar()
+	}
+
+	if fn.Extern {
+		c.requireUnsafe(fmt.Sprintf("call to extern function %s", funcName))
+	}
+
+	if c.checkFormat(funcName, fn, call) {
+		return fn.Return
+	}
+
+	c.checkCallArgs(call, funcName, fn)
+	c.checkRegexLiteral(funcName, call)
+
+	// Return function's return type
+	return fn.Return
+}
+
+// enumOf returns the enum named by the first segment of a two-segment path
+func (c *Checker) enumOf(path *ast.PathExpr) (*types.EnumType, bool) {
+	if len(path.Segments) != 2 {
+		return nil, false
+	}
+
+	typ, _, ok := c.env.Lookup(path.Segments[0])
+	if !ok {
+		return nil, false
+	}
+
+	enum, ok := typ.(*types.EnumType)
+
+	return enum, ok
+}
+
+// checkPathExpr resolves Enum::Variant, or Enum::Variant(args) when call is
+// set, and checks the payload against the variant's declaration
+func (c *Checker) checkPathExpr(path *ast.PathExpr, call *ast.CallExpr) types.Type {
+	c.read(path.Segments[0])
+
+	enum, ok := c.enumOf(path)
+	if !ok {
+		// Module paths name an item by its last segment
+		name := path.Segments[len(path.Segments)-1]
+
+		typ, _, ok := c.env.Lookup(name)
+		if !ok {
+			c.errorf(diag.Undefined, path.String())
+			return c.env.NewTypeVar()
+		}
+
+		return typ
+	}
+
+	variant := path.Segments[1]
+
+	payload, ok := enum.Variants[variant]
+	if !ok {
+		names := make([]string, 0, len(enum.Variants))
+		for name := range enum.Variants {
+			names = append(names, name)
+		}
+
+		var notes []string
+		if guess := suggest(variant, names); guess != "" {
+			notes = append(notes, c.message(diag.DidYouMean, guess))
+		}
+
+		c.error(diag.NoVariant, c.message(diag.NoVariant, variant, enum.Name), notes...)
+
+		if call != nil {
+			for _, arg := range call.Args {
+				c.checkExpr(arg)
+			}
+		}
+
+		return enum
+	}
+
+	if call == nil {
+		if len(payload) > 0 {
+			c.errorf(diag.VariantArity, path.String(), len(payload), 0)
+		}
+
+		return enum
+	}
+
+	if len(payload) == 0 {
+		c.errorf(diag.VariantNoPayload, path.String())
+	} else if len(call.Args) != len(payload) {
+		c.errorf(diag.VariantArity, path.String(), len(payload), len(call.Args))
+	}
+
+	for i := range call.Args {
+		argType := c.checkExpr(call.Args[i])
+		if i >= len(payload) {
+			continue
+		}
+
+		if !containsTypeVar(payload[i]) && !c.coerce(&call.Args[i], argType, payload[i]) {
+			c.errorf(diag.VariantValue, i+1, path.String(), payload[i].String(), argType.String())
+		}
+
+		c.moveIfOwned(call.Args[i], argType)
+	}
+
+	return enum
+}
+
+// isValueExpr reports whether expr denotes a value rather than a module path
+// such as the std in std.io.println
+func (c *Checker) isValueExpr(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		typ, _, ok := c.env.Lookup(e.Name)
+		if !ok {
+			return false
+		}
+
+		_, isFunc := typ.(*types.FuncType)
+
+		return !isFunc
+	case *ast.FieldExpr:
+		return c.isValueExpr(e.Expr)
+	default:
+		return true
+	}
+}
+
+// checkMethodCall resolves recv.method(args), borrowing the receiver
+// automatically when the method takes &self or &mut self
+func (c *Checker) checkMethodCall(call *ast.CallExpr, callee *ast.FieldExpr) types.Type {
+	recvType := c.checkExpr(callee.Expr)
+
+	m, ok := c.env.LookupMethod(recvType, callee.Field)
+	if !ok {
+		// A field holding a function is called through
+		if fn, ok := fieldFunc(recvType, callee.Field); ok {
+			c.checkCallArgs(call, callee.Field, fn)
+			return fn.Return
+		}
+
+		c.errorf(diag.NoMethod, callee.Field, recvType.String())
+
+		for _, arg := range call.Args {
+			c.checkExpr(arg)
+		}
+
+		return c.env.NewTypeVar()
+	}
+
+	switch m.Receiver {
+	case types.NoReceiver:
+		c.errorf(diag.NotAMethod, m.Name, types.TypeName(recvType))
+	case types.RefReceiver:
+		c.autoBorrow(callee.Expr, recvType, SharedBorrow)
+	case types.MutRefReceiver:
+		c.autoBorrow(callee.Expr, recvType, MutBorrow)
+	}
+
+	c.checkCallArgs(call, m.Name, m.Type)
+
+	return m.Type.Return
+}
+
+// fieldFunc returns the type of the field of recv, or of what recv refers
+// to, if the field holds a function
+func fieldFunc(recv types.Type, field string) (*types.FuncType, bool) {
+	if ref, ok := recv.(*types.RefType); ok {
+		recv = ref.Elem
+	}
+
+	st, ok := recv.(*types.StructType)
+	if !ok {
+		return nil, false
+	}
+
+	fn, ok := st.Fields[field].(*types.FuncType)
+
+	return fn, ok
+}
+
+// autoBorrow takes the implicit borrow of a method receiver. A receiver that
+// is already a reference is reborrowed and only needs the right mutability.
+func (c *Checker) autoBorrow(recv ast.Expr, recvType types.Type, kind BorrowState) {
+	if ref, ok := recvType.(*types.RefType); ok {
+		if kind == MutBorrow && !ref.Mut {
+			c.errorf(diag.BorrowBehindShared, recv.String())
+		}
+
+		return
+	}
+
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return
 	}
 
-	return nil
-}
+	sym, ok := c.env.LookupSymbol(ident.Name)
+	if !ok {
+		return
+	}
 
-func (c *Checker) checkReturnStmt(ret *ast.ReturnStmt) types.Type {
-	if ret.Value != nil {
-		return c.checkExpr(ret.Value)
+	state := c.borrowState(sym)
+
+	switch {
+	case kind == MutBorrow && !sym.Mut:
+		c.errorf(diag.BorrowImmutable, ident.Name)
+	case kind == MutBorrow && state != NotBorrowed:
+		c.errorf(diag.MutableWhileBorrowed, ident.Name)
+	case kind == SharedBorrow && state == MutBorrow:
+		c.errorf(diag.SharedWhileMutable, ident.Name)
 	}
 
-	return &types.PrimitiveType{Name: "void", Kind: types.Void}
+	c.loans = append(c.loans, &loan{target: sym, kind: kind})
 }
 
-func (c *Checker) checkIfStmt(ifStmt *ast.IfStmt) types.Type {
-	// Check condition is bool
-	condType := c.checkExpr(ifStmt.Cond)
+// checkCallArgs checks call arguments against the parameters of fn
+func (c *Checker) checkCallArgs(call *ast.CallExpr, funcName string, fn *types.FuncType) {
+	params := fn.Params
 
-	boolType := &types.PrimitiveType{Name: "bool", Kind: types.Bool}
-	if !types.TypesEqual(condType, boolType) {
-		c.error(fmt.Sprintf("if condition must be bool, got %s", condType.String()))
+	// Check argument count
+	if fn.Variadic {
+		// Trailing arguments are elements of the variadic slice
+		fixed := len(fn.Params) - 1
+		if len(call.Args) < fixed {
+			c.errorf(diag.TooFewArguments, funcName, fixed, len(call.Args))
+		}
+
+		params = fn.Params[:fixed:fixed]
+		for len(params) < len(call.Args) {
+			params = append(params, fn.Params[fixed].(*types.SliceType).Elem)
+		}
+	} else if len(call.Args) != len(fn.Params) {
+		c.errorf(diag.ArgumentCount, funcName, len(fn.Params), len(call.Args))
+		// Still check arguments to find other errors
 	}
 
-	// Check then block
-	c.checkBlock(ifStmt.Then)
+	builtinType, ok := c.env.Builtin(funcName)
+	builtin := ok && builtinType == types.Type(fn)
 
-	// Check else block if present
-	if ifStmt.Else != nil {
-		c.checkStmt(ifStmt.Else)
+	// Check argument types
+	minArgs := len(call.Args)
+	if len(params) < minArgs {
+		minArgs = len(params)
 	}
 
-	return nil
-}
+	for i := 0; i < minArgs; i++ {
+		argType := c.checkExpr(call.Args[i])
+		expectedType := params[i]
 
-func (c *Checker) checkExpr(expr ast.Expr) types.Type {
-	switch e := expr.(type) {
-	case *ast.IntLit:
-		return &types.PrimitiveType{Name: "i32", Kind: types.Int32}
-	case *ast.FloatLit:
-		return &types.PrimitiveType{Name: "f64", Kind: types.Float64}
-	case *ast.BoolLit:
-		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
-	case *ast.StringLit:
-		// String is []u8
-		u8 := &types.PrimitiveType{Name: "u8", Kind: types.UInt8}
-		return &types.SliceType{Elem: u8}
-	case *ast.NilLit:
-		// nil can be any pointer type, return a type var for now
-		return c.env.NewTypeVar()
-	case *ast.Ident:
-		// Look up the symbol
-		sym, ok := c.env.LookupSymbol(e.Name)
-		if !ok {
-			c.error(fmt.Sprintf("undefined variable: %s", e.Name))
-			return c.env.NewTypeVar()
+		// Passing by value moves the argument into the callee. Generic
+		// builtins such as len only inspect their argument.
+		if !builtin || !containsTypeVar(expectedType) {
+			c.moveIfOwned(call.Args[i], argType)
 		}
 
-		// Check if moved
-		if c.moved[sym] {
-			c.error(fmt.Sprintf("use of moved value: %s", e.Name))
-			return c.env.NewTypeVar()
+		// Skip type checking if either is a type variable (for generic/builtin functions)
+		if _, isTypeVar := expectedType.(*types.TypeVar); isTypeVar {
+			continue
 		}
 
-		// If type is not Copy and not borrowed, mark as moved
-		// (simplified: only track in assignments)
-		return sym.Type
-	case *ast.BinaryExpr:
-		return c.checkBinaryExpr(e)
-	case *ast.UnaryExpr:
-		return c.checkUnaryExpr(e)
-	case *ast.CallExpr:
-		return c.checkCallExpr(e)
-	case *ast.StructExpr:
-		return c.checkStructExpr(e)
-	// ... other exprs
-	default:
-		c.error(fmt.Sprintf("unknown expression type: %T", expr))
-		return c.env.NewTypeVar()
-	}
-}
+		// A reference to a type variable accepts any reference that is at
+		// least as mutable
+		if ref, ok := expectedType.(*types.RefType); ok {
+			if _, isTypeVar := ref.Elem.(*types.TypeVar); isTypeVar {
+				if argRef, ok := argType.(*types.RefType); ok && (argRef.Mut || !ref.Mut) {
+					continue
+				}
+			}
+		}
 
-func (c *Checker) checkBinaryExpr(bin *ast.BinaryExpr) types.Type {
-	leftType := c.checkExpr(bin.Left)
-	rightType := c.checkExpr(bin.Right)
+		// A slice of a type variable accepts any slice, and a str as its bytes
+		if slice, ok := expectedType.(*types.SliceType); ok {
+			if _, isTypeVar := slice.Elem.(*types.TypeVar); isTypeVar {
+				if _, isSlice := argType.(*types.SliceType); isSlice || types.IsString(argType) {
+					continue
+				}
+			}
+		}
 
-	// Check types match
-	if !types.TypesEqual(leftType, rightType) {
-		c.error(fmt.Sprintf("type mismatch in binary expression: %s and %s",
-			leftType.String(), rightType.String()))
+		if !c.coerce(&call.Args[i], argType, expectedType) {
+			c.errorf(diag.ArgumentMismatch, i+1, funcName, expectedType.String(), argType.String())
+		}
 	}
 
-	// Arithmetic operators return same type
-	if bin.Op == "+" || bin.Op == "-" || bin.Op == "*" || bin.Op == "/" || bin.Op == "%" {
-		return leftType
+	// Check remaining arguments if there are extra
+	for i := minArgs; i < len(call.Args); i++ {
+		c.checkExpr(call.Args[i])
 	}
+}
 
-	// Comparison operators return bool
-	if bin.Op == "==" || bin.Op == "!=" || bin.Op == "<" || bin.Op == ">" ||
-		bin.Op == "<=" || bin.Op == ">=" {
-		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+// coerce reports whether a value of type from may be used where to is
+// expected. Widening conversions are made explicit by wrapping *value in a
+// ConvExpr so that lowering emits the cast.
+func (c *Checker) coerce(value *ast.Expr, from, to types.Type) bool {
+	// A constant that does not fit where it is used is reported as such,
+	// even when its type is already the right one
+	if overflows(*value, to) {
+		v, _ := consteval.Eval(*value, noConsts)
+		c.errorf(diag.ConstantOverflow, v, to.String())
+
+		return true
 	}
 
-	// Logical operators return bool
-	if bin.Op == "&&" || bin.Op == "||" {
-		return &types.PrimitiveType{Name: "bool", Kind: types.Bool}
+	if types.TypesEqual(from, to) {
+		return true
 	}
 
-	return leftType
-}
+	// Some, None, Ok and Err leave the types they do not mention open, and
+	// take them from where the value is used
+	if types.Instantiates(from, to) {
+		c.types[*value] = to
+		return true
+	}
 
-func (c *Checker) checkUnaryExpr(un *ast.UnaryExpr) types.Type {
-	exprType := c.checkExpr(un.Expr)
+	// A tuple literal is converted element by element
+	if tuple, ok := (*value).(*ast.TupleExpr); ok {
+		fromTuple, okFrom := from.(*types.TupleType)
+		toTuple, okTo := to.(*types.TupleType)
 
-	if un.Op == "&" {
-		// Shared borrow
-		if ident, ok := un.Expr.(*ast.Ident); ok {
-			// Look up the symbol
-			sym, ok := c.env.LookupSymbol(ident.Name)
-			if ok {
-				// Check not exclusively borrowed
-				if c.borrows[sym] == MutBorrow {
-					c.error(fmt.Sprintf("cannot borrow %s as shared because it is also borrowed as mutable", ident.Name))
+		if okFrom && okTo && len(fromTuple.Elems) == len(toTuple.Elems) {
+			for i := range tuple.Elems {
+				if !c.coerce(&tuple.Elems[i], fromTuple.Elems[i], toTuple.Elems[i]) {
+					return false
 				}
-
-				c.borrows[sym] = SharedBorrow
 			}
-		}
 
-		return &types.RefType{Mut: false, Elem: exprType}
+			c.types[tuple] = to
+
+			return true
+		}
 	}
 
-	if un.Op == "&mut" {
-		// Exclusive borrow
-		if ident, ok := un.Expr.(*ast.Ident); ok {
-			// Look up the symbol
-			sym, ok := c.env.LookupSymbol(ident.Name)
-			if ok {
-				// Check not borrowed at all
-				if c.borrows[sym] != NotBorrowed {
-					c.error(fmt.Sprintf("cannot borrow %s as mutable because it is already borrowed", ident.Name))
-				}
+	// So is an array literal, which an empty one always needs
+	if array, ok := (*value).(*ast.ArrayExpr); ok {
+		fromArray, okFrom := from.(*types.ArrayType)
+		toArray, okTo := to.(*types.ArrayType)
 
-				c.borrows[sym] = MutBorrow
+		if okFrom && okTo && fromArray.Len == toArray.Len {
+			for i := range array.Elems {
+				if !c.coerce(&array.Elems[i], fromArray.Elem, toArray.Elem) {
+					return false
+				}
 			}
+
+			c.types[array] = to
+
+			return true
 		}
+	}
 
-		return &types.RefType{Mut: true, Elem: exprType}
+	// A str is laid out like a []u8 and can be viewed as its bytes
+	if types.IsString(from) && isBytes(to) {
+		return true
 	}
 
-	if un.Op == "*" {
-		// Dereference
-		if refType, ok := exprType.(*types.RefType); ok {
-			return refType.Elem
+	// A literal constant that overflows its default type, or does not widen,
+	// is computed in the type it is used as when it fits there
+	if !types.Widens(from, to) && !c.unsizes(from, to) || overflows(*value, from) {
+		if !fitsConstant(*value, from, to) {
+			return false
 		}
 
-		if ptrType, ok := exprType.(*types.PtrType); ok {
-			return ptrType.Elem
-		}
+		ast.Inspect(*value, func(n ast.Node) bool {
+			if e, ok := n.(ast.Expr); ok {
+				c.types[e] = to
+			}
+
+			return true
+		})
 
-		c.error("cannot dereference non-pointer type")
+		return true
 	}
 
-	return exprType
+	*value = &ast.ConvExpr{Span: *(*value).Pos(), Expr: *value, From: typeExpr(from), To: typeExpr(to)}
+
+	return true
 }
 
-func (c *Checker) checkCallExpr(call *ast.CallExpr) types.Type {
-	// Extract function name from callee
-	var funcName string
-	switch callee := call.Callee.(type) {
-	case *ast.Ident:
-		funcName = callee.Name
-	case *ast.FieldExpr:
-		// For module paths like std::io::println, just use the field name
-		funcName = callee.Field
-	default:
-		c.error(fmt.Sprintf("invalid function call: %T", call.Callee))
-		return c.env.NewTypeVar()
+// fitsConstant reports whether value is an integer constant built from
+// literals whose value fits in to. Such a constant takes any integer type
+// that can hold it, so u8 and u64 variables can be set from literals.
+func fitsConstant(value ast.Expr, from, to types.Type) bool {
+	if !types.IsInteger(from) || !types.IsInteger(to) {
+		return false
 	}
 
-	// Look up function
-	funcType, _, ok := c.env.Lookup(funcName)
+	v, err := consteval.Eval(value, noConsts)
+
+	return err == nil && consteval.Fits(v, to.String())
+}
+
+// overflows reports whether value is an integer constant built from
+// literals that does not fit in typ
+func overflows(value ast.Expr, typ types.Type) bool {
+	v, err := consteval.Eval(value, noConsts)
+
+	return err == nil && types.IsInteger(typ) && !consteval.Fits(v, typ.String())
+}
+
+// isConstant reports whether value is an integer constant built from
+// literals
+func isConstant(value ast.Expr) bool {
+	_, err := consteval.Eval(value, noConsts)
+
+	return err == nil
+}
+
+// noConsts resolves no names, so that only literals count as constants
+func noConsts(string) (int64, bool) { return 0, false }
+
+// isBytes reports whether t is []u8
+func isBytes(t types.Type) bool {
+	slice, ok := t.(*types.SliceType)
 	if !ok {
-		c.error(fmt.Sprintf("undefined function: %s", funcName))
-		return c.env.NewTypeVar()
+		return false
 	}
 
-	// Check if it's actually a function type
-	fn, ok := funcType.(*types.FuncType)
+	elem, ok := slice.Elem.(*types.PrimitiveType)
+
+	return ok && elem.Kind == types.UInt8
+}
+
+// unsizes reports whether from is a reference to a concrete type that can be
+// turned into the trait object reference to
+func (c *Checker) unsizes(from, to types.Type) bool {
+	src, ok := from.(*types.RefType)
 	if !ok {
-		c.error(fmt.Sprintf("%s is not a function", funcName))
-		return c.env.NewTypeVar()
+		return false
 	}
 
-	// Check argument count
-	if len(call.Args) != len(fn.Params) {
-		c.error(fmt.Sprintf("function %s expects %d arguments, got %d",
-			funcName, len(fn.Params), len(call.Args)))
-		// Still check arguments to find other errors
+	dst, ok := to.(*types.RefType)
+	if !ok || (dst.Mut && !src.Mut) {
+		return false
 	}
 
-	// Check argument types
-	minArgs := len(call.Args)
-	if len(fn.Params) < minArgs {
-		minArgs = len(fn.Params)
+	dyn, ok := dst.Elem.(*types.DynType)
+	if !ok {
+		return false
 	}
 
-	for i := 0; i < minArgs; i++ {
-		argType := c.checkExpr(call.Args[i])
-		expectedType := fn.Params[i]
+	if _, isDyn := src.Elem.(*types.DynType); isDyn {
+		return false
+	}
 
-		// Skip type checking if either is a type variable (for generic/builtin functions)
-		if _, isTypeVar := expectedType.(*types.TypeVar); isTypeVar {
-			continue
-		}
+	return c.env.Implements(dyn.Trait.Name, src.Elem)
+}
 
-		if !types.TypesEqual(argType, expectedType) {
-			c.error(fmt.Sprintf("argument %d to %s: expected %s, got %s",
-				i+1, funcName, expectedType.String(), argType.String()))
-		}
+// typeExpr spells a checked type as an AST type for conversions the checker
+// inserts
+func typeExpr(t types.Type) ast.Type {
+	switch t := t.(type) {
+	case *types.RefType:
+		return &ast.RefType{Mut: t.Mut, Elem: typeExpr(t.Elem)}
+	case *types.DynType:
+		return &ast.DynType{Trait: &ast.TypePath{Path: []string{t.Trait.Name}}}
+	default:
+		return &ast.TypePath{Path: []string{types.TypeName(t)}}
 	}
+}
 
-	// Check remaining arguments if there are extra
-	for i := minArgs; i < len(call.Args); i++ {
-		c.checkExpr(call.Args[i])
+// containsTypeVar reports whether t mentions an uninstantiated type variable
+func containsTypeVar(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.TypeVar:
+		return true
+	case *types.RefType:
+		return containsTypeVar(t.Elem)
+	case *types.PtrType:
+		return containsTypeVar(t.Elem)
+	case *types.SliceType:
+		return containsTypeVar(t.Elem)
+	case *types.ArrayType:
+		return containsTypeVar(t.Elem)
+	default:
+		return false
 	}
-
-	// Return function's return type
-	return fn.Return
 }
 
 func (c *Checker) checkStructExpr(s *ast.StructExpr) types.Type {
@@ -419,46 +2106,53 @@ func (c *Checker) checkStructExpr(s *ast.StructExpr) types.Type {
 }
 
 func (c *Checker) checkStructDecl(s *ast.StructDecl) {
-	// Track if we pushed a scope for type parameters
-	scopePushed := false
+	// Define the struct before resolving its fields, so that fields can
+	// refer to it through a pointer or reference
+	structType := &types.StructType{
+		Name:    s.Name,
+		Fields:  make(map[string]types.Type),
+		TParams: s.TParams,
+	}
+
+	c.env.Define(s.Name, structType, false)
 
-	// If struct has type parameters, push a new scope and define them
+	// Type parameters are type variables while the fields are resolved
 	if len(s.TParams) > 0 {
 		c.env.PushScope()
 
-		scopePushed = true
-
-		// Define type parameters as type variables in scope
 		for _, tparam := range s.TParams {
-			typeVar := c.env.NewTypeVar()
-			c.env.Define(tparam, typeVar, false)
+			c.env.Define(tparam, c.env.NewTypeVar(), false)
 		}
 	}
 
-	// Register struct type
-	fields := make(map[string]types.Type)
 	for _, field := range s.Fields {
-		fields[field.Name] = c.resolveType(field.Type)
+		structType.Fields[field.Name] = c.resolveType(field.Type)
 	}
 
-	structType := &types.StructType{
-		Name:    s.Name,
-		Fields:  fields,
-		TParams: s.TParams,
-	}
-
-	// Pop scope if we pushed one, then define struct in parent scope
-	if scopePushed {
+	if len(s.TParams) > 0 {
 		c.env.PopScope()
 	}
-
-	// Define struct type in current (parent) scope
-	c.env.Define(s.Name, structType, false)
 }
 
 func (c *Checker) checkEnumDecl(e *ast.EnumDecl) {
-	// Register enum type
-	variants := make(map[string][]types.Type)
+	// Define the enum before resolving its payloads, so that payloads can
+	// refer to it through a pointer or reference
+	enumType := &types.EnumType{
+		Name:     e.Name,
+		Variants: make(map[string][]types.Type),
+		TParams:  e.TParams,
+	}
+
+	c.env.Define(e.Name, enumType, false)
+
+	// Type parameters are type variables while the payloads are resolved
+	if len(e.TParams) > 0 {
+		c.env.PushScope()
+
+		for _, tparam := range e.TParams {
+			c.env.Define(tparam, c.env.NewTypeVar(), false)
+		}
+	}
 
 	for _, variant := range e.Variants {
 		variantTypes := []types.Type{}
@@ -466,30 +2160,82 @@ func (c *Checker) checkEnumDecl(e *ast.EnumDecl) {
 			variantTypes = append(variantTypes, c.resolveType(vtype))
 		}
 
-		variants[variant.Name] = variantTypes
+		enumType.Variants[variant.Name] = variantTypes
 	}
 
-	enumType := &types.EnumType{
-		Name:     e.Name,
-		Variants: variants,
-		TParams:  e.TParams,
+	if len(e.TParams) > 0 {
+		c.env.PopScope()
 	}
 
-	c.env.Define(e.Name, enumType, false)
+	enumType.Discriminants = c.discriminants(e)
+}
+
+func (c *Checker) checkTraitDecl(t *ast.TraitDecl) {
+	// Type parameters, and Self, stand for whatever the impl supplies
+	c.env.PushScope()
+
+	trait := &types.TraitType{Name: t.Name, TParams: t.TParams, Self: c.env.NewTypeVar()}
+	c.env.Define("Self", trait.Self, false)
+
+	for _, tparam := range t.TParams {
+		c.env.Define(tparam, c.env.NewTypeVar(), false)
+	}
+
+	for _, sig := range t.Sigs {
+		if trait.Method(sig.Name) != nil {
+			c.errorf(diag.DuplicateTraitMethod, sig.Name, t.Name)
+			continue
+		}
+
+		trait.Methods = append(trait.Methods, &types.Method{
+			Name:     sig.Name,
+			Receiver: receiverOf(sig.Params),
+			Type:     c.signature(sig.Params, sig.Return),
+		})
+	}
+
+	c.env.PopScope()
+
+	c.env.Define(t.Name, trait, false)
 }
 
+// resolveType returns the type an annotation names. Signatures, impl
+// receivers and let annotations are looked at more than once, so each
+// annotation is resolved, and its problems reported, only the first time.
 func (c *Checker) resolveType(astType ast.Type) types.Type {
+	if typ, ok := c.annots[astType]; ok {
+		return typ
+	}
+
+	typ := c.resolveAnnotation(astType)
+	c.annots[astType] = typ
+
+	return typ
+}
+
+func (c *Checker) resolveAnnotation(astType ast.Type) types.Type {
 	switch t := astType.(type) {
 	case *ast.TypePath:
+		if len(t.Path) > 1 {
+			c.read(t.Path[0])
+		}
+
 		// Handle generic instantiation
 		if len(t.Args) > 0 {
 			// Generic instantiation
 			baseType, _, ok := c.env.Lookup(t.Path[len(t.Path)-1])
 			if !ok {
-				c.error(fmt.Sprintf("undefined type: %s", t.Path[len(t.Path)-1]))
+				c.errorf(diag.UndefinedType, t.Path[len(t.Path)-1])
 				return c.env.NewTypeVar()
 			}
 
+			switch baseType.(type) {
+			case *types.OptionType, *types.ResultType:
+				return c.instantiatePrelude(t, baseType)
+			case *types.VecType, *types.MapType:
+				return c.instantiateCollection(t, baseType)
+			}
+
 			// Resolve generic arguments (for validation)
 			for _, arg := range t.Args {
 				_ = c.resolveType(arg)
@@ -504,7 +2250,7 @@ func (c *Checker) resolveType(astType ast.Type) types.Type {
 		if len(t.Path) == 1 {
 			typ, _, ok := c.env.Lookup(t.Path[0])
 			if !ok {
-				c.error(fmt.Sprintf("undefined type: %s", t.Path[0]))
+				c.errorf(diag.UndefinedType, t.Path[0])
 				return c.env.NewTypeVar()
 			}
 
@@ -513,7 +2259,7 @@ func (c *Checker) resolveType(astType ast.Type) types.Type {
 		// For now, just use last component
 		typ, _, ok := c.env.Lookup(t.Path[len(t.Path)-1])
 		if !ok {
-			c.error(fmt.Sprintf("undefined type: %s", t.Path[len(t.Path)-1]))
+			c.errorf(diag.UndefinedType, t.Path[len(t.Path)-1])
 			return c.env.NewTypeVar()
 		}
 
@@ -521,6 +2267,22 @@ func (c *Checker) resolveType(astType ast.Type) types.Type {
 	case *ast.RefType:
 		elem := c.resolveType(t.Elem)
 		return &types.RefType{Mut: t.Mut, Elem: elem}
+	case *ast.DynType:
+		name := t.Trait.Path[len(t.Trait.Path)-1]
+
+		typ, _, ok := c.env.Lookup(name)
+		if !ok {
+			c.errorf(diag.UndefinedTrait, name)
+			return c.env.NewTypeVar()
+		}
+
+		trait, ok := typ.(*types.TraitType)
+		if !ok {
+			c.errorf(diag.DynNotTrait, name, name)
+			return c.env.NewTypeVar()
+		}
+
+		return &types.DynType{Trait: trait}
 	case *ast.PtrType:
 		elem := c.resolveType(t.Elem)
 		return &types.PtrType{Elem: elem}
@@ -530,31 +2292,20 @@ func (c *Checker) resolveType(astType ast.Type) types.Type {
 	case *ast.ArrayType:
 		elem := c.resolveType(t.Elem)
 
-		// Parse array length from AST
-		if intLit, ok := t.Len.(*ast.IntLit); ok {
-			// strconv.ParseInt with base 0 automatically handles:
-			// - Decimal: "10"
-			// - Hex: "0x10"
-			// - Octal: "0o10" or "010"
-			// - Binary: "0b10"
-			length, err := strconv.ParseInt(intLit.Value, 0, 64)
-			if err != nil {
-				c.error(fmt.Sprintf("invalid array length: %s", intLit.Value))
-				return &types.ArrayType{Elem: elem, Len: 0}
-			}
-
-			// Validate length is positive
-			if length <= 0 {
-				c.error(fmt.Sprintf("array length must be positive, got %d", length))
-				return &types.ArrayType{Elem: elem, Len: 0}
-			}
+		// The length may be any constant expression
+		length, err := c.constValue(t.Len)
+		if err != nil {
+			c.errorf(diag.ArrayLengthConst, err)
+			return &types.ArrayType{Elem: elem, Len: 0}
+		}
 
-			return &types.ArrayType{Elem: elem, Len: int(length)}
+		// Validate length is positive
+		if length <= 0 {
+			c.errorf(diag.ArrayLengthPositive, length)
+			return &types.ArrayType{Elem: elem, Len: 0}
 		}
 
-		// If no length specified or not an IntLit, default to 0 (error case)
-		c.error("array length must be a constant integer")
-		return &types.ArrayType{Elem: elem, Len: 0}
+		return &types.ArrayType{Elem: elem, Len: int(length)}
 	case *ast.TupleType:
 		elems := []types.Type{}
 		for _, e := range t.Elems {
@@ -562,10 +2313,21 @@ func (c *Checker) resolveType(astType ast.Type) types.Type {
 		}
 
 		return &types.TupleType{Elems: elems}
+	case *ast.FuncType:
+		fn := &types.FuncType{Return: &types.PrimitiveType{Name: "void", Kind: types.Void}}
+		for _, p := range t.Params {
+			fn.Params = append(fn.Params, c.resolveType(p))
+		}
+
+		if t.Return != nil {
+			fn.Return = c.resolveType(t.Return)
+		}
+
+		return fn
 	case *ast.VoidType:
 		return &types.PrimitiveType{Name: "void", Kind: types.Void}
 	default:
-		c.error(fmt.Sprintf("unknown type: %T", astType))
+		c.errorf(diag.UnknownType, astType)
 		return c.env.NewTypeVar()
 	}
 }
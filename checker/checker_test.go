@@ -2,8 +2,10 @@ package checker
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/yarlson/yarlang/ast"
 	"github.com/yarlson/yarlang/lexer"
 	"github.com/yarlson/yarlang/parser"
 	"github.com/yarlson/yarlang/types"
@@ -53,6 +55,78 @@ fn main() {
 `,
 			wantErr: true,
 		},
+		{
+			name: "short declaration",
+			input: `
+fn main() {
+	x := 5
+	let y: i32 = x + 1
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "short declaration is immutable",
+			input: `
+fn main() {
+	x := 5
+	x = 6
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "short declaration cannot redeclare in the same scope",
+			input: `
+fn main() {
+	x := 5
+	x := 6
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "array literal",
+			input: `
+fn main() {
+	let a = [1, 2, 3]
+	let b: [i64; 2] = [4, 5]
+	let c: [i32; 3] = a
+	let d: i64 = b[0]
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "array literal elements of different types",
+			input: `
+fn main() {
+	let a = [1, true]
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "array literal of the wrong length",
+			input: `
+fn main() {
+	let a: [i32; 2] = [1, 2, 3]
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "short declaration may shadow an outer scope",
+			input: `
+fn main() {
+	x := 5
+	{
+		x := true
+	}
+}
+`,
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,10 +151,10 @@ fn main() {
 
 func TestArrayLength(t *testing.T) {
 	tests := []struct {
-		name         string
-		arrayType    string
-		wantErr      bool
-		wantLength   int
+		name       string
+		arrayType  string
+		wantErr    bool
+		wantLength int
 	}{
 		{
 			name:       "decimal array length",
@@ -277,6 +351,199 @@ fn main() {
 `,
 			wantErr: false,
 		},
+		{
+			name: "i32 argument widens to i64 parameter",
+			input: `
+fn wide(a i64) {}
+
+fn main() {
+	wide(5)
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "i64 argument does not narrow to i32 parameter",
+			input: `
+fn narrow(a i32) {}
+
+fn main() {
+	let x: i64 = 5
+	narrow(x)
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "signed argument does not convert to unsigned parameter",
+			input: `
+fn unsigned(a u64) {}
+
+fn main() {
+	let n: i32 = 5
+	unsigned(n)
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "integer literal takes an unsigned parameter type",
+			input: `
+fn unsigned(a u64) {}
+
+fn main() {
+	unsigned(5)
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "return value widens to declared type",
+			input: `
+fn wide() i64 {
+	return 5
+}
+
+fn main() {}
+`,
+			wantErr: false,
+		},
+		{
+			name: "return type mismatch",
+			input: `
+fn flag() i32 {
+	return true
+}
+
+fn main() {}
+`,
+			wantErr: true,
+		},
+		{
+			name: "tuple return and destructuring",
+			input: `
+fn divmod(a i32, b i32) (i32, i32) {
+	return (a / b, a % b)
+}
+
+fn main() {
+	let (q, r) = divmod(7, 2)
+	let sum: i32 = q + r
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "tuple element types are checked",
+			input: `
+fn pair() (i32, bool) {
+	return (1, true)
+}
+
+fn main() {
+	let (n, ok) = pair()
+	let x: i32 = ok
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "tuple literal widens element by element",
+			input: `
+fn pair() (i32, i64) {
+	return (1, 5000000000)
+}
+
+fn main() {
+	let t = pair()
+	let n: i64 = t.1
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "tuple index out of range",
+			input: `
+fn main() {
+	let t = (1, 2)
+	let n = t.2
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "destructuring arity mismatch",
+			input: `
+fn pair() (i32, i32) {
+	return (1, 2)
+}
+
+fn main() {
+	let (a, b, c) = pair()
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "variadic call with extra arguments",
+			input: `
+fn sum(base i32, nums ...i32) i32 {
+	return base
+}
+
+fn main() {
+	let a = sum(1)
+	let b = sum(1, 2, 3, 4)
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "variadic parameter is a slice in the body",
+			input: `
+fn first(nums ...i32) []i32 {
+	return nums
+}
+
+fn main() {}
+`,
+			wantErr: false,
+		},
+		{
+			name: "variadic call missing fixed arguments",
+			input: `
+fn sum(base i32, nums ...i32) i32 {
+	return base
+}
+
+fn main() {
+	let a = sum()
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "variadic argument type mismatch",
+			input: `
+fn sum(base i32, nums ...i32) i32 {
+	return base
+}
+
+fn main() {
+	let a = sum(1, 2, true)
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "variadic parameter not last",
+			input: `
+fn bad(nums ...i32, base i32) {}
+
+fn main() {}
+`,
+			wantErr: true,
+		},
 		{
 			name: "builtin panic",
 			input: `
@@ -286,6 +553,69 @@ fn main() {
 `,
 			wantErr: false,
 		},
+		{
+			name: "call through function value",
+			input: `
+fn add(a i32, b i32) i32 {
+	return a + b
+}
+
+fn main() {
+	let f: fn(i32, i32) i32 = add
+	let x: i32 = f(1, 2)
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "function value of the wrong type",
+			input: `
+fn add(a i32, b i32) i32 {
+	return a + b
+}
+
+fn main() {
+	let f: fn(i32) i32 = add
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "call through function field",
+			input: `
+struct Op {
+	apply: fn(i32) bool,
+}
+
+fn even(n i32) bool {
+	return n % 2 == 0
+}
+
+fn main() {
+	let op = Op{apply: even}
+	let b: bool = op.apply(4)
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "call through function field with wrong argument",
+			input: `
+struct Op {
+	apply: fn(i32) bool,
+}
+
+fn even(n i32) bool {
+	return n % 2 == 0
+}
+
+fn main() {
+	let op = Op{apply: even}
+	let b = op.apply(true)
+}
+`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -307,3 +637,803 @@ fn main() {
 		})
 	}
 }
+
+func TestMainSignature(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"fn main() {}", false},
+		{"fn main() i32 { return 0 }", false},
+		{"fn main() i64 { return 0 }", true},
+		{"fn main(n i32) {}", true},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		file := p.ParseFile()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		err := NewChecker().CheckFile(file)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: CheckFile() error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllPathsReturn(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name:    "empty body",
+			input:   `fn f() i32 {}`,
+			wantErr: true,
+		},
+		{
+			name: "if without else",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		return 1
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name: "if with else",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		return 1
+	} else {
+		return 2
+	}
+}`,
+			wantErr: false,
+		},
+		{
+			name: "else branch falls through",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		return 1
+	} else {
+		let x = 2
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name: "else if chain",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		return 1
+	} else if n < 0 {
+		return 2
+	} else {
+		return 0
+	}
+}`,
+			wantErr: false,
+		},
+		{
+			name: "ends in panic",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		return 1
+	}
+	panic("negative")
+}`,
+			wantErr: false,
+		},
+		{
+			name: "infinite loop",
+			input: `fn f() i32 {
+	while true {
+	}
+}`,
+			wantErr: false,
+		},
+		{
+			name: "infinite loop with break",
+			input: `fn f() i32 {
+	while true {
+		break
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name: "break in nested loop",
+			input: `fn f(n i32) i32 {
+	while true {
+		while n > 0 {
+			break
+		}
+	}
+}`,
+			wantErr: false,
+		},
+		{
+			name: "conditional loop",
+			input: `fn f(n i32) i32 {
+	while n > 0 {
+		return 1
+	}
+}`,
+			wantErr: true,
+		},
+		{
+			name:    "void function",
+			input:   `fn f(n i32) {}`,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil && !strings.Contains(err.Error(), "missing return at end of function f") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGuardStmt(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "guard returns",
+			input: `fn f(b i32) i32 {
+	guard b != 0 else {
+		return 0
+	}
+	return 10 / b
+}`,
+		},
+		{
+			name: "guard panics",
+			input: `fn f(b i32) i32 {
+	guard b != 0 else {
+		panic("division by zero")
+	}
+	return 10 / b
+}`,
+		},
+		{
+			name: "guard continues a loop",
+			input: `fn f() {
+	let mut i = 0
+	while i < 10 {
+		i = i + 1
+		guard i > 5 else {
+			continue
+		}
+	}
+}`,
+		},
+		{
+			name: "guard falls through",
+			input: `fn f(b i32) {
+	guard b != 0 else {
+		println(b)
+	}
+}`,
+			errMsg: "guard else block must end in return, break, continue or panic",
+		},
+		{
+			name: "guard on non-bool",
+			input: `fn f(b i32) {
+	guard b else {
+		return
+	}
+}`,
+			errMsg: "guard condition must be bool, got i32",
+		},
+		{
+			name: "guard does not end a function",
+			input: `fn f(b i32) i32 {
+	guard b != 0 else {
+		return 0
+	}
+}`,
+			errMsg: "missing return at end of function f",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestCoercionInsertsConversion(t *testing.T) {
+	input := `
+fn wide(a i64) {}
+
+fn main() {
+	let mut y: i64 = 1
+	y = 2
+	wide(3)
+}
+`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if err := NewChecker().CheckFile(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := file.Items[1].(*ast.FuncDecl).Body
+	values := []ast.Expr{
+		body.Stmts[0].(*ast.LetStmt).Value,
+		body.Stmts[1].(*ast.AssignStmt).Value,
+		body.Stmts[2].(*ast.ExprStmt).Expr.(*ast.CallExpr).Args[0],
+	}
+
+	for i, v := range values {
+		conv, ok := v.(*ast.ConvExpr)
+		if !ok {
+			t.Errorf("coercion site %d: expected ConvExpr, got %T", i, v)
+			continue
+		}
+
+		if conv.From.String() != "i32" || conv.To.String() != "i64" {
+			t.Errorf("coercion site %d: expected i32 -> i64, got %s -> %s", i, conv.From, conv.To)
+		}
+	}
+}
+
+func TestNumericConversions(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "narrower operand widens",
+			input: `fn f(a i32, b i64) i64 {
+	return a + b
+}`,
+		},
+		{
+			name: "unsigned operand widens to wider signed",
+			input: `fn f(a u8, b i16) bool {
+	return a < b
+}`,
+		},
+		{
+			name: "literal takes the type of a u8 operand",
+			input: `fn f(x u8) u8 {
+	let y: u8 = x + 1
+	return 2 * y
+}`,
+		},
+		{
+			name: "literal takes the type of small integer operands",
+			input: `fn f(a i8, b i16, c u16) bool {
+	let x: i8 = a - 1
+	let y: i16 = b * 3
+	let z: u16 = c % 7
+	return x < 0 && y > 1 && z != 2
+}`,
+		},
+		{
+			name: "literal that does not fit the other operand",
+			input: `fn f(x u8) u8 {
+	return x + 256
+}`,
+			errMsg: "constant 256 overflows u8",
+		},
+		{
+			name: "mixed signedness needs a cast",
+			input: `fn f(a i32, b u64) u64 {
+	return a + b
+}`,
+			errMsg: "cannot add i32 and u64, use `as`",
+		},
+		{
+			name: "mixed float and integer needs a cast",
+			input: `fn f(a f64, b i32) bool {
+	return a == b
+}`,
+			errMsg: "cannot compare f64 and i32, use `as`",
+		},
+		{
+			name: "explicit cast",
+			input: `fn f(a i32, b u64) u64 {
+	return a as u64 + b
+}`,
+		},
+		{
+			name: "narrowing cast",
+			input: `fn f(a f64) u8 {
+	return a as u8
+}`,
+		},
+		{
+			name: "bool casts to an integer",
+			input: `fn f(b bool) i32 {
+	return b as i32
+}`,
+		},
+		{
+			name: "integer does not cast to bool",
+			input: `fn f(n i32) bool {
+	return n as bool
+}`,
+			errMsg: "cannot cast i32 to bool",
+		},
+		{
+			name: "literal takes an unsigned type",
+			input: `fn f(a u64) u64 {
+	let b: u8 = 255
+	return a * 2
+}`,
+		},
+		{
+			name: "literal out of range",
+			input: `fn f() {
+	let b: u8 = 256
+}`,
+			errMsg: "constant 256 overflows u8",
+		},
+		{
+			name: "literal out of range of its own type",
+			input: `fn f() {
+	let b: i32 = 2147483648
+}`,
+			errMsg: "constant 2147483648 overflows i32",
+		},
+		{
+			name: "constant expression out of range",
+			input: `fn f() {
+	let b: i32 = 2147483647 + 1
+}`,
+			errMsg: "constant 2147483648 overflows i32",
+		},
+		{
+			name: "argument out of range",
+			input: `fn g(n i32) {}
+fn f() {
+	g(2147483648)
+}`,
+			errMsg: "constant 2147483648 overflows i32",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestSecureMemoryBuiltins(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "memzero_explicit takes a mutable borrow",
+			input: `fn f() {
+	let mut key: i32 = 42
+	memzero_explicit(&mut key)
+}`,
+		},
+		{
+			name: "memzero_explicit rejects a shared borrow",
+			input: `fn f() {
+	let key: i32 = 42
+	memzero_explicit(&key)
+}`,
+			errMsg: "argument 1 to memzero_explicit: expected &mut ?",
+		},
+		{
+			name: "ct_eq returns bool",
+			input: `fn f() bool {
+	return ct_eq("a", "b")
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "string literal is a str",
+			input: `fn f() {
+	let s: str = "hello"
+}`,
+		},
+		{
+			name: "str is viewed as its bytes",
+			input: `fn f(s str) bool {
+	let b: []u8 = s
+	return ct_eq(s, "abc")
+}`,
+		},
+		{
+			name: "bytes are not a str",
+			input: `fn f(b []u8) {
+	let s: str = b
+}`,
+			errMsg: "type mismatch: expected str, got []u8",
+		},
+		{
+			name: "len of a str",
+			input: `fn f(s str) usize {
+	return len(s)
+}`,
+		},
+		{
+			name: "str equality",
+			input: `fn f(a str, b str) bool {
+	return a == "x" || a != b
+}`,
+		},
+		{
+			name: "str ordering",
+			input: `fn f(a str, b str) bool {
+	return a < b || a >= "x"
+}`,
+		},
+		{
+			name: "str helpers",
+			input: `fn f(s str) i64 {
+	let head: str = str_slice(s, 0, str_index(s, ","))
+	let n: i64 = str_to_int(head)
+	return n + str_compare(str_from_int(n), head) as i64
+}`,
+		},
+		{
+			name: "path and fs builtins take and return str",
+			input: `fn f(dir str) bool {
+	let file: str = path_join(dir, "a.txt")
+	return fs_exists(file) && path_ext(file) == ".txt"
+}`,
+		},
+		{
+			name: "process builtins take a command handle",
+			input: `fn f(dir str) str {
+	let cmd: i32 = process_new("ls")
+	process_arg(cmd, "-a")
+	process_dir(cmd, dir)
+	if process_run(cmd) != 0 {
+		return process_stderr(cmd)
+	}
+	return process_stdout(cmd)
+}`,
+		},
+		{
+			name: "runtime introspection builtins",
+			input: `fn f() i64 {
+	let v: str = runtime_version()
+	return runtime_allocs() + runtime_alloc_bytes() + runtime_stack_size()
+}`,
+		},
+		{
+			name: "str concatenation",
+			input: `fn f(a str, b str) str {
+	let mut s = a + ", " + b
+	s += "!"
+	return s
+}`,
+		},
+		{
+			name: "no arithmetic on str",
+			input: `fn f(a str, b str) str {
+	return a - b
+}`,
+			errMsg: "operator - is not defined on str",
+		},
+		{
+			name: "no compound arithmetic on str",
+			input: `fn f(a str) {
+	let mut s = a
+	s *= 2
+}`,
+			errMsg: "operator * is not defined on str",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestDefer(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "defer a call",
+			input: `fn cleanup(n i32) {
+}
+
+fn f() {
+	let n = 1
+	defer cleanup(n)
+}`,
+		},
+		{
+			name: "defer something that is not a call",
+			input: `fn f() {
+	let n = 1
+	defer n + 1
+}`,
+			errMsg: "defer needs a function call, got (n + 1)",
+		},
+		{
+			name: "deferred arguments are checked",
+			input: `fn cleanup(n i32) {
+}
+
+fn f() {
+	defer cleanup(missing)
+}`,
+			errMsg: "undefined variable: missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestUnsafeEnforcement(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "dereference outside unsafe",
+			input: `fn f(p *i32) i32 {
+	return *p
+}`,
+			errMsg: "dereference of raw pointer requires an unsafe block",
+		},
+		{
+			name: "dereference inside unsafe",
+			input: `fn f(p *i32) i32 {
+	unsafe {
+		return *p
+	}
+}`,
+		},
+		{
+			name: "unsafe reaches nested statements",
+			input: `fn f(p *i32, ok bool) i32 {
+	unsafe {
+		while ok == true {
+			if ok == true {
+				return *(p + 1)
+			}
+		}
+	}
+	return 0
+}`,
+		},
+		{
+			name: "references need no unsafe",
+			input: `fn f(r &i32) i32 {
+	return *r
+}`,
+		},
+		{
+			name: "pointer arithmetic outside unsafe",
+			input: `fn f(p *i32) *i32 {
+	return p + 1
+}`,
+			errMsg: "pointer arithmetic requires an unsafe block",
+		},
+		{
+			name: "extern call outside unsafe",
+			input: `extern fn abs(x i32) i32
+
+fn f() i32 {
+	return abs(1)
+}`,
+			errMsg: "call to extern function abs requires an unsafe block",
+		},
+		{
+			name: "extern call inside unsafe",
+			input: `extern fn abs(x i32) i32
+
+fn f() i32 {
+	unsafe {
+		return abs(1)
+	}
+}`,
+		},
+		{
+			name: "nested function does not inherit unsafe",
+			input: `fn f(p *i32) {
+	unsafe {
+		fn g(q *i32) i32 {
+			return *q
+		}
+	}
+}`,
+			errMsg: "dereference of raw pointer requires an unsafe block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
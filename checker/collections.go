@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/types"
+)
+
+// collection returns the built-in Vec or Map that name still refers to,
+// rather than a type of the same name declared in the file
+func (c *Checker) collection(name string) (types.Type, bool) {
+	typ, _, ok := c.env.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+
+	switch typ.(type) {
+	case *types.VecType, *types.MapType:
+		return typ, true
+	default:
+		return nil, false
+	}
+}
+
+// instantiateCollection resolves Vec<T> or Map<K, V>
+func (c *Checker) instantiateCollection(t *ast.TypePath, base types.Type) types.Type {
+	args := make([]types.Type, len(t.Args))
+	for i, arg := range t.Args {
+		args[i] = c.resolveType(arg)
+	}
+
+	switch base.(type) {
+	case *types.VecType:
+		if len(args) != 1 {
+			c.errorf(diag.VecArity, len(args))
+			return base
+		}
+
+		return &types.VecType{Elem: args[0]}
+	default:
+		if len(args) != 2 {
+			c.errorf(diag.MapArity, len(args))
+			return base
+		}
+
+		if !types.IsMapKey(args[0]) {
+			c.errorf(diag.MapKey, args[0].String())
+		}
+
+		return &types.MapType{Key: args[0], Value: args[1]}
+	}
+}
+
+// checkCollectionPath types Vec::new() and Map::new(), which make an empty
+// collection whose element types are taken from where it is used. It
+// reports false if the path does not start with a built-in collection.
+func (c *Checker) checkCollectionPath(path *ast.PathExpr, call *ast.CallExpr) (types.Type, bool) {
+	if len(path.Segments) != 2 {
+		return nil, false
+	}
+
+	base, ok := c.collection(path.Segments[0])
+	if !ok {
+		return nil, false
+	}
+
+	var typ types.Type = &types.VecType{Elem: c.env.NewTypeVar()}
+	if _, ok := base.(*types.MapType); ok {
+		typ = &types.MapType{Key: c.env.NewTypeVar(), Value: c.env.NewTypeVar()}
+	}
+
+	if path.Segments[1] != "new" {
+		c.errorf(diag.NoAssocFunc, path.Segments[0], path.Segments[1])
+
+		for _, arg := range call.Args {
+			c.checkExpr(arg)
+		}
+
+		return typ, true
+	}
+
+	c.checkCallArgs(call, path.Segments[0]+"::new", &types.FuncType{Return: typ})
+	c.made = append(c.made, call)
+
+	return typ, true
+}
+
+// checkCollectionElems reports each Vec or Map the function being checked
+// makes whose element types nothing has fixed by the end of the function,
+// which could not be lowered
+func (c *Checker) checkCollectionElems() {
+	for _, call := range c.made {
+		leave := c.enter(call)
+
+		switch t := c.types[call].(type) {
+		case *types.VecType:
+			if containsTypeVar(t.Elem) {
+				c.errorf(diag.ElemUnknown, "Vec", "Vec<i32>", "Vec")
+			}
+		case *types.MapType:
+			if containsTypeVar(t.Key) || containsTypeVar(t.Value) {
+				c.errorf(diag.ElemUnknown, "Map", "Map<str, i32>", "Map")
+			}
+		}
+
+		leave()
+	}
+}
@@ -0,0 +1,220 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestVec(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "methods of an annotated Vec",
+			input: `fn sum(v &Vec<i64>) i64 {
+	let mut total: i64 = 0
+	let mut i: i64 = 0
+	while i < v.len() {
+		total = total + v.get(i)
+		i = i + 1
+	}
+	return total
+}
+
+fn main() {
+	let mut v: Vec<i64> = Vec::new()
+	v.push(1)
+	v.set(0, 2)
+	let x: i64 = v.pop()
+	let y: i64 = x + sum(&v)
+}`,
+		},
+		{
+			name: "elements must match",
+			input: `fn main() {
+	let mut v: Vec<i32> = Vec::new()
+	v.push("x")
+}`,
+			errMsg: "argument 1 to push: expected i32, got str",
+		},
+		{
+			name: "push needs a mutable Vec",
+			input: `fn main() {
+	let v: Vec<i32> = Vec::new()
+	v.push(1)
+}`,
+			errMsg: "cannot borrow immutable variable v as mutable",
+		},
+		{
+			name: "element type must be known",
+			input: `fn main() {
+	let mut v = Vec::new()
+	v.push(1)
+}`,
+			errMsg: "element types of the Vec are not known",
+		},
+		{
+			name: "element type of an unused Vec must be known",
+			input: `fn main() {
+	let v = Vec::new()
+}`,
+			errMsg: "element types of the Vec are not known",
+		},
+		{
+			name: "element type fixed by where the Vec goes",
+			input: `fn make() Vec<i32> {
+	return Vec::new()
+}
+
+fn main() {
+	let v: Vec<str> = Vec::new()
+	let w = make()
+}`,
+		},
+		{
+			name: "wrong number of type arguments",
+			input: `fn f(v Vec<i32, i32>) {
+}`,
+			errMsg: "Vec takes 1 type argument, got 2",
+		},
+		{
+			name: "unknown associated function",
+			input: `fn main() {
+	let v: Vec<i32> = Vec::with_capacity(4)
+}`,
+			errMsg: "Vec has no associated function with_capacity",
+		},
+		{
+			name:   "unknown method",
+			input:  `fn f(v Vec<i32>) { v.clear() }`,
+			errMsg: "no method clear",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "methods of an annotated Map",
+			input: `fn find(m &Map<str, i64>, k str) Option<i64> {
+	let v = m.get(k)?
+	return Some(v)
+}
+
+fn main() {
+	let mut m: Map<str, i64> = Map::new()
+	m.insert("a", 1)
+	let had: bool = m.contains("a")
+	let n: i64 = m.len()
+	let v: Option<i64> = m.remove("a")
+}`,
+		},
+		{
+			name: "a loop binds keys and values",
+			input: `fn total(m Map<i32, i64>) i64 {
+	let mut sum: i64 = 0
+	for k, v in m {
+		let key: i32 = k
+		sum = sum + v
+	}
+	return sum
+}`,
+		},
+		{
+			name: "values must match",
+			input: `fn main() {
+	let mut m: Map<i32, str> = Map::new()
+	m.insert(1, 2)
+}`,
+			errMsg: "argument 2 to insert: expected str, got i32",
+		},
+		{
+			name:   "keys must be integers or strs",
+			input:  `fn f(m Map<f64, i32>) {}`,
+			errMsg: "Map keys must be integers or str, not f64",
+		},
+		{
+			name:   "wrong number of type arguments",
+			input:  `fn f(m Map<i32>) {}`,
+			errMsg: "Map takes 2 type arguments, got 1",
+		},
+		{
+			name: "key and value types must be known",
+			input: `fn main() {
+	let mut m = Map::new()
+	m.insert(1, 2)
+}`,
+			errMsg: "element types of the Map are not known",
+		},
+		{
+			name: "key and value types of an unused Map must be known",
+			input: `fn main() {
+	let m = Map::new()
+}`,
+			errMsg: "element types of the Map are not known",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
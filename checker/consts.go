@@ -0,0 +1,173 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/consteval"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/types"
+)
+
+// checkConst checks a const declaration, at top level or in a block. The
+// value of an integer constant is computed now so that array lengths and
+// other constants can use it.
+func (c *Checker) checkConst(name string, typ ast.Type, value *ast.Expr) {
+	declared := c.resolveType(typ)
+	errs := len(c.errors)
+	valueType := c.checkExpr(*value)
+
+	// A literal that overflows the declared type is reported below, with
+	// the constant's name
+	if !overflows(*value, declared) && !c.coerce(value, valueType, declared) {
+		c.errorf(diag.ConstMismatch, name, declared.String(), valueType.String())
+	}
+
+	c.env.Define(name, declared, false)
+
+	// A value whose arithmetic was already found to fail has no value
+	if !types.IsInteger(declared) || len(c.errors) > errs {
+		return
+	}
+
+	v, err := c.constValue(*value)
+	if err != nil {
+		c.errorf(diag.ConstInvalid, name, err)
+		return
+	}
+
+	if !consteval.Fits(v, declared.String()) {
+		c.errorf(diag.ConstOverflow, name, v, declared.String())
+		return
+	}
+
+	if sym, ok := c.env.LookupSymbol(name); ok {
+		c.consts[sym] = v
+	}
+}
+
+// constValue evaluates an integer constant expression over the constants
+// in scope
+func (c *Checker) constValue(expr ast.Expr) (int64, error) {
+	return consteval.Eval(expr, func(name string) (int64, bool) {
+		sym, ok := c.env.LookupSymbol(name)
+		if !ok {
+			return 0, false
+		}
+
+		c.read(name)
+
+		v, ok := c.consts[sym]
+
+		return v, ok
+	})
+}
+
+// checkConstArith computes bin when both operands are constants, so that
+// dividing by zero or overflowing typ is reported now instead of trapping
+// or wrapping at run time. Operands made only of literals take their type
+// from where the result is used, and are checked against it there.
+func (c *Checker) checkConstArith(bin *ast.BinaryExpr, typ types.Type) {
+	right, err := c.constValue(bin.Right)
+	if err != nil {
+		return
+	}
+
+	if right == 0 && (bin.Op == "/" || bin.Op == "%") {
+		c.errorf(diag.DivisionByZero, bin.String())
+		return
+	}
+
+	left, err := c.constValue(bin.Left)
+	if err != nil {
+		return
+	}
+
+	// An operand whose own arithmetic overflows was reported when it was
+	// checked, and the result overflowing with it is the same mistake
+	if overflowed(bin.Left, left, typ) || overflowed(bin.Right, right, typ) {
+		return
+	}
+
+	v, err := c.constValue(bin)
+	if err != nil {
+		c.errorf(diag.ConstEval, bin.String(), err)
+		return
+	}
+
+	if _, err := consteval.Eval(bin, noConsts); err != nil && !consteval.Fits(v, typ.String()) {
+		c.errorf(diag.ArithmeticOverflow, bin.String(), typ.String(), v)
+	}
+}
+
+// overflowed reports whether operand is arithmetic whose value v does not
+// fit typ
+func overflowed(operand ast.Expr, v int64, typ types.Type) bool {
+	_, ok := operand.(*ast.BinaryExpr)
+	return ok && !consteval.Fits(v, typ.String())
+}
+
+// checkConstIndex rejects a constant index that is negative, or not below
+// length when the length is known (it is -1 for slices and strings)
+func (c *Checker) checkConstIndex(index *ast.IndexExpr, length int) {
+	v, err := c.constValue(index.Index)
+	if err != nil {
+		return
+	}
+
+	if v < 0 {
+		c.errorf(diag.NegativeIndex, v, index.String())
+		return
+	}
+
+	if length >= 0 && v >= int64(length) {
+		c.errorf(diag.IndexOutOfRange, v, index.String(), length)
+	}
+}
+
+func hasPayload(e *ast.EnumDecl) bool {
+	for _, variant := range e.Variants {
+		if len(variant.Types) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// discriminants numbers the variants of an enum without payloads. Variants
+// count up from the previous one, or from zero, unless given a value.
+func (c *Checker) discriminants(e *ast.EnumDecl) map[string]int64 {
+	if hasPayload(e) {
+		for _, v := range e.Variants {
+			if v.Value != nil {
+				c.errorf(diag.DiscriminantWithPayload, v.Name, e.Name, e.Name)
+			}
+		}
+
+		return nil
+	}
+
+	values := make(map[string]int64, len(e.Variants))
+	owner := make(map[int64]string, len(e.Variants))
+	next := int64(0)
+
+	for _, variant := range e.Variants {
+		if variant.Value != nil {
+			v, err := c.constValue(variant.Value)
+			if err != nil {
+				c.errorf(diag.InvalidDiscriminant, e.Name, variant.Name, err)
+			} else {
+				next = v
+			}
+		}
+
+		if prev, ok := owner[next]; ok {
+			c.errorf(diag.DuplicateDiscriminant, prev, variant.Name, e.Name, next)
+		}
+
+		values[variant.Name] = next
+		owner[next] = variant.Name
+		next++
+	}
+
+	return values
+}
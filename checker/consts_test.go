@@ -0,0 +1,190 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+	"github.com/yarlson/yarlang/types"
+)
+
+func TestConstExpressions(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "array length from constant",
+			input: `const KB: i32 = 1024
+const SIZE: i32 = 4 * KB
+
+struct Page { data: [u8; SIZE] }
+
+fn main() {}`,
+		},
+		{
+			name: "constant declared after its use",
+			input: `struct Page { data: [u8; SIZE / 2] }
+
+const SIZE: i32 = 4096
+
+fn main() {}`,
+		},
+		{
+			name: "block constant",
+			input: `fn main() {
+	const N: i32 = 3
+	struct S { a: [u8; N + 1] }
+}`,
+		},
+		{
+			name: "array length from variable",
+			input: `fn main() {
+	let n = 4
+	struct S { a: [u8; n] }
+}`,
+			shouldErr: true,
+			errMsg:    "array length must be a constant integer: n is not a constant",
+		},
+		{
+			name:      "negative array length",
+			input:     `const N: i32 = 2 - 3` + "\n" + `struct S { a: [u8; N] }`,
+			shouldErr: true,
+			errMsg:    "array length must be positive, got -1",
+		},
+		{
+			name:      "division by zero",
+			input:     `const N: i32 = 1 / 0`,
+			shouldErr: true,
+			errMsg:    "division by zero in (1 / 0)",
+		},
+		{
+			name: "division by a constant zero",
+			input: `fn f(x i32) i32 {
+	return x % (2 - 2)
+}`,
+			shouldErr: true,
+			errMsg:    "division by zero in (x % (2 - 2))",
+		},
+		{
+			name:      "arithmetic on constants overflows their type",
+			input:     `const A: u8 = 200` + "\n" + `const B: u8 = 100` + "\n" + `fn f() u8 { return A + B }`,
+			shouldErr: true,
+			errMsg:    "(A + B) overflows u8: the result is 300",
+		},
+		{
+			name:      "unsigned constant underflow",
+			input:     `const A: u8 = 200` + "\n" + `const B: u8 = 100` + "\n" + `const C: u8 = B - A`,
+			shouldErr: true,
+			errMsg:    "(B - A) overflows u8: the result is -100",
+		},
+		{
+			name:  "literal arithmetic takes the declared type",
+			input: `fn f() { let x: i64 = 3000000000 * 2 }`,
+		},
+		{
+			name:      "literal that defaults to i32 overflows",
+			input:     `fn f() { let x = 3000000000 }`,
+			shouldErr: true,
+			errMsg:    "constant 3000000000 overflows i32",
+		},
+		{
+			name:      "overflow of declared type",
+			input:     `const BIG: i32 = 1 << 40`,
+			shouldErr: true,
+			errMsg:    "const BIG: constant 1099511627776 overflows i32",
+		},
+		{
+			name:  "constant index in range",
+			input: `const LAST: i32 = 3` + "\n" + `fn f(a [i32; 4], s []u8) i32 { return a[LAST] + a[0] + (s[100] as i32) }`,
+		},
+		{
+			name:      "constant index past the end of an array",
+			input:     `const N: i32 = 4` + "\n" + `fn f(a [i32; N]) i32 { return a[N] }`,
+			shouldErr: true,
+			errMsg:    "index 4 in a[N] is out of range for length 4",
+		},
+		{
+			name:      "negative constant index",
+			input:     `fn f(s []u8) u8 { return s[1 - 2] }`,
+			shouldErr: true,
+			errMsg:    "index -1 in s[(1 - 2)] is negative",
+		},
+		{
+			name:      "assignment through an out of range index",
+			input:     `fn f(b [u8; 2]) { let mut a: [u8; 2] = b` + "\n" + `a[2] = 1 }`,
+			shouldErr: true,
+			errMsg:    "index 2 in a[2] is out of range for length 2",
+		},
+		{
+			name:  "variable index is checked at run time",
+			input: `fn f(a [i32; 4], i i32) i32 { return a[i + 10] }`,
+		},
+		{
+			name:  "discriminant from constant",
+			input: `const BASE: i32 = 100` + "\n" + `enum Level { Low = BASE, Mid, High = BASE * 2 }`,
+		},
+		{
+			name:      "duplicate discriminant",
+			input:     `enum Level { Low = 1, Mid = 0, High }`,
+			shouldErr: true,
+			errMsg:    "variants Low and High of Level have the same discriminant 1",
+		},
+		{
+			name:      "discriminant on payload enum",
+			input:     `enum Shape { Circle(i32) = 1, Dot }`,
+			shouldErr: true,
+			errMsg:    "variant Circle of Shape cannot have a discriminant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnumDiscriminants(t *testing.T) {
+	p := parser.New(lexer.New(`enum Level { Low = 1, Mid, High = 10, Max }`))
+	file := p.ParseFile()
+
+	c := NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	typ, _, _ := c.env.Lookup("Level")
+	got := typ.(*types.EnumType).Discriminants
+
+	want := map[string]int64{"Low": 1, "Mid": 2, "High": 10, "Max": 11}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("discriminant of %s: expected %d, got %d", name, v, got[name])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package checker
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden diagnostics files")
+
+// TestDiagnosticsGolden checks every program in testdata/diagnostics against
+// the diagnostics recorded next to it, so a change to what users see shows
+// up in review. Run with -update to record new output.
+func TestDiagnosticsGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "diagnostics", "*.yar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no programs in testdata/diagnostics")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".yar")
+
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := diagnostics(string(source))
+			golden := strings.TrimSuffix(file, ".yar") + ".golden"
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to record it)", err)
+			}
+
+			if got != string(want) {
+				t.Errorf("diagnostics changed\nwant:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+// diagnostics renders what yar check reports for source, one per line:
+// parser errors alone if there are any, else the checker's errors and
+// warnings
+func diagnostics(source string) string {
+	var b strings.Builder
+
+	p := parser.New(lexer.New(source))
+	file := p.ParseFile()
+
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		c := NewChecker()
+		_ = c.CheckFile(file)
+		diags = c.Diagnostics()
+	}
+
+	for _, d := range diags {
+		b.WriteString(goldenLine(d) + "\n")
+	}
+
+	return b.String()
+}
+
+// goldenLine renders d as line:column: severity[code]: message, leaving
+// out the parts d does not have
+func goldenLine(d diag.Diagnostic) string {
+	head := d.Severity.String()
+	if d.Code != "" {
+		head += "[" + string(d.Code) + "]"
+	}
+
+	if d.Line > 0 {
+		head = fmt.Sprintf("%d:%d: %s", d.Line, d.Column, head)
+	}
+
+	return head + ": " + d.Summary()
+}
+
+// TestSetLocale checks that a translated checker reports the same codes in
+// its own words
+func TestSetLocale(t *testing.T) {
+	src := "fn main() {\n\tx := 1\n\tprintln(y)\n}\n"
+
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	c := NewChecker()
+	c.SetLocale("de")
+	_ = c.CheckFile(file)
+
+	want := []string{"E0017: undefinierte Variable: y"}
+	if got := c.Errors(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors are %q, want %q", got, want)
+	}
+
+	want = []string{"Variable x wird nie verwendet"}
+	if got := c.Warnings(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings are %q, want %q", got, want)
+	}
+}
+
+// TestDiagnosticSpans checks that diagnostics point at the node they are
+// about, so the renderer can underline it
+func TestDiagnosticSpans(t *testing.T) {
+	src := "fn f(n i32) i32 {\n\treturn 1\n}\n\nfn main() {\n\tx := 1\n\tlet s: i32 = \"a\"\n\tprintln(y)\n\tprintln(f(1, 2))\n\treturn\n\tprintln(s)\n}\n"
+
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	c := NewChecker()
+	_ = c.CheckFile(file)
+
+	type span struct {
+		code                 diag.Code
+		line, column, length int
+	}
+
+	want := []span{
+		{diag.TypeMismatch, 7, 2, 16},
+		{diag.UndefinedVariable, 8, 10, 1},
+		{diag.ArgumentCount, 9, 10, 7},
+		{diag.UnreachableCode, 11, 2, 10},
+		{diag.UnusedVariable, 1, 1, 2}, // a parameter points at its function
+		{diag.UnusedVariable, 6, 2, 6},
+	}
+
+	var got []span
+	for _, d := range c.Diagnostics() {
+		got = append(got, span{d.Code, d.Line, d.Column, d.Length})
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics are at %v, want %v", got, want)
+	}
+}
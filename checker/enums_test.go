@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestEnumVariants(t *testing.T) {
+	const decls = `enum Color { Red, Green, Blue }
+enum Shape { Circle(i32), Rect(i32, i32) }
+enum Option<T> { Some(T), None }
+`
+
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "unit variant",
+			input: decls + `fn main() {
+	let c: Color = Color::Red
+}`,
+		},
+		{
+			name: "variant with payload",
+			input: decls + `fn main() {
+	let s: Shape = Shape::Rect(2, 3)
+}`,
+		},
+		{
+			name: "generic variant",
+			input: decls + `fn main() {
+	let a = Option::Some(5)
+	let b = Option::None
+}`,
+		},
+		{
+			name: "variant passed to function",
+			input: decls + `fn paint(c Color) {}
+fn main() {
+	paint(Color::Blue)
+}`,
+		},
+		{
+			name: "unknown variant",
+			input: decls + `fn main() {
+	let c = Color::Gren
+}`,
+			shouldErr: true,
+			errMsg:    "no variant Gren in enum Color; did you mean Green?",
+		},
+		{
+			name: "payload arity",
+			input: decls + `fn main() {
+	let s = Shape::Rect(2)
+}`,
+			shouldErr: true,
+			errMsg:    "wrong number of values for variant Shape::Rect: expected 2, got 1",
+		},
+		{
+			name: "payload variant used without values",
+			input: decls + `fn main() {
+	let s = Shape::Circle
+}`,
+			shouldErr: true,
+			errMsg:    "wrong number of values for variant Shape::Circle: expected 1, got 0",
+		},
+		{
+			name: "payload type",
+			input: decls + `fn main() {
+	let s = Shape::Circle(true)
+}`,
+			shouldErr: true,
+			errMsg:    "value 1 of Shape::Circle: expected i32, got bool",
+		},
+		{
+			name: "unit variant called",
+			input: decls + `fn main() {
+	let c = Color::Red(1)
+}`,
+			shouldErr: true,
+			errMsg:    "variant Color::Red has no payload and cannot be called",
+		},
+		{
+			name: "variant type mismatch",
+			input: decls + `fn main() {
+	let c: Shape = Color::Red
+}`,
+			shouldErr: true,
+			errMsg:    "type mismatch: expected Shape, got Color",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestFieldAccess(t *testing.T) {
+	const point = "struct Point { x: i32, y: i32 }\n"
+
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "read field",
+			input: point + `fn main() {
+	let p = Point{x: 1, y: 2}
+	let x: i32 = p.x
+}`,
+		},
+		{
+			name: "read field through reference",
+			input: point + `fn get(p &Point) i32 {
+	return p.y
+}
+fn main() {}`,
+		},
+		{
+			name: "nested field",
+			input: point + `struct Line { from: Point, to: Point }
+fn main() {
+	let l = Line{from: Point{x: 1, y: 2}, to: Point{x: 3, y: 4}}
+	let x: i32 = l.to.x
+}`,
+		},
+		{
+			name: "unknown field suggests a close name",
+			input: point + `fn main() {
+	let p = Point{x: 1, y: 2}
+	let n = p.z
+}`,
+			shouldErr: true,
+			errMsg:    "no field z on type Point; did you mean x?",
+		},
+		{
+			name: "unknown field with no close name",
+			input: `struct Account { balance: i32 }
+fn main() {
+	let a = Account{balance: 1}
+	let n = a.owner
+}`,
+			shouldErr: true,
+			errMsg:    "no field owner on type Account",
+		},
+		{
+			name: "field of a non-struct",
+			input: `fn main() {
+	let n = 5
+	let m = n.x
+}`,
+			shouldErr: true,
+			errMsg:    "type i32 has no field x",
+		},
+		{
+			name: "assign field of mutable variable",
+			input: point + `fn main() {
+	let mut p = Point{x: 1, y: 2}
+	p.x = 3
+}`,
+		},
+		{
+			name: "assign field of immutable variable",
+			input: point + `fn main() {
+	let p = Point{x: 1, y: 2}
+	p.x = 3
+}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to field of immutable variable: p.x",
+		},
+		{
+			name: "assign field through mutable reference",
+			input: point + `fn reset(p &mut Point) {
+	p.x = 0
+}
+fn main() {}`,
+		},
+		{
+			name: "assign field through shared reference",
+			input: point + `fn reset(p &Point) {
+	p.x = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to p.x through shared reference p",
+		},
+		{
+			name: "assign field of an element of an immutable array",
+			input: point + `fn reset(ps [Point; 2]) {
+	ps[0].x = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to field of immutable variable: ps[0].x",
+		},
+		{
+			name: "assign field of an element through a shared reference",
+			input: point + `fn reset(ps &[Point; 2]) {
+	ps[0].x = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to ps[0].x through shared reference ps",
+		},
+		{
+			name: "assign field of an element through a mutable reference",
+			input: point + `fn reset(ps &mut [Point; 2]) {
+	ps[0].x = 0
+}
+fn main() {}`,
+		},
+		{
+			name: "assign field of a dereferenced shared reference",
+			input: point + `fn reset(p &Point) {
+	(*p).x = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "through shared reference p",
+		},
+		{
+			name: "assign field of a dereferenced mutable reference",
+			input: point + `fn reset(p &mut Point) {
+	(*p).x = 0
+}
+fn main() {}`,
+		},
+		{
+			name: "assign field through a shared reference field",
+			input: point + `struct Holder { p: &Point }
+fn reset(h &mut Holder) {
+	h.p.x = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to h.p.x through shared reference h.p",
+		},
+		{
+			name: "assign through a dereferenced shared reference",
+			input: `fn reset(n &i32) {
+	*n = 0
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to *n through shared reference n",
+		},
+		{
+			name: "assign through a dereferenced mutable reference",
+			input: `fn reset(n &mut i32) {
+	*n = 0
+}
+fn main() {}`,
+		},
+		{
+			name: "assign field with wrong type",
+			input: point + `fn main() {
+	let mut p = Point{x: 1, y: 2}
+	p.x = true
+}`,
+			shouldErr: true,
+			errMsg:    "type mismatch: expected i32, got bool",
+		},
+		{
+			name: "assign field while borrowed",
+			input: point + `fn main() {
+	let mut p = Point{x: 1, y: 2}
+	let r = &p
+	p.x = 3
+	let s = r
+}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to p.x because p is borrowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	fields := []string{"balance", "owner", "id"}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"balnce", "balance"},
+		{"ownr", "owner"},
+		{"ix", "id"},
+		{"created", ""},
+		{"x", ""},
+	}
+
+	for _, tt := range tests {
+		if got := suggest(tt.name, fields); got != tt.expected {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
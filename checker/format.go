@@ -0,0 +1,64 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/format"
+	"github.com/yarlson/yarlang/types"
+)
+
+// checkFormat checks a call to println whose first argument is a format
+// string: a string literal in which each {} is replaced by the next of the
+// remaining arguments. It reports false for a call that prints its single
+// argument as it is, which is checked like any other.
+func (c *Checker) checkFormat(name string, fn *types.FuncType, call *ast.CallExpr) bool {
+	builtin, ok := c.env.Builtin(name)
+	if !ok || builtin != types.Type(fn) || name != "println" || len(call.Args) == 0 {
+		return false
+	}
+
+	lit, isLit := call.Args[0].(*ast.StringLit)
+	if len(call.Args) == 1 && (!isLit || format.Plain(lit.Value)) {
+		return false
+	}
+
+	for _, arg := range call.Args {
+		c.checkExpr(arg)
+	}
+
+	if !isLit {
+		c.errorf(diag.FormatNotLiteral)
+		return true
+	}
+
+	pieces, err := format.Split(lit.Value)
+	if err != nil {
+		c.errorf(diag.FormatInvalid, err)
+		return true
+	}
+
+	args := call.Args[1:]
+	if len(pieces)-1 != len(args) {
+		c.errorf(diag.FormatArgCount, len(pieces)-1, len(args))
+	}
+
+	// An argument whose type is open has been reported already
+	for _, arg := range args {
+		if t := c.types[arg]; !formattable(t) && !containsTypeVar(t) {
+			c.errorf(diag.FormatArgType, t.String())
+		}
+	}
+
+	return true
+}
+
+// formattable reports whether a {} placeholder can print a value of type t
+func formattable(t types.Type) bool {
+	if _, ok := t.(*types.StringType); ok {
+		return true
+	}
+
+	prim, ok := t.(*types.PrimitiveType)
+
+	return ok && (types.IsNumeric(t) || prim.Kind == types.Bool || prim.Kind == types.Char)
+}
@@ -0,0 +1,88 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "placeholders take primitives and strs",
+			input: `fn main() {
+	let x: u8 = 1
+	println("{} {} {} {}", x, 2.5, true, "s")
+}`,
+		},
+		{
+			name:  "escaped braces",
+			input: `fn main() { println("{{}}") }`,
+		},
+		{
+			name:   "too few arguments",
+			input:  `fn main() { println("{} {}", 1) }`,
+			errMsg: "format string has 2 placeholders but 1 arguments were given",
+		},
+		{
+			name:   "a placeholder with no argument",
+			input:  `fn main() { println("{}") }`,
+			errMsg: "format string has 1 placeholders but 0 arguments were given",
+		},
+		{
+			name:   "unmatched brace",
+			input:  `fn main() { println("{x}", 1) }`,
+			errMsg: "invalid format string: unclosed {",
+		},
+		{
+			name: "format must be a literal",
+			input: `fn main() {
+	let f = "{}"
+	println(f, 1)
+}`,
+			errMsg: "println with more than one argument needs a string literal as its format",
+		},
+		{
+			name: "argument that cannot be formatted",
+			input: `struct P { x: i32 }
+
+fn main() {
+	println("{}", P { x: 1 })
+}`,
+			errMsg: "cannot format P",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
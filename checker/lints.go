@@ -0,0 +1,146 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+)
+
+// Level is how a lint is reported
+type Level int
+
+const (
+	Allow Level = iota // not reported
+	Warn               // reported as a warning
+	Deny               // reported as an error
+)
+
+// lints maps every lint to its default level
+var lints = map[string]Level{
+	"unused_variables": Warn,
+	"unused_imports":   Warn,
+	"dead_code":        Warn,
+	"shadowing":        Allow,
+	"invalid_regex":    Deny,
+}
+
+// allLints names every lint at once, in attributes and on the command line
+const allLints = "warnings"
+
+// Lints returns the names of all lints, sorted
+func Lints() []string {
+	names := make([]string, 0, len(lints))
+	for name := range lints {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseLevel parses allow, warn or deny
+func ParseLevel(s string) (Level, bool) {
+	switch s {
+	case "allow":
+		return Allow, true
+	case "warn":
+		return Warn, true
+	case "deny":
+		return Deny, true
+	default:
+		return Allow, false
+	}
+}
+
+// SetLint sets the level of a lint, or of every lint for "warnings", for
+// the whole file. Attributes on items take precedence.
+func (c *Checker) SetLint(name string, level Level) error {
+	if name == allLints {
+		for lint := range lints {
+			c.levels[lint] = level
+		}
+
+		return nil
+	}
+
+	if _, ok := lints[name]; !ok {
+		return fmt.Errorf("unknown lint: %s", name)
+	}
+
+	c.levels[name] = level
+
+	return nil
+}
+
+// lint reports the message of code at the level the lint had where the
+// problem was found, and returns the diagnostic so the caller can add a
+// position or a fix. It returns nil if the lint is allowed.
+func (c *Checker) lint(level Level, name string, code diag.Code, args ...any) *diag.Diagnostic {
+	switch level {
+	case Warn:
+		c.warn(code, c.message(code, args...))
+		return &c.warnings[len(c.warnings)-1]
+	case Deny:
+		c.error(code, fmt.Sprintf("%s [deny %s]", c.message(code, args...), name))
+		return &c.errors[len(c.errors)-1]
+	default:
+		return nil
+	}
+}
+
+// withLints checks an item under the lint levels set by its #[allow],
+// #[warn] and #[deny] attributes
+func (c *Checker) withLints(attrs []*ast.Attribute, check func()) {
+	if len(attrs) == 0 {
+		check()
+		return
+	}
+
+	saved := c.levels
+
+	c.levels = make(map[string]Level, len(saved))
+	for name, level := range saved {
+		c.levels[name] = level
+	}
+
+	for _, attr := range attrs {
+		// #[inline] is a hint for the optimizer, not a lint level
+		if attr.Name == "inline" {
+			if len(attr.Args) > 0 {
+				c.errorf(diag.AttrTakesNoArgs, attr.String())
+			}
+
+			continue
+		}
+
+		level, ok := ParseLevel(attr.Name)
+		if !ok {
+			c.errorf(diag.UnknownAttr, attr.String())
+			continue
+		}
+
+		for _, name := range attr.Args {
+			if err := c.SetLint(name, level); err != nil {
+				c.errorf(diag.InvalidAttr, attr.String(), err)
+			}
+		}
+	}
+
+	check()
+
+	c.levels = saved
+}
+
+// summary is the first line of stmt, to name it in a diagnostic
+func summary(stmt ast.Stmt) string {
+	s := stmt.String()
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,252 @@
+package checker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestLintLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		flags    map[string]Level
+		warnings []string
+		errMsg   string
+	}{
+		{
+			name: "dead code warns by default",
+			input: `fn f() i32 {
+	return 1
+	println(2)
+}`,
+			warnings: []string{"unreachable code after return 1"},
+		},
+		{
+			name: "dead code after break",
+			input: `fn f() {
+	while true {
+		break
+		println(1)
+	}
+}`,
+			warnings: []string{"unreachable code after break"},
+		},
+		{
+			name: "allow on the function",
+			input: `#[allow(unused_variables)]
+fn f() {
+	let x = 1
+}`,
+		},
+		{
+			name: "allow applies to one function only",
+			input: `#[allow(unused_variables)]
+fn f() {
+	let x = 1
+}
+
+fn g() {
+	let y = 1
+}`,
+			warnings: []string{"variable y is never used"},
+		},
+		{
+			name: "deny on the function",
+			input: `#[deny(unused_variables)]
+fn f() {
+	let x = 1
+}`,
+			errMsg: "variable x is never used [deny unused_variables]",
+		},
+		{
+			name: "allow on an import",
+			input: `#[allow(unused_imports)]
+use std::io
+
+fn main() {}`,
+		},
+		{
+			name: "shadowing is allowed by default",
+			input: `fn f() i32 {
+	let x = 1
+	let x = x + 1
+	return x
+}`,
+		},
+		{
+			name: "shadowing warns when enabled",
+			input: `fn f() i32 {
+	let x = 1
+	let x = x + 1
+	return x
+}`,
+			flags:    map[string]Level{"shadowing": Warn},
+			warnings: []string{"variable x shadows variable x"},
+		},
+		{
+			name: "shadowing a parameter in a nested block",
+			input: `fn f(n i32) i32 {
+	if n > 0 {
+		let n = 0
+		return n
+	}
+	return n
+}`,
+			flags:    map[string]Level{"shadowing": Warn},
+			warnings: []string{"variable n shadows parameter n of f"},
+		},
+		{
+			name: "shadowing a function",
+			input: `fn g() {}
+
+fn f() i32 {
+	let g = 1
+	return g
+}`,
+			flags:    map[string]Level{"shadowing": Warn},
+			warnings: []string{"variable g shadows the earlier declaration of g"},
+		},
+		{
+			name: "underscore names may shadow",
+			input: `fn f() {
+	let _x = 1
+	let _x = 2
+}`,
+			flags: map[string]Level{"shadowing": Warn},
+		},
+		{
+			name: "deny all warnings",
+			input: `fn f() {
+	let x = 1
+}`,
+			flags:  map[string]Level{"warnings": Deny},
+			errMsg: "variable x is never used [deny unused_variables]",
+		},
+		{
+			name: "attribute overrides flag",
+			input: `#[allow(warnings)]
+fn f() {
+	let x = 1
+}`,
+			flags: map[string]Level{"warnings": Deny},
+		},
+		{
+			name: "valid regex literal",
+			input: `fn f() bool {
+	return regex_match("^[a-z]+(-[a-z]+)*$", "yar-lang")
+}`,
+		},
+		{
+			name: "invalid regex literal is denied",
+			input: `fn f() i32 {
+	return regex_find("(ab", "abc")
+}`,
+			errMsg: "invalid regex \"(ab\" in call to regex_find: missing closing ) [deny invalid_regex]",
+		},
+		{
+			name: "reversed range",
+			input: `fn f() bool {
+	return regex_match("[z-a]", "b")
+}`,
+			errMsg: "invalid regex \"[z-a]\" in call to regex_match: invalid character class range",
+		},
+		{
+			name: "repetition of nothing",
+			input: `fn f() bool {
+	return regex_match("*a", "a")
+}`,
+			errMsg: "invalid regex \"*a\" in call to regex_match: missing argument to repetition operator",
+		},
+		{
+			name: "extended regex constructs RE2 rejects",
+			input: `fn f() bool {
+	let a = regex_match("(a)\1", "aa")
+	let b = regex_match("[\]", "x")
+	let c = regex_match("a{1001}", "a")
+	let d = regex_match("ab)", "ab)")
+	return a && b && c && regex_match("\w+\b", "ab") && d
+}`,
+		},
+		{
+			name: "invalid regex can be allowed",
+			input: `#[allow(invalid_regex)]
+fn f() bool {
+	return regex_match("a[", "aa")
+}`,
+		},
+		{
+			name: "pattern in a variable is not checked",
+			input: `fn f(pattern str) bool {
+	return regex_match(pattern, "abc")
+}`,
+		},
+		{
+			name: "unknown lint",
+			input: `#[allow(unused_things)]
+fn f() {}`,
+			errMsg: "#[allow(unused_things)]: unknown lint: unused_things",
+		},
+		{
+			name: "unknown attribute",
+			input: `#[cold]
+fn f() {}`,
+			errMsg: "unknown attribute #[cold]",
+		},
+		{
+			name: "inline",
+			input: `#[inline]
+fn f() {}`,
+		},
+		{
+			name: "inline with arguments",
+			input: `#[inline(always)]
+fn f() {}`,
+			errMsg: "#[inline(always)] takes no arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			for name, level := range tt.flags {
+				if err := c.SetLint(name, level); err != nil {
+					t.Fatalf("SetLint: %v", err)
+				}
+			}
+
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+
+			if len(c.Warnings()) != 0 || len(tt.warnings) != 0 {
+				if !reflect.DeepEqual(c.Warnings(), tt.warnings) {
+					t.Errorf("wrong warnings. expected=%q, got=%q", tt.warnings, c.Warnings())
+				}
+			}
+		})
+	}
+}
+
+func TestSetLintUnknown(t *testing.T) {
+	if err := NewChecker().SetLint("nope", Deny); err == nil {
+		t.Error("expected an error for an unknown lint")
+	}
+}
@@ -0,0 +1,429 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestMethodResolution(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "method call with auto-ref",
+			input: `struct Point { x: i32, y: i32 }
+impl Point {
+	fn origin_distance(&self) i32 { return 0 }
+}
+fn main() {
+	let p = Point{x: 1, y: 2}
+	let d: i32 = p.origin_distance()
+}`,
+		},
+		{
+			name: "method declared after its use",
+			input: `struct Point { x: i32, y: i32 }
+fn main() {
+	let p = Point{x: 1, y: 2}
+	let d: i32 = p.scaled(2)
+}
+impl Point {
+	fn scaled(&self, k i32) i32 { return k }
+}`,
+		},
+		{
+			name: "methods call each other through self",
+			input: `struct Counter { n: i32 }
+impl Counter {
+	fn get(&self) i32 { return 1 }
+	fn twice(&self) i32 { return self.get() + self.get() }
+}
+fn main() {}`,
+		},
+		{
+			name: "wrong argument type",
+			input: `struct Point { x: i32, y: i32 }
+impl Point {
+	fn scaled(&self, k i32) i32 { return k }
+}
+fn main() {
+	let p = Point{x: 1, y: 2}
+	let d = p.scaled(true)
+}`,
+			shouldErr: true,
+			errMsg:    "argument 1 to scaled",
+		},
+		{
+			name: "unknown method",
+			input: `struct Point { x: i32, y: i32 }
+fn main() {
+	let p = Point{x: 1, y: 2}
+	p.missing()
+}`,
+			shouldErr: true,
+			errMsg:    "no method missing on type Point",
+		},
+		{
+			name: "&mut self on immutable binding",
+			input: `struct Counter { n: i32 }
+impl Counter {
+	fn bump(&mut self) {}
+}
+fn main() {
+	let c = Counter{n: 0}
+	c.bump()
+}`,
+			shouldErr: true,
+			errMsg:    "cannot borrow immutable variable c as mutable",
+		},
+		{
+			name: "&mut self on mutable binding",
+			input: `struct Counter { n: i32 }
+impl Counter {
+	fn bump(&mut self) {}
+}
+fn main() {
+	let mut c = Counter{n: 0}
+	c.bump()
+	c.bump()
+}`,
+		},
+		{
+			name: "&mut self through shared reference",
+			input: `struct Counter { n: i32 }
+impl Counter {
+	fn bump(&mut self) {}
+	fn peek(&self) { self.bump() }
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "behind a shared reference",
+		},
+		{
+			name: "&mut self while shared borrow is live",
+			input: `struct Counter { n: i32 }
+impl Counter {
+	fn bump(&mut self) {}
+}
+fn main() {
+	let mut c = Counter{n: 0}
+	let r = &c
+	c.bump()
+	let s = r
+}`,
+			shouldErr: true,
+			errMsg:    "already borrowed",
+		},
+		{
+			name: "duplicate method",
+			input: `struct Point { x: i32 }
+impl Point {
+	fn a(&self) {}
+	fn a(&self) {}
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "duplicate method a on Point",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTraitConformance(t *testing.T) {
+	const shape = `trait Shape {
+	fn area(&self) i32;
+	fn scale(&mut self, k i32);
+}
+struct Square { side: i32 }
+`
+
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "all methods implemented",
+			input: shape + `impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+	fn scale(&mut self, k i32) {}
+}
+fn main() {
+	let s = Square{side: 2}
+	let a: i32 = s.area()
+}`,
+		},
+		{
+			name: "missing method",
+			input: shape + `impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "impl Shape for Square: missing method fn scale(&mut self, i32) void",
+		},
+		{
+			name: "extra method",
+			input: shape + `impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+	fn scale(&mut self, k i32) {}
+	fn perimeter(&self) i32 { return 4 }
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "impl Shape for Square: method perimeter is not a member of trait Shape",
+		},
+		{
+			name: "wrong return type",
+			input: shape + `impl Shape for Square {
+	fn area(&self) bool { return true }
+	fn scale(&mut self, k i32) {}
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "method area has signature fn area(&self) bool, trait requires fn area(&self) i32",
+		},
+		{
+			name: "wrong parameter type",
+			input: shape + `impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+	fn scale(&mut self, k bool) {}
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "method scale has signature fn scale(&mut self, bool) void",
+		},
+		{
+			name: "wrong receiver",
+			input: shape + `impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+	fn scale(&self, k i32) {}
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "trait requires fn scale(&mut self, i32) void",
+		},
+		{
+			name: "generic trait parameter",
+			input: `trait Into<T> {
+	fn into(&self) T;
+}
+struct Meters { v: i32 }
+impl Into<i32> for Meters {
+	fn into(&self) i32 { return 1 }
+}
+fn main() {}`,
+		},
+		{
+			name: "Self is the implementing type",
+			input: `trait Twin {
+	fn dup(&self) Self;
+	fn same(&self, other &Self) bool;
+}
+struct Square { side: i32 }
+impl Twin for Square {
+	fn dup(&self) Self { return Square{side: self.side} }
+	fn same(&self, other &Square) bool { return true }
+}
+fn main() {}`,
+		},
+		{
+			name: "Self is not any type",
+			input: `trait Twin {
+	fn dup(&self) Self;
+}
+struct Square { side: i32 }
+impl Twin for Square {
+	fn dup(&self) i32 { return 1 }
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "method dup has signature fn dup(&self) i32, trait requires fn dup(&self) Square",
+		},
+		{
+			name: "impl of a non-trait",
+			input: `struct Square { side: i32 }
+struct Other { x: i32 }
+impl Other for Square {}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "impl Other for Square: Other is not a trait",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTraitObjects(t *testing.T) {
+	const shapes = `trait Shape {
+	fn area(&self) i32;
+	fn grow(&mut self);
+}
+struct Square { side: i32 }
+struct Circle { r: i32 }
+impl Shape for Square {
+	fn area(&self) i32 { return 1 }
+	fn grow(&mut self) {}
+}
+impl Shape for Circle {
+	fn area(&self) i32 { return 3 }
+	fn grow(&mut self) {}
+}
+struct Rock { w: i32 }
+`
+
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "references to different types coerce to &dyn",
+			input: shapes + `fn total(a &dyn Shape, b &dyn Shape) i32 {
+	return a.area() + b.area()
+}
+fn main() {
+	let s = Square{side: 2}
+	let c = Circle{r: 1}
+	let t: i32 = total(&s, &c)
+}`,
+		},
+		{
+			name: "let binding coerces to &dyn",
+			input: shapes + `fn main() {
+	let s = Square{side: 2}
+	let d: &dyn Shape = &s
+	let a: i32 = d.area()
+}`,
+		},
+		{
+			name: "type without impl",
+			input: shapes + `fn main() {
+	let r = Rock{w: 1}
+	let d: &dyn Shape = &r
+}`,
+			shouldErr: true,
+			errMsg:    "type mismatch: expected &dyn Shape, got &Rock",
+		},
+		{
+			name: "shared reference does not coerce to &mut dyn",
+			input: shapes + `fn main() {
+	let mut s = Square{side: 2}
+	let d: &mut dyn Shape = &s
+}`,
+			shouldErr: true,
+			errMsg:    "expected &mut dyn Shape, got &Square",
+		},
+		{
+			name: "&mut self method through &dyn",
+			input: shapes + `fn grow_all(a &dyn Shape) {
+	a.grow()
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "behind a shared reference",
+		},
+		{
+			name: "method not in trait",
+			input: shapes + `fn f(a &dyn Shape) {
+	a.perimeter()
+}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "no method perimeter on type &dyn Shape",
+		},
+		{
+			name: "dyn of a non-trait",
+			input: shapes + `fn f(a &dyn Rock) {}
+fn main() {}`,
+			shouldErr: true,
+			errMsg:    "Rock is not a trait",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/types"
+)
+
+// The functions of std::os are called by their full path, or by the name
+// std::os is imported under:
+//
+//	use std::os
+//
+//	fn main() {
+//	    let args = os::args()
+//	}
+
+// osFuncs are the signatures of the functions of std::os
+var osFuncs = map[string]*types.FuncType{
+	"args": {Return: &types.SliceType{Elem: &types.StringType{}}},
+	"env": {
+		Params: []types.Type{&types.StringType{}},
+		Return: &types.OptionType{Elem: &types.StringType{}},
+	},
+}
+
+// osPath returns the name of the function of std::os that path names, if
+// it names one
+func (c *Checker) osPath(path *ast.PathExpr) (string, bool) {
+	module, name, ok := c.stdPath(path)
+	return name, ok && module == "os"
+}
+
+// checkOSPath types a call of a function of std::os. It reports false if
+// the path does not name one.
+func (c *Checker) checkOSPath(path *ast.PathExpr, call *ast.CallExpr) (types.Type, bool) {
+	name, ok := c.osPath(path)
+	if !ok {
+		return nil, false
+	}
+
+	c.read(path.Segments[0])
+
+	fn, ok := osFuncs[name]
+	if !ok {
+		c.errorf(diag.OSNoFunc, name)
+
+		for _, arg := range call.Args {
+			c.checkExpr(arg)
+		}
+
+		return c.env.NewTypeVar(), true
+	}
+
+	c.checkCallArgs(call, "std::os::"+name, fn)
+
+	return fn.Return, true
+}
@@ -0,0 +1,81 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestOS(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "full paths",
+			input: `fn home() Option<str> {
+	let h = std::os::env("HOME")?
+	return Some(h)
+}
+
+fn main() {
+	let args: []str = std::os::args()
+	println(len(args))
+	let _ = home()
+}`,
+		},
+		{
+			name: "imported module",
+			input: `use std::os as system
+
+fn main() {
+	println(len(system::args()))
+}`,
+		},
+		{
+			name:   "unknown function",
+			input:  `fn main() { std::os::argv() }`,
+			errMsg: "std::os has no function argv",
+		},
+		{
+			name:   "env takes a str",
+			input:  `fn main() { std::os::env(1) }`,
+			errMsg: "argument 1 to std::os::env: expected str, got i32",
+		},
+		{
+			name:   "module not imported",
+			input:  `fn main() { os::args() }`,
+			errMsg: "undefined function: args",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -59,6 +59,158 @@ fn main() {
 			true,
 			"use of moved value",
 		},
+		{
+			// Passing a Move value to a function moves it
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() { let s = Point{x: 1, y: 2}; take(s); take(s) }`,
+			true,
+			"use of moved value",
+		},
+		{
+			// Passing a Copy value does not move it
+			`fn take(n i32) {}
+fn main() { let x = 1; take(x); take(x) }`,
+			false,
+			"",
+		},
+		{
+			// Passing a Move value as a parameter of generic type moves it too
+			`struct Point { x: i32, y: i32 }
+trait Sink<T> { fn put(&self, v T) }
+fn fill(k &dyn Sink, s Point) { k.put(s); k.put(s) }`,
+			true,
+			"use of moved value",
+		},
+		{
+			// Reassignment makes a moved variable usable again
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() { let mut s = Point{x: 1, y: 2}; take(s); s = Point{x: 3, y: 4}; take(s) }`,
+			false,
+			"",
+		},
+		{
+			// A move in one branch does not affect the other branch
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	if true {
+		take(s)
+	} else {
+		take(s)
+	}
+}`,
+			false,
+			"",
+		},
+		{
+			// A move in a branch that falls through is visible afterwards
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	if true {
+		take(s)
+	}
+	take(s)
+}`,
+			true,
+			"use of moved value",
+		},
+		{
+			// A move in a branch that returns is not visible afterwards
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	if true {
+		take(s)
+		return
+	}
+	take(s)
+}`,
+			false,
+			"",
+		},
+		{
+			// So is one in a branch that panics or loops forever
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	if true {
+		take(s)
+		panic("gone")
+	} else if false {
+		take(s)
+		while true {}
+	}
+	take(s)
+}`,
+			false,
+			"",
+		},
+		{
+			// A move in a while body is a use after move on the next iteration
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	let mut i = 0
+	while i < 2 {
+		take(s)
+		i += 1
+	}
+}`,
+			true,
+			"use of moved value: s, moved in the previous iteration of the loop",
+		},
+		{
+			// So is one in a for body
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	for i in 0..3 {
+		take(s)
+	}
+}`,
+			true,
+			"use of moved value: s, moved in the previous iteration of the loop",
+		},
+		{
+			// A body that assigns the variable before using it may move it
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let mut s = Point{x: 1, y: 2}
+	for i in 0..3 {
+		s = Point{x: 3, y: 4}
+		take(s)
+	}
+}`,
+			false,
+			"",
+		},
+		{
+			// As may one that leaves the loop after the move, or moves a
+			// variable declared in the body
+			`struct Point { x: i32, y: i32 }
+fn take(p Point) {}
+fn main() {
+	let s = Point{x: 1, y: 2}
+	while true {
+		let t = Point{x: 3, y: 4}
+		take(t)
+		take(s)
+		break
+	}
+}`,
+			false,
+			"",
+		},
 	}
 
 	for _, tt := range tests {
@@ -100,17 +252,67 @@ func TestBorrowChecking(t *testing.T) {
 			"",
 		},
 		{
-			// Exclusive borrow + shared borrow - ERROR
-			`fn main() { let mut x = 5; let a = &mut x; let b = &x }`,
+			// Exclusive borrow + shared borrow while the first is live - ERROR
+			`fn main() { let mut x = 5; let a = &mut x; let b = &x; let c = a }`,
 			true,
 			"cannot borrow",
 		},
 		{
-			// Multiple exclusive borrows - ERROR
-			`fn main() { let mut x = 5; let a = &mut x; let b = &mut x }`,
+			// Multiple exclusive borrows while the first is live - ERROR
+			`fn main() { let mut x = 5; let a = &mut x; let b = &mut x; let c = a }`,
 			true,
 			"cannot borrow",
 		},
+		{
+			// Shadowing creates a new variable; the old one stays borrowed - OK
+			`fn main() { let mut x = 5; let a = &mut x; let x = 6; let b = &x; let c = a }`,
+			false,
+			"",
+		},
+		{
+			// Borrowing the old variable while it is exclusively borrowed - ERROR
+			`fn main() { let mut x = 5; let a = &mut x; let x = &x; let c = a }`,
+			true,
+			"cannot borrow x as shared",
+		},
+		{
+			// Exclusive borrow is dead after its last use - OK
+			`fn main() { let mut x = 5; let a = &mut x; let y = a; let b = &x }`,
+			false,
+			"",
+		},
+		{
+			// Exclusive borrow that is never used ends immediately - OK
+			`fn main() { let mut x = 5; let a = &mut x; let b = &mut x; let c = b }`,
+			false,
+			"",
+		},
+		{
+			// Temporary borrows end with their statement - OK
+			`fn take(r &mut i32) {}
+fn main() { let mut x = 5; take(&mut x); take(&mut x) }`,
+			false,
+			"",
+		},
+		{
+			// Borrow copied into another reference stays live through it - ERROR
+			`fn main() { let mut x = 5; let a = &mut x; let b = a; let c = &x; let d = b }`,
+			true,
+			"cannot borrow",
+		},
+		{
+			// Borrow held by a reference in an inner block ends with the block - OK
+			`fn main() {
+	let mut x = 5
+	if true {
+		let a = &mut x
+		let y = a
+	}
+	let b = &x
+}`,
+			false,
+			"",
+		},
 	}
 
 	for _, tt := range tests {
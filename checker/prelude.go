@@ -0,0 +1,163 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/types"
+)
+
+// variants maps each variant of the prelude's Option and Result to its type
+var variants = map[string]string{
+	"Some": "Option",
+	"None": "Option",
+	"Ok":   "Result",
+	"Err":  "Result",
+}
+
+// prelude reports whether name still refers to the built-in Option or
+// Result, rather than a type of the same name declared in the file
+func (c *Checker) prelude(name string) bool {
+	typ, _, ok := c.env.Lookup(name)
+	if !ok {
+		return false
+	}
+
+	switch typ.(type) {
+	case *types.OptionType, *types.ResultType:
+		return true
+	default:
+		return false
+	}
+}
+
+// instantiatePrelude resolves Option<T> or Result<T, E>
+func (c *Checker) instantiatePrelude(t *ast.TypePath, base types.Type) types.Type {
+	args := make([]types.Type, len(t.Args))
+	for i, arg := range t.Args {
+		args[i] = c.resolveType(arg)
+	}
+
+	switch base.(type) {
+	case *types.OptionType:
+		if len(args) != 1 {
+			c.errorf(diag.OptionArity, len(args))
+			return base
+		}
+
+		return &types.OptionType{Elem: args[0]}
+	default:
+		if len(args) != 2 {
+			c.errorf(diag.ResultArity, len(args))
+			return base
+		}
+
+		return &types.ResultType{Ok: args[0], Err: args[1]}
+	}
+}
+
+// checkVariant types a call to Some, Ok or Err from its argument. The type
+// the variant does not mention is left open. It reports false if name is
+// not a prelude variant in scope.
+func (c *Checker) checkVariant(name string, call *ast.CallExpr) (types.Type, bool) {
+	owner, ok := variants[name]
+	if !ok || !c.prelude(owner) {
+		return nil, false
+	}
+
+	if _, _, shadowed := c.env.Lookup(name); shadowed {
+		return nil, false
+	}
+
+	if name == "None" {
+		c.errorf(diag.NoneNoPayload)
+		return &types.OptionType{Elem: c.env.NewTypeVar()}, true
+	}
+
+	if len(call.Args) != 1 {
+		c.errorf(diag.VariantTakesOne, name, len(call.Args))
+
+		for _, arg := range call.Args {
+			c.checkExpr(arg)
+		}
+
+		return c.env.NewTypeVar(), true
+	}
+
+	payload := c.checkExpr(call.Args[0])
+	c.moveIfOwned(call.Args[0], payload)
+
+	switch name {
+	case "Some":
+		return &types.OptionType{Elem: payload}, true
+	case "Ok":
+		return &types.ResultType{Ok: payload, Err: c.env.NewTypeVar()}, true
+	default:
+		return &types.ResultType{Ok: c.env.NewTypeVar(), Err: payload}, true
+	}
+}
+
+// checkPreludePath resolves Option::Some(x), Option::None, Result::Ok(x)
+// and Result::Err(e). call is nil when the path is not called.
+func (c *Checker) checkPreludePath(path *ast.PathExpr, call *ast.CallExpr) (types.Type, bool) {
+	if len(path.Segments) != 2 || !c.prelude(path.Segments[0]) {
+		return nil, false
+	}
+
+	owner, variant := path.Segments[0], path.Segments[1]
+	if variants[variant] != owner {
+		c.errorf(diag.PreludeNoVariant, owner, variant)
+		return c.env.NewTypeVar(), true
+	}
+
+	if variant == "None" {
+		if call != nil {
+			c.errorf(diag.NoneNoPayload)
+		}
+
+		return &types.OptionType{Elem: c.env.NewTypeVar()}, true
+	}
+
+	if call == nil {
+		c.errorf(diag.VariantNeedsPayload, owner, variant)
+		return c.env.NewTypeVar(), true
+	}
+
+	return c.checkVariant(variant, call)
+}
+
+// checkPropagateExpr types x?. On a Result it yields the Ok value and
+// returns the Err from the enclosing function, which must return a Result
+// that can hold it; on an Option it yields the Some value and returns None
+// from a function returning an Option.
+func (c *Checker) checkPropagateExpr(expr *ast.PropagateExpr) types.Type {
+	inner := c.checkExpr(expr.Expr)
+
+	ret := "void"
+	if c.ret != nil {
+		ret = c.ret.String()
+	}
+
+	switch t := inner.(type) {
+	case *types.ResultType:
+		fnResult, ok := c.ret.(*types.ResultType)
+		if !ok {
+			c.errorf(diag.PropagateNeedsResult, inner.String(), ret)
+			return t.Ok
+		}
+
+		if !types.Instantiates(t.Err, fnResult.Err) {
+			c.errorf(diag.PropagateErrType, t.Err.String(), ret)
+		}
+
+		return t.Ok
+	case *types.OptionType:
+		if _, ok := c.ret.(*types.OptionType); !ok {
+			c.errorf(diag.PropagateNeedsOption, inner.String(), ret)
+		}
+
+		return t.Elem
+	default:
+		c.errorf(diag.PropagateOperand, inner.String())
+		return c.env.NewTypeVar()
+	}
+}
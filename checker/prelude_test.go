@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name: "Ok and Err build a Result",
+			input: `fn parse(x i32) Result<i32, str> {
+	if x < 0 {
+		return Err("negative")
+	}
+	return Ok(x)
+}`,
+		},
+		{
+			name: "Some and None build an Option",
+			input: `fn first(x i32) Option<i32> {
+	if x == 0 {
+		return None
+	}
+	return Option::Some(x)
+}`,
+		},
+		{
+			name: "payload must match",
+			input: `fn f() Result<i32, str> {
+	return Ok("x")
+}`,
+			errMsg: "return type mismatch",
+		},
+		{
+			name: "? yields the Ok value",
+			input: `fn parse(x i32) Result<i32, str> {
+	return Ok(x)
+}
+
+fn twice(x i32) Result<i32, str> {
+	let v: i32 = parse(x)?
+	return Ok(v * 2)
+}`,
+		},
+		{
+			name: "? yields the Some value",
+			input: `fn first(x i32) Option<i32> {
+	return Some(x)
+}
+
+fn next(x i32) Option<i32> {
+	let v: i32 = first(x)?
+	return Some(v + 1)
+}`,
+		},
+		{
+			name: "? outside a function returning Result",
+			input: `fn parse(x i32) Result<i32, str> {
+	return Ok(x)
+}
+
+fn f(x i32) i32 {
+	return parse(x)?
+}`,
+			errMsg: "? on Result<i32, str> needs the function to return a Result, but it returns i32",
+		},
+		{
+			name: "? with a different error type",
+			input: `fn parse(x i32) Result<i32, str> {
+	return Ok(x)
+}
+
+fn f(x i32) Result<i32, i32> {
+	return Ok(parse(x)?)
+}`,
+			errMsg: "? cannot return error type str from a function returning Result<i32, i32>",
+		},
+		{
+			name: "? on a plain value",
+			input: `fn f(x i32) Option<i32> {
+	return Some(x?)
+}`,
+			errMsg: "? needs a Result or an Option, got i32",
+		},
+		{
+			name: "wrong number of type arguments",
+			input: `fn f() Option<i32, i32> {
+	return None
+}`,
+			errMsg: "Option takes 1 type argument, got 2",
+		},
+		{
+			name: "unknown variant",
+			input: `fn f() Option<i32> {
+	return Option::Ok(1)
+}`,
+			errMsg: "Option has no variant Ok",
+		},
+		{
+			name: "a declared enum shadows the prelude",
+			input: `enum Option { Some(i32), Nothing }
+
+fn f() Option {
+	return Option::Nothing
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+)
+
+// checkRecursiveTypes reports structs and enums that contain themselves by
+// value, directly or through other types. Such a type has no finite size;
+// the cycle has to go through a pointer or reference.
+func (c *Checker) checkRecursiveTypes(items []ast.Decl) {
+	// Types declared in the file, in source order, and the types each one
+	// contains by value
+	var order []string
+
+	declared := make(map[string]ast.Decl)
+	contains := make(map[string][]string)
+
+	for _, decl := range items {
+		switch d := decl.(type) {
+		case *ast.StructDecl:
+			order = append(order, d.Name)
+			declared[d.Name] = d
+
+			for _, field := range d.Fields {
+				contains[d.Name] = valueTypes(field.Type, contains[d.Name])
+			}
+		case *ast.EnumDecl:
+			order = append(order, d.Name)
+			declared[d.Name] = d
+
+			for _, variant := range d.Variants {
+				for _, typ := range variant.Types {
+					contains[d.Name] = valueTypes(typ, contains[d.Name])
+				}
+			}
+		}
+	}
+
+	done := make(map[string]bool)
+	reported := make(map[string]bool)
+
+	var (
+		path  []string
+		visit func(name string)
+	)
+
+	visit = func(name string) {
+		for i, n := range path {
+			if n != name {
+				continue
+			}
+
+			cycle := append(append([]string{}, path[i:]...), name)
+			if !reported[name] {
+				leave := c.enter(declared[name])
+				c.errorf(diag.InfiniteSize, name, strings.Join(cycle, " -> "))
+				leave()
+			}
+
+			for _, n := range path[i:] {
+				reported[n] = true
+			}
+
+			return
+		}
+
+		if done[name] {
+			return
+		}
+
+		path = append(path, name)
+		for _, next := range contains[name] {
+			if declared[next] != nil {
+				visit(next)
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[name] = true
+	}
+
+	for _, name := range order {
+		visit(name)
+	}
+}
+
+// valueTypes appends the names of the types that typ holds by value.
+// Pointers, references and slices hold their element indirectly, and
+// generic arguments are not followed.
+func valueTypes(typ ast.Type, names []string) []string {
+	switch t := typ.(type) {
+	case *ast.TypePath:
+		return append(names, t.Path[len(t.Path)-1])
+	case *ast.ArrayType:
+		return valueTypes(t.Elem, names)
+	case *ast.TupleType:
+		for _, elem := range t.Elems {
+			names = valueTypes(elem, names)
+		}
+	}
+
+	return names
+}
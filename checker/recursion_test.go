@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestRecursiveTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{
+			name:   "struct contains itself",
+			input:  `struct Node { value: i32, next: Node }`,
+			errMsg: "recursive type Node has infinite size: Node -> Node",
+		},
+		{
+			name: "mutual recursion",
+			input: `struct A { b: B }
+struct B { c: (i32, C) }
+struct C { a: [A; 2] }`,
+			errMsg: "recursive type A has infinite size: A -> B -> C -> A",
+		},
+		{
+			name:   "enum contains itself",
+			input:  `enum List { Cons(i32, List), Nil }`,
+			errMsg: "recursive type List has infinite size: List -> List",
+		},
+		{
+			name:  "pointer breaks the cycle",
+			input: `struct Node { value: i32, next: *Node }`,
+		},
+		{
+			name:  "reference breaks the cycle",
+			input: `enum Tree { Leaf(i32), Branch(&Tree, &Tree) }`,
+		},
+		{
+			name:  "slice breaks the cycle",
+			input: `struct Dir { children: []Dir }`,
+		},
+		{
+			name: "shared type is not a cycle",
+			input: `struct Point { x: i32, y: i32 }
+struct Line { from: Point, to: Point }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+
+			if strings.Count(err.Error(), "recursive type") != 1 {
+				t.Errorf("expected the cycle to be reported once, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package checker
+
+import (
+	"regexp/syntax"
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+)
+
+// regexBuiltins are the builtins whose first argument is a pattern
+var regexBuiltins = map[string]bool{
+	"regex_match":   true,
+	"regex_find":    true,
+	"regex_replace": true,
+}
+
+// The runtime compiles patterns with regcomp as POSIX extended regular
+// expressions, which Go cannot call. They are parsed with RE2 instead,
+// after rewriting the backslashes the two read differently, and only the
+// errors regcomp reports for the same patterns are trusted; RE2 rejects
+// some patterns regcomp accepts, such as a{1001} and a lone ).
+
+// sharedErrors are the errors RE2 reports only for patterns regcomp also
+// rejects
+var sharedErrors = map[syntax.ErrorCode]bool{
+	syntax.ErrMissingParen:          true,
+	syntax.ErrMissingBracket:        true,
+	syntax.ErrInvalidCharRange:      true,
+	syntax.ErrTrailingBackslash:     true,
+	syntax.ErrMissingRepeatArgument: true,
+}
+
+// checkRegexLiteral reports a literal pattern that the runtime would reject,
+// so the mistake is caught before the program runs
+func (c *Checker) checkRegexLiteral(name string, call *ast.CallExpr) {
+	if !regexBuiltins[name] || len(call.Args) == 0 {
+		return
+	}
+
+	lit, ok := call.Args[0].(*ast.StringLit)
+	if !ok {
+		return
+	}
+
+	_, err := syntax.Parse(ereToRE2(lit.Value), syntax.POSIX)
+	if serr, ok := err.(*syntax.Error); ok && sharedErrors[serr.Code] {
+		c.lint(c.levels["invalid_regex"], "invalid_regex", diag.InvalidRegex, lit.Value, name, serr.Code)
+	}
+}
+
+// ereToRE2 rewrites the backslashes of a POSIX extended pattern for RE2:
+// one in a bracket expression stands for itself, and one before a letter or
+// digit is a back-reference or a GNU operator such as \w, which RE2 does
+// not know, so the pair becomes a plain letter
+func ereToRE2(p string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == '[':
+			end := bracketEnd(p, i)
+			b.WriteString(strings.ReplaceAll(p[i:end], `\`, `\\`))
+			i = end - 1
+		case p[i] == '\\' && i+1 < len(p) && isAlnum(p[i+1]):
+			b.WriteByte('x')
+			i++
+		case p[i] == '\\' && i+1 < len(p):
+			b.WriteString(p[i : i+2])
+			i++
+		default:
+			b.WriteByte(p[i])
+		}
+	}
+
+	return b.String()
+}
+
+// bracketEnd returns the offset just past the bracket expression that
+// starts at p[start], or len(p) when it is not closed. A ] right after the
+// [ or [^ is a member, as is anything in [:class:], [.coll.] or [=equiv=].
+func bracketEnd(p string, start int) int {
+	i := start + 1
+	if i < len(p) && p[i] == '^' {
+		i++
+	}
+
+	if i < len(p) && p[i] == ']' {
+		i++
+	}
+
+	for i < len(p) {
+		switch {
+		case p[i] == ']':
+			return i + 1
+		case p[i] == '[' && i+1 < len(p) && strings.IndexByte(":.=", p[i+1]) >= 0:
+			closing := string(p[i+1]) + "]"
+			if n := strings.Index(p[i+2:], closing); n >= 0 {
+				i += 2 + n + 2
+				continue
+			}
+
+			i++
+		default:
+			i++
+		}
+	}
+
+	return len(p)
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
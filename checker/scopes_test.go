@@ -0,0 +1,170 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestLocalDeclarations(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "nested function",
+			input: `fn main() {
+	fn sq(x i32) i32 {
+		return x * x
+	}
+	let y: i32 = sq(3)
+}`,
+		},
+		{
+			name: "recursive nested function",
+			input: `fn main() {
+	fn fact(n i32) i32 {
+		if n < 2 {
+			return 1
+		}
+		return n * fact(n - 1)
+	}
+	let y = fact(5)
+}`,
+		},
+		{
+			name: "nested function reads enclosing local",
+			input: `fn main() {
+	let base = 10
+	fn add(x i32) i32 {
+		return base + x
+	}
+	let y = add(1)
+}`,
+		},
+		{
+			name: "nested function assigns enclosing local",
+			input: `fn main() {
+	let mut count = 0
+	fn bump() {
+		count = count + 1
+	}
+}`,
+			shouldErr: true,
+			errMsg:    "cannot assign to captured variable: count",
+		},
+		{
+			name: "nested function checks its own returns",
+			input: `fn main() {
+	fn f(n i32) i32 {
+		let x = n
+	}
+}`,
+			shouldErr: true,
+			errMsg:    "missing return at end of function f",
+		},
+		{
+			name: "local struct",
+			input: `fn main() {
+	struct Point { x: i32, y: i32 }
+	let p = Point { x: 1, y: 2 }
+	let x: i32 = p.x
+}`,
+		},
+		{
+			name: "nested function out of scope",
+			input: `fn main() {
+	{
+		fn inner() i32 {
+			return 1
+		}
+	}
+	let y = inner()
+}`,
+			shouldErr: true,
+			errMsg:    "inner",
+		},
+		{
+			name: "local struct out of scope",
+			input: `fn f() {
+	struct Point { x: i32 }
+}
+
+fn g() {
+	let p: Point = Point { x: 1 }
+}`,
+			shouldErr: true,
+			errMsg:    "Point",
+		},
+		{
+			name: "range loop variable",
+			input: `fn main() {
+	let mut total: i32 = 0
+	for i in 0..10 {
+		total = total + i
+	}
+}`,
+		},
+		{
+			name: "loop variable out of scope",
+			input: `fn main() {
+	for i in 0..10 {
+	}
+	let y = i
+}`,
+			shouldErr: true,
+			errMsg:    "undefined variable: i",
+		},
+		{
+			name: "non-integer range",
+			input: `fn main() {
+	for i in true..false {
+	}
+}`,
+			shouldErr: true,
+			errMsg:    "range bounds must be integers, got bool",
+		},
+		{
+			name: "block local out of scope",
+			input: `fn main() {
+	{
+		let x = 1
+	}
+	let y = x
+}`,
+			shouldErr: true,
+			errMsg:    "undefined variable: x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			err := c.CheckFile(file)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q", tt.errMsg)
+				}
+
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package checker
+
+import (
+	"github.com/yarlson/yarlang/ast"
+)
+
+// A function f of a standard library module written in YarLang is added to
+// the file as std::module::f when the file imports the module, and is
+// called as std::module::f or, through the import, as module::f.
+
+// stdPath splits path into a module of the standard library and a name in
+// it, if path is std::module::name, or alias::name where alias is what
+// use std::module imports the module as
+func (c *Checker) stdPath(path *ast.PathExpr) (string, string, bool) {
+	segs := path.Segments
+
+	switch {
+	case len(segs) == 3 && segs[0] == "std":
+		return segs[1], segs[2], true
+	case len(segs) == 2:
+		imp, ok := c.imports[segs[0]]
+		if ok && len(imp.decl.Path) == 2 && imp.decl.Path[0] == "std" {
+			return imp.decl.Path[1], segs[1], true
+		}
+	}
+
+	return "", "", false
+}
+
+// stdFunc returns the name of the standard library function path calls,
+// if its module has been loaded into the file
+func (c *Checker) stdFunc(path *ast.PathExpr) (string, bool) {
+	module, name, ok := c.stdPath(path)
+	if !ok {
+		return "", false
+	}
+
+	qualified := "std::" + module + "::" + name
+	if _, _, ok := c.env.Lookup(qualified); !ok {
+		return "", false
+	}
+
+	return qualified, true
+}
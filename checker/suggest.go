@@ -0,0 +1,46 @@
+package checker
+
+import "sort"
+
+// suggest returns the candidate closest to name, or "" if none is close
+// enough to be a plausible typo
+func suggest(name string, candidates []string) string {
+	sort.Strings(candidates)
+
+	best := ""
+	bestDist := len(name)/3 + 1
+
+	for _, cand := range candidates {
+		if d := editDistance(name, cand); d <= bestDist && (best == "" || d < editDistance(name, best)) {
+			best = cand
+		}
+	}
+
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
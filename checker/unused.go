@@ -0,0 +1,138 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/types"
+)
+
+// Diagnostics returns the errors followed by the warnings of the last check
+func (c *Checker) Diagnostics() []diag.Diagnostic {
+	return append(append([]diag.Diagnostic{}, c.errors...), c.warnings...)
+}
+
+// Warnings returns the warnings of the last check
+func (c *Checker) Warnings() []string {
+	out := make([]string, len(c.warnings))
+	for i, d := range c.warnings {
+		out[i] = d.Summary()
+	}
+
+	return out
+}
+
+// warn records msg as the warning of code
+func (c *Checker) warn(code diag.Code, msg string) {
+	c.warnings = append(c.warnings, diag.Diagnostic{
+		Severity: diag.SeverityWarning,
+		Code:     code,
+		Message:  msg,
+		Line:     c.at.Line,
+		Column:   c.at.Column,
+		Length:   c.at.Length,
+	})
+}
+
+// binding is a variable or parameter that should be read at least once
+type binding struct {
+	sym   *types.Symbol
+	desc  string   // How the warning names it, e.g. "parameter n of f"
+	level Level    // Level of unused_variables where it was declared
+	at    ast.Span // Where it was declared
+}
+
+// bind defines a local and tracks whether it is read. Names starting with
+// an underscore opt out.
+func (c *Checker) bind(name string, typ types.Type, mut bool, desc string) {
+	c.env.Define(name, typ, mut)
+
+	if strings.HasPrefix(name, "_") {
+		return
+	}
+
+	sym, ok := c.env.LookupSymbol(name)
+	if !ok {
+		return
+	}
+
+	if sym.Shadows != nil {
+		c.lint(c.levels["shadowing"], "shadowing", diag.Shadowing, desc, c.describe(sym.Shadows))
+	}
+
+	c.bindings = append(c.bindings, binding{sym: sym, desc: desc, level: c.levels["unused_variables"], at: c.at})
+}
+
+// describe names sym the way diagnostics do
+func (c *Checker) describe(sym *types.Symbol) string {
+	for _, b := range c.bindings {
+		if b.sym == sym {
+			return b.desc
+		}
+	}
+
+	return c.message(diag.DescEarlier, sym.Name)
+}
+
+// read records a use of name, as a variable or as the first segment of a
+// path naming an imported module
+func (c *Checker) read(name string) {
+	if sym, ok := c.env.LookupSymbol(name); ok {
+		c.used[sym] = true
+	}
+
+	if imp, ok := c.imports[name]; ok {
+		imp.used = true
+	}
+}
+
+// use is an imported module and whether anything refers to it
+type use struct {
+	decl  *ast.UseDecl
+	used  bool
+	level Level // Level of unused_imports at the use declaration
+}
+
+func (c *Checker) checkUseDecl(decl *ast.UseDecl) {
+	name := decl.Alias
+	if name == "" {
+		name = decl.Path[len(decl.Path)-1]
+	}
+
+	if _, ok := c.imports[name]; ok {
+		c.errorf(diag.DuplicateImport, name)
+		return
+	}
+
+	c.imports[name] = &use{decl: decl, level: c.levels["unused_imports"]}
+	c.importOrder = append(c.importOrder, name)
+}
+
+// warnUnused reports the bindings and imports that were never read
+func (c *Checker) warnUnused() {
+	for _, b := range c.bindings {
+		if !c.used[b.sym] {
+			c.at = b.at
+			c.lint(b.level, "unused_variables", diag.UnusedVariable, b.desc)
+		}
+	}
+
+	c.at = ast.Span{}
+
+	for _, name := range c.importOrder {
+		imp := c.imports[name]
+		if imp.used || strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		d := c.lint(imp.level, "unused_imports", diag.UnusedImport, imp.decl.String())
+		if d != nil && imp.decl.Line > 0 {
+			d.Line, d.Column, d.Length = imp.decl.Line, imp.decl.Column, len(imp.decl.String())
+			d.Fix = &diag.Fix{
+				Message: c.message(diag.FixRemoveImport),
+				Edits:   []diag.Edit{{Line: imp.decl.Line, Old: imp.decl.String()}},
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestUnusedWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "all used",
+			input: `fn add(a i32, b i32) i32 {
+	let sum = a + b
+	return sum
+}`,
+		},
+		{
+			name: "unused variable",
+			input: `fn main() {
+	let x = 1
+}`,
+			want: []string{"variable x is never used"},
+		},
+		{
+			name: "assignment is not a read",
+			input: `fn main() {
+	let mut x = 1
+	x = 2
+}`,
+			want: []string{"variable x is never used"},
+		},
+		{
+			name: "assignment through a reference reads it",
+			input: `fn main() {
+	let mut x = 1
+	let r = &mut x
+	*r = 9
+	println(x)
+}`,
+		},
+		{
+			name: "unused parameter",
+			input: `fn first(a i32, b i32) i32 {
+	return a
+}`,
+			want: []string{"parameter b of first is never used"},
+		},
+		{
+			name: "underscore opts out",
+			input: `fn first(a i32, _b i32) i32 {
+	let _tmp = 1
+	return a
+}`,
+		},
+		{
+			name: "unused tuple element",
+			input: `fn pair() (i32, i32) {
+	return (1, 2)
+}
+
+fn main() {
+	let (a, b) = pair()
+	println(a)
+}`,
+			want: []string{"variable b is never used"},
+		},
+		{
+			name: "read by nested function",
+			input: `fn main() {
+	let base = 1
+	fn get() i32 {
+		return base
+	}
+	println(get())
+}`,
+		},
+		{
+			name: "unused import",
+			input: `use std::io
+use std::fmt as f
+
+fn main() {
+	f::println_all(1)
+}`,
+			want: []string{"unused import: use std::io"},
+		},
+		{
+			name: "used import",
+			input: `use std::fmt
+
+fn main() {
+	fmt::println_all(1)
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := parser.New(l)
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := NewChecker()
+			_ = c.CheckFile(file)
+
+			got := c.Warnings()
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("warnings = %q, want %q", got, tt.want)
+			}
+
+			for _, d := range c.Diagnostics() {
+				if d.Severity == diag.SeverityWarning && !contains(tt.want, d.Message) {
+					t.Errorf("unexpected warning diagnostic %q", d.Message)
+				}
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestUnusedImportFix(t *testing.T) {
+	src := "use std::fmt\n\nfn main() {\n\tprintln(1)\n}\n"
+
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	c := NewChecker()
+	_ = c.CheckFile(file)
+
+	diags := c.Diagnostics()
+	if len(diags) != 1 || diags[0].Fix == nil {
+		t.Fatalf("want one diagnostic with a fix, got %+v", diags)
+	}
+
+	fixed, n := diag.ApplyFixes([]byte(src), diags)
+	if want := "\nfn main() {\n\tprintln(1)\n}\n"; n != 1 || string(fixed) != want {
+		t.Errorf("fixed %d:\n%q, want %q", n, fixed, want)
+	}
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/yarlson/yarlang/checker"
 	"github.com/yarlson/yarlang/codegen"
-	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/diag"
 	"github.com/yarlson/yarlang/mir"
-	"github.com/yarlson/yarlang/parser"
 	runtimec "github.com/yarlson/yarlang/runtime"
 )
 
@@ -39,13 +42,95 @@ func materializeRuntime() (string, func(), error) {
 	return tmp.Name(), cleanup, nil
 }
 
-func handleBuild(args []string) {
-	if len(args) < 1 {
+// buildOptions holds the flags shared by build, run and check
+type buildOptions struct {
+	debugLower   debugFilter
+	debugCodegen debugFilter
+	lints        *lintFlags
+	emit         string // stage to stop after, or empty to build an executable
+	mem2reg      bool
+	overflow     bool // panic on integer overflow instead of wrapping
+	optLevel     int
+	passes       string // MIR pipeline replacing the one of optLevel
+	timePasses   bool
+	color        *colorFlag
+	fix          bool // check only: apply the fixes diagnostics suggest
+}
+
+// parseBuildArgs parses build flags and returns the input file
+func parseBuildArgs(command string, args []string) (string, buildOptions) {
+	opts := buildOptions{debugLower: debugFilter{}, debugCodegen: debugFilter{}, lints: &lintFlags{}, color: &colorFlag{}}
+
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.Var(opts.debugLower, "debug-lower", "dump the AST, and the MIR after lowering and around each pass, of the named `functions`")
+	fs.Var(opts.debugCodegen, "debug-codegen", "dump the MIR around each pass and before codegen, and the LLVM IR, of the named `functions`")
+	fs.Var(opts.lints, "W", "set lint levels, as `lint=level` with level allow, warn or deny")
+	fs.BoolVar(&opts.mem2reg, "mem2reg", false, "turn locals that are only loaded and stored into SSA values before codegen")
+	fs.StringVar(&opts.emit, "emit", "", "write the output of `stage` instead of an executable; mir is the only stage")
+	fs.IntVar(&opts.optLevel, "O", 0, "optimization `level`: 0, 1 or 2")
+	fs.BoolVar(&opts.overflow, "overflow-checks", false, "panic on integer overflow instead of wrapping; on by default at -O0")
+	fs.StringVar(&opts.passes, "passes", "", "run the MIR `passes` named, as dce,inline=40,mem2reg, instead of the -O pipeline")
+	fs.BoolVar(&opts.timePasses, "time-passes", false, "print how long each MIR pass took")
+	fs.Var(opts.color, "color", "color diagnostics: `when` is always, never or auto (a terminal without NO_COLOR)")
+
+	if command == "check" {
+		fs.BoolVar(&opts.fix, "fix", false, "apply the fixes diagnostics suggest to the file, then check it again")
+	}
+
+	if err := fs.Parse(optFlags(args)); err != nil {
+		os.Exit(1)
+	}
+
+	if opts.optLevel < 0 || opts.optLevel > 2 {
+		fmt.Printf("Error: unknown optimization level -O%d\n", opts.optLevel)
+		os.Exit(1)
+	}
+
+	// Debug builds check for overflow unless told otherwise
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "overflow-checks" })
+
+	if !explicit {
+		opts.overflow = opts.optLevel == 0
+	}
+
+	if opts.passes != "" {
+		if _, err := mir.ParsePipeline(opts.passes); err != nil {
+			fmt.Printf("Error: --passes: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if opts.emit != "" && opts.emit != "mir" {
+		fmt.Printf("Error: unknown --emit stage %q\n", opts.emit)
+		os.Exit(1)
+	}
+
+	if fs.NArg() < 1 {
 		fmt.Println("Error: no input file specified")
 		os.Exit(1)
 	}
 
-	inputFile := args[0]
+	return fs.Arg(0), opts
+}
+
+// optFlags spells -O2 as -O=2, which the flag package can parse
+func optFlags(args []string) []string {
+	out := make([]string, len(args))
+
+	for i, arg := range args {
+		if len(arg) == 3 && strings.HasPrefix(arg, "-O") && arg[2] >= '0' && arg[2] <= '9' {
+			arg = "-O=" + arg[2:]
+		}
+
+		out[i] = arg
+	}
+
+	return out
+}
+
+func handleBuild(args []string) {
+	inputFile, opts := parseBuildArgs("build", args)
 	outputFile := strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
 
 	// Read source
@@ -55,35 +140,49 @@ func handleBuild(args []string) {
 		os.Exit(1)
 	}
 
-	// Lex
-	l := lexer.New(string(source))
-	p := parser.New(l)
-	file := p.ParseFile()
+	// A .mir file, as written by --emit=mir, skips the front end
+	var mirMod *mir.Module
 
-	if len(p.Errors()) > 0 {
-		fmt.Println("Parser errors:")
-
-		for _, err := range p.Errors() {
-			fmt.Printf("  %s\n", err)
+	if filepath.Ext(inputFile) == ".mir" {
+		mirMod, err = mir.Parse(string(source))
+		if err != nil {
+			fmt.Printf("MIR error: %v\n", err)
+			os.Exit(1)
 		}
-
-		os.Exit(1)
+	} else {
+		mirMod = lowerSource(inputFile, source, opts)
 	}
 
-	// Type check
-	c := checker.NewChecker()
-	if err := c.CheckFile(file); err != nil {
-		fmt.Printf("Type error: %v\n", err)
+	optimize(mirMod, opts)
+
+	// A .mir file may be written by hand, and codegen expects its input
+	// well formed
+	if err := mirMod.Verify(); err != nil {
+		fmt.Printf("MIR error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Lower to MIR
-	lower := mir.NewLowerer()
-	mirMod := lower.LowerFile(file)
+	if opts.emit == "mir" {
+		mirFile := outputFile + ".mir"
+		if err := os.WriteFile(mirFile, []byte(mirMod.String()), 0644); err != nil {
+			fmt.Printf("Error writing MIR: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Wrote: %s\n", mirFile)
+
+		return
+	}
 
 	// Generate LLVM IR
+	opts.debugCodegen.dumpMIR("before codegen", mirMod)
+
 	cg := codegen.NewCodegen()
+	cg.SetSourceFile(filepath.Base(inputFile))
+	cg.SetModulePath(filepath.Base(outputFile))
+	cg.SetOverflowChecks(opts.overflow)
 	llvmMod := cg.GenModule(mirMod)
+	opts.debugCodegen.dumpIR(llvmMod)
 
 	// Write LLVM IR to file
 	llFile := outputFile + ".ll"
@@ -100,9 +199,15 @@ func handleBuild(args []string) {
 	}
 	defer cleanup()
 
-	// Compile with clang, linking the runtime
-	cmd := exec.Command("clang", "-O2", llFile, runtimePath, "-o", outputFile)
+	// Compile with clang, linking the runtime. clang checks the IR before
+	// running LLVM's default pipeline for the -O level on it.
+	cmd := exec.Command("clang", fmt.Sprintf("-O%d", opts.optLevel), fmt.Sprintf("-DYAR_VERSION=%q", version), llFile, runtimePath, "-o", outputFile)
 	if output, err := cmd.CombinedOutput(); err != nil {
+		if fn, ok := rejectedIR(llFile, output); ok {
+			fmt.Printf("Internal compiler error: LLVM rejected the generated IR\n%s\n%s\n", output, fn)
+			os.Exit(1)
+		}
+
 		fmt.Printf("Error compiling: %v\n%s\n", err, output)
 		os.Exit(1)
 	}
@@ -110,9 +215,113 @@ func handleBuild(args []string) {
 	fmt.Printf("Built: %s\n", outputFile)
 }
 
+// rejectedIR reports whether clang's output is an error in the IR of
+// llFile, which only a bug in the compiler causes, and returns the function
+// the error is in, or the offending line outside of any
+func rejectedIR(llFile string, output []byte) (string, bool) {
+	at := regexp.MustCompile(regexp.QuoteMeta(llFile) + `:(\d+):\d+: error`).FindSubmatch(output)
+	if at == nil {
+		return "", false
+	}
+
+	src, err := os.ReadFile(llFile)
+	if err != nil {
+		return "", true
+	}
+
+	lines := strings.Split(string(src), "\n")
+
+	n, _ := strconv.Atoi(string(at[1]))
+	if n < 1 || n > len(lines) {
+		return "", true
+	}
+
+	start := n - 1
+	for start > 0 && !strings.HasPrefix(lines[start], "define ") && lines[start] != "}" {
+		start--
+	}
+
+	if !strings.HasPrefix(lines[start], "define ") {
+		return lines[n-1], true
+	}
+
+	end := start
+	for end < len(lines)-1 && lines[end] != "}" {
+		end++
+	}
+
+	return strings.Join(lines[start:end+1], "\n"), true
+}
+
+// optimize runs the MIR pipeline the flags select: the one of the -O level
+// or the one --passes names, then mem2reg if asked for and not yet run
+func optimize(mod *mir.Module, opts buildOptions) {
+	pm := mir.Pipeline(opts.optLevel)
+	if opts.passes != "" {
+		// parseBuildArgs has checked the pipeline
+		pm, _ = mir.ParsePipeline(opts.passes)
+	}
+
+	if opts.mem2reg && !pm.Has("mem2reg") {
+		p, _ := mir.LookupPass("mem2reg")
+		pm.Add(p)
+	}
+
+	// Functions named by either debug flag are dumped around each pass
+	if passes := opts.debugLower.union(opts.debugCodegen); len(passes) > 0 {
+		pm.SetDump(passes.dumpMIR)
+	}
+
+	pm.Run(mod)
+
+	if opts.timePasses {
+		for _, t := range pm.Timings() {
+			fmt.Fprintf(os.Stderr, ";; pass %-12s %v\n", t.Name, t.Duration)
+		}
+	}
+}
+
+// newChecker returns a checker with the lint levels of the flags, speaking
+// the language diag.Locale picks for inputFile
+func newChecker(inputFile string, opts buildOptions) *checker.Checker {
+	c := checker.NewChecker()
+	c.SetLocale(diag.Locale(filepath.Dir(inputFile)))
+
+	if err := opts.lints.apply(c); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	return c
+}
+
+// lowerSource parses, checks and lowers a source file, exiting on errors
+func lowerSource(inputFile string, source []byte, opts buildOptions) *mir.Module {
+	file, c := checkSource(inputFile, source, opts)
+
+	opts.debugLower.dumpAST(file)
+
+	// Lower to MIR
+	lower := mir.NewLowerer()
+	lower.SetTypes(c.Types())
+	mirMod := lower.LowerFile(file)
+	opts.debugLower.dumpMIR("after lowering", mirMod)
+
+	if errs := lower.Errors(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("Lowering error: %v\n", err)
+		}
+
+		os.Exit(1)
+	}
+
+	return mirMod
+}
+
 func handleRun(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Error: no input file specified")
+	inputFile, opts := parseBuildArgs("run", args)
+	if opts.emit != "" {
+		fmt.Println("Error: --emit builds no executable to run")
 		os.Exit(1)
 	}
 
@@ -120,26 +329,32 @@ func handleRun(args []string) {
 	handleBuild(args)
 
 	// Run the executable
-	inputFile := args[0]
 	execFile := strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
 
-	cmd := exec.Command("./" + execFile)
+	// A relative path is run from the current directory, not looked up in
+	// $PATH
+	if !filepath.IsAbs(execFile) {
+		execFile = "." + string(filepath.Separator) + execFile
+	}
+
+	cmd := exec.Command(execFile)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	// The program's exit status becomes yar's
 	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.ExitCode())
+		}
+
 		fmt.Printf("Error running: %v\n", err)
 		os.Exit(1)
 	}
 }
 
 func handleCheck(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Error: no input file specified")
-		os.Exit(1)
-	}
-
-	inputFile := args[0]
+	inputFile, opts := parseBuildArgs("check", args)
 
 	// Read source
 	source, err := os.ReadFile(inputFile)
@@ -148,27 +363,7 @@ func handleCheck(args []string) {
 		os.Exit(1)
 	}
 
-	// Lex and parse
-	l := lexer.New(string(source))
-	p := parser.New(l)
-	file := p.ParseFile()
-
-	if len(p.Errors()) > 0 {
-		fmt.Println("Parser errors:")
-
-		for _, err := range p.Errors() {
-			fmt.Printf("  %s\n", err)
-		}
-
-		os.Exit(1)
-	}
-
-	// Type check
-	c := checker.NewChecker()
-	if err := c.CheckFile(file); err != nil {
-		fmt.Printf("Type error: %v\n", err)
-		os.Exit(1)
-	}
+	checkSource(inputFile, source, opts)
 
 	fmt.Printf("✓ %s type-checks successfully\n", inputFile)
 }
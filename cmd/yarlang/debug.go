@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/mir"
+)
+
+// debugFilter selects the functions whose compilation stages are dumped by
+// --debug-lower and --debug-codegen
+type debugFilter map[string]bool
+
+// String implements flag.Value
+func (d debugFilter) String() string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Set implements flag.Value; names may be comma separated or the flag repeated
+func (d debugFilter) Set(spec string) error {
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			d[name] = true
+		}
+	}
+
+	return nil
+}
+
+// union selects the functions either filter does
+func (d debugFilter) union(other debugFilter) debugFilter {
+	out := debugFilter{}
+	for name := range d {
+		out[name] = true
+	}
+
+	for name := range other {
+		out[name] = true
+	}
+
+	return out
+}
+
+func debugf(stage, fn, format string, args ...any) {
+	fmt.Fprintf(os.Stderr, ";; %s %s\n", stage, fn)
+	fmt.Fprintf(os.Stderr, format, args...)
+	fmt.Fprintln(os.Stderr)
+}
+
+// dumpAST prints the declarations of the selected functions
+func (d debugFilter) dumpAST(file *ast.File) {
+	for _, item := range file.Items {
+		var fns []*ast.FuncDecl
+
+		switch decl := item.(type) {
+		case *ast.FuncDecl:
+			fns = append(fns, decl)
+		case *ast.ImplBlock:
+			fns = decl.Fns
+		}
+
+		for _, fn := range fns {
+			if d[fn.Name] && fn.Body != nil {
+				debugf("ast", fn.Name, "%s %s", fn.String(), fn.Body.String())
+			}
+		}
+	}
+}
+
+// dumpMIR prints the selected functions as they are after stage
+func (d debugFilter) dumpMIR(stage string, mod *mir.Module) {
+	for _, fn := range mod.Functions {
+		if d[fn.Name] {
+			debugf("mir "+stage, fn.Name, "%s", fn.String())
+		}
+	}
+}
+
+// dumpIR prints the LLVM IR generated for the selected functions
+func (d debugFilter) dumpIR(mod *ir.Module) {
+	for _, fn := range mod.Funcs {
+		if d[fn.Name()] {
+			debugf("llvm", fn.Name(), "%s", fn.LLString())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/yarlson/yarlang/grammar"
+)
+
+// handleInternal dispatches developer-facing subcommands that are not part of
+// the user workflow
+func handleInternal(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: no internal command specified")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "emit-grammar":
+		handleEmitGrammar(args[1:])
+	case "gen-program":
+		handleGenProgram(args[1:])
+	default:
+		fmt.Printf("Unknown internal command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleEmitGrammar writes the TextMate grammar to stdout, or to the file
+// given with -o
+func handleEmitGrammar(args []string) {
+	data, err := grammar.TextMate().JSON()
+	if err != nil {
+		fmt.Printf("Error generating grammar: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 2 && args[0] == "-o" {
+		if err := os.WriteFile(args[1], append(data, '\n'), 0644); err != nil {
+			fmt.Printf("Error writing grammar: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	fmt.Println(string(data))
+}
+
+// handleGenProgram prints a random program derived from the EBNF grammar,
+// the input the grammar conformance test feeds the parser
+func handleGenProgram(args []string) {
+	fs := flag.NewFlagSet("gen-program", flag.ExitOnError)
+	seed := fs.Int64("seed", 1, "random `seed`")
+	_ = fs.Parse(args)
+
+	fmt.Print(grammar.Generate(grammar.Grammar(), "File", rand.New(rand.NewSource(*seed))))
+}
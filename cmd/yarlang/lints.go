@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yarlson/yarlang/checker"
+)
+
+// lintSetting is one name=level pair given to -W
+type lintSetting struct {
+	name  string
+	level checker.Level
+}
+
+// lintFlags collects -W settings in the order they were given, so that a
+// later -W overrides an earlier one
+type lintFlags struct {
+	settings []lintSetting
+}
+
+// String implements flag.Value
+func (l *lintFlags) String() string {
+	if l == nil {
+		return ""
+	}
+
+	specs := make([]string, len(l.settings))
+	for i, s := range l.settings {
+		specs[i] = fmt.Sprintf("%s=%d", s.name, s.level)
+	}
+
+	return strings.Join(specs, ",")
+}
+
+// Set implements flag.Value; settings may be comma separated or the flag repeated
+func (l *lintFlags) Set(spec string) error {
+	for _, setting := range strings.Split(spec, ",") {
+		if setting = strings.TrimSpace(setting); setting == "" {
+			continue
+		}
+
+		name, levelName, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("expected lint=level, got %q", setting)
+		}
+
+		level, ok := checker.ParseLevel(levelName)
+		if !ok {
+			return fmt.Errorf("unknown lint level %q; use allow, warn or deny", levelName)
+		}
+
+		l.settings = append(l.settings, lintSetting{name: name, level: level})
+	}
+
+	return nil
+}
+
+// apply sets the lint levels on c before it checks a file
+func (l *lintFlags) apply(c *checker.Checker) error {
+	for _, s := range l.settings {
+		if err := c.SetLint(s.name, s.level); err != nil {
+			return fmt.Errorf("%w (known lints: %s, or warnings for all)", err, strings.Join(checker.Lints(), ", "))
+		}
+	}
+
+	return nil
+}
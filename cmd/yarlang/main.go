@@ -5,6 +5,10 @@ import (
 	"os"
 )
 
+// version is printed in the usage and built into the runtime, where
+// programs can read it with runtime_version
+const version = "0.1.0"
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -19,6 +23,8 @@ func main() {
 		handleRun(os.Args[2:])
 	case "check":
 		handleCheck(os.Args[2:])
+	case "internal":
+		handleInternal(os.Args[2:])
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -27,10 +33,41 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Println("YarLang Compiler v0.1.0")
+	fmt.Println("YarLang Compiler v" + version)
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  yar build <file>    Compile YarLang source to executable")
 	fmt.Println("  yar run <file>      Compile and run YarLang source")
 	fmt.Println("  yar check <file>    Type-check without compiling")
+	fmt.Println()
+	fmt.Println("Build flags:")
+	fmt.Println("  --debug-lower=f,g    Dump the AST and MIR of the named functions")
+	fmt.Println("  --debug-codegen=f,g  Dump the final MIR and LLVM IR of the named functions")
+	fmt.Println("  -O1, -O2             Inline calls to small functions and those marked #[inline]")
+	fmt.Println("  --mem2reg            Turn locals that are only loaded and stored into SSA values")
+	fmt.Println("  --overflow-checks    Panic on integer overflow instead of wrapping (default at -O0;")
+	fmt.Println("                       --overflow-checks=false turns it off)")
+	fmt.Println("  --passes=dce,inline  Run the named MIR passes instead of the -O pipeline;")
+	fmt.Println("                       passes: dce, inline[=threshold], mem2reg")
+	fmt.Println("  --time-passes        Print how long each MIR pass took")
+	fmt.Println("  --emit=mir           Write file.mir instead of an executable; build accepts .mir input")
+	fmt.Println("  -W lint=level        Set a lint to allow, warn or deny (also accepted by check)")
+	fmt.Println("                       Lints: unused_variables, unused_imports, dead_code, shadowing,")
+	fmt.Println("                       invalid_regex, warnings (all)")
+	fmt.Println("  --color=auto         Color diagnostics: always, never, or auto for a terminal")
+	fmt.Println("                       without NO_COLOR (also accepted by run and check)")
+	fmt.Println("  --fix                Check only: apply the fixes diagnostics suggest, such as")
+	fmt.Println("                       removing unused imports, then check again")
+	fmt.Println()
+	fmt.Println("Compiler environment:")
+	fmt.Println("  YAR_LANG=de            Language of diagnostics (en, de); else lang in yar.toml")
+	fmt.Println("  NO_COLOR=1             Never color diagnostics unless --color=always")
+	fmt.Println()
+	fmt.Println("Runtime environment:")
+	fmt.Println("  YAR_LOG=alloc,process  Trace runtime events on stderr (or all)")
+	fmt.Println("  YAR_STACK_SIZE=8M      Raise the stack limit of the program")
+	fmt.Println()
+	fmt.Println("Internal:")
+	fmt.Println("  yar internal emit-grammar [-o file]  Print the TextMate grammar derived from the lexer")
+	fmt.Println("  yar internal gen-program [-seed n]   Print a random program derived from grammar/yarlang.ebnf")
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/diag"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+	"github.com/yarlson/yarlang/stdlib"
+)
+
+// colorFlag is the --color setting
+type colorFlag struct {
+	mode diag.ColorMode
+}
+
+// String implements flag.Value
+func (f *colorFlag) String() string {
+	if f == nil {
+		return "auto"
+	}
+
+	return [...]string{"auto", "always", "never"}[f.mode]
+}
+
+// Set implements flag.Value
+func (f *colorFlag) Set(s string) error {
+	mode, err := diag.ParseColorMode(s)
+	f.mode = mode
+
+	return err
+}
+
+// checkSource parses and checks a source file, printing its diagnostics and
+// exiting if it has errors
+func checkSource(inputFile string, source []byte, opts buildOptions) (*ast.File, *checker.Checker) {
+	r := diag.NewRenderer(inputFile, source, opts.color.mode.Enabled(os.Stdout))
+	locale := diag.Locale(filepath.Dir(inputFile))
+
+	p := parser.New(lexer.New(string(source)))
+	p.SetLocale(locale)
+	file := p.ParseFile()
+
+	if len(p.Diagnostics()) > 0 {
+		report(r, locale, p.Diagnostics())
+		os.Exit(1)
+	}
+
+	if err := stdlib.Load(file); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	c := newChecker(inputFile, opts)
+	err := c.CheckFile(file)
+
+	if opts.fix {
+		if fixed, n := diag.ApplyFixes(source, c.Diagnostics()); n > 0 {
+			if err := os.WriteFile(inputFile, fixed, 0o644); err != nil {
+				fmt.Printf("Error writing %s: %v\n", inputFile, err)
+				os.Exit(1)
+			}
+
+			fmt.Println(diag.Message(locale, plural(n, diag.FixedProblem, diag.FixedProblems), n, inputFile))
+
+			opts.fix = false
+
+			return checkSource(inputFile, fixed, opts)
+		}
+	}
+
+	// Warnings first, so the errors that stop the build are printed last
+	diags := c.Diagnostics()
+	errs := len(c.Errors())
+	report(r, locale, append(diags[errs:], diags[:errs]...))
+
+	if err != nil {
+		os.Exit(1)
+	}
+
+	return file, c
+}
+
+// report renders diags with a blank line after each, then counts the errors
+// in the language of locale
+func report(r *diag.Renderer, locale string, diags []diag.Diagnostic) {
+	errs := 0
+
+	for _, d := range diags {
+		r.Render(os.Stdout, d)
+		fmt.Println()
+
+		if d.Severity == diag.SeverityError {
+			errs++
+		}
+	}
+
+	if errs > 0 {
+		fmt.Println(diag.Message(locale, plural(errs, diag.ErrorCount, diag.ErrorsCount), errs))
+	}
+}
+
+// plural picks the message for n of something: one for exactly one, else
+// many
+func plural(n int, one, many diag.Code) diag.Code {
+	if n == 1 {
+		return one
+	}
+
+	return many
+}
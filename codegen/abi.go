@@ -0,0 +1,213 @@
+package codegen
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/yarlson/yarlang/mir"
+)
+
+// Functions written in C, those of the runtime and those declared extern,
+// take and return structs, tuples and enums the way the x86-64 System V
+// ABI has C compilers do. An aggregate of up to 16 bytes travels in
+// registers as one scalar for each eightbyte of it; a larger one is passed
+// as a pointer to a copy (byval) and returned through a pointer to space
+// the caller provides (sret). Functions of the module pass aggregates as
+// LLVM values, which both sides of the call agree on.
+
+// maxRegAggregate is the size in bytes up to which an aggregate is passed
+// in registers
+const maxRegAggregate = 16
+
+// genCCall calls a function written in C. Each str or slice is passed as
+// its pointer and its length, and each other aggregate by the ABI's rules.
+func (cg *Codegen) genCCall(call *mir.Call, block *ir.Block, args []value.Value) {
+	retTy := cg.toLLVMType(call.RetTy)
+
+	var (
+		cArgs  []value.Value
+		params []*ir.Param
+		sret   value.Value
+	)
+
+	cRet := retTy
+
+	if isCAggregate(retTy) {
+		if size, _ := sizeOf(retTy); size > maxRegAggregate {
+			sret = cg.entryAlloca(retTy)
+			cArgs = append(cArgs, sret)
+			params = append(params, cParam(sret.Type(), ir.SRet{Typ: retTy}))
+			cRet = types.Void
+		} else {
+			cRet = coerced(eightbytes(retTy))
+		}
+	}
+
+	for _, arg := range cg.splitPairs(block, args) {
+		t := arg.Type()
+		if !isCAggregate(t) {
+			cArgs = append(cArgs, arg)
+			params = append(params, cParam(t))
+
+			continue
+		}
+
+		slot := cg.entryAlloca(t)
+		block.NewStore(arg, slot)
+
+		if size, _ := sizeOf(t); size > maxRegAggregate {
+			cArgs = append(cArgs, slot)
+			params = append(params, cParam(slot.Type(), ir.Byval{Typ: t}))
+
+			continue
+		}
+
+		// Read the aggregate back an eightbyte at a time
+		parts := eightbytes(t)
+		view := block.NewBitCast(slot, types.NewPointer(types.NewStruct(parts...)))
+
+		for n, part := range parts {
+			cArgs = append(cArgs, block.NewLoad(part, recordField(block, types.NewStruct(parts...), view, n)))
+			params = append(params, cParam(part))
+		}
+	}
+
+	callee := cg.getFunctionByName(call.Callee)
+	if callee == nil {
+		callee = cg.mod.NewFunc(call.Callee, cRet, params...)
+	}
+
+	result := value.Value(block.NewCall(callee, cArgs...))
+
+	switch {
+	case sret != nil:
+		result = block.NewLoad(retTy, sret)
+	case !cRet.Equal(retTy):
+		// The eightbytes come back in registers and are stored to be read
+		// as the aggregate they make up
+		slot := cg.entryAlloca(retTy)
+		block.NewStore(result, block.NewBitCast(slot, types.NewPointer(cRet)))
+		result = block.NewLoad(retTy, slot)
+	}
+
+	if call.Dest != "" {
+		if named, ok := result.(value.Named); ok {
+			named.SetName(call.Dest)
+		}
+
+		cg.values[call.Dest] = result
+	}
+}
+
+// isCAggregate reports whether t is passed to C by the rules for
+// aggregates. A str or slice is split before, and a returned one comes
+// back in two registers as it is.
+func isCAggregate(t types.Type) bool {
+	s, ok := t.(*types.StructType)
+	return ok && !isPair(s)
+}
+
+func cParam(t types.Type, attrs ...ir.ParamAttribute) *ir.Param {
+	p := ir.NewParam("", t)
+	p.Attrs = attrs
+
+	return p
+}
+
+// coerced is the type a C function returns the eightbytes parts in
+func coerced(parts []types.Type) types.Type {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return types.NewStruct(parts...)
+}
+
+// scalar is a field of an aggregate that is not itself an aggregate, at
+// its offset in bytes
+type scalar struct {
+	offset int64
+	typ    types.Type
+}
+
+// flatten lists the scalars of t, which starts at offset
+func flatten(t types.Type, offset int64, out []scalar) []scalar {
+	switch t := t.(type) {
+	case *types.StructType:
+		var off int64
+
+		for _, field := range t.Fields {
+			size, align := sizeOf(field)
+			off = (off + align - 1) / align * align
+			out = flatten(field, offset+off, out)
+			off += size
+		}
+	case *types.ArrayType:
+		size, _ := sizeOf(t.ElemType)
+		for i := int64(0); i < int64(t.Len); i++ {
+			out = flatten(t.ElemType, offset+i*size, out)
+		}
+	default:
+		out = append(out, scalar{offset: offset, typ: t})
+	}
+
+	return out
+}
+
+// eightbytes returns the scalars an aggregate of up to 16 bytes is passed
+// in, one for each eightbyte. An eightbyte of only floating-point fields
+// goes in an SSE register, as a float, two floats or a double; any other
+// goes in an integer register, as an integer as wide as the bytes it uses.
+func eightbytes(t types.Type) []types.Type {
+	size, _ := sizeOf(t)
+	fields := flatten(t, 0, nil)
+
+	var parts []types.Type
+
+	for start := int64(0); start < size; start += 8 {
+		var used int64
+
+		found, floats, singles := false, true, 0
+
+		for _, f := range fields {
+			if f.offset < start || f.offset >= start+8 {
+				continue
+			}
+
+			n, _ := sizeOf(f.typ)
+			used = max(used, f.offset+n-start)
+			found = true
+
+			if ft, ok := f.typ.(*types.FloatType); !ok {
+				floats = false
+			} else if ft.Kind == types.FloatKindFloat {
+				singles++
+			}
+		}
+
+		switch {
+		case !found:
+		case floats && used <= 4:
+			parts = append(parts, types.Float)
+		case floats && singles == 2:
+			parts = append(parts, types.NewVector(2, types.Float))
+		case floats:
+			parts = append(parts, types.Double)
+		default:
+			parts = append(parts, types.NewInt(uint64(used*8)))
+		}
+	}
+
+	return parts
+}
+
+// entryAlloca allocates a slot of type t at the start of the current
+// function, so that a call in a loop does not grow the stack
+func (cg *Codegen) entryAlloca(t types.Type) *ir.InstAlloca {
+	entry := cg.currentFn.Blocks[0]
+
+	alloca := ir.NewAlloca(t)
+	entry.Insts = append([]ir.Instruction{alloca}, entry.Insts...)
+
+	return alloca
+}
@@ -0,0 +1,77 @@
+package codegen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/llir/llvm/ir/types"
+	"github.com/yarlson/yarlang/mir"
+)
+
+func TestEightbytes(t *testing.T) {
+	tests := []struct {
+		typ  types.Type
+		want string
+	}{
+		{types.NewStruct(types.I32, types.I32), "[i64]"},
+		{types.NewStruct(types.I8, types.I8, types.I8), "[i24]"},
+		{types.NewStruct(types.I64, types.I32, types.I8), "[i64 i40]"},
+		{types.NewStruct(types.Double, types.Double), "[double double]"},
+		{types.NewStruct(types.Float, types.Float), "[<2 x float>]"},
+		{types.NewStruct(types.Float, types.I32), "[i64]"},
+		{types.NewStruct(types.I64, types.Float), "[i64 float]"},
+		{types.NewArray(3, types.I32), "[i64 i32]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(eightbytes(tt.typ)); got != tt.want {
+			t.Errorf("eightbytes(%s) = %s, want %s", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestCodegenCCall(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	void := &mir.PrimitiveType{Name: "void"}
+	pair := &mir.StructType{Name: "Pair"}
+	big := &mir.StructType{Name: "Big"}
+
+	mirFn := &mir.Function{
+		Name:  "test",
+		RetTy: void,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Alloca{Name: "p", Type: pair},
+					&mir.Load{Dest: "t1", Source: "p", Type: pair},
+					&mir.Call{Dest: "t2", Callee: "make_big", Args: []string{"t1"}, RetTy: big},
+					&mir.Call{Dest: "t3", Callee: "shrink", Args: []string{"t2"}, RetTy: pair},
+					&mir.Ret{Type: void},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{
+		Structs: []*mir.StructType{
+			{Name: "Pair", Fields: []mir.Type{i32, i32}},
+			{Name: "Big", Fields: []mir.Type{i32, i32, i32, i32, i32}},
+		},
+		Functions: []*mir.Function{mirFn},
+	}).String()
+
+	for _, want := range []string{
+		"declare void @make_big(%struct.Big* sret(%struct.Big) %0, i64 %1)",
+		"declare i64 @shrink(%struct.Big* byval(%struct.Big) %0)",
+		"call void @make_big(%struct.Big* %3, i64 %6)",
+		"%t2 = load %struct.Big, %struct.Big* %3",
+		"%7 = call i64 @shrink(%struct.Big* %1)",
+		"%t3 = load %struct.Pair, %struct.Pair* %0",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
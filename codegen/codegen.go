@@ -1,6 +1,9 @@
 package codegen
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
@@ -14,9 +17,30 @@ type Codegen struct {
 	mod       *ir.Module
 	currentFn *ir.Func
 	locals    map[string]*ir.InstAlloca
-	values    map[string]value.Value // Track all SSA values
-	blocks    map[string]*ir.Block   // Map from label to LLVM block
-	globals   map[string]*ir.Global  // Map from global name to LLVM global
+	values    map[string]value.Value       // Track all SSA values
+	blocks    map[string]*ir.Block         // Map from label to LLVM block
+	globals   map[string]*ir.Global        // Map from global name to LLVM global
+	defined   map[string]bool              // Functions of the MIR module; any other callee is in the runtime
+	structs   map[string]*types.StructType // Named struct types, by MIR struct name
+	enums     map[string]*enumLayout       // Enum layouts, by MIR enum name
+	phis      []pendingPhi                 // Phis of the current function, filled in once all its blocks exist
+	ends      map[string]*ir.Block         // Block each MIR block of the current function ends in
+
+	path           string // module path symbols are mangled with; empty keeps bare names
+	overflowChecks bool   // see overflow.go
+	overflows      int    // checks emitted so far, to name their blocks
+
+	// The defer stack of the current function; see defer.go
+	deferHead  *ir.InstAlloca
+	deferSites []*deferSite
+	deferRuns  []deferRun
+}
+
+// pendingPhi is an LLVM phi waiting for the incoming values of its MIR phi,
+// which may be defined in blocks generated after it
+type pendingPhi struct {
+	phi *ir.InstPhi
+	mir *mir.Phi
 }
 
 func NewCodegen() *Codegen {
@@ -25,21 +49,90 @@ func NewCodegen() *Codegen {
 		locals:  make(map[string]*ir.InstAlloca),
 		values:  make(map[string]value.Value),
 		blocks:  make(map[string]*ir.Block),
+		ends:    make(map[string]*ir.Block),
 		globals: make(map[string]*ir.Global),
+		defined: make(map[string]bool),
+		structs: make(map[string]*types.StructType),
+		enums:   make(map[string]*enumLayout),
+	}
+}
+
+// SetModulePath makes the functions of the module, other than main, link as
+// symbols mangled with path, so that they collide neither with those of
+// another module nor with the runtime's
+func (cg *Codegen) SetModulePath(path string) {
+	cg.path = path
+}
+
+// symbol returns the name the function MIR calls name links as
+func (cg *Codegen) symbol(name string) string {
+	if name == "main" && cg.defined[name] {
+		return entrySymbol
 	}
+
+	if cg.path == "" || !cg.defined[name] {
+		return name
+	}
+
+	return mir.Mangle(cg.path, name)
+}
+
+// SetSourceFile names the module after the file it is generated from. Each
+// Codegen builds a module of its own, so several files can be generated at
+// once.
+func (cg *Codegen) SetSourceFile(name string) {
+	cg.mod.SourceFilename = name
 }
 
 func (cg *Codegen) GenModule(mirMod *mir.Module) *ir.Module {
+	// Name every struct and enum type before filling any in, so that
+	// fields may refer to types defined later
+	for _, st := range mirMod.Structs {
+		cg.structs[st.Name] = &types.StructType{}
+		cg.mod.NewTypeDef("struct."+st.Name, cg.structs[st.Name])
+	}
+
+	for _, e := range mirMod.Enums {
+		cg.enums[e.Name] = &enumLayout{typ: &types.StructType{}}
+		cg.mod.NewTypeDef("enum."+e.Name, cg.enums[e.Name].typ)
+	}
+
+	for _, st := range mirMod.Structs {
+		for _, field := range st.Fields {
+			cg.structs[st.Name].Fields = append(cg.structs[st.Name].Fields, cg.toLLVMType(field))
+		}
+	}
+
+	for _, e := range mirMod.Enums {
+		cg.defineEnum(e)
+	}
+
 	// Generate global constants first
 	for _, global := range mirMod.Globals {
 		cg.genGlobal(global)
 	}
 
-	// Then generate functions
+	// Then generate functions, declaring them all first so that a call may
+	// precede its callee's definition
+	for _, fn := range mirMod.Functions {
+		cg.declareFunction(fn)
+	}
+
 	for _, fn := range mirMod.Functions {
 		cg.genFunction(fn)
 	}
 
+	if cg.defined["main"] {
+		cg.genEntry(cg.getFunctionByName("main"))
+	}
+
+	// Vtables can only be filled in once every method has been generated
+	for _, global := range mirMod.Globals {
+		if vt, ok := global.(*mir.GlobalVTable); ok {
+			cg.fillVTable(vt)
+		}
+	}
+
 	return cg.mod
 }
 
@@ -57,26 +150,190 @@ func (cg *Codegen) genGlobal(global mir.Global) {
 		global := cg.mod.NewGlobalDef(g.Name, strConst)
 		global.Linkage = enum.LinkagePrivate
 		global.UnnamedAddr = enum.UnnamedAddrUnnamedAddr
+		global.Immutable = true
 
 		// Store in globals map
 		cg.globals[g.Name] = global
 
 		// Also store in values map for easy lookup
 		cg.values[g.Name] = global
+	case *mir.GlobalVTable:
+		// Declared here so that functions can refer to it; the entries are
+		// filled in by fillVTable after all functions exist
+		global := cg.mod.NewGlobal(g.Name, types.NewArray(uint64(len(g.Methods)), types.I8Ptr))
+		global.Linkage = enum.LinkagePrivate
+		global.Immutable = true
+		cg.globals[g.Name] = global
+	case *mir.GlobalConst:
+		var init constant.Constant
+		if strings.HasPrefix(g.Value, "@") {
+			// A str refers to the GlobalString declared before it
+			init = cg.getValue(g.Value, g.Type, nil).(constant.Constant)
+		} else {
+			init = constantOf(g.Value, cg.toLLVMType(g.Type))
+		}
+
+		global := cg.mod.NewGlobalDef(g.Name, init)
+		global.Linkage = enum.LinkagePrivate
+		global.Immutable = true
+		cg.globals[g.Name] = global
 	}
 }
 
-func (cg *Codegen) genFunction(mirFn *mir.Function) {
+// fillVTable initializes a vtable with pointers to its methods. Methods that
+// are not defined in this module are declared as external.
+func (cg *Codegen) fillVTable(vt *mir.GlobalVTable) {
+	entries := make([]constant.Constant, len(vt.Methods))
+	for i, name := range vt.Methods {
+		fn := cg.getFunctionByName(name)
+		if fn == nil {
+			fn = cg.getOrCreateFunction(name, types.Void, nil)
+		}
+		entries[i] = constant.NewBitCast(fn, types.I8Ptr)
+	}
+
+	global := cg.globals[vt.Name]
+	global.Init = constant.NewArray(global.ContentType.(*types.ArrayType), entries...)
+}
+
+// genPackSlice copies the elements into a stack array and builds the
+// {ptr, len} pair pointing at it
+func (cg *Codegen) genPackSlice(pack *mir.PackSlice, block *ir.Block) {
+	elemTy := cg.toLLVMType(pack.Elem)
+	sliceTy := cg.toLLVMType(&mir.SliceType{Elem: pack.Elem})
+
+	var ptr value.Value = constant.NewNull(types.NewPointer(elemTy))
+
+	if len(pack.Elems) > 0 {
+		arrayTy := types.NewArray(uint64(len(pack.Elems)), elemTy)
+		array := block.NewAlloca(arrayTy)
+		zero := constant.NewInt(types.I32, 0)
+
+		for idx, elem := range pack.Elems {
+			slot := block.NewGetElementPtr(arrayTy, array, zero, constant.NewInt(types.I32, int64(idx)))
+			block.NewStore(cg.getValue(elem, pack.Elem, block), slot)
+		}
+
+		ptr = block.NewGetElementPtr(arrayTy, array, zero, zero)
+	}
+
+	withPtr := block.NewInsertValue(constant.NewUndef(sliceTy), ptr, 0)
+	slice := block.NewInsertValue(withPtr, constant.NewInt(types.I64, int64(len(pack.Elems))), 1)
+	slice.SetName(pack.Dest)
+	cg.values[pack.Dest] = slice
+}
+
+// genElemAddr indexes into an array in place, or into the elements a slice
+// points to
+func (cg *Codegen) genElemAddr(addr *mir.ElemAddr, block *ir.Block) {
+	idx := cg.getValue(addr.Index, &mir.PrimitiveType{Name: "usize"}, block)
+	base := cg.address(addr.Base)
+
+	var gep *ir.InstGetElementPtr
+
+	switch t := addr.Type.(type) {
+	case *mir.ArrayType:
+		gep = block.NewGetElementPtr(cg.toLLVMType(t), base, constant.NewInt(types.I32, 0), idx)
+	case *mir.SliceType:
+		elemTy := cg.toLLVMType(t.Elem)
+		slice := block.NewLoad(cg.toLLVMType(t), base)
+		gep = block.NewGetElementPtr(elemTy, block.NewExtractValue(slice, 0), idx)
+	default:
+		return
+	}
+
+	gep.SetName(addr.Dest)
+	cg.values[addr.Dest] = gep
+}
+
+// genPayloadAddr reinterprets the payload area of an enum as the payload
+// struct of one variant and takes the address of one of its values
+func (cg *Codegen) genPayloadAddr(addr *mir.PayloadAddr, block *ir.Block) {
+	layout := cg.enums[addr.Type.Name]
+	zero := constant.NewInt(types.I32, 0)
+
+	area := block.NewGetElementPtr(layout.typ, cg.address(addr.Base), zero, constant.NewInt(types.I32, 1))
+	payload := layout.payloads[addr.Variant]
+	cast := block.NewBitCast(area, types.NewPointer(payload))
+
+	gep := block.NewGetElementPtr(payload, cast, zero, constant.NewInt(types.I32, int64(addr.Index)))
+	gep.SetName(addr.Dest)
+	cg.values[addr.Dest] = gep
+}
+
+// genVCall loads the method from its vtable slot and calls it with the object
+// pointer as the receiver
+func (cg *Codegen) genVCall(call *mir.VCall, block *ir.Block) {
+	vtable := cg.getValue(call.VTable, &mir.PtrType{Elem: &mir.PtrType{Elem: &mir.PrimitiveType{Name: "i8"}}}, block)
+	slot := block.NewGetElementPtr(types.I8Ptr, vtable, constant.NewInt(types.I32, int64(call.Slot)))
+	method := block.NewLoad(types.I8Ptr, slot)
+
+	self := cg.getValue(call.Object, &mir.PtrType{Elem: &mir.PrimitiveType{Name: "i8"}}, block)
+	if !self.Type().Equal(types.I8Ptr) {
+		self = block.NewBitCast(self, types.I8Ptr)
+	}
+
+	args := []value.Value{self}
+	paramTypes := []types.Type{types.I8Ptr}
+
+	for idx, arg := range call.Args {
+		val := cg.getValue(arg, call.ArgTys[idx], block)
+		args = append(args, val)
+		paramTypes = append(paramTypes, val.Type())
+	}
+
+	sig := types.NewFunc(cg.toLLVMType(call.RetTy), paramTypes...)
+	callee := block.NewBitCast(method, types.NewPointer(sig))
+	result := block.NewCall(callee, args...)
+
+	if call.Dest != "" {
+		result.SetName(call.Dest)
+		cg.values[call.Dest] = result
+	}
+}
+
+// genIndirectCall calls the function a function value points to
+func (cg *Codegen) genIndirectCall(call *mir.IndirectCall, block *ir.Block) {
+	callee := cg.getValue(call.Callee, call.Type, block)
+
+	args := make([]value.Value, len(call.Args))
+	for idx, arg := range call.Args {
+		args[idx] = cg.getValue(arg, call.Type.Params[idx], block)
+	}
+
+	result := block.NewCall(callee, args...)
+
+	if call.Dest != "" {
+		result.SetName(call.Dest)
+		cg.values[call.Dest] = result
+	}
+}
+
+// declareFunction adds the signature of mirFn to the module
+func (cg *Codegen) declareFunction(mirFn *mir.Function) *ir.Func {
 	// Convert MIR types to LLVM types
 	params := make([]*ir.Param, len(mirFn.Params))
 	for i, p := range mirFn.Params {
 		params[i] = ir.NewParam(p.Name, cg.toLLVMType(p.Type))
-		// Track function parameters as values
-		cg.values[p.Name] = params[i]
 	}
 
 	retTy := cg.toLLVMType(mirFn.RetTy)
-	fn := cg.mod.NewFunc(mirFn.Name, retTy, params...)
+	cg.defined[mirFn.Name] = true
+
+	return cg.mod.NewFunc(cg.symbol(mirFn.Name), retTy, params...)
+}
+
+func (cg *Codegen) genFunction(mirFn *mir.Function) {
+	fn := cg.getFunctionByName(mirFn.Name)
+	if fn == nil {
+		fn = cg.declareFunction(mirFn)
+	}
+
+	// Track function parameters as values
+	for i, p := range mirFn.Params {
+		cg.values[p.Name] = fn.Params[i]
+	}
+
 	cg.currentFn = fn
 
 	// Create all LLVM blocks first (so we can reference them in branches)
@@ -85,6 +342,10 @@ func (cg *Codegen) genFunction(mirFn *mir.Function) {
 		cg.blocks[bb.Label] = llvmBlock
 	}
 
+	if len(mirFn.Blocks) > 0 && hasDefers(mirFn) {
+		cg.initDefers(cg.blocks[mirFn.Blocks[0].Label])
+	}
+
 	// Materialize parameters on the stack when MIR expects loads/stores by name
 	if len(mirFn.Blocks) > 0 && len(mirFn.Params) > 0 {
 		entryBlock := cg.blocks[mirFn.Blocks[0].Label]
@@ -103,30 +364,54 @@ func (cg *Codegen) genFunction(mirFn *mir.Function) {
 		cg.genBasicBlock(bb, llvmBlock)
 	}
 
+	for _, p := range cg.phis {
+		for _, in := range p.mir.Incoming {
+			pred := cg.ends[in.Label]
+			p.phi.Incs = append(p.phi.Incs, ir.NewIncoming(cg.getValue(in.Value, p.mir.Type, pred), pred))
+		}
+	}
+
+	cg.finishDefers()
+
+	cg.phis = nil
 	cg.currentFn = nil
 	cg.locals = make(map[string]*ir.InstAlloca)
 	cg.values = make(map[string]value.Value)
 	cg.blocks = make(map[string]*ir.Block)
+	cg.ends = make(map[string]*ir.Block)
 }
 
 func (cg *Codegen) genBasicBlock(mirBB *mir.BasicBlock, llvmBB *ir.Block) {
 	for _, instr := range mirBB.Instrs {
 		switch i := instr.(type) {
 		case *mir.Alloca:
-			alloca := llvmBB.NewAlloca(cg.toLLVMType(i.Type))
+			// A slot declared in a loop, such as the temporary an element
+			// of a Vec passes through, is still allocated only once
+			alloca := cg.entryAlloca(cg.toLLVMType(i.Type))
 			alloca.SetName(i.Name)
 			cg.locals[i.Name] = alloca
 			cg.values[i.Name] = alloca
 		case *mir.Load:
-			src := cg.locals[i.Source]
+			src := cg.address(i.Source)
 			load := llvmBB.NewLoad(cg.toLLVMType(i.Type), src)
+			load.Volatile = i.Volatile
 			load.SetName(i.Dest)
 			cg.values[i.Dest] = load
+		case *mir.AddrOf:
+			cg.values[i.Dest] = cg.locals[i.Source]
+		case *mir.Phi:
+			// The type is set up front since the incoming values come later
+			phi := &ir.InstPhi{Typ: cg.toLLVMType(i.Type)}
+			phi.SetName(i.Dest)
+			llvmBB.Insts = append(llvmBB.Insts, phi)
+			cg.values[i.Dest] = phi
+			cg.phis = append(cg.phis, pendingPhi{phi: phi, mir: i})
 		case *mir.Store:
 			// Get the value to store
 			val := cg.getValue(i.Value, i.Type, llvmBB)
-			dest := cg.locals[i.Dest]
-			llvmBB.NewStore(val, dest)
+			dest := cg.address(i.Dest)
+			store := llvmBB.NewStore(val, dest)
+			store.Volatile = i.Volatile
 		case *mir.BinOp:
 			// Get operands
 			left := cg.getValue(i.Left, i.Type, llvmBB)
@@ -134,8 +419,16 @@ func (cg *Codegen) genBasicBlock(mirBB *mir.BasicBlock, llvmBB *ir.Block) {
 
 			var result value.Value
 			// Handle comparison operations (return i1/bool)
-			if i.Op >= mir.Eq && i.Op <= mir.Ge {
-				result = llvmBB.NewICmp(cg.opToICmpPred(i.Op), left, right)
+			if t, ok := left.Type().(*types.StructType); ok && isPair(t) {
+				result = cg.strCompare(llvmBB, i.Op, left, right)
+			} else if types.IsFloat(left.Type()) {
+				result = cg.floatOp(llvmBB, i.Op, left, right)
+			} else if i.Op >= mir.Eq && i.Op <= mir.Ge {
+				result = llvmBB.NewICmp(cg.opToICmpPred(i.Op, isUnsigned(i.Type)), left, right)
+			} else if cg.checksOverflow(i.Op, left) {
+				result, llvmBB = cg.checkedOp(llvmBB, i.Op, isUnsigned(i.Type), left, right)
+			} else if isUnsigned(i.Type) && (i.Op == mir.Div || i.Op == mir.Mod || i.Op == mir.Shr) {
+				result = unsignedOp(llvmBB, i.Op, left, right)
 			} else {
 				// Handle arithmetic operations
 				switch i.Op {
@@ -165,30 +458,91 @@ func (cg *Codegen) genBasicBlock(mirBB *mir.BasicBlock, llvmBB *ir.Block) {
 				result.(interface{ SetName(string) }).SetName(i.Dest)
 				cg.values[i.Dest] = result
 			}
-		case *mir.Call:
-			args, argTypes := cg.buildCallArgs(i, llvmBB)
-			if cg.genBuiltinCall(i, llvmBB, args) {
-				continue
+		case *mir.MakeTuple:
+			var agg value.Value = constant.NewUndef(cg.toLLVMType(i.Type))
+			for idx, elem := range i.Elems {
+				agg = llvmBB.NewInsertValue(agg, cg.getValue(elem, i.Type.Elems[idx], llvmBB), uint64(idx))
 			}
 
-			callee := cg.getFunctionByName(i.Callee)
-
-			// If function not found, create external declaration using inferred arg types
-			if callee == nil {
-				retTy := cg.toLLVMType(i.RetTy)
-				params := make([]*ir.Param, len(argTypes))
-				for idx, argTy := range argTypes {
-					params[idx] = ir.NewParam("", argTy)
-				}
-				callee = cg.mod.NewFunc(i.Callee, retTy, params...)
+			if named, ok := agg.(value.Named); ok {
+				named.SetName(i.Dest)
+			}
+			cg.values[i.Dest] = agg
+		case *mir.Insert:
+			var agg value.Value = constant.NewZeroInitializer(cg.toLLVMType(i.Type))
+			if i.Agg != "" {
+				agg = cg.getValue(i.Agg, i.Type, llvmBB)
 			}
 
-			call := llvmBB.NewCall(callee, args...)
+			insert := llvmBB.NewInsertValue(agg, cg.getValue(i.Value, i.Elem, llvmBB), uint64(i.Index))
+			insert.SetName(i.Dest)
+			cg.values[i.Dest] = insert
+		case *mir.FieldAddr:
+			zero := constant.NewInt(types.I32, 0)
+			idx := constant.NewInt(types.I32, int64(i.Index))
+			gep := llvmBB.NewGetElementPtr(cg.toLLVMType(i.Type), cg.address(i.Base), zero, idx)
+			gep.SetName(i.Dest)
+			cg.values[i.Dest] = gep
+		case *mir.ElemAddr:
+			cg.genElemAddr(i, llvmBB)
+		case *mir.TagAddr:
+			zero := constant.NewInt(types.I32, 0)
+			gep := llvmBB.NewGetElementPtr(cg.toLLVMType(i.Type), cg.address(i.Base), zero, zero)
+			gep.SetName(i.Dest)
+			cg.values[i.Dest] = gep
+		case *mir.PayloadAddr:
+			cg.genPayloadAddr(i, llvmBB)
+		case *mir.EnumTag:
+			tag := llvmBB.NewExtractValue(cg.getValue(i.Value, i.Type, llvmBB), 0)
+			tag.SetName(i.Dest)
+			cg.values[i.Dest] = tag
+		case *mir.PackSlice:
+			cg.genPackSlice(i, llvmBB)
+		case *mir.Extract:
+			tuple := cg.getValue(i.Tuple, i.Type, llvmBB)
+			elem := llvmBB.NewExtractValue(tuple, uint64(i.Index))
+			elem.SetName(i.Dest)
+			cg.values[i.Dest] = elem
+		case *mir.Cast:
+			val := cg.getValue(i.Value, i.From, llvmBB)
+			to := cg.toLLVMType(i.To)
 
-			if i.Dest != "" {
-				call.SetName(i.Dest)
-				cg.values[i.Dest] = call
+			var result value.Named
+			switch i.Kind {
+			case mir.SExt:
+				result = llvmBB.NewSExt(val, to)
+			case mir.ZExt:
+				result = llvmBB.NewZExt(val, to)
+			case mir.FPExt:
+				result = llvmBB.NewFPExt(val, to)
+			case mir.Trunc:
+				result = llvmBB.NewTrunc(val, to)
+			case mir.FPTrunc:
+				result = llvmBB.NewFPTrunc(val, to)
+			case mir.SIToFP:
+				result = llvmBB.NewSIToFP(val, to)
+			case mir.UIToFP:
+				result = llvmBB.NewUIToFP(val, to)
+			case mir.FPToSI:
+				result = llvmBB.NewFPToSI(val, to)
+			case mir.FPToUI:
+				result = llvmBB.NewFPToUI(val, to)
+			case mir.BitCast:
+				result = llvmBB.NewBitCast(val, to)
 			}
+			result.SetName(i.Dest)
+			cg.values[i.Dest] = result
+		case *mir.Call:
+			cg.genCall(i, llvmBB)
+		case *mir.VCall:
+			cg.genVCall(i, llvmBB)
+		case *mir.FuncRef:
+			cg.values[i.Dest] = cg.getFunctionByName(i.Func)
+		case *mir.SizeOf:
+			size, _ := sizeOf(cg.toLLVMType(i.Type))
+			cg.values[i.Dest] = constant.NewInt(types.I64, size)
+		case *mir.IndirectCall:
+			cg.genIndirectCall(i, llvmBB)
 		case *mir.Br:
 			// Unconditional branch
 			targetBlock := cg.blocks[i.Label]
@@ -203,50 +557,83 @@ func (cg *Codegen) genBasicBlock(mirBB *mir.BasicBlock, llvmBB *ir.Block) {
 			if i.Value == "" {
 				llvmBB.NewRet(nil)
 			} else {
-				// Check if it's a tracked value (from Load, etc.)
-				if val, ok := cg.values[i.Value]; ok {
-					llvmBB.NewRet(val)
-				} else {
-					// Otherwise, try to load from local
-					if alloca, ok := cg.locals[i.Value]; ok {
-						val := llvmBB.NewLoad(cg.toLLVMType(i.Type), alloca)
-						llvmBB.NewRet(val)
-					} else {
-						// Must be a constant
-						val := cg.parseConstant(i.Value, i.Type)
-						llvmBB.NewRet(val)
-					}
-				}
+				llvmBB.NewRet(cg.getValue(i.Value, i.Type, llvmBB))
 			}
+		case *mir.Unreachable:
+			llvmBB.NewUnreachable()
 		case *mir.DeferPush:
-			// TODO: proper defer runtime support needed
-			// For v0.4, simplified implementation - defer is not yet fully functional
-			// This would need a defer stack and runtime support
-			// For now, generate a comment as a placeholder
+			cg.genDeferPush(i, llvmBB)
 		case *mir.DeferRunAll:
-			// TODO: proper defer runtime support needed
-			// For v0.4, simplified implementation - defer is not yet fully functional
-			// This would need to iterate the defer stack in LIFO order
-			// For now, generate a comment as a placeholder
+			// The rest of the block follows the loop that runs the calls
+			llvmBB = cg.genDeferRunAll(llvmBB)
 		}
 	}
+
+	cg.ends[mirBB.Label] = llvmBB
 }
 
-func (cg *Codegen) buildCallArgs(call *mir.Call, block *ir.Block) ([]value.Value, []types.Type) {
+func (cg *Codegen) genCall(i *mir.Call, llvmBB *ir.Block) {
+	args := cg.buildCallArgs(i, llvmBB)
+	if cg.genBuiltinCall(i, llvmBB, args) {
+		return
+	}
+
+	// The runtime and extern functions are written in C
+	if !cg.defined[i.Callee] {
+		cg.genCCall(i, llvmBB, args)
+		return
+	}
+
+	call := llvmBB.NewCall(cg.getFunctionByName(i.Callee), args...)
+
+	if i.Dest != "" {
+		call.SetName(i.Dest)
+		cg.values[i.Dest] = call
+	}
+}
+
+// address resolves the memory a load or store refers to: a local, or a
+// pointer computed by an earlier instruction
+func (cg *Codegen) address(name string) value.Value {
+	if alloca, ok := cg.locals[name]; ok {
+		return alloca
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return cg.globals[name[1:]]
+	}
+
+	return cg.values[name]
+}
+
+func (cg *Codegen) buildCallArgs(call *mir.Call, block *ir.Block) []value.Value {
+	var params []*ir.Param
+	if cg.defined[call.Callee] {
+		params = cg.getFunctionByName(call.Callee).Params
+	}
+
 	args := make([]value.Value, len(call.Args))
-	argTypes := make([]types.Type, len(call.Args))
 	for idx, arg := range call.Args {
-		val := cg.getValue(arg, &mir.PrimitiveType{Name: "i32"}, block)
+		var val value.Value
+
+		// A literal takes the type of the parameter it is passed to
+		if isConstant(arg) && idx < len(params) {
+			val = constantOf(arg, params[idx].Typ)
+		} else {
+			val = cg.getValue(arg, &mir.PrimitiveType{Name: "i32"}, block)
+		}
+
 		args[idx] = val
-		argTypes[idx] = val.Type()
 	}
-	return args, argTypes
+	return args
 }
 
 func (cg *Codegen) genBuiltinCall(call *mir.Call, block *ir.Block, args []value.Value) bool {
 	switch call.Callee {
 	case "println":
 		return cg.lowerPrintln(block, args)
+	case "len":
+		return cg.lowerLen(call, block, args)
 	default:
 		return false
 	}
@@ -259,17 +646,28 @@ func (cg *Codegen) lowerPrintln(block *ir.Block, args []value.Value) bool {
 
 	arg := args[0]
 	switch t := arg.Type().(type) {
-	case *types.PointerType:
-		if !isI8Pointer(t) {
+	case *types.StructType:
+		if !isPair(t) {
 			return false
 		}
-		fn := cg.getOrCreateFunction("println", types.Void, []types.Type{t})
-		block.NewCall(fn, arg)
+		fn := cg.getOrCreateFunction("println", types.Void, []types.Type{t.Fields[0], types.I64})
+		block.NewCall(fn, cg.splitPairs(block, args)...)
 		return true
 	case *types.IntType:
 		name := "println_i32"
-		if t.BitSize == 1 {
+		switch t.BitSize {
+		case 1:
 			name = "println_bool"
+		case 64:
+			name = "println_i64"
+		}
+		fn := cg.getOrCreateFunction(name, types.Void, []types.Type{t})
+		block.NewCall(fn, arg)
+		return true
+	case *types.FloatType:
+		name := "println_f64"
+		if t.Kind == types.FloatKindFloat {
+			name = "println_f32"
 		}
 		fn := cg.getOrCreateFunction(name, types.Void, []types.Type{t})
 		block.NewCall(fn, arg)
@@ -279,17 +677,102 @@ func (cg *Codegen) lowerPrintln(block *ir.Block, args []value.Value) bool {
 	}
 }
 
+// lowerLen reads the length of a str or slice, truncated if the call was
+// lowered without the checker's usize type
+func (cg *Codegen) lowerLen(call *mir.Call, block *ir.Block, args []value.Value) bool {
+	if len(args) != 1 {
+		return false
+	}
+
+	t, ok := args[0].Type().(*types.StructType)
+	if !ok || !isPair(t) {
+		return false
+	}
+
+	var result value.Value = block.NewExtractValue(args[0], 1)
+	if retTy, ok := cg.toLLVMType(call.RetTy).(*types.IntType); ok && retTy.BitSize < 64 {
+		result = block.NewTrunc(result, retTy)
+	}
+
+	if call.Dest != "" {
+		cg.values[call.Dest] = result
+	}
+
+	return true
+}
+
+// strCompare compares two strs by content: == and != with the runtime's
+// str_eq, the orderings with its str_compare. The checker allows no other
+// operator on them.
+func (cg *Codegen) strCompare(block *ir.Block, op mir.OpKind, left, right value.Value) value.Value {
+	args := cg.splitPairs(block, []value.Value{left, right})
+
+	argTypes := make([]types.Type, len(args))
+	for i, arg := range args {
+		argTypes[i] = arg.Type()
+	}
+
+	if op != mir.Eq && op != mir.Ne {
+		fn := cg.getOrCreateFunction("str_compare", types.I32, argTypes)
+		return block.NewICmp(cg.opToICmpPred(op, false), block.NewCall(fn, args...), constant.NewInt(types.I32, 0))
+	}
+
+	fn := cg.getOrCreateFunction("str_eq", types.I1, argTypes)
+
+	var result value.Value = block.NewCall(fn, args...)
+	if op == mir.Ne {
+		result = block.NewXor(result, constant.True)
+	}
+
+	return result
+}
+
+// splitPairs passes each str or slice in args as its pointer followed by
+// its length
+func (cg *Codegen) splitPairs(block *ir.Block, args []value.Value) []value.Value {
+	var out []value.Value
+
+	for _, arg := range args {
+		if t, ok := arg.Type().(*types.StructType); ok && isPair(t) {
+			out = append(out, block.NewExtractValue(arg, 0), block.NewExtractValue(arg, 1))
+			continue
+		}
+
+		out = append(out, arg)
+	}
+
+	return out
+}
+
+// isPair reports whether t is the {ptr, i64} layout of a str or slice
+func isPair(t *types.StructType) bool {
+	if len(t.Fields) != 2 {
+		return false
+	}
+
+	_, isPtr := t.Fields[0].(*types.PointerType)
+
+	return isPtr && t.Fields[1].Equal(types.I64)
+}
+
+// getFunctionByName returns the function MIR calls name, if declared
 func (cg *Codegen) getFunctionByName(name string) *ir.Func {
+	return cg.lookupSymbol(cg.symbol(name))
+}
+
+func (cg *Codegen) lookupSymbol(symbol string) *ir.Func {
 	for _, fn := range cg.mod.Funcs {
-		if fn.Name() == name {
+		if fn.Name() == symbol {
 			return fn
 		}
 	}
 	return nil
 }
 
+// getOrCreateFunction returns the runtime function or intrinsic name,
+// declaring it if need be
 func (cg *Codegen) getOrCreateFunction(name string, retTy types.Type, paramTypes []types.Type) *ir.Func {
-	if fn := cg.getFunctionByName(name); fn != nil {
+	if fn := cg.lookupSymbol(name); fn != nil {
 		return fn
 	}
 	params := make([]*ir.Param, len(paramTypes))
@@ -299,37 +782,97 @@ func (cg *Codegen) getOrCreateFunction(name string, retTy types.Type, paramTypes
 	return cg.mod.NewFunc(name, retTy, params...)
 }
 
-func isI8Pointer(ty types.Type) bool {
-	ptr, ok := ty.(*types.PointerType)
-	if !ok {
-		return false
-	}
-	if elem, ok := ptr.ElemType.(*types.IntType); ok {
-		return elem.BitSize == 8
+// floatOp emits arithmetic or an ordered comparison on floats
+func (cg *Codegen) floatOp(block *ir.Block, op mir.OpKind, left, right value.Value) value.Value {
+	switch op {
+	case mir.Add:
+		return block.NewFAdd(left, right)
+	case mir.Sub:
+		return block.NewFSub(left, right)
+	case mir.Mul:
+		return block.NewFMul(left, right)
+	case mir.Div:
+		return block.NewFDiv(left, right)
+	case mir.Mod:
+		return block.NewFRem(left, right)
+	case mir.Eq:
+		return block.NewFCmp(enum.FPredOEQ, left, right)
+	case mir.Ne:
+		return block.NewFCmp(enum.FPredONE, left, right)
+	case mir.Lt:
+		return block.NewFCmp(enum.FPredOLT, left, right)
+	case mir.Le:
+		return block.NewFCmp(enum.FPredOLE, left, right)
+	case mir.Gt:
+		return block.NewFCmp(enum.FPredOGT, left, right)
+	case mir.Ge:
+		return block.NewFCmp(enum.FPredOGE, left, right)
+	default:
+		return nil
 	}
-	return false
 }
 
-// opToICmpPred converts MIR comparison operations to LLVM icmp predicates
-func (cg *Codegen) opToICmpPred(op mir.OpKind) enum.IPred {
+// opToICmpPred converts MIR comparison operations to LLVM icmp predicates,
+// which order unsigned integers differently
+func (cg *Codegen) opToICmpPred(op mir.OpKind, unsigned bool) enum.IPred {
 	switch op {
 	case mir.Eq:
 		return enum.IPredEQ
 	case mir.Ne:
 		return enum.IPredNE
 	case mir.Lt:
+		if unsigned {
+			return enum.IPredULT
+		}
 		return enum.IPredSLT
 	case mir.Le:
+		if unsigned {
+			return enum.IPredULE
+		}
 		return enum.IPredSLE
 	case mir.Gt:
+		if unsigned {
+			return enum.IPredUGT
+		}
 		return enum.IPredSGT
 	case mir.Ge:
+		if unsigned {
+			return enum.IPredUGE
+		}
 		return enum.IPredSGE
 	default:
 		return enum.IPredEQ
 	}
 }
 
+// unsignedOp emits the division, remainder or right shift of unsigned
+// integers, which differ from those of signed ones
+func unsignedOp(block *ir.Block, op mir.OpKind, left, right value.Value) value.Value {
+	switch op {
+	case mir.Div:
+		return block.NewUDiv(left, right)
+	case mir.Mod:
+		return block.NewURem(left, right)
+	default:
+		return block.NewLShr(left, right)
+	}
+}
+
+// isUnsigned reports whether t is an unsigned integer type
+func isUnsigned(t mir.Type) bool {
+	p, ok := t.(*mir.PrimitiveType)
+	if !ok {
+		return false
+	}
+
+	switch p.Name {
+	case "u8", "u16", "u32", "u64", "usize":
+		return true
+	default:
+		return false
+	}
+}
+
 // getValue gets an LLVM value from a MIR value string
 // Handles both constants (like "42") and local variables (like "x")
 func (cg *Codegen) getValue(valueStr string, ty mir.Type, block *ir.Block) value.Value {
@@ -343,6 +886,16 @@ func (cg *Codegen) getValue(valueStr string, ty mir.Type, block *ir.Block) value
 			if arrayType, ok := globalType.(*types.ArrayType); ok {
 				// Create indices for getelementptr: (i32 0, i32 0)
 				zero := constant.NewInt(types.I32, 0)
+
+				// A string literal is a str: its first byte and its length,
+				// not counting the NUL kept for C
+				if arrayType.ElemType.Equal(types.I8) {
+					ptr := constant.NewGetElementPtr(arrayType, global, zero, zero)
+					length := constant.NewInt(types.I64, int64(arrayType.Len-1))
+
+					return constant.NewStruct(cg.toLLVMType(&mir.StrType{}).(*types.StructType), ptr, length)
+				}
+
 				indices := []value.Value{zero, zero}
 
 				// Get pointer to first element
@@ -370,33 +923,33 @@ func (cg *Codegen) getValue(valueStr string, ty mir.Type, block *ir.Block) value
 
 // parseConstant parses a constant value from a string
 func (cg *Codegen) parseConstant(value string, ty mir.Type) constant.Constant {
-	llvmType := cg.toLLVMType(ty)
-
-	// Parse integer constants
-	if intType, ok := llvmType.(*types.IntType); ok {
-		var intVal int64
-		// Simple parsing - in a real implementation, handle errors
-		if len(value) > 0 {
-			negative := false
-			str := value
-			if value[0] == '-' {
-				negative = true
-				str = value[1:]
-			}
-			for _, ch := range str {
-				if ch >= '0' && ch <= '9' {
-					intVal = intVal*10 + int64(ch-'0')
-				}
-			}
-			if negative {
-				intVal = -intVal
-			}
+	return constantOf(value, cg.toLLVMType(ty))
+}
+
+// constantOf builds a literal of type t. Zero is the only constant of other
+// types, such as the pair of an empty str.
+func constantOf(value string, t types.Type) constant.Constant {
+	switch t := t.(type) {
+	case *types.IntType:
+		v, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			u, _ := strconv.ParseUint(value, 0, 64)
+			v = int64(u)
 		}
-		return constant.NewInt(intType, intVal)
+
+		return constant.NewInt(t, v)
+	case *types.FloatType:
+		v, _ := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64)
+		return constant.NewFloat(t, v)
+	default:
+		return constant.NewZeroInitializer(t)
 	}
+}
 
-	// Default to zero for unhandled types
-	return constant.NewInt(types.I32, 0)
+// isConstant reports whether a MIR value is a numeric literal rather than
+// the name of a temporary, local or global
+func isConstant(value string) bool {
+	return value != "" && (value[0] == '-' || value[0] == '.' || value[0] >= '0' && value[0] <= '9')
 }
 
 func (cg *Codegen) toLLVMType(mirType mir.Type) types.Type {
@@ -417,7 +970,7 @@ func (cg *Codegen) toLLVMType(mirType mir.Type) types.Type {
 			return types.I16
 		case "u32":
 			return types.I32
-		case "u64":
+		case "u64", "usize", "isize":
 			return types.I64
 		case "f32":
 			return types.Float
@@ -433,6 +986,38 @@ func (cg *Codegen) toLLVMType(mirType mir.Type) types.Type {
 	case *mir.PtrType:
 		elem := cg.toLLVMType(t.Elem)
 		return types.NewPointer(elem)
+	case *mir.SliceType:
+		return types.NewStruct(types.NewPointer(cg.toLLVMType(t.Elem)), types.I64)
+	case *mir.ArrayType:
+		return types.NewArray(uint64(t.Len), cg.toLLVMType(t.Elem))
+	case *mir.StrType:
+		return types.NewStruct(types.I8Ptr, types.I64)
+	case *mir.FuncType:
+		params := make([]types.Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = cg.toLLVMType(p)
+		}
+
+		return types.NewPointer(types.NewFunc(cg.toLLVMType(t.Ret), params...))
+	case *mir.TupleType:
+		elems := make([]types.Type, len(t.Elems))
+		for i, elem := range t.Elems {
+			elems[i] = cg.toLLVMType(elem)
+		}
+
+		return types.NewStruct(elems...)
+	case *mir.StructType:
+		if st, ok := cg.structs[t.Name]; ok {
+			return st
+		}
+
+		return types.I32
+	case *mir.EnumType:
+		if e, ok := cg.enums[t.Name]; ok {
+			return e.typ
+		}
+
+		return types.I32
 	default:
 		return types.I32
 	}
@@ -39,6 +39,55 @@ func TestCodegenSimpleFunction(t *testing.T) {
 	}
 }
 
+func TestCodegenSourceFile(t *testing.T) {
+	cg := NewCodegen()
+	cg.SetSourceFile("main.yar")
+
+	moduleIR := cg.GenModule(&mir.Module{}).String()
+	if !containsString(moduleIR, `source_filename = "main.yar"`) {
+		t.Errorf("expected the source file in generated IR:\n%s", moduleIR)
+	}
+}
+
+func TestCodegenModulePath(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	void := &mir.PrimitiveType{Name: "void"}
+	helper := &mir.Function{
+		Name:   "abs",
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{{Label: "entry", Instrs: []mir.Instruction{&mir.Ret{Value: "1", Type: i32}}}},
+	}
+	main := &mir.Function{
+		Name:  "main",
+		RetTy: void,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Call{Dest: "t1", Callee: "abs", RetTy: i32},
+					&mir.Call{Callee: "println", Args: []string{"t1"}, RetTy: void},
+					&mir.Ret{Type: void},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	cg.SetModulePath("app")
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{helper, main}}).String()
+
+	for _, want := range []string{
+		"define i32 @_Y3app3abs()",
+		"define void @yar_main()",
+		"%t1 = call i32 @_Y3app3abs()",
+		"call void @println_i32(i32 %t1)",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
 func TestCodegenLoadsFunctionParameter(t *testing.T) {
 	i32 := &mir.PrimitiveType{Name: "i32"}
 	mirFn := &mir.Function{
@@ -256,6 +305,133 @@ func TestCodegenBinOpComparison(t *testing.T) {
 	}
 }
 
+func TestCodegenUnsignedOps(t *testing.T) {
+	u8 := &mir.PrimitiveType{Name: "u8"}
+	i8 := &mir.PrimitiveType{Name: "i8"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "a", Type: u8}, {Name: "b", Type: i8}},
+		RetTy:  &mir.PrimitiveType{Name: "bool"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.BinOp{Dest: "t1", Op: mir.Div, Left: "a", Right: "3", Type: u8},
+					&mir.BinOp{Dest: "t2", Op: mir.Mod, Left: "a", Right: "3", Type: u8},
+					&mir.BinOp{Dest: "t3", Op: mir.Shr, Left: "a", Right: "1", Type: u8},
+					&mir.BinOp{Dest: "t4", Op: mir.Div, Left: "b", Right: "3", Type: i8},
+					&mir.BinOp{Dest: "t5", Op: mir.Shr, Left: "b", Right: "1", Type: i8},
+					&mir.BinOp{Dest: "t6", Op: mir.Lt, Left: "b", Right: "0", Type: i8},
+					&mir.BinOp{Dest: "t7", Op: mir.Lt, Left: "t1", Right: "t3", Type: u8},
+					&mir.Ret{Value: "t7", Type: &mir.PrimitiveType{Name: "bool"}},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	for _, want := range []string{
+		"%t1 = udiv i8 %a, 3",
+		"%t2 = urem i8",
+		"%t3 = lshr i8",
+		"%t4 = sdiv i8",
+		"%t5 = ashr i8",
+		"%t6 = icmp slt i8",
+		"%t7 = icmp ult i8 %t1, %t3",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenFunctionValues(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	fnTy := &mir.FuncType{Params: []mir.Type{i32}, Ret: i32}
+	neg := &mir.Function{
+		Name:   "neg",
+		Params: []mir.Param{{Name: "x", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{{
+			Label: "entry",
+			Instrs: []mir.Instruction{
+				&mir.BinOp{Dest: "t1", Op: mir.Sub, Left: "0", Right: "x", Type: i32},
+				&mir.Ret{Value: "t1", Type: i32},
+			},
+		}},
+	}
+	apply := &mir.Function{
+		Name:   "apply",
+		Params: []mir.Param{{Name: "f", Type: fnTy}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{{
+			Label: "entry",
+			Instrs: []mir.Instruction{
+				&mir.IndirectCall{Dest: "t1", Callee: "f", Args: []string{"5"}, Type: fnTy},
+				&mir.Ret{Value: "t1", Type: i32},
+			},
+		}},
+	}
+	test := &mir.Function{
+		Name:  "test",
+		RetTy: i32,
+		Blocks: []*mir.BasicBlock{{
+			Label: "entry",
+			Instrs: []mir.Instruction{
+				&mir.FuncRef{Dest: "t1", Func: "neg", Type: fnTy},
+				&mir.Call{Dest: "t2", Callee: "apply", Args: []string{"t1"}, RetTy: i32},
+				&mir.Ret{Value: "t2", Type: i32},
+			},
+		}},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{neg, apply, test}}).String()
+
+	for _, want := range []string{
+		"define i32 @apply(i32 (i32)* %f)",
+		"%t1 = call i32 %f(i32 5)",
+		"%t2 = call i32 @apply(i32 (i32)* @neg)",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenVec(t *testing.T) {
+	i64 := &mir.PrimitiveType{Name: "i64"}
+	vec := &mir.PtrType{Elem: &mir.PrimitiveType{Name: "i8"}}
+	pair := &mir.TupleType{Elems: []mir.Type{&mir.PrimitiveType{Name: "i32"}, i64}}
+
+	fn := &mir.Function{
+		Name:  "test",
+		RetTy: i64,
+		Blocks: []*mir.BasicBlock{{
+			Label: "entry",
+			Instrs: []mir.Instruction{
+				&mir.SizeOf{Dest: "t1", Type: pair},
+				&mir.Call{Dest: "t2", Callee: "yar_vec_new", Args: []string{"t1"}, RetTy: vec},
+				&mir.Call{Dest: "t3", Callee: "yar_vec_len", Args: []string{"t2"}, RetTy: i64},
+				&mir.Ret{Value: "t3", Type: i64},
+			},
+		}},
+	}
+
+	moduleIR := NewCodegen().GenModule(&mir.Module{Functions: []*mir.Function{fn}}).String()
+
+	for _, want := range []string{
+		"call i8* @yar_vec_new(i64 16)",
+		"call i64 @yar_vec_len(i8* %t2)",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
 func TestCodegenCall(t *testing.T) {
 	// Create MIR for:
 	// fn helper() i32 { ret i32 42 }
@@ -305,6 +481,49 @@ func TestCodegenCall(t *testing.T) {
 	}
 }
 
+func TestCodegenCallBeforeDefinition(t *testing.T) {
+	// fn test() i32 calls helper, which is defined after it
+	testFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{},
+		RetTy:  &mir.PrimitiveType{Name: "i32"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Call{Dest: "x", Callee: "helper", Args: []string{"1"}, RetTy: &mir.PrimitiveType{Name: "i32"}},
+					&mir.Ret{Value: "x", Type: &mir.PrimitiveType{Name: "i32"}},
+				},
+			},
+		},
+	}
+
+	helperFn := &mir.Function{
+		Name:   "helper",
+		Params: []mir.Param{{Name: "a", Type: &mir.PrimitiveType{Name: "i32"}}},
+		RetTy:  &mir.PrimitiveType{Name: "i32"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Ret{Value: "42", Type: &mir.PrimitiveType{Name: "i32"}},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{testFn, helperFn}}).String()
+
+	if containsString(moduleIR, "declare i32 @helper") {
+		t.Errorf("expected helper to be defined once, not declared:\n%s", moduleIR)
+	}
+
+	if !containsString(moduleIR, "define i32 @helper(i32 %a)") {
+		t.Errorf("expected helper definition in generated IR:\n%s", moduleIR)
+	}
+}
+
 func TestCodegenBranch(t *testing.T) {
 	// Create MIR for: fn test() i32 { br label %end; end: ret i32 42 }
 	mirFn := &mir.Function{
@@ -504,7 +723,7 @@ func TestCodegenStringConstant(t *testing.T) {
 
 	// Check that global string constant was created
 	// LLVM format: @.str.0 = private unnamed_addr constant [6 x i8] c"hello\00"
-	if !containsString(moduleIR, "@.str.0") {
+	if !containsString(moduleIR, `@.str.0 = private unnamed_addr constant [6 x i8] c"hello\00"`) {
 		t.Errorf("expected global string constant @.str.0 in generated IR")
 	}
 	if !containsString(moduleIR, "hello") {
@@ -514,7 +733,7 @@ func TestCodegenStringConstant(t *testing.T) {
 
 func TestCodegenStringInCall(t *testing.T) {
 	// Test that string constants can be passed to function calls
-	// MIR for: fn main() { call void @println(i8* @.str.0) }
+	// MIR for: fn main() { call void @println(str @.str.0) }
 	mirMod := &mir.Module{
 		Globals: []mir.Global{
 			&mir.GlobalString{Name: ".str.0", Value: "hello"},
@@ -557,10 +776,9 @@ func TestCodegenStringInCall(t *testing.T) {
 		t.Errorf("expected global string constant @.str.0 in generated IR")
 	}
 
-	// Check that the call uses the string reference
-	// The exact format depends on how we handle string arguments
-	if !containsString(moduleIR, "call void @println") {
-		t.Errorf("expected 'call void @println' in generated IR")
+	// The runtime takes the str as its pointer and its length
+	if !containsString(moduleIR, "call void @println(i8* %0, i64 %1)") {
+		t.Errorf("expected the str split into pointer and length in generated IR:\n%s", moduleIR)
 	}
 }
 
@@ -633,3 +851,611 @@ func TestCodegenPrintlnBool(t *testing.T) {
 		t.Fatalf("bool-only module should not declare println_i32, got:\n%s", moduleIR)
 	}
 }
+
+func TestCodegenPrintlnFloat(t *testing.T) {
+	f32 := &mir.PrimitiveType{Name: "f32"}
+	f64 := &mir.PrimitiveType{Name: "f64"}
+	void := &mir.PrimitiveType{Name: "void"}
+	mirFn := &mir.Function{
+		Name:   "main",
+		Params: []mir.Param{{Name: "x", Type: f64}, {Name: "y", Type: f32}},
+		RetTy:  void,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Call{Dest: "", Callee: "println", Args: []string{"x"}, RetTy: void},
+					&mir.Call{Dest: "", Callee: "println", Args: []string{"y"}, RetTy: void},
+					&mir.Ret{Type: void},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	llvmMod := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}})
+	moduleIR := llvmMod.String()
+
+	for _, want := range []string{"call void @println_f64(double %x)", "call void @println_f32(float %y)"} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenCast(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	i64 := &mir.PrimitiveType{Name: "i64"}
+	u8 := &mir.PrimitiveType{Name: "u8"}
+	f32 := &mir.PrimitiveType{Name: "f32"}
+	f64 := &mir.PrimitiveType{Name: "f64"}
+	void := &mir.PrimitiveType{Name: "void"}
+	mirFn := &mir.Function{
+		Name:   "main",
+		Params: []mir.Param{},
+		RetTy:  void,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Cast{Dest: "a", Kind: mir.SExt, Value: "5", From: i32, To: i64},
+					&mir.Cast{Dest: "b", Kind: mir.ZExt, Value: "7", From: u8, To: i64},
+					&mir.Alloca{Name: "x", Type: f32},
+					&mir.Load{Dest: "xv", Source: "x", Type: f32},
+					&mir.Cast{Dest: "c", Kind: mir.FPExt, Value: "xv", From: f32, To: f64},
+					&mir.Ret{Type: void},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	llvmMod := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}})
+	moduleIR := llvmMod.String()
+
+	for _, want := range []string{"%a = sext i32 5 to i64", "%b = zext i8 7 to i64", "%c = fpext float %xv to double"} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR, got:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenVTableDispatch(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	self := &mir.PtrType{Elem: &mir.PrimitiveType{Name: "i8"}}
+	area := &mir.Function{
+		Name:   "Square.area",
+		Params: []mir.Param{{Name: "self", Type: self}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{Label: "entry", Instrs: []mir.Instruction{&mir.Ret{Value: "4", Type: i32}}},
+		},
+	}
+	main := &mir.Function{
+		Name:   "main",
+		Params: []mir.Param{},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Alloca{Name: "sq", Type: i32},
+					&mir.VCall{Dest: "a", VTable: "@vtable.Shape.Square", Slot: 0, Object: "sq", RetTy: i32},
+					&mir.Ret{Value: "a", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	llvmMod := cg.GenModule(&mir.Module{
+		Globals:   []mir.Global{&mir.GlobalVTable{Name: "vtable.Shape.Square", Methods: []string{"Square.area"}}},
+		Functions: []*mir.Function{area, main},
+	})
+	moduleIR := llvmMod.String()
+
+	for _, want := range []string{
+		`@vtable.Shape.Square = private constant [1 x i8*] [i8* bitcast (i32 (i8*)* @Square.area to i8*)]`,
+		`load i8*, i8**`,
+		`bitcast i8* %`,
+		`%a = call i32 %`,
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR, got:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenTupleReturn(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	pair := &mir.TupleType{Elems: []mir.Type{i32, i32}}
+	mirFn := &mir.Function{
+		Name:   "pair",
+		Params: []mir.Param{},
+		RetTy:  pair,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.MakeTuple{Dest: "p", Elems: []string{"1", "2"}, Type: pair},
+					&mir.Extract{Dest: "second", Tuple: "p", Index: 1, Type: i32},
+					&mir.Ret{Value: "p", Type: pair},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	llvmMod := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}})
+	moduleIR := llvmMod.String()
+
+	for _, want := range []string{
+		"define { i32, i32 } @pair()",
+		"insertvalue { i32, i32 } undef, i32 1, 0",
+		"%p = insertvalue { i32, i32 } %0, i32 2, 1",
+		"%second = extractvalue { i32, i32 } %p, 1",
+		"ret { i32, i32 } %p",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR, got:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenPackSlice(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	void := &mir.PrimitiveType{Name: "void"}
+	mirFn := &mir.Function{
+		Name:   "main",
+		Params: []mir.Param{},
+		RetTy:  void,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.PackSlice{Dest: "nums", Elems: []string{"1", "2"}, Elem: i32},
+					&mir.PackSlice{Dest: "empty", Elem: i32},
+					&mir.Ret{Type: void},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	llvmMod := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}})
+	moduleIR := llvmMod.String()
+
+	for _, want := range []string{
+		"alloca [2 x i32]",
+		"store i32 1, i32* %",
+		"store i32 2, i32* %",
+		"%nums = insertvalue { i32*, i64 } %",
+		", i64 2, 1",
+		"insertvalue { i32*, i64 } undef, i32* null, 0",
+		"%empty = insertvalue { i32*, i64 } %",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR, got:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenUnreachable(t *testing.T) {
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{},
+		RetTy:  &mir.PrimitiveType{Name: "i32"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label:  "entry",
+				Instrs: []mir.Instruction{&mir.Unreachable{}},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	if !containsString(moduleIR, "unreachable") {
+		t.Errorf("expected 'unreachable' in generated IR:\n%s", moduleIR)
+	}
+}
+
+func TestCodegenVolatileStore(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{},
+		RetTy:  &mir.PrimitiveType{Name: "void"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Alloca{Name: "key", Type: i32},
+					&mir.Store{Value: "0", Dest: "key", Type: i32, Volatile: true},
+					&mir.Load{Dest: "t1", Source: "key", Type: i32, Volatile: true},
+					&mir.Ret{},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	for _, want := range []string{"store volatile i32 0, i32* %key", "load volatile i32, i32* %key"} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenStr(t *testing.T) {
+	str := &mir.StrType{}
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "name", Type: str}},
+		RetTy:  &mir.PrimitiveType{Name: "bool"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Load{Dest: "t1", Source: "name", Type: str},
+					&mir.Call{Dest: "t2", Callee: "len", Args: []string{"t1"}, RetTy: i32},
+					&mir.BinOp{Dest: "t3", Op: mir.Ne, Left: "t1", Right: "@.str.0", Type: i32},
+					&mir.BinOp{Dest: "t4", Op: mir.Le, Left: "t1", Right: "@.str.0", Type: i32},
+					&mir.BinOp{Dest: "t5", Op: mir.And, Left: "t3", Right: "t4", Type: &mir.PrimitiveType{Name: "bool"}},
+					&mir.Ret{Value: "t5", Type: &mir.PrimitiveType{Name: "bool"}},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{
+		Globals:   []mir.Global{&mir.GlobalString{Name: ".str.0", Value: "hello"}},
+		Functions: []*mir.Function{mirFn},
+	}).String()
+
+	for _, want := range []string{
+		"define i1 @test({ i8*, i64 } %name)",
+		"extractvalue { i8*, i64 } %t1, 1",
+		"trunc i64",
+		"{ i8*, i64 } { i8* getelementptr ([6 x i8], [6 x i8]* @.str.0, i32 0, i32 0), i64 5 }",
+		"call i1 @str_eq(",
+		"xor i1",
+		"call i32 @str_compare(",
+		"icmp sle i32",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenFloat(t *testing.T) {
+	f64 := &mir.PrimitiveType{Name: "f64"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "x", Type: f64}},
+		RetTy:  &mir.PrimitiveType{Name: "bool"},
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Load{Dest: "t1", Source: "x", Type: f64},
+					&mir.BinOp{Dest: "t2", Op: mir.Mul, Left: "t1", Right: "2.5", Type: f64},
+					&mir.BinOp{Dest: "t3", Op: mir.Lt, Left: "t2", Right: "1_000.0", Type: f64},
+					&mir.Ret{Value: "t3", Type: &mir.PrimitiveType{Name: "bool"}},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	for _, want := range []string{
+		"fmul double %t1, 2.5",
+		"fcmp olt double %t2, 1000.0",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenGlobalConst(t *testing.T) {
+	f64 := &mir.PrimitiveType{Name: "f64"}
+	str := &mir.StrType{}
+	mirFn := &mir.Function{
+		Name:  "test",
+		RetTy: f64,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Load{Dest: "t1", Source: "@const.NAME", Type: str},
+					&mir.Call{Callee: "println", Args: []string{"t1"}, RetTy: &mir.PrimitiveType{Name: "void"}},
+					&mir.Load{Dest: "t2", Source: "@const.RATE", Type: f64},
+					&mir.Ret{Value: "t2", Type: f64},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{
+		Globals: []mir.Global{
+			&mir.GlobalString{Name: ".str.0", Value: "yar"},
+			&mir.GlobalConst{Name: "const.NAME", Type: str, Value: "@.str.0"},
+			&mir.GlobalConst{Name: "const.RATE", Type: f64, Value: "-0.5"},
+		},
+		Functions: []*mir.Function{mirFn},
+	}).String()
+
+	for _, want := range []string{
+		"@const.NAME = private constant { i8*, i64 } { i8* getelementptr ([4 x i8], [4 x i8]* @.str.0, i32 0, i32 0), i64 3 }",
+		"@const.RATE = private constant double -0.5",
+		"load { i8*, i64 }, { i8*, i64 }* @const.NAME",
+		"load double, double* @const.RATE",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenDefer(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	void := &mir.PrimitiveType{Name: "void"}
+	show := &mir.Function{
+		Name:   "show",
+		Params: []mir.Param{{Name: "n", Type: i32}},
+		RetTy:  void,
+		Blocks: []*mir.BasicBlock{{Label: "entry", Instrs: []mir.Instruction{&mir.Ret{Type: void}}}},
+	}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "x", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.DeferPush{Call: &mir.Call{Callee: "show", Args: []string{"1"}, RetTy: void}},
+					&mir.Load{Dest: "t1", Source: "x", Type: i32},
+					&mir.DeferPush{Call: &mir.Call{Callee: "show", Args: []string{"t1"}, RetTy: void}},
+					&mir.BinOp{Dest: "t2", Op: mir.Add, Left: "t1", Right: "1", Type: i32},
+					&mir.DeferRunAll{},
+					&mir.Ret{Value: "t2", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{show, mirFn}}).String()
+
+	for _, want := range []string{
+		// The stack starts empty, and each push links a record to it
+		"%defer.head = alloca i8*",
+		"store i8* null, i8** %defer.head",
+		"alloca { i8*, i32 }",
+		"alloca { i8*, i32, i32 }",
+		"store i32 %t1, i32*",
+		// The return value is computed before the loop that runs the
+		// calls, which pops records until the stack is empty
+		"%t2 = add i32 %t1, 1\n\tbr label %defer_run_0",
+		"label %defer_done_0, label %defer_pop_0",
+		"i32 0, label %defer_call_0_0",
+		"i32 1, label %defer_call_0_1",
+		"call void @show(i32 1)",
+		"defer_done_0:\n\tret i32 %t2",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenStruct(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	point := &mir.StructType{Name: "Point"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "x", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Load{Dest: "t1", Source: "x", Type: i32},
+					&mir.Insert{Dest: "t2", Value: "t1", Index: 1, Type: point, Elem: i32},
+					&mir.Alloca{Name: "p", Type: point},
+					&mir.Store{Value: "t2", Dest: "p", Type: point},
+					&mir.FieldAddr{Dest: "t3", Base: "p", Index: 1, Type: point},
+					&mir.Load{Dest: "t4", Source: "t3", Type: i32},
+					&mir.Ret{Value: "t4", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{
+		Structs:   []*mir.StructType{{Name: "Point", Fields: []mir.Type{i32, i32}}},
+		Functions: []*mir.Function{mirFn},
+	}).String()
+
+	for _, want := range []string{
+		"%struct.Point = type { i32, i32 }",
+		"%t2 = insertvalue %struct.Point zeroinitializer, i32 %t1, 1",
+		"%t3 = getelementptr %struct.Point, %struct.Point* %p, i32 0, i32 1",
+		"%t4 = load i32, i32* %t3",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenEnum(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	shape := &mir.EnumType{Name: "Shape"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "x", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Alloca{Name: "s", Type: shape},
+					&mir.TagAddr{Dest: "t1", Base: "s", Type: shape},
+					&mir.Store{Value: "1", Dest: "t1", Type: i32},
+					&mir.Load{Dest: "t2", Source: "x", Type: i32},
+					&mir.PayloadAddr{Dest: "t3", Base: "s", Variant: 1, Index: 0, Type: shape},
+					&mir.Store{Value: "t2", Dest: "t3", Type: i32},
+					&mir.Load{Dest: "t4", Source: "s", Type: shape},
+					&mir.EnumTag{Dest: "t5", Value: "t4", Type: shape},
+					&mir.Ret{Value: "t5", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{
+		Enums: []*mir.EnumType{{Name: "Shape", Variants: []mir.Variant{
+			{Name: "Circle", Tag: 0, Payload: []mir.Type{&mir.PrimitiveType{Name: "f64"}}},
+			{Name: "Rect", Tag: 1, Payload: []mir.Type{i32, &mir.PrimitiveType{Name: "i64"}}},
+			{Name: "Empty", Tag: 2},
+		}}},
+		Functions: []*mir.Function{mirFn},
+	}).String()
+
+	for _, want := range []string{
+		"%enum.Shape = type { i32, [2 x i64] }",
+		"%t1 = getelementptr %enum.Shape, %enum.Shape* %s, i32 0, i32 0",
+		"bitcast [2 x i64]* %0 to { i32, i64 }*",
+		"%t3 = getelementptr { i32, i64 }, { i32, i64 }* %1, i32 0, i32 0",
+		"%t5 = extractvalue %enum.Shape %t4, 0",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenElemAddr(t *testing.T) {
+	i64 := &mir.PrimitiveType{Name: "i64"}
+	array := &mir.ArrayType{Elem: i64, Len: 4}
+	slice := &mir.SliceType{Elem: i64}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "a", Type: array}, {Name: "s", Type: slice}},
+		RetTy:  i64,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.ElemAddr{Dest: "t1", Base: "a", Index: "2", Type: array},
+					&mir.Load{Dest: "t2", Source: "t1", Type: i64},
+					&mir.ElemAddr{Dest: "t3", Base: "s", Index: "1", Type: slice},
+					&mir.Load{Dest: "t4", Source: "t3", Type: i64},
+					&mir.BinOp{Dest: "t5", Op: mir.Add, Left: "t2", Right: "t4", Type: i64},
+					&mir.Ret{Value: "t5", Type: i64},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	for _, want := range []string{
+		"define i64 @test([4 x i64] %a, { i64*, i64 } %s)",
+		"%t1 = getelementptr [4 x i64], [4 x i64]* %a.addr, i32 0, i64 2",
+		"%t3 = getelementptr i64, i64* %1, i64 1",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenAddrOf(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	mirFn := &mir.Function{
+		Name:  "test",
+		RetTy: i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Alloca{Name: "a", Type: i32},
+					&mir.Store{Value: "1", Dest: "a", Type: i32},
+					&mir.AddrOf{Dest: "t1", Source: "a", Type: i32},
+					&mir.Store{Value: "2", Dest: "t1", Type: i32},
+					&mir.Load{Dest: "t2", Source: "t1", Type: i32},
+					&mir.Ret{Value: "t2", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	for _, want := range []string{
+		"store i32 2, i32* %a",
+		"%t2 = load i32, i32* %a",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+}
+
+func TestCodegenPhi(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "n", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.Br{Label: "loop"},
+				},
+			},
+			{
+				Label: "loop",
+				Instrs: []mir.Instruction{
+					// The back edge refers to t1, which is generated after the phi
+					&mir.Phi{Dest: "i", Type: i32, Incoming: []mir.Incoming{{Value: "0", Label: "entry"}, {Value: "t1", Label: "loop"}}},
+					&mir.BinOp{Dest: "t1", Op: mir.Add, Left: "i", Right: "1", Type: i32},
+					&mir.BinOp{Dest: "t2", Op: mir.Lt, Left: "t1", Right: "n", Type: i32},
+					&mir.CondBr{Cond: "t2", TrueLabel: "loop", FalseLabel: "exit"},
+				},
+			},
+			{
+				Label: "exit",
+				Instrs: []mir.Instruction{
+					&mir.Ret{Value: "t1", Type: i32},
+				},
+			},
+		},
+	}
+
+	cg := NewCodegen()
+	moduleIR := cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+
+	want := "%i = phi i32 [ 0, %entry ], [ %t1, %loop ]"
+	if !containsString(moduleIR, want) {
+		t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+	}
+}
@@ -0,0 +1,160 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/yarlson/yarlang/mir"
+)
+
+// A function's deferred calls are kept on a stack in its own frame. Each
+// DeferPush allocates a record holding a pointer to the record pushed
+// before it, the index of its site and the arguments of the call, so a
+// defer in a loop pushes a record each time round. DeferRunAll pops the
+// records in LIFO order and makes the call of each one's site.
+
+// deferSite is a DeferPush of the current function
+type deferSite struct {
+	call     *mir.Call
+	record   *types.StructType // i8* link, i32 site, then the captured arguments
+	captured []string          // arguments held in the record, in order
+}
+
+// deferRun is a DeferRunAll whose loop is generated once every site of the
+// function is known: its loop block, and the block after the loop
+type deferRun struct {
+	loop, done *ir.Block
+}
+
+// deferLink is the part of a record every site shares
+var deferLink = types.NewStruct(types.I8Ptr, types.I32)
+
+// hasDefers reports whether fn defers a call
+func hasDefers(fn *mir.Function) bool {
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			if _, ok := instr.(*mir.DeferPush); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// initDefers starts the current function with an empty defer stack
+func (cg *Codegen) initDefers(entry *ir.Block) {
+	cg.deferHead = entry.NewAlloca(types.I8Ptr)
+	cg.deferHead.SetName("defer.head")
+	entry.NewStore(constant.NewNull(types.I8Ptr), cg.deferHead)
+}
+
+// genDeferPush pushes a record for the call. Its arguments are read now;
+// literals and globals are the same when the call runs, so only the other
+// arguments are kept in the record.
+func (cg *Codegen) genDeferPush(push *mir.DeferPush, block *ir.Block) {
+	site := &deferSite{call: push.Call, record: types.NewStruct(types.I8Ptr, types.I32)}
+
+	var args []value.Value
+
+	for _, arg := range push.Call.Args {
+		if isConstant(arg) || strings.HasPrefix(arg, "@") {
+			continue
+		}
+
+		v := cg.getValue(arg, &mir.PrimitiveType{Name: "i32"}, block)
+		site.captured = append(site.captured, arg)
+		site.record.Fields = append(site.record.Fields, v.Type())
+		args = append(args, v)
+	}
+
+	record := block.NewAlloca(site.record)
+
+	block.NewStore(block.NewLoad(types.I8Ptr, cg.deferHead), recordField(block, site.record, record, 0))
+	block.NewStore(constant.NewInt(types.I32, int64(len(cg.deferSites))), recordField(block, site.record, record, 1))
+
+	for n, v := range args {
+		block.NewStore(v, recordField(block, site.record, record, n+2))
+	}
+
+	block.NewStore(block.NewBitCast(record, types.I8Ptr), cg.deferHead)
+
+	cg.deferSites = append(cg.deferSites, site)
+}
+
+// genDeferRunAll branches from block to a loop that runs the deferred
+// calls, and returns the block the loop ends in
+func (cg *Codegen) genDeferRunAll(block *ir.Block) *ir.Block {
+	if cg.deferHead == nil {
+		return block
+	}
+
+	n := len(cg.deferRuns)
+	run := deferRun{
+		loop: cg.currentFn.NewBlock(fmt.Sprintf("defer_run_%d", n)),
+		done: cg.currentFn.NewBlock(fmt.Sprintf("defer_done_%d", n)),
+	}
+
+	block.NewBr(run.loop)
+	cg.deferRuns = append(cg.deferRuns, run)
+
+	return run.done
+}
+
+// finishDefers generates the loops of the current function's DeferRunAlls,
+// each of which pops a record, calls its site and goes round again until
+// the stack is empty
+func (cg *Codegen) finishDefers() {
+	for n, run := range cg.deferRuns {
+		top := run.loop.NewLoad(types.I8Ptr, cg.deferHead)
+		empty := run.loop.NewICmp(enum.IPredEQ, top, constant.NewNull(types.I8Ptr))
+
+		pop := cg.currentFn.NewBlock(fmt.Sprintf("defer_pop_%d", n))
+		run.loop.NewCondBr(empty, run.done, pop)
+
+		link := pop.NewBitCast(top, types.NewPointer(deferLink))
+		pop.NewStore(pop.NewLoad(types.I8Ptr, recordField(pop, deferLink, link, 0)), cg.deferHead)
+		index := pop.NewLoad(types.I32, recordField(pop, deferLink, link, 1))
+
+		cases := make([]*ir.Case, len(cg.deferSites))
+
+		for k, site := range cg.deferSites {
+			call := cg.currentFn.NewBlock(fmt.Sprintf("defer_call_%d_%d", n, k))
+
+			// The call reads its arguments from the record. The function's
+			// values are not needed after this, so they are not restored.
+			if len(site.captured) > 0 {
+				record := call.NewBitCast(top, types.NewPointer(site.record))
+
+				for i, arg := range site.captured {
+					cg.values[arg] = call.NewLoad(site.record.Fields[i+2], recordField(call, site.record, record, i+2))
+				}
+			}
+
+			// The result of a deferred call is dropped
+			c := *site.call
+			c.Dest = ""
+			cg.genCall(&c, call)
+
+			call.NewBr(run.loop)
+			cases[k] = ir.NewCase(constant.NewInt(types.I32, int64(k)), call)
+		}
+
+		pop.NewSwitch(index, run.loop, cases...)
+	}
+
+	cg.deferHead = nil
+	cg.deferSites = nil
+	cg.deferRuns = nil
+}
+
+// recordField returns a pointer to field n of a defer record
+func recordField(block *ir.Block, typ *types.StructType, record value.Value, n int) value.Value {
+	zero := constant.NewInt(types.I32, 0)
+	return block.NewGetElementPtr(typ, record, zero, constant.NewInt(types.I32, int64(n)))
+}
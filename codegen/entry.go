@@ -0,0 +1,35 @@
+package codegen
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+// The program's main links as yar_main. The C main, which the process
+// starts in, hands the command line to the runtime and calls it. A main
+// that returns i32 gives the exit status; one that returns nothing exits
+// with 0.
+
+// entrySymbol is the symbol the program's main links as
+const entrySymbol = "yar_main"
+
+// genEntry defines the C main, which calls main
+func (cg *Codegen) genEntry(main *ir.Func) {
+	argc := ir.NewParam("argc", types.I32)
+	argv := ir.NewParam("argv", types.NewPointer(types.I8Ptr))
+
+	fn := cg.mod.NewFunc("main", types.I32, argc, argv)
+	block := fn.NewBlock("entry")
+
+	setArgs := cg.getOrCreateFunction("yar_set_args", types.Void, []types.Type{argc.Typ, argv.Typ})
+	block.NewCall(setArgs, argc, argv)
+
+	status := block.NewCall(main)
+	if main.Sig.RetType.Equal(types.I32) {
+		block.NewRet(status)
+		return
+	}
+
+	block.NewRet(constant.NewInt(types.I32, 0))
+}
@@ -0,0 +1,54 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/yarlson/yarlang/mir"
+)
+
+func TestCodegenEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		ret   mir.Type
+		value string
+		want  []string
+	}{
+		{
+			name: "void main exits with 0",
+			ret:  &mir.PrimitiveType{Name: "void"},
+			want: []string{
+				"define void @yar_main()",
+				"define i32 @main(i32 %argc, i8** %argv)",
+				"call void @yar_set_args(i32 %argc, i8** %argv)",
+				"call void @yar_main()\n\tret i32 0",
+			},
+		},
+		{
+			name:  "i32 main gives the exit status",
+			ret:   &mir.PrimitiveType{Name: "i32"},
+			value: "3",
+			want: []string{
+				"define i32 @yar_main()",
+				"%0 = call i32 @yar_main()\n\tret i32 %0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := &mir.Function{
+				Name:   "main",
+				RetTy:  tt.ret,
+				Blocks: []*mir.BasicBlock{{Label: "entry", Instrs: []mir.Instruction{&mir.Ret{Value: tt.value, Type: tt.ret}}}},
+			}
+
+			moduleIR := NewCodegen().GenModule(&mir.Module{Functions: []*mir.Function{main}}).String()
+
+			for _, want := range tt.want {
+				if !containsString(moduleIR, want) {
+					t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package codegen
+
+import (
+	"github.com/llir/llvm/ir/types"
+	"github.com/yarlson/yarlang/mir"
+)
+
+// enumLayout is the LLVM form of a MIR enum: a struct of the i32 tag and
+// an array sized and aligned for the largest payload, and for each variant
+// the struct its payload is stored as
+type enumLayout struct {
+	typ      *types.StructType
+	payloads []*types.StructType
+}
+
+// defineEnum fills in the named struct type of e once the types its
+// payloads refer to are complete
+func (cg *Codegen) defineEnum(e *mir.EnumType) {
+	layout := cg.enums[e.Name]
+
+	var size, align int64 = 0, 1
+
+	for _, v := range e.Variants {
+		payload := &types.StructType{}
+		for _, t := range v.Payload {
+			payload.Fields = append(payload.Fields, cg.toLLVMType(t))
+		}
+
+		layout.payloads = append(layout.payloads, payload)
+
+		s, a := sizeOf(payload)
+		size = max(size, s)
+		align = max(align, a)
+	}
+
+	// The payload area is an array of integers as wide as the strictest
+	// alignment, so the struct is aligned for every payload
+	unit := types.NewInt(uint64(align * 8))
+	layout.typ.Fields = []types.Type{types.I32, types.NewArray(uint64((size+align-1)/align), unit)}
+}
+
+// sizeOf returns the size and alignment in bytes of t on a 64-bit target
+func sizeOf(t types.Type) (int64, int64) {
+	switch t := t.(type) {
+	case *types.IntType:
+		n := max(int64(t.BitSize+7)/8, 1)
+		return n, n
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return 4, 4
+		}
+
+		return 8, 8
+	case *types.PointerType:
+		return 8, 8
+	case *types.ArrayType:
+		size, align := sizeOf(t.ElemType)
+		return size * int64(t.Len), align
+	case *types.StructType:
+		var size, align int64 = 0, 1
+
+		for _, field := range t.Fields {
+			s, a := sizeOf(field)
+			size = (size+a-1)/a*a + s
+			align = max(align, a)
+		}
+
+		return (size + align - 1) / align * align, align
+	default:
+		return 8, 8
+	}
+}
@@ -0,0 +1,29 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestSizeOf(t *testing.T) {
+	tests := []struct {
+		typ         types.Type
+		size, align int64
+	}{
+		{types.I1, 1, 1},
+		{types.I32, 4, 4},
+		{types.Double, 8, 8},
+		{types.I8Ptr, 8, 8},
+		{types.NewStruct(types.I8, types.I64), 16, 8},
+		{types.NewStruct(types.I32, types.I8), 8, 4},
+		{types.NewArray(3, types.I16), 6, 2},
+	}
+
+	for _, tt := range tests {
+		size, align := sizeOf(tt.typ)
+		if size != tt.size || align != tt.align {
+			t.Errorf("sizeOf(%s) = %d, %d, want %d, %d", tt.typ, size, align, tt.size, tt.align)
+		}
+	}
+}
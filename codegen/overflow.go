@@ -0,0 +1,86 @@
+package codegen
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/yarlson/yarlang/mir"
+)
+
+// With overflow checks on, integer addition, subtraction and multiplication
+// go through LLVM's *.with.overflow intrinsics. A result that does not fit
+// its type branches to a block that panics, and the rest of the MIR block
+// continues in a block of its own. With them off, the operations wrap.
+
+// overflowOps names the checked operations in intrinsics
+var overflowOps = map[mir.OpKind]string{mir.Add: "add", mir.Sub: "sub", mir.Mul: "mul"}
+
+// overflowVerbs names them in the message a failed check panics with
+var overflowVerbs = map[mir.OpKind]string{mir.Add: "add", mir.Sub: "subtract", mir.Mul: "multiply"}
+
+// SetOverflowChecks turns checked integer arithmetic on or off
+func (cg *Codegen) SetOverflowChecks(on bool) {
+	cg.overflowChecks = on
+}
+
+// checksOverflow reports whether op on left is checked
+func (cg *Codegen) checksOverflow(op mir.OpKind, left value.Value) bool {
+	t, ok := left.Type().(*types.IntType)
+	_, checked := overflowOps[op]
+
+	return cg.overflowChecks && checked && ok && t.BitSize > 1
+}
+
+// checkedOp emits op with an overflow check. It returns the result and the
+// block the code after it goes in.
+func (cg *Codegen) checkedOp(block *ir.Block, op mir.OpKind, unsigned bool, left, right value.Value) (value.Value, *ir.Block) {
+	t := left.Type().(*types.IntType)
+
+	sign := "s"
+	if unsigned {
+		sign = "u"
+	}
+
+	name := fmt.Sprintf("llvm.%s%s.with.overflow.i%d", sign, overflowOps[op], t.BitSize)
+	intrinsic := cg.getOrCreateFunction(name, types.NewStruct(t, types.I1), []types.Type{t, t})
+	pair := block.NewCall(intrinsic, left, right)
+
+	n := cg.overflows
+	cg.overflows++
+
+	failed := cg.currentFn.NewBlock(fmt.Sprintf("overflow_%d", n))
+	cont := cg.currentFn.NewBlock(fmt.Sprintf("no_overflow_%d", n))
+	block.NewCondBr(block.NewExtractValue(pair, 1), failed, cont)
+
+	msg, length := cg.overflowMessage(op)
+	panicFn := cg.getOrCreateFunction("panic", types.Void, []types.Type{types.I8Ptr, types.I64})
+	failed.NewCall(panicFn, msg, length)
+	failed.NewUnreachable()
+
+	return cont.NewExtractValue(pair, 0), cont
+}
+
+// overflowMessage returns the first byte and the length of the message a
+// failed check of op panics with
+func (cg *Codegen) overflowMessage(op mir.OpKind) (constant.Constant, constant.Constant) {
+	name := "overflow." + overflowOps[op]
+
+	global, ok := cg.globals[name]
+	if !ok {
+		text := fmt.Sprintf("attempt to %s with overflow", overflowVerbs[op])
+		global = cg.mod.NewGlobalDef(name, constant.NewCharArrayFromString(text+"\x00"))
+		global.Linkage = enum.LinkagePrivate
+		global.UnnamedAddr = enum.UnnamedAddrUnnamedAddr
+		global.Immutable = true
+		cg.globals[name] = global
+	}
+
+	array := global.ContentType.(*types.ArrayType)
+	zero := constant.NewInt(types.I32, 0)
+
+	return constant.NewGetElementPtr(array, global, zero, zero), constant.NewInt(types.I64, int64(array.Len-1))
+}
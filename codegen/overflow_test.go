@@ -0,0 +1,54 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/yarlson/yarlang/mir"
+)
+
+func TestCodegenOverflowChecks(t *testing.T) {
+	i32 := &mir.PrimitiveType{Name: "i32"}
+	u8 := &mir.PrimitiveType{Name: "u8"}
+	mirFn := &mir.Function{
+		Name:   "test",
+		Params: []mir.Param{{Name: "a", Type: i32}, {Name: "b", Type: u8}},
+		RetTy:  i32,
+		Blocks: []*mir.BasicBlock{
+			{
+				Label: "entry",
+				Instrs: []mir.Instruction{
+					&mir.BinOp{Dest: "t1", Op: mir.Add, Left: "a", Right: "1", Type: i32},
+					&mir.BinOp{Dest: "t2", Op: mir.Mul, Left: "b", Right: "2", Type: u8},
+					&mir.BinOp{Dest: "t3", Op: mir.Div, Left: "t1", Right: "2", Type: i32},
+					&mir.Ret{Value: "t3", Type: i32},
+				},
+			},
+		},
+	}
+
+	gen := func(checks bool) string {
+		cg := NewCodegen()
+		cg.SetOverflowChecks(checks)
+
+		return cg.GenModule(&mir.Module{Functions: []*mir.Function{mirFn}}).String()
+	}
+
+	moduleIR := gen(true)
+	for _, want := range []string{
+		"call { i32, i1 } @llvm.sadd.with.overflow.i32(i32 %a, i32 1)",
+		"br i1 %1, label %overflow_0, label %no_overflow_0",
+		"call void @panic(i8* getelementptr ([29 x i8], [29 x i8]* @overflow.add, i32 0, i32 0), i64 28)",
+		`c"attempt to multiply with overflow\00"`,
+		"no_overflow_0:\n\t%t1 = extractvalue { i32, i1 } %0, 0",
+		"@llvm.umul.with.overflow.i8(i8 %b, i8 2)",
+		"%t3 = sdiv i32 %t1, 2",
+	} {
+		if !containsString(moduleIR, want) {
+			t.Errorf("expected %q in generated IR:\n%s", want, moduleIR)
+		}
+	}
+
+	if moduleIR := gen(false); containsString(moduleIR, "overflow") {
+		t.Errorf("expected wrapping arithmetic without checks:\n%s", moduleIR)
+	}
+}
@@ -0,0 +1,177 @@
+// Package consteval evaluates integer constant expressions at compile time
+package consteval
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// Lookup resolves the name of a constant to its value
+type Lookup func(name string) (int64, bool)
+
+// Eval computes the value of expr. It fails on anything that is not built
+// from integer literals, named constants and integer operators, and on
+// overflow or division by zero.
+func Eval(expr ast.Expr, lookup Lookup) (int64, error) {
+	switch e := expr.(type) {
+	case *ast.IntLit:
+		v, err := strconv.ParseInt(e.Value, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer constant: %s", e.Value)
+		}
+
+		return v, nil
+	case *ast.Ident:
+		if v, ok := lookup(e.Name); ok {
+			return v, nil
+		}
+
+		return 0, fmt.Errorf("%s is not a constant", e.Name)
+	case *ast.ConvExpr:
+		return Eval(e.Expr, lookup)
+	case *ast.UnaryExpr:
+		v, err := Eval(e.Expr, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		return unary(e.Op, v)
+	case *ast.BinaryExpr:
+		left, err := Eval(e.Left, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		right, err := Eval(e.Right, lookup)
+		if err != nil {
+			return 0, err
+		}
+
+		return binary(e.Op, left, right)
+	default:
+		return 0, fmt.Errorf("%s is not a constant expression", expr.String())
+	}
+}
+
+func unary(op string, v int64) (int64, error) {
+	switch op {
+	case "-":
+		if v == minInt {
+			return 0, errOverflow
+		}
+
+		return -v, nil
+	case "+":
+		return v, nil
+	case "~":
+		return ^v, nil
+	default:
+		return 0, fmt.Errorf("operator %s is not allowed in a constant expression", op)
+	}
+}
+
+const minInt = -1 << 63
+
+var errOverflow = errors.New("constant expression overflows")
+
+func binary(op string, a, b int64) (int64, error) {
+	switch op {
+	case "+":
+		r := a + b
+		if (r > a) != (b > 0) {
+			return 0, errOverflow
+		}
+
+		return r, nil
+	case "-":
+		r := a - b
+		if (r < a) != (b > 0) {
+			return 0, errOverflow
+		}
+
+		return r, nil
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+
+		r := a * b
+		if r/b != a || (a == -1 && b == minInt) || (b == -1 && a == minInt) {
+			return 0, errOverflow
+		}
+
+		return r, nil
+	case "/", "%":
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero in constant expression")
+		}
+
+		if a == minInt && b == -1 {
+			return 0, errOverflow
+		}
+
+		if op == "/" {
+			return a / b, nil
+		}
+
+		return a % b, nil
+	case "<<":
+		if b < 0 || b >= 64 || bits.Len64(uint64(abs(a)))+int(b) > 63 {
+			return 0, errOverflow
+		}
+
+		return a << b, nil
+	case ">>":
+		if b < 0 {
+			return 0, fmt.Errorf("negative shift count in constant expression")
+		}
+
+		if b >= 64 {
+			b = 63
+		}
+
+		return a >> b, nil
+	case "&":
+		return a & b, nil
+	case "|":
+		return a | b, nil
+	case "^":
+		return a ^ b, nil
+	default:
+		return 0, fmt.Errorf("operator %s is not allowed in a constant expression", op)
+	}
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+// Fits reports whether v is representable in the named integer type
+func Fits(v int64, typ string) bool {
+	switch typ {
+	case "i8":
+		return v >= -1<<7 && v < 1<<7
+	case "i16":
+		return v >= -1<<15 && v < 1<<15
+	case "i32":
+		return v >= -1<<31 && v < 1<<31
+	case "u8":
+		return v >= 0 && v < 1<<8
+	case "u16":
+		return v >= 0 && v < 1<<16
+	case "u32":
+		return v >= 0 && v < 1<<32
+	case "u64", "usize":
+		return v >= 0
+	default:
+		return true
+	}
+}
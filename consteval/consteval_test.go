@@ -0,0 +1,89 @@
+package consteval
+
+import (
+	"testing"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestEval(t *testing.T) {
+	consts := map[string]int64{"KB": 1024}
+	lookup := func(name string) (int64, bool) {
+		v, ok := consts[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		input string
+		want  int64
+		err   string
+	}{
+		{input: "42", want: 42},
+		{input: "0xff", want: 255},
+		{input: "1_000", want: 1000},
+		{input: "4 * KB", want: 4096},
+		{input: "-(KB / 2) + 1", want: -511},
+		{input: "1 << 10 | 3", want: 1027},
+		{input: "~0 & 0xf", want: 15},
+		{input: "7 % 3 ^ 1", want: 0},
+		{input: "x + 1", err: "x is not a constant"},
+		{input: "1 / (KB - 1024)", err: "division by zero in constant expression"},
+		{input: "0x7fffffffffffffff + 1", err: "constant expression overflows"},
+		{input: "1 << 63", err: "constant expression overflows"},
+		{input: "f(1)", err: "f(1) is not a constant expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := parser.New(lexer.New("const C: i64 = " + tt.input))
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			expr := file.Items[0].(*ast.ConstDecl).Value
+
+			got, err := Eval(expr, lookup)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v (value %d)", tt.err, err, got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		v    int64
+		typ  string
+		want bool
+	}{
+		{127, "i8", true},
+		{128, "i8", false},
+		{-129, "i8", false},
+		{255, "u8", true},
+		{-1, "u32", false},
+		{1 << 31, "i32", false},
+		{1 << 40, "i64", true},
+	}
+
+	for _, tt := range tests {
+		if got := Fits(tt.v, tt.typ); got != tt.want {
+			t.Errorf("Fits(%d, %s) = %v, want %v", tt.v, tt.typ, got, tt.want)
+		}
+	}
+}
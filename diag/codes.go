@@ -0,0 +1,340 @@
+package diag
+
+// Codes of the checker's diagnostics. A code names a problem for good: the
+// message may be reworded or translated, but its code never changes, and
+// new codes are numbered after the last one. Errors are numbered E, lints W.
+const (
+	UnknownDecl             Code = "E0001"
+	DuplicateMethod         Code = "E0002"
+	ImplUndefinedTrait      Code = "E0003"
+	ImplNotTrait            Code = "E0004"
+	NotTraitMember          Code = "E0005"
+	TraitSignature          Code = "E0006"
+	MissingTraitMethod      Code = "E0007"
+	SelfOutsideImpl         Code = "E0008"
+	VariadicNotLast         Code = "E0009"
+	MissingReturn           Code = "E0010"
+	UnknownStmt             Code = "E0011"
+	UnsupportedLocalDecl    Code = "E0012"
+	TypeMismatch            Code = "E0013"
+	ConstantOverflow        Code = "E0014"
+	AlreadyDeclared         Code = "E0015"
+	CannotDestructure       Code = "E0016"
+	UndefinedVariable       Code = "E0017"
+	AssignImmutable         Code = "E0018"
+	AssignCaptured          Code = "E0019"
+	IndexNotInteger         Code = "E0020"
+	CannotIndex             Code = "E0021"
+	NoField                 Code = "E0022"
+	NoTupleElement          Code = "E0023"
+	TypeHasNoField          Code = "E0024"
+	AssignThroughShared     Code = "E0025"
+	AssignFieldImmutable    Code = "E0026"
+	AssignBorrowed          Code = "E0027"
+	ReturnMismatch          Code = "E0028"
+	DeferNotCall            Code = "E0029"
+	IfCondition             Code = "E0030"
+	GuardCondition          Code = "E0031"
+	GuardElse               Code = "E0032"
+	WhileCondition          Code = "E0033"
+	RangeBounds             Code = "E0034"
+	CannotIterate           Code = "E0035"
+	UseAfterMove            Code = "E0036"
+	UnknownExpr             Code = "E0037"
+	MixedOperands           Code = "E0038"
+	BinaryMismatch          Code = "E0039"
+	StrOperator             Code = "E0040"
+	InvalidCast             Code = "E0041"
+	SharedWhileMutable      Code = "E0042"
+	MutableWhileBorrowed    Code = "E0043"
+	DerefNonPointer         Code = "E0044"
+	NeedsUnsafe             Code = "E0045"
+	InvalidCall             Code = "E0046"
+	UndefinedFunction       Code = "E0047"
+	NotAFunction            Code = "E0048"
+	Undefined               Code = "E0049"
+	NoVariant               Code = "E0050"
+	VariantArity            Code = "E0051"
+	VariantNoPayload        Code = "E0052"
+	VariantValue            Code = "E0053"
+	NoMethod                Code = "E0054"
+	NotAMethod              Code = "E0055"
+	BorrowBehindShared      Code = "E0056"
+	BorrowImmutable         Code = "E0057"
+	TooFewArguments         Code = "E0058"
+	ArgumentCount           Code = "E0059"
+	ArgumentMismatch        Code = "E0060"
+	DuplicateTraitMethod    Code = "E0061"
+	UndefinedType           Code = "E0062"
+	UndefinedTrait          Code = "E0063"
+	DynNotTrait             Code = "E0064"
+	ArrayLengthConst        Code = "E0065"
+	ArrayLengthPositive     Code = "E0066"
+	UnknownType             Code = "E0067"
+	ConstMismatch           Code = "E0068"
+	ConstInvalid            Code = "E0069"
+	ConstOverflow           Code = "E0070"
+	DivisionByZero          Code = "E0071"
+	ConstEval               Code = "E0072"
+	ArithmeticOverflow      Code = "E0073"
+	NegativeIndex           Code = "E0074"
+	IndexOutOfRange         Code = "E0075"
+	DiscriminantWithPayload Code = "E0076"
+	InvalidDiscriminant     Code = "E0077"
+	DuplicateDiscriminant   Code = "E0078"
+	AttrTakesNoArgs         Code = "E0079"
+	UnknownAttr             Code = "E0080"
+	InvalidAttr             Code = "E0081"
+	OptionArity             Code = "E0082"
+	ResultArity             Code = "E0083"
+	NoneNoPayload           Code = "E0084"
+	VariantTakesOne         Code = "E0085"
+	PreludeNoVariant        Code = "E0086"
+	VariantNeedsPayload     Code = "E0087"
+	PropagateNeedsResult    Code = "E0088"
+	PropagateErrType        Code = "E0089"
+	PropagateNeedsOption    Code = "E0090"
+	PropagateOperand        Code = "E0091"
+	InfiniteSize            Code = "E0092"
+	DuplicateImport         Code = "E0093"
+	MainSignature           Code = "E0094"
+	VecArity                Code = "E0095"
+	ElemUnknown             Code = "E0096"
+	NoAssocFunc             Code = "E0097"
+	MapArity                Code = "E0098"
+	MapKey                  Code = "E0099"
+	FormatNotLiteral        Code = "E0100"
+	FormatInvalid           Code = "E0101"
+	FormatArgCount          Code = "E0102"
+	FormatArgType           Code = "E0103"
+	OSNoFunc                Code = "E0104"
+	MovedInLoop             Code = "E0105"
+
+	// Lints, reported at the level the lint is set to
+	UnreachableCode Code = "W0001"
+	InvalidRegex    Code = "W0002"
+	Shadowing       Code = "W0003"
+	UnusedVariable  Code = "W0004"
+	UnusedImport    Code = "W0005"
+)
+
+// Fragments of messages that describe part of a diagnostic, such as a
+// suggestion, the kind of a name or a fix. They have no number of their own.
+const (
+	DidYouMean   Code = "did-you-mean"
+	DescParam    Code = "desc-param"
+	DescVariable Code = "desc-variable"
+	DescEarlier  Code = "desc-earlier"
+
+	FixRemoveImport Code = "fix-remove-import"
+)
+
+// Parser errors and the lines that sum up a run. They have no number of
+// their own.
+const (
+	ParseExpectedToken Code = "parse-expected-token"
+	ParseTypeToken     Code = "parse-type-token"
+	ParseDynTrait      Code = "parse-dyn-trait"
+	ParseFuncTypeSep   Code = "parse-func-type-sep"
+	ParsePathSegment   Code = "parse-path-segment"
+	ParseNoPrefix      Code = "parse-no-prefix"
+	ParseFieldName     Code = "parse-field-name"
+	ParseLetName       Code = "parse-let-name"
+	ParseTupleName     Code = "parse-tuple-name"
+	ParseTupleSep      Code = "parse-tuple-sep"
+	ParseElse          Code = "parse-else"
+	ParseForName       Code = "parse-for-name"
+	ParseForSecond     Code = "parse-for-second"
+	ParseForIn         Code = "parse-for-in"
+	ParseUnsafeBlock   Code = "parse-unsafe-block"
+	ParseDeclToken     Code = "parse-decl-token"
+	ParseFuncName      Code = "parse-func-name"
+	ParseTypeParam     Code = "parse-type-param"
+	ParseParamName     Code = "parse-param-name"
+	ParseParamSep      Code = "parse-param-sep"
+	ParseCloseParen    Code = "parse-close-paren"
+	ParseStructName    Code = "parse-struct-name"
+	ParseFieldSep      Code = "parse-field-sep"
+	ParseEnumName      Code = "parse-enum-name"
+	ParseVariantName   Code = "parse-variant-name"
+	ParseVariantSep    Code = "parse-variant-sep"
+	ParseTraitName     Code = "parse-trait-name"
+	ParseTraitFn       Code = "parse-trait-fn"
+	ParseMethodName    Code = "parse-method-name"
+	ParseTraitEnd      Code = "parse-trait-end"
+	ParseImplFn        Code = "parse-impl-fn"
+	ParseImplEnd       Code = "parse-impl-end"
+	ParseTypeName      Code = "parse-type-name"
+	ParseConstName     Code = "parse-const-name"
+	ParseAliasName     Code = "parse-alias-name"
+	ParseAttrTarget    Code = "parse-attr-target"
+
+	ErrorCount    Code = "error-count"
+	ErrorsCount   Code = "errors-count"
+	FixedProblem  Code = "fixed-problem"
+	FixedProblems Code = "fixed-problems"
+)
+
+// english is the catalog every other one falls back to
+var english = Catalog{
+	UnknownDecl:             "unknown declaration type: %T",
+	DuplicateMethod:         "duplicate method %s on %s",
+	ImplUndefinedTrait:      "%s: undefined trait %s",
+	ImplNotTrait:            "%s: %s is not a trait",
+	NotTraitMember:          "%s: method %s is not a member of trait %s",
+	TraitSignature:          "%s: method %s has signature %s, trait requires %s",
+	MissingTraitMethod:      "%s: missing method %s",
+	SelfOutsideImpl:         "self parameter outside of impl block in %s",
+	VariadicNotLast:         "variadic parameter %s of %s must be last",
+	MissingReturn:           "missing return at end of function %s",
+	UnknownStmt:             "unknown statement type: %T",
+	UnsupportedLocalDecl:    "unsupported local declaration: %T",
+	TypeMismatch:            "type mismatch: expected %s, got %s",
+	ConstantOverflow:        "constant %d overflows %s",
+	AlreadyDeclared:         "%s is already declared in this scope",
+	CannotDestructure:       "cannot destructure %s into %d names",
+	UndefinedVariable:       "undefined variable: %s",
+	AssignImmutable:         "cannot assign to immutable variable: %s",
+	AssignCaptured:          "cannot assign to captured variable: %s",
+	IndexNotInteger:         "index must be an integer, got %s",
+	CannotIndex:             "cannot index %s",
+	NoField:                 "no field %s on type %s",
+	NoTupleElement:          "no element %s in tuple %s",
+	TypeHasNoField:          "type %s has no field %s",
+	AssignThroughShared:     "cannot assign to %s through shared reference %s",
+	AssignFieldImmutable:    "cannot assign to field of immutable variable: %s",
+	AssignBorrowed:          "cannot assign to %s because %s is borrowed",
+	ReturnMismatch:          "return type mismatch: expected %s, got %s",
+	DeferNotCall:            "defer needs a function call, got %s",
+	IfCondition:             "if condition must be bool, got %s",
+	GuardCondition:          "guard condition must be bool, got %s",
+	GuardElse:               "guard else block must end in return, break, continue or panic",
+	WhileCondition:          "while condition must be bool, got %s",
+	RangeBounds:             "range bounds must be integers, got %s",
+	CannotIterate:           "cannot iterate over %s",
+	UseAfterMove:            "use of moved value: %s",
+	UnknownExpr:             "unknown expression type: %T",
+	MixedOperands:           "cannot %s %s and %s, use `as`",
+	BinaryMismatch:          "type mismatch in binary expression: %s and %s",
+	StrOperator:             "operator %s is not defined on str",
+	InvalidCast:             "cannot cast %s to %s",
+	SharedWhileMutable:      "cannot borrow %s as shared because it is also borrowed as mutable",
+	MutableWhileBorrowed:    "cannot borrow %s as mutable because it is already borrowed",
+	DerefNonPointer:         "cannot dereference non-pointer type",
+	NeedsUnsafe:             "%s requires an unsafe block",
+	InvalidCall:             "invalid function call: %T",
+	UndefinedFunction:       "undefined function: %s",
+	NotAFunction:            "%s is not a function",
+	Undefined:               "undefined: %s",
+	NoVariant:               "no variant %s in enum %s",
+	VariantArity:            "wrong number of values for variant %s: expected %d, got %d",
+	VariantNoPayload:        "variant %s has no payload and cannot be called",
+	VariantValue:            "value %d of %s: expected %s, got %s",
+	NoMethod:                "no method %s on type %s",
+	NotAMethod:              "%s is an associated function of %s, not a method",
+	BorrowBehindShared:      "cannot borrow %s as mutable because it is behind a shared reference",
+	BorrowImmutable:         "cannot borrow immutable variable %s as mutable",
+	TooFewArguments:         "function %s expects at least %d arguments, got %d",
+	ArgumentCount:           "function %s expects %d arguments, got %d",
+	ArgumentMismatch:        "argument %d to %s: expected %s, got %s",
+	DuplicateTraitMethod:    "duplicate method %s in trait %s",
+	UndefinedType:           "undefined type: %s",
+	UndefinedTrait:          "undefined trait: %s",
+	DynNotTrait:             "dyn %s: %s is not a trait",
+	ArrayLengthConst:        "array length must be a constant integer: %v",
+	ArrayLengthPositive:     "array length must be positive, got %d",
+	UnknownType:             "unknown type: %T",
+	ConstMismatch:           "type mismatch in const %s: expected %s, got %s",
+	ConstInvalid:            "const %s: %v",
+	ConstOverflow:           "const %s: constant %d overflows %s",
+	DivisionByZero:          "division by zero in %s",
+	ConstEval:               "%s: %v",
+	ArithmeticOverflow:      "%s overflows %s: the result is %d",
+	NegativeIndex:           "index %d in %s is negative",
+	IndexOutOfRange:         "index %d in %s is out of range for length %d",
+	DiscriminantWithPayload: "variant %s of %s cannot have a discriminant: enum %s has payloads",
+	InvalidDiscriminant:     "discriminant of %s::%s: %v",
+	DuplicateDiscriminant:   "variants %s and %s of %s have the same discriminant %d",
+	AttrTakesNoArgs:         "%s takes no arguments",
+	UnknownAttr:             "unknown attribute %s",
+	InvalidAttr:             "%s: %v",
+	OptionArity:             "Option takes 1 type argument, got %d",
+	ResultArity:             "Result takes 2 type arguments, got %d",
+	NoneNoPayload:           "None takes no payload",
+	VariantTakesOne:         "%s takes 1 argument, got %d",
+	PreludeNoVariant:        "%s has no variant %s",
+	VariantNeedsPayload:     "%s::%s needs a payload",
+	PropagateNeedsResult:    "? on %s needs the function to return a Result, but it returns %s",
+	PropagateErrType:        "? cannot return error type %s from a function returning %s",
+	PropagateNeedsOption:    "? on %s needs the function to return an Option, but it returns %s",
+	PropagateOperand:        "? needs a Result or an Option, got %s",
+	InfiniteSize:            "recursive type %s has infinite size: %s; use a pointer or reference to break the cycle",
+	DuplicateImport:         "%s is imported more than once",
+	MainSignature:           "main must take no parameters and return nothing or i32, not %s",
+	VecArity:                "Vec takes 1 type argument, got %d",
+	ElemUnknown:             "element types of the %s are not known; annotate it, as in let v: %s = %s::new()",
+	NoAssocFunc:             "%s has no associated function %s",
+	MapArity:                "Map takes 2 type arguments, got %d",
+	MapKey:                  "Map keys must be integers or str, not %s",
+	FormatNotLiteral:        "println with more than one argument needs a string literal as its format",
+	FormatInvalid:           "invalid format string: %v",
+	FormatArgCount:          "format string has %d placeholders but %d arguments were given",
+	FormatArgType:           "cannot format %s: {} takes integers, floats, bool, char and str",
+	OSNoFunc:                "std::os has no function %s",
+	MovedInLoop:             "use of moved value: %s, moved in the previous iteration of the loop",
+
+	UnreachableCode: "unreachable code after %s",
+	InvalidRegex:    "invalid regex %q in call to %s: %v",
+	Shadowing:       "%s shadows %s",
+	UnusedVariable:  "%s is never used",
+	UnusedImport:    "unused import: %s",
+
+	DidYouMean:   "did you mean %s?",
+	DescParam:    "parameter %s of %s",
+	DescVariable: "variable %s",
+	DescEarlier:  "the earlier declaration of %s",
+
+	FixRemoveImport: "remove the unused import",
+
+	ParseExpectedToken: "expected next token to be %v, got %v instead",
+	ParseTypeToken:     "unexpected token in type: %v",
+	ParseDynTrait:      "expected trait name after dyn",
+	ParseFuncTypeSep:   "expected , or ) in function type",
+	ParsePathSegment:   "expected identifier after ::",
+	ParseNoPrefix:      "no prefix parse function for %v",
+	ParseFieldName:     "expected field name",
+	ParseLetName:       "expected identifier after let",
+	ParseTupleName:     "expected identifier in tuple pattern",
+	ParseTupleSep:      "expected comma or ) after name in tuple pattern",
+	ParseElse:          "expected if or { after else",
+	ParseForName:       "expected identifier after for",
+	ParseForSecond:     "expected identifier after comma",
+	ParseForIn:         "expected 'in' after for variable",
+	ParseUnsafeBlock:   "expected { after unsafe",
+	ParseDeclToken:     "unexpected token in declaration: %v",
+	ParseFuncName:      "expected function name",
+	ParseTypeParam:     "expected type parameter name",
+	ParseParamName:     "expected parameter name",
+	ParseParamSep:      "expected comma or ) after parameter",
+	ParseCloseParen:    "expected )",
+	ParseStructName:    "expected struct name",
+	ParseFieldSep:      "expected comma or } after field",
+	ParseEnumName:      "expected enum name",
+	ParseVariantName:   "expected variant name",
+	ParseVariantSep:    "expected comma or } after variant",
+	ParseTraitName:     "expected trait name",
+	ParseTraitFn:       "expected fn in trait",
+	ParseMethodName:    "expected method name",
+	ParseTraitEnd:      "expected } at end of trait",
+	ParseImplFn:        "expected fn in impl block",
+	ParseImplEnd:       "expected } at end of impl block",
+	ParseTypeName:      "expected type name",
+	ParseConstName:     "expected const name",
+	ParseAliasName:     "expected alias name",
+	ParseAttrTarget:    "attribute %s can only be applied to fn, impl and use declarations",
+
+	ErrorCount:    "%d error",
+	ErrorsCount:   "%d errors",
+	FixedProblem:  "Fixed %d problem in %s",
+	FixedProblems: "Fixed %d problems in %s",
+}
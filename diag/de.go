@@ -0,0 +1,165 @@
+package diag
+
+// german translates the messages into German
+var german = Catalog{
+	UnknownDecl:             "unbekannte Deklarationsart: %T",
+	DuplicateMethod:         "doppelte Methode %s für %s",
+	ImplUndefinedTrait:      "%s: undefinierter Trait %s",
+	ImplNotTrait:            "%s: %s ist kein Trait",
+	NotTraitMember:          "%s: Methode %s gehört nicht zum Trait %s",
+	TraitSignature:          "%s: Methode %s hat die Signatur %s, der Trait verlangt %s",
+	MissingTraitMethod:      "%s: Methode %s fehlt",
+	SelfOutsideImpl:         "self-Parameter außerhalb eines impl-Blocks in %s",
+	VariadicNotLast:         "variadischer Parameter %s von %s muss der letzte sein",
+	MissingReturn:           "fehlendes return am Ende der Funktion %s",
+	UnknownStmt:             "unbekannte Anweisungsart: %T",
+	UnsupportedLocalDecl:    "nicht unterstützte lokale Deklaration: %T",
+	TypeMismatch:            "Typkonflikt: %s erwartet, %s erhalten",
+	ConstantOverflow:        "Konstante %d passt nicht in %s",
+	AlreadyDeclared:         "%s ist in diesem Gültigkeitsbereich bereits deklariert",
+	CannotDestructure:       "%s kann nicht in %d Namen zerlegt werden",
+	UndefinedVariable:       "undefinierte Variable: %s",
+	AssignImmutable:         "Zuweisung an unveränderliche Variable nicht möglich: %s",
+	AssignCaptured:          "Zuweisung an erfasste Variable nicht möglich: %s",
+	IndexNotInteger:         "Index muss eine Ganzzahl sein, erhalten: %s",
+	CannotIndex:             "%s kann nicht indiziert werden",
+	NoField:                 "kein Feld %s im Typ %s",
+	NoTupleElement:          "kein Element %s im Tupel %s",
+	TypeHasNoField:          "Typ %s hat kein Feld %s",
+	AssignThroughShared:     "Zuweisung an %s über die geteilte Referenz %s nicht möglich",
+	AssignFieldImmutable:    "Zuweisung an ein Feld einer unveränderlichen Variable nicht möglich: %s",
+	AssignBorrowed:          "Zuweisung an %s nicht möglich, da %s ausgeliehen ist",
+	ReturnMismatch:          "Rückgabetyp passt nicht: %s erwartet, %s erhalten",
+	DeferNotCall:            "defer braucht einen Funktionsaufruf, erhalten: %s",
+	IfCondition:             "if-Bedingung muss bool sein, erhalten: %s",
+	GuardCondition:          "guard-Bedingung muss bool sein, erhalten: %s",
+	GuardElse:               "der else-Block von guard muss mit return, break, continue oder panic enden",
+	WhileCondition:          "while-Bedingung muss bool sein, erhalten: %s",
+	RangeBounds:             "Bereichsgrenzen müssen Ganzzahlen sein, erhalten: %s",
+	CannotIterate:           "über %s kann nicht iteriert werden",
+	UseAfterMove:            "Verwendung eines verschobenen Werts: %s",
+	UnknownExpr:             "unbekannte Ausdrucksart: %T",
+	MixedOperands:           "%[2]s und %[3]s passen nicht zusammen (%[1]s), verwende `as`",
+	BinaryMismatch:          "Typkonflikt im binären Ausdruck: %s und %s",
+	StrOperator:             "Operator %s ist für str nicht definiert",
+	InvalidCast:             "%s kann nicht in %s umgewandelt werden",
+	SharedWhileMutable:      "%s kann nicht geteilt ausgeliehen werden, da es auch veränderlich ausgeliehen ist",
+	MutableWhileBorrowed:    "%s kann nicht veränderlich ausgeliehen werden, da es bereits ausgeliehen ist",
+	DerefNonPointer:         "Dereferenzierung eines Typs, der kein Zeiger ist",
+	NeedsUnsafe:             "%s erfordert einen unsafe-Block",
+	InvalidCall:             "ungültiger Funktionsaufruf: %T",
+	UndefinedFunction:       "undefinierte Funktion: %s",
+	NotAFunction:            "%s ist keine Funktion",
+	Undefined:               "undefiniert: %s",
+	NoVariant:               "keine Variante %s im Enum %s",
+	VariantArity:            "falsche Anzahl von Werten für Variante %s: %d erwartet, %d erhalten",
+	VariantNoPayload:        "Variante %s hat keine Nutzdaten und kann nicht aufgerufen werden",
+	VariantValue:            "Wert %d von %s: %s erwartet, %s erhalten",
+	NoMethod:                "keine Methode %s für Typ %s",
+	NotAMethod:              "%s ist eine assoziierte Funktion von %s, keine Methode",
+	BorrowBehindShared:      "%s kann nicht veränderlich ausgeliehen werden, da es hinter einer geteilten Referenz liegt",
+	BorrowImmutable:         "unveränderliche Variable %s kann nicht veränderlich ausgeliehen werden",
+	TooFewArguments:         "Funktion %s erwartet mindestens %d Argumente, erhalten: %d",
+	ArgumentCount:           "Funktion %s erwartet %d Argumente, erhalten: %d",
+	ArgumentMismatch:        "Argument %d an %s: %s erwartet, %s erhalten",
+	DuplicateTraitMethod:    "doppelte Methode %s im Trait %s",
+	UndefinedType:           "undefinierter Typ: %s",
+	UndefinedTrait:          "undefinierter Trait: %s",
+	DynNotTrait:             "dyn %s: %s ist kein Trait",
+	ArrayLengthConst:        "Array-Länge muss eine konstante Ganzzahl sein: %v",
+	ArrayLengthPositive:     "Array-Länge muss positiv sein, erhalten: %d",
+	UnknownType:             "unbekannter Typ: %T",
+	ConstMismatch:           "Typkonflikt in const %s: %s erwartet, %s erhalten",
+	ConstInvalid:            "const %s: %v",
+	ConstOverflow:           "const %s: Konstante %d passt nicht in %s",
+	DivisionByZero:          "Division durch null in %s",
+	ConstEval:               "%s: %v",
+	ArithmeticOverflow:      "%s läuft in %s über: das Ergebnis ist %d",
+	NegativeIndex:           "Index %d in %s ist negativ",
+	IndexOutOfRange:         "Index %d in %s liegt außerhalb der Länge %d",
+	DiscriminantWithPayload: "Variante %s von %s kann keine Diskriminante haben: Enum %s hat Nutzdaten",
+	InvalidDiscriminant:     "Diskriminante von %s::%s: %v",
+	DuplicateDiscriminant:   "Varianten %s und %s von %s haben dieselbe Diskriminante %d",
+	AttrTakesNoArgs:         "%s nimmt keine Argumente",
+	UnknownAttr:             "unbekanntes Attribut %s",
+	InvalidAttr:             "%s: %v",
+	OptionArity:             "Option nimmt 1 Typargument, erhalten: %d",
+	ResultArity:             "Result nimmt 2 Typargumente, erhalten: %d",
+	NoneNoPayload:           "None hat keine Nutzdaten",
+	VariantTakesOne:         "%s nimmt 1 Argument, erhalten: %d",
+	PreludeNoVariant:        "%s hat keine Variante %s",
+	VariantNeedsPayload:     "%s::%s braucht Nutzdaten",
+	PropagateNeedsResult:    "? auf %s verlangt, dass die Funktion ein Result zurückgibt, sie gibt aber %s zurück",
+	PropagateErrType:        "? kann den Fehlertyp %s nicht aus einer Funktion zurückgeben, die %s liefert",
+	PropagateNeedsOption:    "? auf %s verlangt, dass die Funktion eine Option zurückgibt, sie gibt aber %s zurück",
+	PropagateOperand:        "? braucht ein Result oder eine Option, erhalten: %s",
+	InfiniteSize:            "rekursiver Typ %s hat unendliche Größe: %s; verwende einen Zeiger oder eine Referenz, um den Zyklus zu brechen",
+	DuplicateImport:         "%s wird mehr als einmal importiert",
+	MainSignature:           "main darf keine Parameter haben und muss nichts oder i32 zurückgeben, nicht %s",
+	VecArity:                "Vec nimmt 1 Typargument, erhalten: %d",
+	ElemUnknown:             "Elementtypen des %s sind nicht bekannt; gib sie an, etwa let v: %s = %s::new()",
+	NoAssocFunc:             "%s hat keine assoziierte Funktion %s",
+	MapArity:                "Map nimmt 2 Typargumente, erhalten: %d",
+	MapKey:                  "Schlüssel einer Map müssen Ganzzahlen oder str sein, nicht %s",
+	FormatNotLiteral:        "println mit mehr als einem Argument braucht ein Stringliteral als Format",
+	FormatInvalid:           "ungültiger Formatstring: %v",
+	FormatArgCount:          "Formatstring hat %d Platzhalter, aber %d Argumente wurden übergeben",
+	FormatArgType:           "%s kann nicht formatiert werden: {} nimmt Ganzzahlen, Gleitkommazahlen, bool, char und str",
+	OSNoFunc:                "std::os hat keine Funktion %s",
+	MovedInLoop:             "Verwendung eines verschobenen Werts: %s, verschoben in der vorigen Iteration der Schleife",
+
+	UnreachableCode: "unerreichbarer Code nach %s",
+	InvalidRegex:    "ungültiger regulärer Ausdruck %q im Aufruf von %s: %v",
+	Shadowing:       "%s verdeckt %s",
+	UnusedVariable:  "%s wird nie verwendet",
+	UnusedImport:    "ungenutzter Import: %s",
+
+	DidYouMean:   "meintest du %s?",
+	DescParam:    "Parameter %s von %s",
+	DescVariable: "Variable %s",
+	DescEarlier:  "die frühere Deklaration von %s",
+
+	FixRemoveImport: "den ungenutzten Import entfernen",
+
+	ParseExpectedToken: "als nächstes Token wurde %v erwartet, erhalten: %v",
+	ParseTypeToken:     "unerwartetes Token in Typ: %v",
+	ParseDynTrait:      "Trait-Name nach dyn erwartet",
+	ParseFuncTypeSep:   ", oder ) im Funktionstyp erwartet",
+	ParsePathSegment:   "Bezeichner nach :: erwartet",
+	ParseNoPrefix:      "ein Ausdruck kann nicht mit %v beginnen",
+	ParseFieldName:     "Feldname erwartet",
+	ParseLetName:       "Bezeichner nach let erwartet",
+	ParseTupleName:     "Bezeichner im Tupelmuster erwartet",
+	ParseTupleSep:      "Komma oder ) nach Name im Tupelmuster erwartet",
+	ParseElse:          "if oder { nach else erwartet",
+	ParseForName:       "Bezeichner nach for erwartet",
+	ParseForSecond:     "Bezeichner nach Komma erwartet",
+	ParseForIn:         "'in' nach der for-Variable erwartet",
+	ParseUnsafeBlock:   "{ nach unsafe erwartet",
+	ParseDeclToken:     "unerwartetes Token in Deklaration: %v",
+	ParseFuncName:      "Funktionsname erwartet",
+	ParseTypeParam:     "Name eines Typparameters erwartet",
+	ParseParamName:     "Parametername erwartet",
+	ParseParamSep:      "Komma oder ) nach Parameter erwartet",
+	ParseCloseParen:    ") erwartet",
+	ParseStructName:    "Struct-Name erwartet",
+	ParseFieldSep:      "Komma oder } nach Feld erwartet",
+	ParseEnumName:      "Enum-Name erwartet",
+	ParseVariantName:   "Variantenname erwartet",
+	ParseVariantSep:    "Komma oder } nach Variante erwartet",
+	ParseTraitName:     "Trait-Name erwartet",
+	ParseTraitFn:       "fn im Trait erwartet",
+	ParseMethodName:    "Methodenname erwartet",
+	ParseTraitEnd:      "} am Ende des Traits erwartet",
+	ParseImplFn:        "fn im impl-Block erwartet",
+	ParseImplEnd:       "} am Ende des impl-Blocks erwartet",
+	ParseTypeName:      "Typname erwartet",
+	ParseConstName:     "Konstantenname erwartet",
+	ParseAliasName:     "Aliasname erwartet",
+	ParseAttrTarget:    "das Attribut %s kann nur an fn-, impl- und use-Deklarationen stehen",
+
+	ErrorCount:    "%d Fehler",
+	ErrorsCount:   "%d Fehler",
+	FixedProblem:  "%d Problem in %s behoben",
+	FixedProblems: "%d Probleme in %s behoben",
+}
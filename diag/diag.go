@@ -0,0 +1,112 @@
+// Package diag holds the codes of the checker's diagnostics and the
+// catalogs their messages are written from, one per language
+package diag
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Code identifies a diagnostic independently of the language it is shown in
+type Code string
+
+// Catalog maps codes to fmt templates. A translation may reorder the
+// arguments of a template with explicit indexes such as %[2]s.
+type Catalog map[Code]string
+
+// catalogs are the languages messages can be shown in, by locale
+var catalogs = map[string]Catalog{
+	"en": english,
+	"de": german,
+}
+
+// DefaultLocale is the language of the messages when none is chosen
+const DefaultLocale = "en"
+
+// Message renders the message of code in the language of locale. Codes the
+// catalog of locale lacks, and locales without a catalog, use English.
+func Message(locale string, code Code, args ...any) string {
+	tmpl, ok := catalogs[locale][code]
+	if !ok {
+		tmpl, ok = english[code]
+	}
+
+	if !ok {
+		return strings.TrimSuffix(fmt.Sprintln(append([]any{code}, args...)...), "\n")
+	}
+
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// ConfigFile is the project file whose lang key picks the locale
+const ConfigFile = "yar.toml"
+
+// Locale picks the language of messages for sources in dir: YAR_LANG if set,
+// else the lang key of the nearest yar.toml in dir or a parent of it, else
+// English
+func Locale(dir string) string {
+	if lang := os.Getenv("YAR_LANG"); lang != "" {
+		return normalize(lang)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return DefaultLocale
+	}
+
+	for {
+		if lang, ok := configLang(filepath.Join(dir, ConfigFile)); ok {
+			return normalize(lang)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return DefaultLocale
+		}
+
+		dir = parent
+	}
+}
+
+// configLang reads the top-level lang = "..." key of a yar.toml. It knows
+// only as much TOML as that key needs.
+func configLang(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Keys after the first table belong to that table
+		if strings.HasPrefix(line, "[") {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "lang" {
+			continue
+		}
+
+		value, _, _ = strings.Cut(value, "#")
+
+		return strings.Trim(strings.TrimSpace(value), `"'`), true
+	}
+
+	return "", false
+}
+
+// normalize reduces a locale such as de_DE.UTF-8 to its language, de
+func normalize(locale string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), ".")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang, _, _ = strings.Cut(lang, "-")
+
+	return lang
+}
@@ -0,0 +1,115 @@
+package diag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCatalogsMatchEnglish checks that each translation knows only codes
+// English has, and formats the same arguments with the same verbs
+func TestCatalogsMatchEnglish(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for code, tmpl := range catalog {
+			want, ok := english[code]
+			if !ok {
+				t.Errorf("%s: %s is not an English code", locale, code)
+				continue
+			}
+
+			if got, want := verbs(tmpl), verbs(want); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s formats %v, English formats %v", locale, code, got, want)
+			}
+		}
+	}
+}
+
+// verbs maps each argument a template formats to its verb
+func verbs(tmpl string) map[int]byte {
+	out := make(map[int]byte)
+	arg := 0
+
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			continue
+		}
+
+		i++
+		if i < len(tmpl) && tmpl[i] == '%' {
+			continue
+		}
+
+		if i < len(tmpl) && tmpl[i] == '[' {
+			end := strings.IndexByte(tmpl[i:], ']')
+			n, _ := strconv.Atoi(tmpl[i+1 : i+end])
+			arg = n - 1
+			i += end + 1
+		}
+
+		for i < len(tmpl) && strings.IndexByte("+-# 0123456789.", tmpl[i]) >= 0 {
+			i++
+		}
+
+		if i < len(tmpl) {
+			out[arg] = tmpl[i]
+			arg++
+		}
+	}
+
+	return out
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		locale string
+		code   Code
+		args   []any
+		want   string
+	}{
+		{"en", UndefinedVariable, []any{"y"}, "undefined variable: y"},
+		{"de", UndefinedVariable, []any{"y"}, "undefinierte Variable: y"},
+		{"de", MixedOperands, []any{"add", "i32", "i64"}, "i32 und i64 passen nicht zusammen (add), verwende `as`"},
+		{"fr", UndefinedVariable, []any{"y"}, "undefined variable: y"},
+		{"en", Code("E9999"), []any{"y"}, "E9999 y"},
+	}
+
+	for _, tt := range tests {
+		if got := Message(tt.locale, tt.code, tt.args...); got != tt.want {
+			t.Errorf("Message(%s, %s) = %q, want %q", tt.locale, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	src := filepath.Join(project, "src")
+
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("YAR_LANG", "")
+
+	if got := Locale(src); got != "en" {
+		t.Errorf("without a setting the locale is %q, want en", got)
+	}
+
+	config := "# messages\nlang = \"de\" # German\n\n[build]\nlang = \"en\"\n"
+	if err := os.WriteFile(filepath.Join(project, ConfigFile), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Locale(src); got != "de" {
+		t.Errorf("yar.toml in a parent gives %q, want de", got)
+	}
+
+	t.Setenv("YAR_LANG", "en_US.UTF-8")
+
+	if got := Locale(src); got != "en" {
+		t.Errorf("YAR_LANG gives %q, want en", got)
+	}
+}
@@ -0,0 +1,85 @@
+package diag
+
+import (
+	"sort"
+	"strings"
+)
+
+// Fix is a change to the source that resolves a diagnostic, safe to apply
+// without asking
+type Fix struct {
+	Message string // what the fix does, shown as help
+	Edits   []Edit
+}
+
+// Edit replaces line Line of the source, which must read Old apart from the
+// spaces around it, with New at the same indentation. An empty New removes
+// the line.
+type Edit struct {
+	Line int
+	Old  string
+	New  string
+}
+
+// ApplyFixes applies the fixes of diags to source and returns the result
+// with the number of fixes applied. A fix is skipped whole if the source no
+// longer reads what one of its edits expects, or if an earlier fix already
+// edits one of its lines.
+func ApplyFixes(source []byte, diags []Diagnostic) ([]byte, int) {
+	lines := strings.SplitAfter(string(source), "\n")
+	edits := make(map[int]Edit)
+	applied := 0
+
+	for _, d := range diags {
+		if d.Fix == nil || !fits(lines, edits, d.Fix.Edits) {
+			continue
+		}
+
+		for _, e := range d.Fix.Edits {
+			edits[e.Line] = e
+		}
+
+		applied++
+	}
+
+	order := make([]int, 0, len(edits))
+	for line := range edits {
+		order = append(order, line)
+	}
+
+	// From the bottom up, so removing a line leaves the numbers of the
+	// lines above it alone
+	sort.Sort(sort.Reverse(sort.IntSlice(order)))
+
+	for _, n := range order {
+		e := edits[n]
+		line := lines[n-1]
+
+		if e.New == "" {
+			lines = append(lines[:n-1], lines[n:]...)
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		end := line[len(strings.TrimRight(line, " \t\r\n")):]
+		lines[n-1] = indent + e.New + end
+	}
+
+	return []byte(strings.Join(lines, "")), applied
+}
+
+// fits reports whether edits apply to lines and touch none of the lines
+// taken
+func fits(lines []string, taken map[int]Edit, edits []Edit) bool {
+	for _, e := range edits {
+		if e.Line < 1 || e.Line > len(lines) {
+			return false
+		}
+
+		if _, ok := taken[e.Line]; ok || strings.TrimSpace(lines[e.Line-1]) != e.Old {
+			return false
+		}
+	}
+
+	return len(edits) > 0
+}
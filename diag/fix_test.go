@@ -0,0 +1,35 @@
+package diag
+
+import "testing"
+
+func TestApplyFixes(t *testing.T) {
+	remove := func(line int, old string) Diagnostic {
+		return Diagnostic{Fix: &Fix{Edits: []Edit{{Line: line, Old: old}}}}
+	}
+
+	src := "use a\nuse b\nfn main() {\n\tx := 1\n}\n"
+
+	tests := []struct {
+		name    string
+		diags   []Diagnostic
+		want    string
+		applied int
+	}{
+		{"remove lines", []Diagnostic{remove(1, "use a"), remove(2, "use b")}, "fn main() {\n\tx := 1\n}\n", 2},
+		{"replace keeps the indent", []Diagnostic{{Fix: &Fix{Edits: []Edit{{Line: 4, Old: "x := 1", New: "_x := 1"}}}}}, "use a\nuse b\nfn main() {\n\t_x := 1\n}\n", 1},
+		{"source changed", []Diagnostic{remove(1, "use b")}, src, 0},
+		{"line edited twice", []Diagnostic{remove(2, "use b"), remove(2, "use b")}, "use a\nfn main() {\n\tx := 1\n}\n", 1},
+		{"out of range", []Diagnostic{remove(9, "use a")}, src, 0},
+		{"no fix", []Diagnostic{{Message: "m"}}, src, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, applied := ApplyFixes([]byte(src), tt.diags)
+
+			if string(got) != tt.want || applied != tt.applied {
+				t.Errorf("got %d fixes:\n%s\nwant %d:\n%s", applied, got, tt.applied, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Severity tells errors, which stop compilation, from warnings
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Diagnostic is a problem reported by the parser or the checker
+type Diagnostic struct {
+	Severity Severity
+	Code     Code // empty for problems without a number, such as syntax errors
+	Message  string
+	Notes    []string // shown under the message, such as a suggestion
+	Fix      *Fix     // change that resolves the problem, if one is known
+
+	// Where the problem is, counting from 1. Line is 0 when the reporter
+	// does not know.
+	Line   int
+	Column int
+	Length int // columns the problem spans
+}
+
+// Summary returns the message followed by its notes, on one line
+func (d Diagnostic) Summary() string {
+	return strings.Join(append([]string{d.Message}, d.Notes...), "; ")
+}
+
+// ColorMode is the setting of --color
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+// ParseColorMode reads always, never or auto
+func ParseColorMode(s string) (ColorMode, error) {
+	switch s {
+	case "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	default:
+		return ColorAuto, fmt.Errorf("unknown color mode %q; use always, never or auto", s)
+	}
+}
+
+// Enabled reports whether output to f should be colored. Auto colors a
+// terminal unless NO_COLOR is set or TERM is dumb.
+func (m ColorMode) Enabled(f *os.File) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	blue   = "\x1b[1;34m"
+	cyan   = "\x1b[1;36m"
+)
+
+// Renderer prints diagnostics of one source file, quoting the lines they
+// point at
+type Renderer struct {
+	file  string
+	lines []string
+	color bool
+}
+
+// NewRenderer returns a renderer for diagnostics in source, read from file
+func NewRenderer(file string, source []byte, color bool) *Renderer {
+	return &Renderer{file: file, lines: strings.Split(string(source), "\n"), color: color}
+}
+
+// Render writes d in the form
+//
+//	error[E0017]: undefined variable: y
+//	 --> main.yar:3:10
+//	  |
+//	3 |     println(y)
+//	  |             ^
+//	  = note: did you mean x?
+//	  = help: remove the unused import
+func (r *Renderer) Render(w io.Writer, d Diagnostic) {
+	sev := red
+	if d.Severity == SeverityWarning {
+		sev = yellow
+	}
+
+	head := d.Severity.String()
+	if d.Code != "" {
+		head += "[" + string(d.Code) + "]"
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", r.paint(sev, head), r.paint(bold, d.Message))
+
+	line := ""
+	if d.Line > 0 && d.Line <= len(r.lines) {
+		line = strings.TrimRight(r.lines[d.Line-1], "\r")
+	}
+
+	// The gutter is as wide as the line number, so notes line up under it
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Line)))
+	bar := r.paint(blue, "|")
+
+	switch {
+	case line != "":
+		fmt.Fprintf(w, "%s%s %s:%d:%d\n", gutter, r.paint(blue, "-->"), r.file, d.Line, d.Column)
+		fmt.Fprintf(w, "%s %s\n", gutter, bar)
+		fmt.Fprintf(w, "%s %s %s\n", r.paint(blue, strconv.Itoa(d.Line)), bar, line)
+		fmt.Fprintf(w, "%s %s %s%s\n", gutter, bar, indent(line, d.Column), r.paint(sev, strings.Repeat("^", max(d.Length, 1))))
+	case r.file != "":
+		fmt.Fprintf(w, "%s%s %s\n", gutter, r.paint(blue, "-->"), r.file)
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s %s %s\n", gutter, r.paint(blue, "="), r.paint(cyan, "note:"), note)
+	}
+
+	if d.Fix != nil {
+		fmt.Fprintf(w, "%s %s %s %s\n", gutter, r.paint(blue, "="), r.paint(cyan, "help:"), d.Fix.Message)
+	}
+}
+
+// indent returns the whitespace that puts a caret under column col of
+// line, keeping its tabs so the caret lines up however tabs are shown
+func indent(line string, col int) string {
+	var b strings.Builder
+
+	for i := 0; i < col-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+
+	return b.String()
+}
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.color {
+		return s
+	}
+
+	return color + s + reset
+}
@@ -0,0 +1,90 @@
+package diag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	src := []byte("fn main() {\n\tprintln(y)\n}\n")
+
+	tests := []struct {
+		name string
+		d    Diagnostic
+		want string
+	}{
+		{
+			"span",
+			Diagnostic{Code: UndefinedVariable, Message: "undefined variable: y", Line: 2, Column: 10, Length: 1},
+			"error[E0017]: undefined variable: y\n --> main.yar:2:10\n  |\n2 | \tprintln(y)\n  | \t        ^\n",
+		},
+		{
+			"no position",
+			Diagnostic{Severity: SeverityWarning, Code: UnusedVariable, Message: "variable x is never used", Notes: []string{"did you mean y?"}},
+			"warning[W0004]: variable x is never used\n --> main.yar\n  = note: did you mean y?\n",
+		},
+		{
+			"no code",
+			Diagnostic{Message: "unexpected token", Line: 3, Column: 1, Length: 1},
+			"error: unexpected token\n --> main.yar:3:1\n  |\n3 | }\n  | ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+
+			NewRenderer("main.yar", src, false).Render(&b, tt.d)
+
+			if b.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	var b strings.Builder
+
+	NewRenderer("main.yar", nil, true).Render(&b, Diagnostic{Severity: SeverityWarning, Message: "m"})
+
+	if !strings.HasPrefix(b.String(), yellow+"warning"+reset) {
+		t.Errorf("warning is not yellow: %q", b.String())
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	if _, err := ParseColorMode("sometimes"); err == nil {
+		t.Error("ParseColorMode accepted sometimes")
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+
+	tests := []struct {
+		mode string
+		want bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false}, // a file is not a terminal
+	}
+
+	for _, tt := range tests {
+		mode, err := ParseColorMode(tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := mode.Enabled(f); got != tt.want {
+			t.Errorf("--color=%s colors a file: %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package format splits the format strings println takes into the text
+// around their {} placeholders
+package format
+
+import "fmt"
+
+// Split returns the text of s before, between and after its placeholders,
+// so there is one more piece than there are placeholders. {{ and }} stand
+// for literal braces; any other brace is an error.
+func Split(s string) ([]string, error) {
+	var pieces []string
+
+	piece := []byte{}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '{' && i+1 < len(s) && s[i+1] == '{', s[i] == '}' && i+1 < len(s) && s[i+1] == '}':
+			piece = append(piece, s[i])
+			i++
+		case s[i] == '{' && i+1 < len(s) && s[i+1] == '}':
+			pieces = append(pieces, string(piece))
+			piece = []byte{}
+			i++
+		case s[i] == '{':
+			return nil, fmt.Errorf("unclosed { at byte %d; write {{ for a literal brace", i)
+		case s[i] == '}':
+			return nil, fmt.Errorf("unmatched } at byte %d; write }} for a literal brace", i)
+		default:
+			piece = append(piece, s[i])
+		}
+	}
+
+	return append(pieces, string(piece)), nil
+}
+
+// Plain reports whether s prints as it is written: it has no braces to
+// replace or unescape
+func Plain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '{' || s[i] == '}' {
+			return false
+		}
+	}
+
+	return true
+}
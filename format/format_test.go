@@ -0,0 +1,38 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  bool
+	}{
+		{in: "plain", want: []string{"plain"}},
+		{in: "x = {} y = {}", want: []string{"x = ", " y = ", ""}},
+		{in: "{}{}", want: []string{"", "", ""}},
+		{in: "{{}} {}", want: []string{"{} ", ""}},
+		{in: "a { b", err: true},
+		{in: "a } b", err: true},
+		{in: "{x}", err: true},
+		{in: "end {", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Split(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Split(%q): expected an error, got %q", tt.in, got)
+			}
+
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package grammar
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestSpecIsWellFormed(t *testing.T) {
+	if err := Grammar().Verify("File"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpecTerminalsAreTokens(t *testing.T) {
+	spec := Grammar()
+
+	for _, term := range spec.Terminals() {
+		// "in" and "self" are identifiers the parser looks for by name
+		if term == "in" || term == "self" {
+			continue
+		}
+
+		tokens := lexer.New(term)
+		tok := tokens.NextToken()
+
+		if tok.Type == lexer.IDENT || tok.Type == lexer.ILLEGAL || tok.Literal != term {
+			t.Errorf("%q in the grammar is not a token of the lexer: %v %q", term, tok.Type, tok.Literal)
+		}
+	}
+
+	for kw := range lexer.Keywords() {
+		if !slices.Contains(spec.Terminals(), kw) {
+			t.Errorf("keyword %q does not appear in the grammar", kw)
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"productions", "File = Item | \"x\" [ Name ] .\nItem = { \"y\" } .\nName = IDENT .", ""},
+		{"comment", "// the start\nFile = \"x\" .", ""},
+		{"missing period", "File = \"x\"", "expected \".\""},
+		{"defined twice", "File = \"x\" .\nFile = \"y\" .", "File is defined twice"},
+		{"empty terminal", "File = \"\" .", "unterminated or empty terminal"},
+		{"unclosed group", "File = ( \"x\" .", "expected \")\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec(tt.src)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"complete", "File = Item IDENT .\nItem = \"x\" .", ""},
+		{"undefined", "File = Item .", "File uses undefined Item"},
+		{"unreachable", "File = \"x\" .\nItem = \"y\" .", "Item cannot be reached from File"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = spec.Verify("File")
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestConformance derives random programs from the grammar and checks that
+// the parser accepts each one, and that formatting what it reads gives
+// source that reads back to the same text
+func TestConformance(t *testing.T) {
+	spec := Grammar()
+
+	seeds := int64(2000)
+	if testing.Short() {
+		seeds = 200
+	}
+
+	for seed := int64(0); seed < seeds; seed++ {
+		src := Generate(spec, "File", rand.New(rand.NewSource(seed)))
+
+		file, errs := parse(src)
+		if len(errs) > 0 {
+			t.Fatalf("seed %d: generated program does not parse: %v\n%s", seed, errs, src)
+		}
+
+		formatted := ast.Format(file)
+
+		again, errs := parse(formatted)
+		if len(errs) > 0 {
+			t.Fatalf("seed %d: formatted program does not parse: %v\n%s", seed, errs, formatted)
+		}
+
+		if got := ast.Format(again); got != formatted {
+			t.Fatalf("seed %d: formatting does not round-trip\nfirst:\n%s\nsecond:\n%s", seed, formatted, got)
+		}
+	}
+}
+
+func parse(src string) (*ast.File, []string) {
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	return file, p.Errors()
+}
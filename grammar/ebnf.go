@@ -0,0 +1,307 @@
+package grammar
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// source is the grammar of the language, kept next to the parser it
+// describes
+//
+//go:embed yarlang.ebnf
+var source string
+
+// Expr is one part of a production's right-hand side
+type Expr interface{ isExpr() }
+
+type (
+	// Alternative matches any one of its choices
+	Alternative []Expr
+	// Sequence matches each of its parts in turn
+	Sequence []Expr
+	// Option matches its body or nothing
+	Option struct{ Body Expr }
+	// Repetition matches its body zero or more times
+	Repetition struct{ Body Expr }
+	// Terminal is a keyword or operator, spelled as in source
+	Terminal string
+	// Name refers to a production, or to a token class such as IDENT when
+	// written in capitals
+	Name string
+)
+
+func (Alternative) isExpr() {}
+func (Sequence) isExpr()    {}
+func (Option) isExpr()      {}
+func (Repetition) isExpr()  {}
+func (Terminal) isExpr()    {}
+func (Name) isExpr()        {}
+
+// Spec is a grammar read from EBNF
+type Spec struct {
+	Productions map[string]Expr
+	Order       []string // production names in the order they are written
+}
+
+// Grammar returns the grammar of the language
+func Grammar() *Spec {
+	spec, err := ParseSpec(source)
+	if err != nil {
+		panic(fmt.Sprintf("yarlang.ebnf: %v", err))
+	}
+
+	return spec
+}
+
+// IsToken reports whether name is a token class of package lexer rather
+// than a production
+func IsToken(name string) bool {
+	return name == strings.ToUpper(name)
+}
+
+// ParseSpec reads productions written as name = expr . with // comments
+func ParseSpec(src string) (*Spec, error) {
+	r := &reader{src: src, line: 1}
+	spec := &Spec{Productions: make(map[string]Expr)}
+
+	for r.skip(); r.pos < len(r.src); r.skip() {
+		name := r.name()
+		r.expect("=")
+		body := r.expr()
+		r.expect(".")
+
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		if _, ok := spec.Productions[name]; ok {
+			return nil, fmt.Errorf("line %d: %s is defined twice", r.line, name)
+		}
+
+		spec.Productions[name] = body
+		spec.Order = append(spec.Order, name)
+	}
+
+	return spec, nil
+}
+
+// Verify checks that every name the productions use is defined and that
+// every production can be reached from start
+func (s *Spec) Verify(start string) error {
+	if _, ok := s.Productions[start]; !ok {
+		return fmt.Errorf("no production %s", start)
+	}
+
+	reached := map[string]bool{start: true}
+	work := []string{start}
+
+	for len(work) > 0 {
+		name := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		var err error
+
+		walk(s.Productions[name], func(e Expr) {
+			n, ok := e.(Name)
+			if !ok || IsToken(string(n)) || err != nil {
+				return
+			}
+
+			if _, ok := s.Productions[string(n)]; !ok {
+				err = fmt.Errorf("%s uses undefined %s", name, n)
+				return
+			}
+
+			if !reached[string(n)] {
+				reached[string(n)] = true
+				work = append(work, string(n))
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range s.Order {
+		if !reached[name] {
+			return fmt.Errorf("%s cannot be reached from %s", name, start)
+		}
+	}
+
+	return nil
+}
+
+// Terminals returns every keyword and operator the grammar spells out
+func (s *Spec) Terminals() []string {
+	var out []string
+
+	seen := make(map[string]bool)
+
+	for _, name := range s.Order {
+		walk(s.Productions[name], func(e Expr) {
+			if t, ok := e.(Terminal); ok && !seen[string(t)] {
+				seen[string(t)] = true
+				out = append(out, string(t))
+			}
+		})
+	}
+
+	return out
+}
+
+// walk calls f on e and everything inside it
+func walk(e Expr, f func(Expr)) {
+	f(e)
+
+	switch e := e.(type) {
+	case Alternative:
+		for _, c := range e {
+			walk(c, f)
+		}
+	case Sequence:
+		for _, c := range e {
+			walk(c, f)
+		}
+	case Option:
+		walk(e.Body, f)
+	case Repetition:
+		walk(e.Body, f)
+	}
+}
+
+// reader parses EBNF text. The first error sticks and stops the rest.
+type reader struct {
+	src  string
+	pos  int
+	line int
+	err  error
+}
+
+func (r *reader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, args...))
+	}
+
+	r.pos = len(r.src)
+}
+
+// skip passes over white space and comments
+func (r *reader) skip() {
+	for r.pos < len(r.src) {
+		switch {
+		case r.src[r.pos] == '\n':
+			r.line++
+			r.pos++
+		case unicode.IsSpace(rune(r.src[r.pos])):
+			r.pos++
+		case strings.HasPrefix(r.src[r.pos:], "//"):
+			for r.pos < len(r.src) && r.src[r.pos] != '\n' {
+				r.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (r *reader) peek() byte {
+	r.skip()
+
+	if r.pos >= len(r.src) {
+		return 0
+	}
+
+	return r.src[r.pos]
+}
+
+func (r *reader) expect(lit string) {
+	if r.peek() != lit[0] {
+		r.fail("expected %q", lit)
+		return
+	}
+
+	r.pos++
+}
+
+func (r *reader) name() string {
+	r.skip()
+
+	start := r.pos
+	for r.pos < len(r.src) && (unicode.IsLetter(rune(r.src[r.pos])) || unicode.IsDigit(rune(r.src[r.pos]))) {
+		r.pos++
+	}
+
+	if start == r.pos {
+		r.fail("expected a name")
+	}
+
+	return r.src[start:r.pos]
+}
+
+// expr reads alternatives separated by |
+func (r *reader) expr() Expr {
+	alt := Alternative{r.sequence()}
+
+	for r.err == nil && r.peek() == '|' {
+		r.pos++
+		alt = append(alt, r.sequence())
+	}
+
+	if len(alt) == 1 {
+		return alt[0]
+	}
+
+	return alt
+}
+
+// sequence reads terms up to the end of an alternative
+func (r *reader) sequence() Expr {
+	var seq Sequence
+
+	for r.err == nil {
+		switch c := r.peek(); c {
+		case '"':
+			r.pos++
+
+			end := strings.IndexByte(r.src[r.pos:], '"')
+			if end <= 0 {
+				r.fail("unterminated or empty terminal")
+				return nil
+			}
+
+			seq = append(seq, Terminal(r.src[r.pos:r.pos+end]))
+			r.pos += end + 1
+		case '(':
+			r.pos++
+			seq = append(seq, r.expr())
+			r.expect(")")
+		case '[':
+			r.pos++
+			seq = append(seq, Option{r.expr()})
+			r.expect("]")
+		case '{':
+			r.pos++
+			seq = append(seq, Repetition{r.expr()})
+			r.expect("}")
+		default:
+			if !unicode.IsLetter(rune(c)) {
+				if len(seq) == 0 {
+					r.fail("expected a term")
+				}
+
+				if len(seq) == 1 {
+					return seq[0]
+				}
+
+				return seq
+			}
+
+			seq = append(seq, Name(r.name()))
+		}
+	}
+
+	return seq
+}
@@ -0,0 +1,147 @@
+package grammar
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// maxDepth is how deep the generator nests productions before it takes the
+// shortest way out of each
+const maxDepth = 14
+
+// tokenSamples are the spellings generated for each token class. The names
+// are neither keywords nor "in" and "self", which mean something in place.
+var tokenSamples = map[string][]string{
+	"IDENT":  {"a", "b", "x", "y", "count", "Point", "T"},
+	"INT":    {"0", "1", "42"},
+	"FLOAT":  {"1.5", "0.25"},
+	"CHAR":   {"'a'", `'\n'`},
+	"STRING": {`"hi"`, `""`},
+}
+
+// Generate returns a random program derived from the production start. The
+// tokens are separated by spaces, and NEWLINE is a line break.
+func Generate(spec *Spec, start string, r *rand.Rand) string {
+	g := &generator{spec: spec, rand: r, depths: minDepths(spec)}
+	g.expr(Name(start), 0)
+
+	return g.out.String()
+}
+
+type generator struct {
+	spec   *Spec
+	rand   *rand.Rand
+	depths map[string]int
+	out    strings.Builder
+}
+
+func (g *generator) expr(e Expr, depth int) {
+	switch e := e.(type) {
+	case Alternative:
+		if depth < maxDepth {
+			g.expr(e[g.rand.Intn(len(e))], depth)
+			return
+		}
+
+		// Past the limit, the alternative that nests least ends the program
+		best := e[0]
+		for _, alt := range e[1:] {
+			if depthOf(alt, g.depths) < depthOf(best, g.depths) {
+				best = alt
+			}
+		}
+
+		g.expr(best, depth)
+	case Sequence:
+		for _, part := range e {
+			g.expr(part, depth)
+		}
+	case Option:
+		if depth < maxDepth && g.rand.Intn(2) == 0 {
+			g.expr(e.Body, depth)
+		}
+	case Repetition:
+		if depth < maxDepth {
+			for n := g.rand.Intn(4); n > 0; n-- {
+				g.expr(e.Body, depth)
+			}
+		}
+	case Terminal:
+		g.token(string(e))
+	case Name:
+		if !IsToken(string(e)) {
+			g.expr(g.spec.Productions[string(e)], depth+1)
+			return
+		}
+
+		if e == "NEWLINE" {
+			g.out.WriteString("\n")
+			return
+		}
+
+		samples := tokenSamples[string(e)]
+		g.token(samples[g.rand.Intn(len(samples))])
+	}
+}
+
+func (g *generator) token(text string) {
+	g.out.WriteString(text)
+	g.out.WriteString(" ")
+}
+
+// minDepths finds for each production the fewest productions a derivation
+// of it nests
+func minDepths(spec *Spec) map[string]int {
+	depths := make(map[string]int)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, name := range spec.Order {
+			d := depthOf(spec.Productions[name], depths)
+			if d == unbounded {
+				continue
+			}
+
+			if old, ok := depths[name]; !ok || d+1 < old {
+				depths[name] = d + 1
+				changed = true
+			}
+		}
+	}
+
+	return depths
+}
+
+const unbounded = 1 << 30
+
+func depthOf(e Expr, depths map[string]int) int {
+	switch e := e.(type) {
+	case Alternative:
+		best := unbounded
+		for _, alt := range e {
+			best = min(best, depthOf(alt, depths))
+		}
+
+		return best
+	case Sequence:
+		worst := 0
+		for _, part := range e {
+			worst = max(worst, depthOf(part, depths))
+		}
+
+		return worst
+	case Name:
+		if IsToken(string(e)) {
+			return 0
+		}
+
+		if d, ok := depths[string(e)]; ok {
+			return d
+		}
+
+		return unbounded
+	default:
+		return 0
+	}
+}
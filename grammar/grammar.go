@@ -0,0 +1,187 @@
+package grammar
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yarlson/yarlang/lexer"
+)
+
+// keywordScopes assigns TextMate scopes to keyword tokens. Keywords not listed
+// here fall back to keyword.other so new keywords are still highlighted.
+var keywordScopes = map[lexer.TokenType]string{
+	lexer.BREAK:    "keyword.control",
+	lexer.CONTINUE: "keyword.control",
+	lexer.DEFER:    "keyword.control",
+	lexer.ELSE:     "keyword.control",
+	lexer.FOR:      "keyword.control",
+	lexer.GUARD:    "keyword.control",
+	lexer.IF:       "keyword.control",
+	lexer.RETURN:   "keyword.control",
+	lexer.WHILE:    "keyword.control",
+	lexer.CONST:    "storage.type",
+	lexer.ENUM:     "storage.type",
+	lexer.FN:       "storage.type",
+	lexer.IMPL:     "storage.type",
+	lexer.LET:      "storage.type",
+	lexer.MODULE:   "storage.type",
+	lexer.STRUCT:   "storage.type",
+	lexer.TRAIT:    "storage.type",
+	lexer.TYPE:     "storage.type",
+	lexer.USE:      "storage.type",
+	lexer.VOID:     "storage.type",
+	lexer.DYN:      "storage.modifier",
+	lexer.EXTERN:   "storage.modifier",
+	lexer.MUT:      "storage.modifier",
+	lexer.PUB:      "storage.modifier",
+	lexer.UNSAFE:   "storage.modifier",
+	lexer.TRUE:     "constant.language",
+	lexer.FALSE:    "constant.language",
+	lexer.NIL:      "constant.language",
+	lexer.AS:       "keyword.operator",
+}
+
+// Rule is a TextMate grammar rule
+type Rule struct {
+	Name     string `json:"name,omitempty"`
+	Match    string `json:"match,omitempty"`
+	Begin    string `json:"begin,omitempty"`
+	End      string `json:"end,omitempty"`
+	Include  string `json:"include,omitempty"`
+	Patterns []Rule `json:"patterns,omitempty"`
+}
+
+// TextMateGrammar is the root of a .tmLanguage.json document
+type TextMateGrammar struct {
+	Name       string          `json:"name"`
+	ScopeName  string          `json:"scopeName"`
+	FileTypes  []string        `json:"fileTypes"`
+	Patterns   []Rule          `json:"patterns"`
+	Repository map[string]Rule `json:"repository"`
+}
+
+// TextMate builds the grammar from the lexer's keyword and operator tables
+func TextMate() *TextMateGrammar {
+	g := &TextMateGrammar{
+		Name:      "YarLang",
+		ScopeName: "source.yar",
+		FileTypes: []string{"yar"},
+		Patterns: []Rule{
+			{Include: "#comments"},
+			{Include: "#strings"},
+			{Include: "#chars"},
+			{Include: "#numbers"},
+			{Include: "#keywords"},
+			{Include: "#operators"},
+		},
+		Repository: map[string]Rule{
+			"comments": {Patterns: []Rule{
+				{Name: "comment.line.double-slash.yarlang", Match: `//.*$`},
+				{Name: "comment.block.yarlang", Begin: `/\*`, End: `\*/`},
+			}},
+			"strings": {
+				Name:     "string.quoted.double.yarlang",
+				Begin:    `"`,
+				End:      `"`,
+				Patterns: []Rule{{Name: "constant.character.escape.yarlang", Match: `\\.`}},
+			},
+			"chars": {
+				Name:  "string.quoted.single.yarlang",
+				Match: `'(?:\\.|[^'\\])*'`,
+			},
+			"numbers": {Patterns: []Rule{
+				{Name: "constant.numeric.hex.yarlang", Match: `\b0[xX][0-9a-fA-F_]+\b`},
+				{Name: "constant.numeric.binary.yarlang", Match: `\b0[bB][01_]+\b`},
+				{Name: "constant.numeric.octal.yarlang", Match: `\b0[oO][0-7_]+\b`},
+				{Name: "constant.numeric.float.yarlang", Match: `\b[0-9][0-9_]*(?:\.[0-9][0-9_]*)?[eE][+-]?[0-9_]+\b|\b[0-9][0-9_]*\.[0-9][0-9_]*\b|\.[0-9][0-9_]*\b`},
+				{Name: "constant.numeric.integer.yarlang", Match: `\b[0-9][0-9_]*\b`},
+			}},
+			"keywords": {Patterns: keywordRules()},
+			"operators": {Patterns: []Rule{
+				{Name: "keyword.operator.yarlang", Match: operatorPattern(false)},
+				{Name: "punctuation.yarlang", Match: operatorPattern(true)},
+			}},
+		},
+	}
+
+	return g
+}
+
+// JSON renders the grammar as an indented .tmLanguage.json document
+func (g *TextMateGrammar) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+func keywordRules() []Rule {
+	byScope := make(map[string][]string)
+
+	for kw, tok := range lexer.Keywords() {
+		scope, ok := keywordScopes[tok]
+		if !ok {
+			scope = "keyword.other"
+		}
+
+		byScope[scope] = append(byScope[scope], kw)
+	}
+
+	scopes := make([]string, 0, len(byScope))
+	for scope := range byScope {
+		scopes = append(scopes, scope)
+	}
+
+	sort.Strings(scopes)
+
+	rules := make([]Rule, 0, len(scopes))
+	for _, scope := range scopes {
+		words := byScope[scope]
+		sort.Strings(words)
+
+		rules = append(rules, Rule{
+			Name:  scope + ".yarlang",
+			Match: `\b(?:` + strings.Join(words, "|") + `)\b`,
+		})
+	}
+
+	return rules
+}
+
+// punctuation lists the delimiter tokens that are not operators
+var punctuation = map[lexer.TokenType]bool{
+	lexer.LPAREN:    true,
+	lexer.RPAREN:    true,
+	lexer.LBRACE:    true,
+	lexer.RBRACE:    true,
+	lexer.LBRACKET:  true,
+	lexer.RBRACKET:  true,
+	lexer.COMMA:     true,
+	lexer.SEMICOLON: true,
+	lexer.HASH:      true,
+}
+
+// operatorPattern matches any operator (or, with punct set, any delimiter),
+// longest spelling first so that "<<=" wins over "<<" and "<"
+func operatorPattern(punct bool) string {
+	ops := make([]string, 0)
+
+	for op, tok := range lexer.Operators() {
+		if punctuation[tok] == punct {
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if len(ops[i]) != len(ops[j]) {
+			return len(ops[i]) > len(ops[j])
+		}
+
+		return ops[i] < ops[j]
+	})
+
+	for i, op := range ops {
+		ops[i] = regexp.QuoteMeta(op)
+	}
+
+	return strings.Join(ops, "|")
+}
@@ -0,0 +1,75 @@
+package grammar
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+)
+
+func TestTextMateCoversLexerTables(t *testing.T) {
+	g := TextMate()
+
+	data, err := g.JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("grammar is not valid JSON: %v", err)
+	}
+
+	var keywordRes []*regexp.Regexp
+	for _, rule := range g.Repository["keywords"].Patterns {
+		keywordRes = append(keywordRes, regexp.MustCompile(rule.Match))
+	}
+
+	for kw := range lexer.Keywords() {
+		matched := false
+
+		for _, re := range keywordRes {
+			if re.MatchString(kw) {
+				matched = true
+			}
+		}
+
+		if !matched {
+			t.Errorf("keyword %q is not highlighted", kw)
+		}
+	}
+
+	var operatorRes []*regexp.Regexp
+	for _, rule := range g.Repository["operators"].Patterns {
+		operatorRes = append(operatorRes, regexp.MustCompile(`^(?:`+rule.Match+`)`))
+	}
+
+	for op := range lexer.Operators() {
+		longest := ""
+
+		for _, re := range operatorRes {
+			if m := re.FindString(op); len(m) > len(longest) {
+				longest = m
+			}
+		}
+
+		if longest != op {
+			t.Errorf("operator %q matched as %q", op, longest)
+		}
+	}
+}
+
+func TestTextMateScopes(t *testing.T) {
+	data, err := TextMate().JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+
+	for _, want := range []string{`"scopeName": "source.yar"`, "keyword.control.yarlang", "storage.type.yarlang"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("grammar missing %q", want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ type Lexer struct {
 	ch           byte // current char
 	line         int
 	column       int
+	prev         TokenType // type of the last token returned
 }
 
 // New creates a new Lexer
@@ -22,7 +23,14 @@ func New(input string) *Lexer {
 	return l
 }
 
+// readChar advances to the next character. A newline belongs to the line
+// it ends; the line after it starts at column 1.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // EOF
 	} else {
@@ -32,11 +40,6 @@ func (l *Lexer) readChar() {
 	l.position = l.readPosition
 	l.readPosition++
 	l.column++
-
-	if l.ch == '\n' {
-		l.line++
-		l.column = 0
-	}
 }
 
 func (l *Lexer) peekChar() byte {
@@ -49,6 +52,24 @@ func (l *Lexer) peekChar() byte {
 
 // NextToken returns the next token
 func (l *Lexer) NextToken() Token {
+	tok := l.next()
+	l.prev = tok.Type
+
+	return tok
+}
+
+// afterOperand reports whether the last token can end an operand, so a
+// following '.' selects a field, as in t.0, rather than starting a float
+func (l *Lexer) afterOperand() bool {
+	switch l.prev {
+	case IDENT, INT, FLOAT, STRING, CHAR, TRUE, FALSE, NIL, RPAREN, RBRACKET, RBRACE, QUESTION:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Lexer) next() Token {
 	var tok Token
 
 	l.skipWhitespace()
@@ -86,12 +107,18 @@ func (l *Lexer) NextToken() Token {
 	case '.':
 		// Check if this is '..' (range operator)
 		if l.peekChar() == '.' {
-			ch := l.ch
 			l.readChar()
 
 			tok.Type = DOTDOT
-			tok.Literal = string(ch) + string(l.ch)
-		} else if isDigit(l.peekChar()) {
+			tok.Literal = ".."
+
+			if l.peekChar() == '.' {
+				l.readChar()
+
+				tok.Type = ELLIPSIS
+				tok.Literal = "..."
+			}
+		} else if isDigit(l.peekChar()) && !l.afterOperand() {
 			// Check if this is a float starting with '.' (e.g., .5)
 			return l.readNumber()
 		} else {
@@ -101,6 +128,9 @@ func (l *Lexer) NextToken() Token {
 	case ';':
 		tok.Type = SEMICOLON
 		tok.Literal = ";"
+	case '#':
+		tok.Type = HASH
+		tok.Literal = "#"
 	case ':':
 		if l.peekChar() == ':' {
 			ch := l.ch
@@ -427,8 +457,9 @@ func (l *Lexer) readNumber() Token {
 		l.readChar()
 	}
 
-	// Check for float with decimal point (e.g., 5. or 5.0)
-	if l.ch == '.' {
+	// Check for float with decimal point (e.g., 5. or 5.0). A tuple index
+	// is never one, so t.0.1 is two field selections.
+	if l.ch == '.' && l.prev != DOT {
 		// Check if next char is a digit or if we're at the end/non-digit (for 5. format)
 		nextCh := l.peekChar()
 		if isDigit(nextCh) {
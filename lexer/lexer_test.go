@@ -14,6 +14,8 @@ func TestTokenTypes(t *testing.T) {
 		{"enum", []TokenType{ENUM}},
 		{"trait", []TokenType{TRAIT}},
 		{"impl", []TokenType{IMPL}},
+		{"nums ...i32", []TokenType{IDENT, ELLIPSIS, IDENT}},
+		{"a..b", []TokenType{IDENT, DOTDOT, IDENT}},
 	}
 
 	for _, tt := range tests {
@@ -91,3 +93,86 @@ func TestFloatParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTupleIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{"t.0", []TokenType{IDENT, DOT, INT, EOF}},
+		{"t.0.1", []TokenType{IDENT, DOT, INT, DOT, INT, EOF}},
+		{"f().1", []TokenType{IDENT, LPAREN, RPAREN, DOT, INT, EOF}},
+		{"f()?.1", []TokenType{IDENT, LPAREN, RPAREN, QUESTION, DOT, INT, EOF}},
+		{"P{}.0", []TokenType{IDENT, LBRACE, RBRACE, DOT, INT, EOF}},
+		{"x = .5", []TokenType{IDENT, ASSIGN, FLOAT, EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, want := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != want {
+				t.Errorf("input %q - token %d: expected=%v, got=%v", tt.input, i, want, tok.Type)
+			}
+		}
+	}
+}
+
+func TestOperatorTable(t *testing.T) {
+	for lit, expected := range Operators() {
+		l := New(lit)
+
+		tok := l.NextToken()
+		if tok.Type != expected || tok.Literal != lit {
+			t.Errorf("operator %q: expected %v, got %v %q", lit, expected, tok.Type, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != EOF {
+			t.Errorf("operator %q: lexed as more than one token", lit)
+		}
+	}
+
+	for kw, expected := range Keywords() {
+		if tok := New(kw).NextToken(); tok.Type != expected {
+			t.Errorf("keyword %q: expected %v, got %v", kw, expected, tok.Type)
+		}
+	}
+}
+
+// TestLexedOperatorsInTable lexes every run of up to three punctuation
+// characters, so that an operator NextToken knows but the table lacks is
+// caught as well as the other way round
+func TestLexedOperatorsInTable(t *testing.T) {
+	const punct = "!#$%&()*+,-./:;<=>?@[\\]^`{|}~"
+
+	table := Operators()
+
+	var inputs []string
+
+	for _, a := range punct {
+		inputs = append(inputs, string(a))
+
+		for _, b := range punct {
+			inputs = append(inputs, string(a)+string(b))
+
+			for _, c := range punct {
+				inputs = append(inputs, string(a)+string(b)+string(c))
+			}
+		}
+	}
+
+	for _, input := range inputs {
+		l := New(input)
+
+		for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+			if tok.Type == ILLEGAL || tok.Type == COMMENT {
+				continue
+			}
+
+			if want, ok := table[tok.Literal]; !ok || want != tok.Type {
+				t.Errorf("input %q: lexed %v %q, which the operator table does not have", input, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
@@ -22,12 +22,14 @@ const (
 	CONST    // const
 	CONTINUE // continue
 	DEFER    // defer
+	DYN      // dyn
 	ELSE     // else
 	ENUM     // enum
 	EXTERN   // extern
 	FALSE    // false
 	FN       // fn
 	FOR      // for
+	GUARD    // guard
 	IF       // if
 	IMPL     // impl
 	LET      // let
@@ -90,10 +92,12 @@ const (
 	COMMA       // ,
 	DOT         // .
 	DOTDOT      // .. (for ranges)
+	ELLIPSIS    // ... (variadic parameters)
 	SEMICOLON   // ;
 	COLON       // :
 	COLONCOLON  // ::
 	ARROW       // ->
+	HASH        // # (attributes)
 	NEWLINE     // \n (for ASI)
 )
 
@@ -103,12 +107,14 @@ var keywords = map[string]TokenType{
 	"const":    CONST,
 	"continue": CONTINUE,
 	"defer":    DEFER,
+	"dyn":      DYN,
 	"else":     ELSE,
 	"enum":     ENUM,
 	"extern":   EXTERN,
 	"false":    FALSE,
 	"fn":       FN,
 	"for":      FOR,
+	"guard":    GUARD,
 	"if":       IF,
 	"impl":     IMPL,
 	"let":      LET,
@@ -127,12 +133,84 @@ var keywords = map[string]TokenType{
 	"while":    WHILE,
 }
 
+// operators maps the spelling of every operator and delimiter token to its type
+var operators = map[string]TokenType{
+	"=":   ASSIGN,
+	"+":   PLUS,
+	"-":   MINUS,
+	"*":   STAR,
+	"/":   SLASH,
+	"%":   PERCENT,
+	"&":   AMP,
+	"|":   PIPE,
+	"^":   CARET,
+	"~":   TILDE,
+	"!":   BANG,
+	"<":   LT,
+	">":   GT,
+	"<=":  LTE,
+	">=":  GTE,
+	"==":  EQ,
+	"!=":  NEQ,
+	"&&":  AND,
+	"||":  OR,
+	"<<":  SHL,
+	">>":  SHR,
+	"+=":  PLUS_EQ,
+	"-=":  MINUS_EQ,
+	"*=":  STAR_EQ,
+	"/=":  SLASH_EQ,
+	"%=":  PERCENT_EQ,
+	"&=":  AMP_EQ,
+	"|=":  PIPE_EQ,
+	"^=":  CARET_EQ,
+	"<<=": SHL_EQ,
+	">>=": SHR_EQ,
+	"?":   QUESTION,
+	":=":  COLONASSIGN,
+	"(":   LPAREN,
+	")":   RPAREN,
+	"{":   LBRACE,
+	"}":   RBRACE,
+	"[":   LBRACKET,
+	"]":   RBRACKET,
+	",":   COMMA,
+	".":   DOT,
+	"..":  DOTDOT,
+	"...": ELLIPSIS,
+	";":   SEMICOLON,
+	":":   COLON,
+	"::":  COLONCOLON,
+	"->":  ARROW,
+	"#":   HASH,
+}
+
+// Keywords returns every reserved word mapped to its token type
+func Keywords() map[string]TokenType {
+	out := make(map[string]TokenType, len(keywords))
+	for k, v := range keywords {
+		out[k] = v
+	}
+
+	return out
+}
+
+// Operators returns the spelling of every operator and delimiter token
+func Operators() map[string]TokenType {
+	out := make(map[string]TokenType, len(operators))
+	for k, v := range operators {
+		out[k] = v
+	}
+
+	return out
+}
+
 // Token represents a lexical token
 type Token struct {
 	Type    TokenType
 	Literal string
-	Line    int
-	Column  int
+	Line    int // counting from 1
+	Column  int // counting from 1
 }
 
 // LookupIdent returns the TokenType for an identifier (keyword or IDENT)
@@ -160,12 +238,14 @@ func (t TokenType) String() string {
 		CONST:       "CONST",
 		CONTINUE:    "CONTINUE",
 		DEFER:       "DEFER",
+		DYN:         "DYN",
 		ELSE:        "ELSE",
 		ENUM:        "ENUM",
 		EXTERN:      "EXTERN",
 		FALSE:       "FALSE",
 		FN:          "FN",
 		FOR:         "FOR",
+		GUARD:       "GUARD",
 		IF:          "IF",
 		IMPL:        "IMPL",
 		LET:         "LET",
@@ -223,11 +303,13 @@ func (t TokenType) String() string {
 		COMMA:       "COMMA",
 		DOT:         "DOT",
 		DOTDOT:      "DOTDOT",
+		ELLIPSIS:    "ELLIPSIS",
 		SEMICOLON:   "SEMICOLON",
 		COLON:       "COLON",
 		COLONCOLON:  "COLONCOLON",
 		COLONASSIGN: "COLONASSIGN",
 		ARROW:       "ARROW",
+		HASH:        "HASH",
 		NEWLINE:     "NEWLINE",
 	}
 	if int(t) < len(names) && names[t] != "" {
@@ -0,0 +1,63 @@
+package mir
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+// deepLoops generates n functions, each a nest of depth while loops with a
+// branch in the innermost
+func deepLoops(n, depth int) string {
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "fn f%d(n i32) i32 {\n", i)
+		b.WriteString("\tlet mut sum = 0\n")
+
+		for d := 0; d < depth; d++ {
+			tabs := strings.Repeat("\t", d+1)
+			fmt.Fprintf(&b, "%slet mut i%d = 0\n", tabs, d)
+			fmt.Fprintf(&b, "%swhile i%d < n {\n", tabs, d)
+		}
+
+		tabs := strings.Repeat("\t", depth+1)
+		fmt.Fprintf(&b, "%sif sum %% 2 == 0 {\n%s\tsum += i0\n%s} else {\n%s\tsum -= 1\n%s}\n", tabs, tabs, tabs, tabs, tabs)
+
+		for d := depth - 1; d >= 0; d-- {
+			tabs := strings.Repeat("\t", d+1)
+			fmt.Fprintf(&b, "%s\ti%d += 1\n", tabs, d)
+			fmt.Fprintf(&b, "%s}\n", tabs)
+		}
+
+		b.WriteString("\treturn sum\n}\n\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkLowerDeepLoops(b *testing.B) {
+	p := parser.New(lexer.New(deepLoops(200, 12)))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %v", p.Errors()[:1])
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		b.Fatalf("checker error: %v", err)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lowerer := NewLowerer()
+		lowerer.SetTypes(c.Types())
+		lowerer.LowerFile(file)
+	}
+}
@@ -0,0 +1,293 @@
+package mir
+
+// CFG is the control flow graph of a function, with its dominator tree,
+// dominance frontiers and natural loops. Only the blocks control reaches
+// from the entry are in it, so every block has a dominator.
+type CFG struct {
+	Entry    string
+	Blocks   map[string]*BasicBlock
+	RPO      []string            // blocks in reverse postorder from the entry
+	Succs    map[string][]string // blocks each block branches to, once per edge
+	Preds    map[string][]string // blocks that branch to each block, once per edge
+	Idom     map[string]string   // immediate dominator; the entry's is itself
+	Children map[string][]string // blocks each block immediately dominates
+	Frontier map[string][]string // dominance frontier of each block
+
+	order map[string]int // position in RPO
+}
+
+// CFG builds the control flow graph of fn. The function is not changed;
+// blocks it cannot reach are left out of the graph.
+func (fn *Function) CFG() *CFG {
+	g := &CFG{
+		Blocks:   make(map[string]*BasicBlock),
+		Succs:    make(map[string][]string),
+		Preds:    make(map[string][]string),
+		Idom:     make(map[string]string),
+		Children: make(map[string][]string),
+		Frontier: make(map[string][]string),
+		order:    make(map[string]int),
+	}
+
+	if len(fn.Blocks) == 0 {
+		return g
+	}
+
+	all := make(map[string]*BasicBlock, len(fn.Blocks))
+	for _, bb := range fn.Blocks {
+		all[bb.Label] = bb
+	}
+
+	// Blocks in reverse postorder from the entry
+	var post []string
+
+	var visit func(label string)
+	visit = func(label string) {
+		bb := all[label]
+		g.Blocks[label] = bb
+		g.Succs[label] = successors(bb)
+
+		for _, s := range g.Succs[label] {
+			if _, ok := all[s]; ok && g.Blocks[s] == nil {
+				visit(s)
+			}
+		}
+
+		post = append(post, label)
+	}
+
+	g.Entry = fn.Blocks[0].Label
+	visit(g.Entry)
+
+	g.RPO = make([]string, len(post))
+	for i, label := range post {
+		g.RPO[len(post)-1-i] = label
+		g.order[label] = len(post) - 1 - i
+	}
+
+	for _, label := range g.RPO {
+		for _, s := range g.Succs[label] {
+			g.Preds[s] = append(g.Preds[s], label)
+		}
+	}
+
+	g.dominators()
+
+	return g
+}
+
+// successors lists the blocks the terminator of bb branches to, once per edge
+func successors(bb *BasicBlock) []string {
+	if len(bb.Instrs) == 0 {
+		return nil
+	}
+
+	switch t := bb.Instrs[len(bb.Instrs)-1].(type) {
+	case *Br:
+		return []string{t.Label}
+	case *CondBr:
+		return []string{t.TrueLabel, t.FalseLabel}
+	default:
+		return nil
+	}
+}
+
+// removeUnreachable drops from fn the blocks that are not in the graph
+func (g *CFG) removeUnreachable(fn *Function) {
+	reachable := fn.Blocks[:0]
+
+	for _, bb := range fn.Blocks {
+		if g.Blocks[bb.Label] != nil {
+			reachable = append(reachable, bb)
+		}
+	}
+
+	fn.Blocks = reachable
+}
+
+// dominators finds the immediate dominator of each block with the
+// iterative algorithm of Cooper, Harvey and Kennedy, then the dominance
+// frontiers
+func (g *CFG) dominators() {
+	g.Idom[g.Entry] = g.Entry
+
+	intersect := func(a, b string) string {
+		for a != b {
+			for g.order[a] > g.order[b] {
+				a = g.Idom[a]
+			}
+
+			for g.order[b] > g.order[a] {
+				b = g.Idom[b]
+			}
+		}
+
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, b := range g.RPO[1:] {
+			idom := ""
+
+			for _, p := range g.Preds[b] {
+				if _, ok := g.Idom[p]; !ok {
+					continue
+				}
+
+				if idom == "" {
+					idom = p
+				} else {
+					idom = intersect(p, idom)
+				}
+			}
+
+			if g.Idom[b] != idom {
+				g.Idom[b] = idom
+				changed = true
+			}
+		}
+	}
+
+	for _, b := range g.RPO[1:] {
+		g.Children[g.Idom[b]] = append(g.Children[g.Idom[b]], b)
+	}
+
+	// A join point is in the frontier of every block that dominates one of
+	// its predecessors but not the join itself
+	for _, b := range g.RPO {
+		if len(g.Preds[b]) < 2 {
+			continue
+		}
+
+		for _, p := range g.Preds[b] {
+			for runner := p; runner != g.Idom[b]; runner = g.Idom[runner] {
+				if !contains(g.Frontier[runner], b) {
+					g.Frontier[runner] = append(g.Frontier[runner], b)
+				}
+			}
+		}
+	}
+}
+
+// Dominates reports whether every path from the entry to b passes through
+// a. A block dominates itself.
+func (g *CFG) Dominates(a, b string) bool {
+	if _, ok := g.Idom[b]; !ok {
+		return false
+	}
+
+	for {
+		if a == b {
+			return true
+		}
+
+		if b == g.Entry {
+			return false
+		}
+
+		b = g.Idom[b]
+	}
+}
+
+// Loop is a natural loop: a header that dominates every block of the loop,
+// and the blocks that can reach a back edge to it without passing through it
+type Loop struct {
+	Header  string
+	Blocks  []string // the header, then the body, in reverse postorder
+	Latches []string // blocks with a back edge to the header
+	Exits   []string // blocks outside the loop it branches to
+	Parent  *Loop    // innermost loop around this one, or nil
+	Depth   int      // 1 for a loop no other loop contains
+}
+
+// Contains reports whether label is a block of the loop
+func (l *Loop) Contains(label string) bool {
+	return contains(l.Blocks, label)
+}
+
+// Loops finds the natural loops of the graph, outer loops before the loops
+// they contain. Back edges to the same header make one loop.
+func (g *CFG) Loops() []*Loop {
+	var loops []*Loop
+
+	for _, header := range g.RPO {
+		var latches []string
+
+		for _, p := range g.Preds[header] {
+			if g.Dominates(header, p) && !contains(latches, p) {
+				latches = append(latches, p)
+			}
+		}
+
+		if len(latches) == 0 {
+			continue
+		}
+
+		// Walk back from the latches; the header stops the walk since it
+		// dominates them all
+		in := map[string]bool{header: true}
+		work := append([]string(nil), latches...)
+
+		for len(work) > 0 {
+			b := work[len(work)-1]
+			work = work[:len(work)-1]
+
+			if in[b] {
+				continue
+			}
+
+			in[b] = true
+			work = append(work, g.Preds[b]...)
+		}
+
+		loop := &Loop{Header: header, Latches: latches}
+
+		for _, b := range g.RPO {
+			if in[b] {
+				loop.Blocks = append(loop.Blocks, b)
+			}
+		}
+
+		for _, b := range loop.Blocks {
+			for _, s := range g.Succs[b] {
+				if !in[s] && g.Blocks[s] != nil && !contains(loop.Exits, s) {
+					loop.Exits = append(loop.Exits, s)
+				}
+			}
+		}
+
+		loops = append(loops, loop)
+	}
+
+	// Headers come in reverse postorder, so the loops around a loop come
+	// before it, the innermost of them last
+	for i, loop := range loops {
+		for _, outer := range loops[:i] {
+			if outer.Contains(loop.Header) {
+				loop.Parent = outer
+			}
+		}
+
+		loop.Depth = 1
+		if loop.Parent != nil {
+			loop.Depth = loop.Parent.Depth + 1
+		}
+	}
+
+	return loops
+}
+
+// LoopOf returns the innermost of loops that contains label, or nil
+func LoopOf(loops []*Loop, label string) *Loop {
+	var inner *Loop
+
+	for _, loop := range loops {
+		if loop.Contains(label) && (inner == nil || loop.Depth > inner.Depth) {
+			inner = loop
+		}
+	}
+
+	return inner
+}
@@ -0,0 +1,120 @@
+package mir
+
+import (
+	"reflect"
+	"testing"
+)
+
+// nestedLoops is
+//
+//	entry -> outer -> inner -> body -> inner
+//	                    inner -> latch -> outer
+//	         outer -> exit
+//
+// with a block dead that nothing reaches
+func nestedLoops() *Function {
+	br := func(label string) Instruction { return &Br{Label: label} }
+	cond := func(t, f string) Instruction { return &CondBr{Cond: "c", TrueLabel: t, FalseLabel: f} }
+
+	return &Function{Name: "f", Blocks: []*BasicBlock{
+		{Label: "entry", Instrs: []Instruction{br("outer")}},
+		{Label: "outer", Instrs: []Instruction{cond("inner", "exit")}},
+		{Label: "inner", Instrs: []Instruction{cond("body", "latch")}},
+		{Label: "body", Instrs: []Instruction{br("inner")}},
+		{Label: "latch", Instrs: []Instruction{br("outer")}},
+		{Label: "exit", Instrs: []Instruction{&Ret{}}},
+		{Label: "dead", Instrs: []Instruction{br("exit")}},
+	}}
+}
+
+func TestCFG(t *testing.T) {
+	fn := nestedLoops()
+	g := fn.CFG()
+
+	if len(fn.Blocks) != 7 {
+		t.Errorf("building the graph changed the function to %d blocks", len(fn.Blocks))
+	}
+
+	if _, ok := g.Blocks["dead"]; ok {
+		t.Error("the unreachable block is in the graph")
+	}
+
+	if want := []string{"entry", "outer", "exit", "inner", "latch", "body"}; !reflect.DeepEqual(g.RPO, want) {
+		t.Errorf("RPO = %v, want %v", g.RPO, want)
+	}
+
+	if want := []string{"entry", "latch"}; !reflect.DeepEqual(g.Preds["outer"], want) {
+		t.Errorf("preds of outer = %v, want %v", g.Preds["outer"], want)
+	}
+
+	// The edge from dead is not in the graph
+	if want := []string{"outer"}; !reflect.DeepEqual(g.Preds["exit"], want) {
+		t.Errorf("preds of exit = %v, want %v", g.Preds["exit"], want)
+	}
+
+	idoms := map[string]string{"entry": "entry", "outer": "entry", "inner": "outer", "body": "inner", "latch": "inner", "exit": "outer"}
+	if !reflect.DeepEqual(g.Idom, idoms) {
+		t.Errorf("Idom = %v, want %v", g.Idom, idoms)
+	}
+
+	if want := []string{"inner"}; !reflect.DeepEqual(g.Frontier["body"], want) {
+		t.Errorf("frontier of body = %v, want %v", g.Frontier["body"], want)
+	}
+
+	dominates := []struct {
+		a, b string
+		want bool
+	}{
+		{"entry", "body", true},
+		{"inner", "latch", true},
+		{"inner", "inner", true},
+		{"body", "latch", false},
+		{"inner", "exit", false},
+		{"entry", "dead", false},
+	}
+
+	for _, tt := range dominates {
+		if got := g.Dominates(tt.a, tt.b); got != tt.want {
+			t.Errorf("Dominates(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	loops := nestedLoops().CFG().Loops()
+
+	if len(loops) != 2 {
+		t.Fatalf("found %d loops, want 2", len(loops))
+	}
+
+	outer, inner := loops[0], loops[1]
+
+	want := &Loop{
+		Header:  "outer",
+		Blocks:  []string{"outer", "inner", "latch", "body"},
+		Latches: []string{"latch"},
+		Exits:   []string{"exit"},
+		Depth:   1,
+	}
+	if !reflect.DeepEqual(outer, want) {
+		t.Errorf("outer loop = %+v, want %+v", outer, want)
+	}
+
+	want = &Loop{
+		Header:  "inner",
+		Blocks:  []string{"inner", "body"},
+		Latches: []string{"body"},
+		Exits:   []string{"latch"},
+		Parent:  outer,
+		Depth:   2,
+	}
+	if !reflect.DeepEqual(inner, want) {
+		t.Errorf("inner loop = %+v, want %+v", inner, want)
+	}
+
+	for label, want := range map[string]*Loop{"body": inner, "latch": outer, "exit": nil} {
+		if got := LoopOf(loops, label); got != want {
+			t.Errorf("LoopOf(%s) = %+v, want %+v", label, got, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package mir
+
+// EliminateDeadCode removes from each function the instructions after a
+// block's first terminator, the blocks no path from the entry reaches, and
+// the instructions whose results are never used and that have no other
+// effect. Lowering leaves the first two behind after return, break and
+// continue, where the rest of the source block is still emitted.
+func EliminateDeadCode(m *Module) {
+	for _, fn := range m.Functions {
+		eliminateDeadCode(fn)
+	}
+}
+
+func eliminateDeadCode(fn *Function) {
+	if len(fn.Blocks) == 0 {
+		return
+	}
+
+	for _, bb := range fn.Blocks {
+		for i, instr := range bb.Instrs {
+			if isTerminator(instr) {
+				bb.Instrs = bb.Instrs[:i+1]
+				break
+			}
+		}
+	}
+
+	g := fn.CFG()
+	g.removeUnreachable(fn)
+	prunePhis(fn, g)
+	removeDeadInstrs(fn)
+}
+
+// prunePhis drops phi entries for edges that no longer exist because their
+// block was removed or no longer branches to the phi's block
+func prunePhis(fn *Function, g *CFG) {
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			phi, ok := instr.(*Phi)
+			if !ok {
+				continue
+			}
+
+			kept := phi.Incoming[:0]
+
+			for _, in := range phi.Incoming {
+				if contains(g.Preds[bb.Label], in.Label) {
+					kept = append(kept, in)
+				}
+			}
+
+			phi.Incoming = kept
+		}
+	}
+}
+
+// removeDeadInstrs deletes side-effect free instructions whose results are
+// never used, along with locals that are stored to but never read. Values
+// are marked live from the instructions that have effects, so a cycle of
+// dead values, such as a loop counter nothing reads, goes too.
+func removeDeadInstrs(fn *Function) {
+	locals := make(map[string]bool)
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			if a, ok := instr.(*Alloca); ok {
+				locals[a.Name] = true
+			}
+		}
+	}
+
+	// Stores to a local only matter once something reads the local
+	localStore := func(instr Instruction) (*Store, bool) {
+		st, ok := instr.(*Store)
+		return st, ok && !st.Volatile && locals[st.Dest]
+	}
+
+	live := make(map[string]bool)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, bb := range fn.Blocks {
+			for _, instr := range bb.Instrs {
+				if _, ok := instr.(*Alloca); ok {
+					continue
+				}
+
+				if dest, ok := pureDest(instr); ok && !live[dest] {
+					continue
+				}
+
+				if st, ok := localStore(instr); ok && !live[st.Dest] {
+					continue
+				}
+
+				for _, op := range operands(instr) {
+					if !live[*op] {
+						live[*op] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, bb := range fn.Blocks {
+		kept := bb.Instrs[:0]
+
+		for _, instr := range bb.Instrs {
+			if a, ok := instr.(*Alloca); ok && !live[a.Name] {
+				continue
+			}
+
+			if dest, ok := pureDest(instr); ok && !live[dest] {
+				continue
+			}
+
+			if st, ok := localStore(instr); ok && !live[st.Dest] {
+				continue
+			}
+
+			kept = append(kept, instr)
+		}
+
+		bb.Instrs = kept
+	}
+}
+
+// pureDest returns the result of an instruction that does nothing but
+// compute it, so that it can go when the result is unused
+func pureDest(instr Instruction) (string, bool) {
+	switch i := instr.(type) {
+	case *Load:
+		return i.Dest, !i.Volatile
+	case *AddrOf:
+		return i.Dest, true
+	case *BinOp:
+		return i.Dest, true
+	case *MakeTuple:
+		return i.Dest, true
+	case *PackSlice:
+		return i.Dest, true
+	case *Extract:
+		return i.Dest, true
+	case *Insert:
+		return i.Dest, true
+	case *FieldAddr:
+		return i.Dest, true
+	case *ElemAddr:
+		return i.Dest, true
+	case *TagAddr:
+		return i.Dest, true
+	case *PayloadAddr:
+		return i.Dest, true
+	case *EnumTag:
+		return i.Dest, true
+	case *Cast:
+		return i.Dest, true
+	case *Phi:
+		return i.Dest, true
+	case *FuncRef:
+		return i.Dest, true
+	case *SizeOf:
+		return i.Dest, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,154 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestEliminateDeadCode(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+
+	tests := []struct {
+		name   string
+		blocks []*BasicBlock
+		want   string
+	}{
+		{
+			name: "unused pure results go, calls stay",
+			blocks: []*BasicBlock{
+				{Label: "entry", Instrs: []Instruction{
+					&BinOp{Dest: "t1", Op: Add, Left: "1", Right: "2", Type: i32},
+					&BinOp{Dest: "t2", Op: Mul, Left: "t1", Right: "3", Type: i32},
+					&Call{Dest: "t3", Callee: "g", RetTy: i32},
+					&Ret{Value: "0", Type: i32},
+				}},
+			},
+			want: "bb_entry:\n  %t3 = call i32 @g()\n  ret i32 %0\n",
+		},
+		{
+			name: "instructions after a terminator",
+			blocks: []*BasicBlock{
+				{Label: "entry", Instrs: []Instruction{
+					&Ret{Value: "0", Type: i32},
+					&Call{Dest: "t1", Callee: "g", RetTy: i32},
+					&Unreachable{},
+				}},
+			},
+			want: "bb_entry:\n  ret i32 %0\n",
+		},
+		{
+			name: "locals that are only stored to",
+			blocks: []*BasicBlock{
+				{Label: "entry", Instrs: []Instruction{
+					&Alloca{Name: "x", Type: i32},
+					&Call{Dest: "t1", Callee: "g", RetTy: i32},
+					&BinOp{Dest: "t2", Op: Add, Left: "t1", Right: "1", Type: i32},
+					&Store{Value: "t2", Dest: "x", Type: i32},
+					&Alloca{Name: "y", Type: i32},
+					&Store{Value: "1", Dest: "y", Type: i32, Volatile: true},
+					&Ret{Value: "0", Type: i32},
+				}},
+			},
+			want: "bb_entry:\n  %t1 = call i32 @g()\n  %y = alloca i32\n  store volatile i32 %1, i32* %y\n  ret i32 %0\n",
+		},
+		{
+			name: "unreachable blocks and their phi entries",
+			blocks: []*BasicBlock{
+				{Label: "entry", Instrs: []Instruction{
+					&Br{Label: "exit"},
+				}},
+				{Label: "dead", Instrs: []Instruction{
+					&Br{Label: "exit"},
+				}},
+				{Label: "exit", Instrs: []Instruction{
+					&Phi{Dest: "p", Type: i32, Incoming: []Incoming{{Value: "1", Label: "entry"}, {Value: "2", Label: "dead"}}},
+					&Ret{Value: "p", Type: i32},
+				}},
+			},
+			want: "bb_entry:\n  br label %bb_exit\nbb_exit:\n  %p = phi i32 [%1, %bb_entry]\n  ret i32 %p\n",
+		},
+		{
+			name: "loop phis that only feed themselves",
+			blocks: []*BasicBlock{
+				{Label: "entry", Instrs: []Instruction{
+					&Br{Label: "loop"},
+				}},
+				{Label: "loop", Instrs: []Instruction{
+					&Phi{Dest: "i", Type: i32, Incoming: []Incoming{{Value: "0", Label: "entry"}, {Value: "t1", Label: "loop"}}},
+					&BinOp{Dest: "t1", Op: Add, Left: "i", Right: "1", Type: i32},
+					&Call{Dest: "t2", Callee: "g", RetTy: i32},
+					&BinOp{Dest: "t3", Op: Eq, Left: "t2", Right: "0", Type: i32},
+					&CondBr{Cond: "t3", TrueLabel: "loop", FalseLabel: "exit"},
+				}},
+				{Label: "exit", Instrs: []Instruction{
+					&Ret{Value: "0", Type: i32},
+				}},
+			},
+			want: "bb_entry:\n  br label %bb_loop\nbb_loop:\n  %t2 = call i32 @g()\n  %t3 = eq i32 %t2, %0\n  br i1 %t3, label %bb_loop, label %bb_exit\nbb_exit:\n  ret i32 %0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := &Function{Name: "f", RetTy: i32, Blocks: tt.blocks}
+			EliminateDeadCode(&Module{Functions: []*Function{fn}})
+
+			want := "define i32 @f() {\n" + tt.want + "}\n"
+			if got := fn.String(); got != want {
+				t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestEliminateDeadCodeAfterEarlyExits(t *testing.T) {
+	input := `fn f(n i32) i32 {
+	while true {
+		if n > 0 {
+			return n
+		}
+		break
+		let x = n + 1
+	}
+	return 0
+	let y = n * 2
+}`
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	module := lowerer.LowerFile(file)
+	EliminateDeadCode(module)
+
+	fn := module.Function("f")
+
+	for _, bb := range fn.Blocks {
+		for i, instr := range bb.Instrs {
+			if isTerminator(instr) != (i == len(bb.Instrs)-1) {
+				t.Errorf("block %s: terminator out of place at %d in:\n%s", bb.Label, i, fn)
+			}
+		}
+	}
+
+	out := fn.String()
+	for _, unwanted := range []string{"%x", "%y", "mul"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected no %q in:\n%s", unwanted, out)
+		}
+	}
+}
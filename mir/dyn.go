@@ -0,0 +1,134 @@
+package mir
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/types"
+)
+
+// A &dyn Trait is a pair of the object's address, as an i8 pointer, and the
+// vtable of its type's impl of the trait. Each impl of a trait gets a vtable
+// listing its methods in the order the trait declares them, and a method
+// called through a trait object is looked up there by that position.
+
+// dynRefType is the type &dyn Trait is lowered as, whatever the trait
+func dynRefType() *TupleType {
+	i8ptr := &PtrType{Elem: &PrimitiveType{Name: "i8"}}
+	return &TupleType{Elems: []Type{i8ptr, &PtrType{Elem: i8ptr}}}
+}
+
+// dynTrait returns the trait of t if it spells &dyn Trait or &mut dyn Trait
+func dynTrait(t ast.Type) (string, bool) {
+	ref, ok := t.(*ast.RefType)
+	if !ok {
+		return "", false
+	}
+
+	dyn, ok := ref.Elem.(*ast.DynType)
+	if !ok {
+		return "", false
+	}
+
+	return dyn.Trait.Path[len(dyn.Trait.Path)-1], true
+}
+
+// declareVTable adds the vtable of impl, if it implements a trait
+func (l *Lowerer) declareVTable(impl *ast.ImplBlock, typ string) {
+	if impl.Trait == nil {
+		return
+	}
+
+	trait, ok := l.traits[impl.Trait.Path[len(impl.Trait.Path)-1]]
+	if !ok {
+		return
+	}
+
+	vt := &GlobalVTable{Name: VTableName(trait.Name, typ)}
+	for _, sig := range trait.Sigs {
+		vt.Methods = append(vt.Methods, methodName(typ, sig.Name))
+	}
+
+	l.vtables[vt.Name] = true
+	l.module.Globals = append(l.module.Globals, vt)
+}
+
+// lowerUnsize makes the trait object for ptr, a pointer to a value of type
+// from, which implements trait
+func (l *Lowerer) lowerUnsize(ptr string, from Type, trait string) string {
+	elem := from
+	if p, ok := from.(*PtrType); ok {
+		elem = p.Elem
+	}
+
+	name, ok := receiverName(elem)
+	if !ok || !l.vtables[VTableName(trait, name)] {
+		l.errorf("conversion of %s to &dyn %s cannot be lowered", from.String(), trait)
+		return "undef"
+	}
+
+	dyn := dynRefType()
+
+	obj := l.newTemp()
+	l.emit(&Cast{Dest: obj, Kind: BitCast, Value: ptr, From: from, To: dyn.Elems[0]})
+
+	result := l.newTemp()
+	l.emit(&MakeTuple{Dest: result, Elems: []string{obj, "@" + VTableName(trait, name)}, Type: dyn})
+
+	return result
+}
+
+// dynMethod returns the trait method callee calls when its receiver is a
+// trait object, and its slot in the trait's vtables
+func (l *Lowerer) dynMethod(callee *ast.FieldExpr) (*types.Method, int, bool) {
+	ref, ok := l.types[callee.Expr].(*types.RefType)
+	if !ok {
+		return nil, 0, false
+	}
+
+	dyn, ok := ref.Elem.(*types.DynType)
+	if !ok {
+		return nil, 0, false
+	}
+
+	for i, m := range dyn.Trait.Methods {
+		if m.Name == callee.Field {
+			return m, i, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// lowerDynCall calls a method through the vtable of a trait object. It
+// returns the call's result, or "" for a void method.
+func (l *Lowerer) lowerDynCall(call *ast.CallExpr, callee *ast.FieldExpr, slot int) string {
+	retTy := l.typeOf(call)
+	if t, ok := l.types[call]; ok {
+		if _, known := l.fromChecker(t); !known {
+			l.errorf("method %s cannot be called through a trait object", callee.Field)
+			return "undef"
+		}
+	}
+
+	dyn := dynRefType()
+	recv := l.lowerExpr(callee.Expr)
+
+	obj := l.newTemp()
+	l.emit(&Extract{Dest: obj, Tuple: recv, Index: 0, Type: dyn.Elems[0]})
+
+	vtable := l.newTemp()
+	l.emit(&Extract{Dest: vtable, Tuple: recv, Index: 1, Type: dyn.Elems[1]})
+
+	vcall := &VCall{VTable: vtable, Slot: slot, Object: obj, RetTy: retTy}
+	for _, arg := range call.Args {
+		vcall.Args = append(vcall.Args, l.lowerExpr(arg))
+		vcall.ArgTys = append(vcall.ArgTys, l.typeOf(arg))
+	}
+
+	if prim, ok := retTy.(*PrimitiveType); !ok || prim.Name != "void" {
+		vcall.Dest = l.newTemp()
+	}
+
+	l.emit(vcall)
+
+	return vcall.Dest
+}
@@ -0,0 +1,168 @@
+package mir
+
+import (
+	"fmt"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/consteval"
+)
+
+// declareEnum records that name is an enum, so that types can refer to it
+// before its payloads are lowered. Generic enums have no single layout and
+// are left out.
+func (l *Lowerer) declareEnum(decl *ast.EnumDecl) {
+	if len(decl.TParams) > 0 {
+		return
+	}
+
+	e := &EnumType{Name: decl.Name}
+	l.enums[decl.Name] = e
+	l.module.Enums = append(l.module.Enums, e)
+}
+
+// defineEnum lowers the variants of an enum declared by declareEnum and
+// adds a constructor function for each. Tags count up from zero, or from
+// the last explicit discriminant.
+func (l *Lowerer) defineEnum(decl *ast.EnumDecl) {
+	e, ok := l.enums[decl.Name]
+	if !ok {
+		return
+	}
+
+	next := int64(0)
+
+	for _, variant := range decl.Variants {
+		if variant.Value != nil {
+			if v, err := consteval.Eval(variant.Value, l.constant); err == nil {
+				next = v
+			}
+		}
+
+		v := Variant{Name: variant.Name, Tag: next}
+		for _, t := range variant.Types {
+			v.Payload = append(v.Payload, l.lowerType(t))
+		}
+
+		e.Variants = append(e.Variants, v)
+		next++
+	}
+
+	for i := range e.Variants {
+		fn := constructor(e, i)
+		l.signatures[fn.Name] = fn
+		l.module.Functions = append(l.module.Functions, fn)
+	}
+}
+
+// constructor builds the function that makes variant i of e from its
+// payload: it sets the tag and each payload value of an enum on the stack
+// and returns it
+func constructor(e *EnumType, i int) *Function {
+	v := e.Variants[i]
+	typ := &EnumType{Name: e.Name}
+	i32 := &PrimitiveType{Name: "i32"}
+
+	fn := &Function{
+		Name:  Constructor(e.Name, v.Name),
+		RetTy: typ,
+	}
+
+	entry := &BasicBlock{Label: fn.Name + ".entry_1"}
+	entry.Instrs = []Instruction{
+		&Alloca{Name: "e", Type: typ},
+		&TagAddr{Dest: "t1", Base: "e", Type: typ},
+		&Store{Value: fmt.Sprint(v.Tag), Dest: "t1", Type: i32},
+	}
+
+	tmp := 1
+
+	for j, t := range v.Payload {
+		param := fmt.Sprintf("v%d", j)
+		fn.Params = append(fn.Params, Param{Name: param, Type: t})
+
+		val := fmt.Sprintf("t%d", tmp+1)
+		addr := fmt.Sprintf("t%d", tmp+2)
+		tmp += 2
+
+		entry.Instrs = append(entry.Instrs,
+			&Load{Dest: val, Source: param, Type: t},
+			&PayloadAddr{Dest: addr, Base: "e", Variant: i, Index: j, Type: typ},
+			&Store{Value: val, Dest: addr, Type: t},
+		)
+	}
+
+	result := fmt.Sprintf("t%d", tmp+1)
+	entry.Instrs = append(entry.Instrs,
+		&Load{Dest: result, Source: "e", Type: typ},
+		&Ret{Value: result, Type: typ},
+	)
+
+	fn.Blocks = []*BasicBlock{entry}
+
+	return fn
+}
+
+// variantOf resolves Enum::Variant to the enum's layout and the variant's
+// constructor
+func (l *Lowerer) variantOf(path *ast.PathExpr) (*EnumType, string, bool) {
+	if len(path.Segments) != 2 {
+		return nil, "", false
+	}
+
+	e, ok := l.enums[path.Segments[0]]
+	if !ok {
+		return nil, "", false
+	}
+
+	for _, v := range e.Variants {
+		if v.Name == path.Segments[1] {
+			return e, Constructor(e.Name, v.Name), true
+		}
+	}
+
+	return nil, "", false
+}
+
+// lowerPathExpr lowers a variant without a payload to a call of its
+// constructor
+func (l *Lowerer) lowerPathExpr(path *ast.PathExpr) string {
+	e, name, ok := l.variantOf(path)
+	if !ok {
+		// Option::None
+		if e, name, ok = l.preludeVariant(path, path); !ok {
+			return "undef"
+		}
+	}
+
+	result := l.newTemp()
+	l.emit(&Call{Dest: result, Callee: name, RetTy: &EnumType{Name: e.Name}})
+
+	return result
+}
+
+// hasPayload reports whether any variant of e carries values
+func hasPayload(e *EnumType) bool {
+	for _, v := range e.Variants {
+		if len(v.Payload) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lowerEnumCompare compares two values of an enum without payloads by
+// their tags, since the variant is all there is to compare
+func (l *Lowerer) lowerEnumCompare(bin *ast.BinaryExpr, typ *EnumType) string {
+	tags := make([]string, 2)
+	for i, operand := range []ast.Expr{bin.Left, bin.Right} {
+		val := l.lowerExpr(operand)
+		tags[i] = l.newTemp()
+		l.emit(&EnumTag{Dest: tags[i], Value: val, Type: typ})
+	}
+
+	result := l.newTemp()
+	l.emit(&BinOp{Dest: result, Op: l.binOpKind(bin.Op), Left: tags[0], Right: tags[1], Type: &PrimitiveType{Name: "i32"}})
+
+	return result
+}
@@ -0,0 +1,103 @@
+package mir
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/format"
+)
+
+// println with a format string is lowered to a runtime call for each piece
+// of text and each argument, none of which ends the line, and then println
+// of the text after the last placeholder. Arguments are printed by
+// yar_print_<kind>; integers are widened to 64 bits first.
+
+// formatString returns the format string of call, if it is println with
+// one: a string literal followed by the values for its placeholders, or a
+// literal that has braces to unescape
+func (l *Lowerer) formatString(call *ast.CallExpr) (string, bool) {
+	ident, ok := call.Callee.(*ast.Ident)
+	if !ok || ident.Name != "println" || len(call.Args) == 0 {
+		return "", false
+	}
+
+	if _, ok := l.signatures["println"]; ok {
+		return "", false
+	}
+
+	lit, ok := call.Args[0].(*ast.StringLit)
+	if !ok || len(call.Args) == 1 && format.Plain(lit.Value) {
+		return "", false
+	}
+
+	return lit.Value, true
+}
+
+// lowerFormat prints the pieces of the format string s, with the values of
+// args in its placeholders
+func (l *Lowerer) lowerFormat(s string, args []ast.Expr) {
+	pieces, err := format.Split(s)
+	if err != nil || len(pieces) != len(args)+1 {
+		l.errorf("format string %q does not match its %d arguments", s, len(args))
+		return
+	}
+
+	void := &PrimitiveType{Name: "void"}
+
+	for i, arg := range args {
+		if pieces[i] != "" {
+			text := l.lowerExpr(&ast.StringLit{Value: pieces[i]})
+			l.emit(&Call{Callee: "yar_print_str", Args: []string{text}, RetTy: void})
+		}
+
+		l.printValue(arg)
+	}
+
+	text := l.lowerExpr(&ast.StringLit{Value: pieces[len(args)]})
+	l.emit(&Call{Callee: "println", Args: []string{text}, RetTy: void})
+}
+
+// printValue prints the value of arg in a placeholder
+func (l *Lowerer) printValue(arg ast.Expr) {
+	typ := l.typeOf(arg)
+
+	val := l.lowerExpr(arg)
+	if isImmediate(val) {
+		tmp := l.newTemp()
+		l.emit(materialize(tmp, val, typ))
+		val = tmp
+	}
+
+	name := ""
+
+	switch t := typ.(type) {
+	case *StrType:
+		name = "yar_print_str"
+	case *PrimitiveType:
+		switch t.Name {
+		case "bool", "char", "f32", "f64", "i64", "u64":
+			name = "yar_print_" + t.Name
+		case "isize":
+			name = "yar_print_i64"
+		case "usize":
+			name = "yar_print_u64"
+		case "i8", "i16", "i32":
+			name, val = "yar_print_i64", l.widen(val, t, SExt, "i64")
+		case "u8", "u16", "u32":
+			name, val = "yar_print_u64", l.widen(val, t, ZExt, "u64")
+		}
+	}
+
+	if name == "" {
+		l.errorf("cannot format a value of type %s", typ.String())
+		return
+	}
+
+	l.emit(&Call{Callee: name, Args: []string{val}, RetTy: &PrimitiveType{Name: "void"}})
+}
+
+// widen converts val, an integer of type from, to the wider integer to
+func (l *Lowerer) widen(val string, from Type, kind CastKind, to string) string {
+	result := l.newTemp()
+	l.emit(&Cast{Dest: result, Kind: kind, Value: val, From: from, To: &PrimitiveType{Name: to}})
+
+	return result
+}
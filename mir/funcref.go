@@ -0,0 +1,96 @@
+package mir
+
+import "github.com/yarlson/yarlang/ast"
+
+// A function named where a value is expected is lowered as its address,
+// which a variable or a field of a fn(..) type holds. Calling such a value
+// is an indirect call, typed by the value's function type.
+
+// lowerFuncRef takes the address of the function name, if name denotes one
+// rather than a variable
+func (l *Lowerer) lowerFuncRef(name string) (string, bool) {
+	if _, ok := l.localType(name); ok {
+		return "", false
+	}
+
+	if _, ok := l.nestedFunc(name); ok {
+		l.errorf("nested function %s cannot be used as a value", name)
+		return "undef", true
+	}
+
+	sig, ok := l.signatures[name]
+	if !ok {
+		return "", false
+	}
+
+	if l.externs[name] || l.variadic[name] {
+		l.errorf("function %s cannot be used as a value", name)
+		return "undef", true
+	}
+
+	result := l.newTemp()
+	l.emit(&FuncRef{Dest: result, Func: name, Type: funcTypeOf(sig)})
+
+	return result, true
+}
+
+// funcTypeOf is the type of a pointer to fn
+func funcTypeOf(fn *Function) *FuncType {
+	t := &FuncType{Ret: fn.RetTy}
+	for _, p := range fn.Params {
+		t.Params = append(t.Params, p.Type)
+	}
+
+	return t
+}
+
+// funcValue returns the function type of callee if the call goes through
+// a function value: a variable of a fn(..) type, or such a field of a
+// struct that has no method of that name
+func (l *Lowerer) funcValue(callee ast.Expr) (*FuncType, bool) {
+	switch c := callee.(type) {
+	case *ast.Ident:
+		typ, ok := l.localType(c.Name)
+		if !ok {
+			return nil, false
+		}
+
+		fn, ok := typ.(*FuncType)
+
+		return fn, ok
+	case *ast.FieldExpr:
+		if _, ok := l.methodOf(c); ok {
+			return nil, false
+		}
+
+		_, _, elem, ok := l.fieldOf(c)
+		if !ok {
+			return nil, false
+		}
+
+		fn, ok := elem.(*FuncType)
+
+		return fn, ok
+	default:
+		return nil, false
+	}
+}
+
+// lowerIndirectCall calls the function value call's callee holds. It
+// returns the call's result, or "" for a function that returns nothing.
+func (l *Lowerer) lowerIndirectCall(call *ast.CallExpr, fn *FuncType) string {
+	callee := l.lowerExpr(call.Callee)
+
+	icall := &IndirectCall{Callee: callee, Type: fn}
+	for _, arg := range call.Args {
+		icall.Args = append(icall.Args, l.lowerExpr(arg))
+	}
+
+	if prim, ok := fn.Ret.(*PrimitiveType); !ok || prim.Name != "void" {
+		icall.Dest = l.newTemp()
+	}
+
+	l.emit(icall)
+
+	return icall.Dest
+}
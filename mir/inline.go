@@ -0,0 +1,438 @@
+package mir
+
+import "fmt"
+
+// Inline replaces calls to small functions with a copy of their body.
+// Functions of at most threshold instructions are inlined, and those marked
+// #[inline] whatever their size. Recursive functions and functions that
+// defer calls never are. Callees are inlined into before their callers, so
+// chains of small calls collapse from the bottom up.
+func Inline(m *Module, threshold int) {
+	in := &inliner{
+		funcs:     make(map[string]*Function, len(m.Functions)),
+		threshold: threshold,
+	}
+
+	for _, fn := range m.Functions {
+		in.funcs[fn.Name] = fn
+	}
+
+	in.recursive = in.findRecursive(m)
+
+	for _, fn := range in.callOrder(m) {
+		in.inlineCalls(fn)
+	}
+}
+
+type inliner struct {
+	funcs     map[string]*Function
+	recursive map[string]bool
+	threshold int
+	count     int // calls inlined into the current function, for unique names
+}
+
+// callees lists the functions of the module fn calls directly
+func (in *inliner) callees(fn *Function) []string {
+	var names []string
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			if call, ok := instr.(*Call); ok && in.funcs[call.Callee] != nil {
+				names = append(names, call.Callee)
+			}
+		}
+	}
+
+	return names
+}
+
+// findRecursive returns the functions that can reach themselves through
+// calls
+func (in *inliner) findRecursive(m *Module) map[string]bool {
+	recursive := make(map[string]bool)
+
+	for _, fn := range m.Functions {
+		seen := make(map[string]bool)
+		work := in.callees(fn)
+
+		for len(work) > 0 {
+			name := work[len(work)-1]
+			work = work[:len(work)-1]
+
+			if name == fn.Name {
+				recursive[fn.Name] = true
+				break
+			}
+
+			if !seen[name] {
+				seen[name] = true
+				work = append(work, in.callees(in.funcs[name])...)
+			}
+		}
+	}
+
+	return recursive
+}
+
+// callOrder lists the functions so that callees come before their callers,
+// outside of recursion
+func (in *inliner) callOrder(m *Module) []*Function {
+	var order []*Function
+
+	seen := make(map[string]bool)
+
+	var visit func(fn *Function)
+	visit = func(fn *Function) {
+		seen[fn.Name] = true
+
+		for _, name := range in.callees(fn) {
+			if !seen[name] {
+				visit(in.funcs[name])
+			}
+		}
+
+		order = append(order, fn)
+	}
+
+	for _, fn := range m.Functions {
+		if !seen[fn.Name] {
+			visit(fn)
+		}
+	}
+
+	return order
+}
+
+// inlinable reports whether call can be replaced with the body of callee
+func (in *inliner) inlinable(call *Call, callee *Function) bool {
+	if len(callee.Blocks) == 0 || in.recursive[callee.Name] || len(call.Args) != len(callee.Params) {
+		return false
+	}
+
+	size := 0
+
+	for _, bb := range callee.Blocks {
+		for _, instr := range bb.Instrs {
+			// The deferred calls of a callee would run at the caller's exit
+			if _, ok := instr.(*DeferPush); ok {
+				return false
+			}
+		}
+
+		size += len(bb.Instrs)
+	}
+
+	return callee.Inline || size <= in.threshold
+}
+
+func (in *inliner) inlineCalls(fn *Function) {
+	in.count = 0
+
+	// Blocks spliced in are scanned in turn, which finds the calls after an
+	// inlined one in the block that continues it
+	for i := 0; i < len(fn.Blocks); i++ {
+		for j, instr := range fn.Blocks[i].Instrs {
+			call, ok := instr.(*Call)
+			if !ok {
+				continue
+			}
+
+			callee := in.funcs[call.Callee]
+			if callee == nil || callee == fn || !in.inlinable(call, callee) {
+				continue
+			}
+
+			in.splice(fn, i, j, call, callee)
+
+			break
+		}
+	}
+}
+
+// splice replaces instruction at of block bi, a call to callee, with a copy
+// of the callee's body. The block branches to the copy's entry, each return
+// stores its value and branches to a new block holding the rest of the
+// original one, and that block loads the result. Parameters, locals and
+// the result live in slots at the top of the function, so that a call in a
+// loop does not grow the stack.
+func (in *inliner) splice(fn *Function, bi, at int, call *Call, callee *Function) {
+	in.count++
+	prefix := fmt.Sprintf("%s.%d.", callee.Name, in.count)
+
+	names := make(map[string]string)
+	for _, p := range callee.Params {
+		names[p.Name] = prefix + p.Name
+	}
+
+	for _, bb := range callee.Blocks {
+		for _, instr := range bb.Instrs {
+			if dest := defined(instr); dest != nil && *dest != "" {
+				names[*dest] = prefix + *dest
+			}
+		}
+	}
+
+	bb := fn.Blocks[bi]
+	cont := &BasicBlock{Label: prefix + "cont"}
+	rest := append([]Instruction(nil), bb.Instrs[at+1:]...)
+	bb.Instrs = bb.Instrs[:at]
+
+	var slots []Instruction
+
+	for i, p := range callee.Params {
+		slots = append(slots, &Alloca{Name: names[p.Name], Type: p.Type})
+		bb.Instrs = append(bb.Instrs, &Store{Value: call.Args[i], Dest: names[p.Name], Type: p.Type})
+	}
+
+	result := ""
+	if void, ok := callee.RetTy.(*PrimitiveType); call.Dest != "" && (!ok || void.Name != "void") {
+		result = prefix + "ret"
+		slots = append(slots, &Alloca{Name: result, Type: callee.RetTy})
+		cont.Instrs = append(cont.Instrs, &Load{Dest: call.Dest, Source: result, Type: callee.RetTy})
+	}
+
+	cont.Instrs = append(cont.Instrs, rest...)
+	bb.Instrs = append(bb.Instrs, &Br{Label: prefix + callee.Blocks[0].Label})
+
+	body := make([]*BasicBlock, 0, len(callee.Blocks)+1)
+
+	for _, cb := range callee.Blocks {
+		nb := &BasicBlock{Label: prefix + cb.Label}
+
+		for _, instr := range cb.Instrs {
+			switch i := instr.(type) {
+			case *DeferRunAll:
+				// Runs the callee's defers, and it has none
+			case *Alloca:
+				slots = append(slots, &Alloca{Name: names[i.Name], Type: i.Type})
+			case *Ret:
+				if result != "" && i.Value != "" {
+					nb.Instrs = append(nb.Instrs, &Store{Value: renamed(names, i.Value), Dest: result, Type: i.Type})
+				}
+
+				nb.Instrs = append(nb.Instrs, &Br{Label: cont.Label})
+			default:
+				nb.Instrs = append(nb.Instrs, rename(instr, names, prefix))
+			}
+		}
+
+		body = append(body, nb)
+	}
+
+	// Phis after the call now see its edge come from the continuation
+	for _, label := range successors(cont) {
+		for _, b := range fn.Blocks {
+			if b.Label != label {
+				continue
+			}
+
+			for _, instr := range b.Instrs {
+				if phi, ok := instr.(*Phi); ok {
+					for k := range phi.Incoming {
+						if phi.Incoming[k].Label == bb.Label {
+							phi.Incoming[k].Label = cont.Label
+						}
+					}
+				}
+			}
+		}
+	}
+
+	entry := fn.Blocks[0]
+	entry.Instrs = append(slots, entry.Instrs...)
+
+	blocks := make([]*BasicBlock, 0, len(fn.Blocks)+len(body)+1)
+	blocks = append(blocks, fn.Blocks[:bi+1]...)
+	blocks = append(blocks, body...)
+	blocks = append(blocks, cont)
+	blocks = append(blocks, fn.Blocks[bi+1:]...)
+	fn.Blocks = blocks
+}
+
+func renamed(names map[string]string, name string) string {
+	if n, ok := names[name]; ok {
+		return n
+	}
+
+	return name
+}
+
+// rename returns a copy of instr with the values in names and every label
+// given their new names
+func rename(instr Instruction, names map[string]string, prefix string) Instruction {
+	c := clone(instr)
+
+	for _, op := range operands(c) {
+		*op = renamed(names, *op)
+	}
+
+	if dest := defined(c); dest != nil {
+		*dest = renamed(names, *dest)
+	}
+
+	switch i := c.(type) {
+	case *Br:
+		i.Label = prefix + i.Label
+	case *CondBr:
+		i.TrueLabel = prefix + i.TrueLabel
+		i.FalseLabel = prefix + i.FalseLabel
+	case *Phi:
+		for k := range i.Incoming {
+			i.Incoming[k].Label = prefix + i.Incoming[k].Label
+		}
+	}
+
+	return c
+}
+
+// defined returns the name an instruction defines, or nil
+func defined(instr Instruction) *string {
+	switch i := instr.(type) {
+	case *Alloca:
+		return &i.Name
+	case *Load:
+		return &i.Dest
+	case *AddrOf:
+		return &i.Dest
+	case *BinOp:
+		return &i.Dest
+	case *MakeTuple:
+		return &i.Dest
+	case *PackSlice:
+		return &i.Dest
+	case *Extract:
+		return &i.Dest
+	case *Insert:
+		return &i.Dest
+	case *FieldAddr:
+		return &i.Dest
+	case *ElemAddr:
+		return &i.Dest
+	case *TagAddr:
+		return &i.Dest
+	case *PayloadAddr:
+		return &i.Dest
+	case *EnumTag:
+		return &i.Dest
+	case *Phi:
+		return &i.Dest
+	case *Cast:
+		return &i.Dest
+	case *Call:
+		return &i.Dest
+	case *VCall:
+		return &i.Dest
+	case *FuncRef:
+		return &i.Dest
+	case *SizeOf:
+		return &i.Dest
+	case *IndirectCall:
+		return &i.Dest
+	default:
+		return nil
+	}
+}
+
+// clone copies instr, including the slices it holds, so that renaming the
+// copy leaves the original alone
+func clone(instr Instruction) Instruction {
+	switch i := instr.(type) {
+	case *Alloca:
+		c := *i
+		return &c
+	case *Load:
+		c := *i
+		return &c
+	case *AddrOf:
+		c := *i
+		return &c
+	case *Store:
+		c := *i
+		return &c
+	case *BinOp:
+		c := *i
+		return &c
+	case *MakeTuple:
+		c := *i
+		c.Elems = append([]string(nil), i.Elems...)
+
+		return &c
+	case *PackSlice:
+		c := *i
+		c.Elems = append([]string(nil), i.Elems...)
+
+		return &c
+	case *Extract:
+		c := *i
+		return &c
+	case *Insert:
+		c := *i
+		return &c
+	case *FieldAddr:
+		c := *i
+		return &c
+	case *ElemAddr:
+		c := *i
+		return &c
+	case *TagAddr:
+		c := *i
+		return &c
+	case *PayloadAddr:
+		c := *i
+		return &c
+	case *EnumTag:
+		c := *i
+		return &c
+	case *Phi:
+		c := *i
+		c.Incoming = append([]Incoming(nil), i.Incoming...)
+
+		return &c
+	case *Cast:
+		c := *i
+		return &c
+	case *Call:
+		c := *i
+		c.Args = append([]string(nil), i.Args...)
+
+		return &c
+	case *VCall:
+		c := *i
+		c.Args = append([]string(nil), i.Args...)
+
+		return &c
+	case *FuncRef:
+		c := *i
+		return &c
+	case *SizeOf:
+		c := *i
+		return &c
+	case *IndirectCall:
+		c := *i
+		c.Args = append([]string(nil), i.Args...)
+
+		return &c
+	case *Ret:
+		c := *i
+		return &c
+	case *Br:
+		c := *i
+		return &c
+	case *CondBr:
+		c := *i
+		return &c
+	case *Unreachable:
+		return &Unreachable{}
+	case *DeferRunAll:
+		return &DeferRunAll{}
+	case *DeferPush:
+		c := *i
+		call := *i.Call
+		call.Args = append([]string(nil), i.Call.Args...)
+		c.Call = &call
+
+		return &c
+	default:
+		return instr
+	}
+}
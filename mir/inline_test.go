@@ -0,0 +1,192 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestInline(t *testing.T) {
+	const decls = `fn sq(x i32) i32 {
+	return x * x
+}
+
+fn fact(n i32) i32 {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n - 1)
+}
+
+#[inline]
+fn big(a i32) i32 {
+	let mut r = a + 1
+	r = r * 2
+	r = r - 3
+	r = r * r
+	r = r + 7
+	return r
+}
+
+fn clamp(x i32, hi i32) i32 {
+	if x > (hi) {
+		return hi
+	}
+	return x
+}
+`
+
+	tests := []struct {
+		name      string
+		body      string
+		threshold int
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:      "small function",
+			body:      `return sq(3) + 1`,
+			threshold: 10,
+			contains: []string{
+				"%sq.1.x = alloca i32",
+				"%sq.1.ret = alloca i32",
+				"store i32 %3, i32* %sq.1.x",
+				"br label %bb_sq.1.sq.entry_1",
+				"%sq.1.t3 = mul i32 %sq.1.t1, %sq.1.t2",
+				"store i32 %sq.1.t3, i32* %sq.1.ret",
+				"br label %bb_sq.1.cont",
+				"%t1 = load i32, i32* %sq.1.ret",
+				"%t2 = add i32 %t1, %1",
+			},
+			excludes: []string{"call", "defer_run_all\n  br"},
+		},
+		{
+			name:      "every return goes to the continuation",
+			body:      `return clamp(12, 10)`,
+			threshold: 10,
+			contains: []string{
+				"store i32 %clamp.1.t4, i32* %clamp.1.ret",
+				"store i32 %clamp.1.t5, i32* %clamp.1.ret",
+				"bb_clamp.1.cont:\n  %t1 = load i32, i32* %clamp.1.ret",
+			},
+			excludes: []string{"call"},
+		},
+		{
+			name:      "recursive functions stay calls",
+			body:      `return fact(5)`,
+			threshold: 100,
+			contains:  []string{"%t1 = call i32 @fact(5)"},
+		},
+		{
+			name:      "over the threshold",
+			body:      `return clamp(1, 2)`,
+			threshold: 3,
+			contains:  []string{"%t1 = call i32 @clamp(1, 2)"},
+		},
+		{
+			name:      "inline attribute ignores the threshold",
+			body:      `return big(2)`,
+			threshold: 0,
+			contains:  []string{"store i32 %2, i32* %big.1.a"},
+			excludes:  []string{"call"},
+		},
+		{
+			name:      "callees are inlined into first",
+			body:      `return sq(sq(2))`,
+			threshold: 10,
+			contains:  []string{"%sq.1.x", "%sq.2.x"},
+			excludes:  []string{"call"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := decls + "fn f() i32 {\n\t" + tt.body + "\n}\n"
+
+			p := parser.New(lexer.New(input))
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := checker.NewChecker()
+			if err := c.CheckFile(file); err != nil {
+				t.Fatalf("checker error: %v", err)
+			}
+
+			lowerer := NewLowerer()
+			lowerer.SetTypes(c.Types())
+			module := lowerer.LowerFile(file)
+			Inline(module, tt.threshold)
+
+			out := module.Function("f").String()
+
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected %q in:\n%s", want, out)
+				}
+			}
+
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("expected no %q in:\n%s", unwanted, out)
+				}
+			}
+		})
+	}
+}
+
+func TestInlineRelabelsPhis(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	id := &Function{
+		Name:   "id",
+		Params: []Param{{Name: "x", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*BasicBlock{{Label: "entry", Instrs: []Instruction{
+			&Load{Dest: "t1", Source: "x", Type: i32},
+			&Ret{Value: "t1", Type: i32},
+		}}},
+	}
+	f := &Function{
+		Name:  "f",
+		RetTy: i32,
+		Blocks: []*BasicBlock{
+			{Label: "entry", Instrs: []Instruction{
+				&Call{Dest: "t1", Callee: "id", Args: []string{"7"}, RetTy: i32},
+				&Br{Label: "exit"},
+			}},
+			{Label: "exit", Instrs: []Instruction{
+				&Phi{Dest: "p", Type: i32, Incoming: []Incoming{{Value: "t1", Label: "entry"}}},
+				&Ret{Value: "p", Type: i32},
+			}},
+		},
+	}
+
+	Inline(&Module{Functions: []*Function{id, f}}, 10)
+
+	want := `define i32 @f() {
+bb_entry:
+  %id.1.x = alloca i32
+  %id.1.ret = alloca i32
+  store i32 %7, i32* %id.1.x
+  br label %bb_id.1.entry
+bb_id.1.entry:
+  %id.1.t1 = load i32, i32* %id.1.x
+  store i32 %id.1.t1, i32* %id.1.ret
+  br label %bb_id.1.cont
+bb_id.1.cont:
+  %t1 = load i32, i32* %id.1.ret
+  br label %bb_exit
+bb_exit:
+  %p = phi i32 [%t1, %bb_id.1.cont]
+  ret i32 %p
+}
+`
+	if got := f.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
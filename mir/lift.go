@@ -0,0 +1,147 @@
+package mir
+
+import (
+	"fmt"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// lifted is a nested function hoisted to the top level of the module. The
+// variables it reads from enclosing functions become extra parameters,
+// passed by value at every call.
+type lifted struct {
+	decl     *ast.FuncDecl
+	sig      *Function
+	captures []Param
+	outer    *scope // Nested functions and constants visible at the declaration, and itself
+}
+
+// liftFunc hoists a function declared in the current function's body and
+// queues it for lowering. It becomes callable from the rest of the body.
+func (l *Lowerer) liftFunc(fn *ast.FuncDecl) {
+	name := fmt.Sprintf("%s.%s", l.currentFn.Name, fn.Name)
+	for i := 2; l.signatures[name] != nil; i++ {
+		name = fmt.Sprintf("%s.%s.%d", l.currentFn.Name, fn.Name, i)
+	}
+
+	decl := *fn
+	decl.Name = name
+
+	f := &lifted{decl: fn, sig: l.lowerSignature(&decl), outer: newScope(l.globals)}
+	f.captures = l.captures(fn)
+	f.sig.Params = append(f.sig.Params, f.captures...)
+	l.signatures[name] = f.sig
+
+	// Flatten the functions and constants in scope, innermost first, so
+	// that later declarations in the enclosing blocks stay invisible to f
+	for s := l.scope; s != l.globals; s = s.parent {
+		for k, v := range s.fns {
+			if _, ok := f.outer.fns[k]; !ok {
+				f.outer.fns[k] = v
+			}
+		}
+
+		for k, v := range s.consts {
+			if _, ok := f.outer.consts[k]; !ok {
+				f.outer.consts[k] = v
+			}
+		}
+	}
+
+	f.outer.fns[fn.Name] = f
+	l.scope.fns[fn.Name] = f
+	l.pending = append(l.pending, f)
+}
+
+func (l *Lowerer) lowerLifted(f *lifted) {
+	l.lowerFuncBody(f.sig, f.decl.Body, f.outer)
+}
+
+// captures lists the variables of the current function that fn refers to,
+// including those needed to call the nested functions it calls
+func (l *Lowerer) captures(fn *ast.FuncDecl) []Param {
+	bound := make(map[string]bool)
+	for _, p := range fn.Params {
+		bound[p.Name] = true
+	}
+
+	var (
+		params []Param
+		seen   = make(map[string]bool)
+	)
+
+	capture := func(name string) {
+		if bound[name] || seen[name] {
+			return
+		}
+
+		if typ, ok := l.localType(name); ok {
+			seen[name] = true
+			params = append(params, Param{Name: name, Type: typ})
+		}
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStmt:
+			bound[n.Name] = true
+		case *ast.LetTupleStmt:
+			for _, name := range n.Names {
+				bound[name] = true
+			}
+		case *ast.FuncDecl:
+			for _, p := range n.Params {
+				bound[p.Name] = true
+			}
+		}
+
+		return true
+	})
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			capture(n.Name)
+		case *ast.CallExpr:
+			if ident, ok := n.Callee.(*ast.Ident); ok && ident.Name != fn.Name {
+				if callee, ok := l.nestedFunc(ident.Name); ok {
+					for _, p := range callee.captures {
+						capture(p.Name)
+					}
+				}
+			}
+		}
+
+		return true
+	})
+
+	return params
+}
+
+// localType finds the type of a variable in scope in the current function
+func (l *Lowerer) localType(name string) (Type, bool) {
+	slot, ok := "", false
+	for s := l.scope; s != nil && !ok; s = s.parent {
+		slot, ok = s.vars[name]
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	for _, p := range l.currentFn.Params {
+		if p.Name == slot {
+			return p.Type, true
+		}
+	}
+
+	for _, bb := range l.currentFn.Blocks {
+		for _, instr := range bb.Instrs {
+			if alloca, ok := instr.(*Alloca); ok && alloca.Name == slot {
+				return alloca.Type, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -2,28 +2,71 @@ package mir
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/consteval"
+	"github.com/yarlson/yarlang/types"
 )
 
 // Lowerer lowers AST to MIR
 type Lowerer struct {
-	tmpCounter        int
-	bbCounter         int
-	strCounter        int // Counter for string constants
+	tmpCounter        int // Per-function counter for temporaries
+	bbCounter         int // Per-function counter for block labels
+	strCounter        int // Per-function counter for string constants
+	fileStrCounter    int // Counter for string constants of file-level constants
 	module            *Module
 	currentFn         *Function
 	currentBB         *BasicBlock
-	loopExitLabel     string // Label to jump to for break
-	loopContinueLabel string // Label to jump to for continue
+	loopExitLabel     string                          // Label to jump to for break
+	loopContinueLabel string                          // Label to jump to for continue
+	signatures        map[string]*Function            // Signatures of the file's functions, without bodies
+	variadic          map[string]bool                 // Functions whose last parameter is variadic
+	externs           map[string]bool                 // Functions declared extern, which have no body here
+	globals           *scope                          // Top-level constants
+	scope             *scope                          // Innermost block scope of the current function
+	slots             map[string]int                  // Variables declared so far in the current function, by name
+	pending           []*lifted                       // Nested functions waiting to be lowered
+	types             map[ast.Expr]types.Type         // Checker's type of each expression, if known
+	structs           map[string]*layout              // Non-generic structs, by name
+	enums             map[string]*EnumType            // Non-generic enums, by name
+	methods           map[string]map[string]*Function // Method signatures, by receiver type name and method name
+	self              Type                            // Receiver type of the impl being lowered, which Self names
+	traits            map[string]*ast.TraitDecl       // Traits, by name
+	vtables           map[string]bool                 // Names of the vtables declared so far
+	literals          map[string]string               // String constants made so far, by value
+	imports           map[string]string               // Imported modules' paths, by the name they are used under
+	errors            []error                         // Constructs the lowerer cannot lower
 }
 
 func NewLowerer() *Lowerer {
 	return &Lowerer{
-		module: &Module{Globals: []Global{}, Functions: []*Function{}},
+		module:     &Module{Globals: []Global{}, Functions: []*Function{}},
+		globals:    newScope(nil),
+		signatures: make(map[string]*Function),
+		variadic:   make(map[string]bool),
+		externs:    make(map[string]bool),
+		structs:    make(map[string]*layout),
+		enums:      make(map[string]*EnumType),
+		methods:    make(map[string]map[string]*Function),
+		traits:     make(map[string]*ast.TraitDecl),
+		vtables:    make(map[string]bool),
+		literals:   make(map[string]string),
+		imports:    make(map[string]string),
 	}
 }
 
+// Errors returns the constructs the checker accepted but the lowerer could
+// not lower. The module must not be compiled when there are any.
+func (l *Lowerer) Errors() []error {
+	return l.errors
+}
+
+func (l *Lowerer) errorf(format string, args ...any) {
+	l.errors = append(l.errors, fmt.Errorf(format, args...))
+}
+
 func (l *Lowerer) newTemp() string {
 	l.tmpCounter++
 	return fmt.Sprintf("t%d", l.tmpCounter)
@@ -31,7 +74,7 @@ func (l *Lowerer) newTemp() string {
 
 func (l *Lowerer) newBB(name string) *BasicBlock {
 	l.bbCounter++
-	label := fmt.Sprintf("%s_%d", name, l.bbCounter)
+	label := fmt.Sprintf("%s.%s_%d", l.currentFn.Name, name, l.bbCounter)
 
 	return &BasicBlock{Label: label, Instrs: []Instruction{}}
 }
@@ -43,16 +86,68 @@ func (l *Lowerer) emit(instr Instruction) {
 }
 
 func (l *Lowerer) LowerFile(file *ast.File) *Module {
+	// Record constants and signatures up front so calls to functions
+	// defined later in the file are typed correctly
+	l.scope = l.globals
+
+	// Struct and enum names are known before any fields are lowered, since
+	// fields may refer to types declared later
+	for _, item := range file.Items {
+		switch d := item.(type) {
+		case *ast.StructDecl:
+			l.declareStruct(d)
+		case *ast.EnumDecl:
+			l.declareEnum(d)
+		case *ast.TraitDecl:
+			l.traits[d.Name] = d
+		case *ast.UseDecl:
+			l.declareUse(d)
+		}
+	}
+
 	for _, item := range file.Items {
-		if fn, ok := item.(*ast.FuncDecl); ok {
-			l.lowerFunc(fn)
+		switch d := item.(type) {
+		case *ast.StructDecl:
+			l.defineStruct(d)
+		case *ast.EnumDecl:
+			l.defineEnum(d)
+		case *ast.ConstDecl:
+			if !l.defineConst(d.Name, d.Value) {
+				l.defineGlobalConst(d)
+			}
+		case *ast.FuncDecl:
+			l.signatures[d.Name] = l.lowerSignature(d)
+			l.externs[d.Name] = d.Extern
+		case *ast.ImplBlock:
+			l.declareImpl(d)
 		}
 	}
 
+	// Extern functions are declared by codegen at their first call
+	for _, item := range file.Items {
+		switch d := item.(type) {
+		case *ast.FuncDecl:
+			if !d.Extern {
+				l.lowerFunc(d)
+			}
+		case *ast.ImplBlock:
+			l.lowerImpl(d)
+		}
+	}
+
+	// Nested functions are lowered after their parents, which may queue
+	// functions nested further in
+	for len(l.pending) > 0 {
+		fn := l.pending[0]
+		l.pending = l.pending[1:]
+		l.lowerLifted(fn)
+	}
+
 	return l.module
 }
 
-func (l *Lowerer) lowerFunc(fn *ast.FuncDecl) {
+// lowerSignature lowers the name, parameters and return type of fn
+func (l *Lowerer) lowerSignature(fn *ast.FuncDecl) *Function {
 	mirFn := &Function{
 		Name:   fn.Name,
 		Params: []Param{},
@@ -60,38 +155,73 @@ func (l *Lowerer) lowerFunc(fn *ast.FuncDecl) {
 		Blocks: []*BasicBlock{},
 	}
 
+	for _, attr := range fn.Attrs {
+		if attr.Name == "inline" {
+			mirFn.Inline = true
+		}
+	}
+
 	// Lower parameters
 	for _, param := range fn.Params {
-		mirFn.Params = append(mirFn.Params, Param{
-			Name: param.Name,
-			Type: l.lowerType(param.Type),
-		})
+		typ := l.lowerType(param.Type)
+		if param.Variadic {
+			typ = &SliceType{Elem: typ}
+			l.variadic[fn.Name] = true
+		}
+
+		mirFn.Params = append(mirFn.Params, Param{Name: param.Name, Type: typ})
 	}
 
+	return mirFn
+}
+
+func (l *Lowerer) lowerFunc(fn *ast.FuncDecl) {
+	l.lowerFuncBody(l.lowerSignature(fn), fn.Body, newScope(l.globals))
+}
+
+// lowerFuncBody lowers body into mirFn. outer holds the nested functions
+// the body can call; parameters are declared in a scope of their own below it.
+func (l *Lowerer) lowerFuncBody(mirFn *Function, body *ast.Block, outer *scope) {
+	l.scope = outer
+	l.slots = make(map[string]int)
+
+	l.pushScope()
+	defer l.popScope()
+
+	for _, p := range mirFn.Params {
+		l.declare(p.Name)
+	}
+
+	// Number temporaries, blocks and strings per function, and name blocks
+	// and strings after it, so that adding or changing one function never
+	// renames anything in another
+	l.tmpCounter = 0
+	l.bbCounter = 0
+	l.strCounter = 0
+
 	l.currentFn = mirFn
 	l.currentBB = l.newBB("entry")
 	mirFn.Blocks = append(mirFn.Blocks, l.currentBB)
 
 	// Lower body
-	l.lowerBlock(fn.Body)
+	l.lowerBlock(body)
 
-	// Add implicit return for void functions if not already present
+	// Add implicit return for void functions if not already present. The
+	// checker rejects non-void functions that can fall off the end, so any
+	// block left open there is dead and is closed with unreachable.
 	if l.currentBB != nil {
 		hasTerminator := false
 		if len(l.currentBB.Instrs) > 0 {
-			lastInstr := l.currentBB.Instrs[len(l.currentBB.Instrs)-1]
-			_, isRet := lastInstr.(*Ret)
-			_, isBr := lastInstr.(*Br)
-			_, isCondBr := lastInstr.(*CondBr)
-			hasTerminator = isRet || isBr || isCondBr
+			hasTerminator = isTerminator(l.currentBB.Instrs[len(l.currentBB.Instrs)-1])
 		}
 
-		// If the last instruction is not a terminator and the function is void, add implicit return
 		if !hasTerminator {
 			if voidType, ok := mirFn.RetTy.(*PrimitiveType); ok && voidType.Name == "void" {
 				// Insert DeferRunAll before implicit return
 				l.emit(&DeferRunAll{})
 				l.emit(&Ret{Value: "", Type: &PrimitiveType{Name: "void"}})
+			} else {
+				l.emit(&Unreachable{})
 			}
 		}
 	}
@@ -102,6 +232,9 @@ func (l *Lowerer) lowerFunc(fn *ast.FuncDecl) {
 }
 
 func (l *Lowerer) lowerBlock(block *ast.Block) {
+	l.pushScope()
+	defer l.popScope()
+
 	for _, stmt := range block.Stmts {
 		l.lowerStmt(stmt)
 	}
@@ -110,27 +243,50 @@ func (l *Lowerer) lowerBlock(block *ast.Block) {
 func (l *Lowerer) lowerStmt(stmt ast.Stmt) {
 	switch s := stmt.(type) {
 	case *ast.ReturnStmt:
-		// Insert DeferRunAll before return
-		l.emit(&DeferRunAll{})
+		// The value is computed before the deferred calls run, so they
+		// cannot change what is returned
 		if s.Value != nil {
-			val := l.lowerExpr(s.Value)
-			l.emit(&Ret{Value: val, Type: &PrimitiveType{Name: "i32"}})
+			var val string
+
+			// A tuple literal takes its element types from the signature
+			if tuple, ok := s.Value.(*ast.TupleExpr); ok {
+				retTy, _ := l.currentFn.RetTy.(*TupleType)
+				val = l.lowerTupleExpr(tuple, retTy)
+			} else {
+				val = l.lowerExpr(s.Value)
+			}
+
+			l.emit(&DeferRunAll{})
+			l.emit(&Ret{Value: val, Type: l.currentFn.RetTy})
 		} else {
+			l.emit(&DeferRunAll{})
 			l.emit(&Ret{Type: &PrimitiveType{Name: "void"}})
 		}
 	case *ast.LetStmt:
-		// Allocate on stack
-		l.emit(&Alloca{Name: s.Name, Type: &PrimitiveType{Name: "i32"}})
-		val := l.lowerExpr(s.Value)
-		l.emit(&Store{Value: val, Dest: s.Name, Type: &PrimitiveType{Name: "i32"}})
-	case *ast.AssignStmt:
-		// Handle assignment to existing variable
+		// The value is lowered first since it may refer to a variable the
+		// new one shadows
 		val := l.lowerExpr(s.Value)
-		if ident, ok := s.Target.(*ast.Ident); ok {
-			l.emit(&Store{Value: val, Dest: ident.Name, Type: &PrimitiveType{Name: "i32"}})
+
+		typ := l.typeOf(s.Value)
+		if s.Type != nil {
+			typ = l.lowerType(s.Type)
 		}
+
+		// Allocate on stack
+		slot := l.declare(s.Name)
+		l.emit(&Alloca{Name: slot, Type: typ})
+		l.emit(&Store{Value: val, Dest: slot, Type: typ})
+	case *ast.LetTupleStmt:
+		l.lowerLetTupleStmt(s)
+	case *ast.ShortDecl:
+		// x := value is an immutable let with the value's type
+		l.lowerStmt(&ast.LetStmt{Name: s.Name, Value: s.Value})
+	case *ast.AssignStmt:
+		l.lowerAssignStmt(s)
 	case *ast.IfStmt:
 		l.lowerIfStmt(s)
+	case *ast.GuardStmt:
+		l.lowerGuardStmt(s)
 	case *ast.WhileStmt:
 		l.lowerWhileStmt(s)
 	case *ast.ForStmt:
@@ -150,6 +306,36 @@ func (l *Lowerer) lowerStmt(stmt ast.Stmt) {
 	case *ast.DeferStmt:
 		// Lower the deferred expression (typically a call)
 		l.lowerDeferStmt(s)
+	case *ast.DeclStmt:
+		switch d := s.Decl.(type) {
+		case *ast.FuncDecl:
+			l.liftFunc(d)
+		case *ast.StructDecl:
+			l.declareStruct(d)
+			l.defineStruct(d)
+		case *ast.EnumDecl:
+			l.declareEnum(d)
+			l.defineEnum(d)
+		}
+	case *ast.Block:
+		l.lowerBlock(s)
+	case *ast.UnsafeBlock:
+		// unsafe only lifts checker restrictions
+		l.lowerBlock(s.Body)
+	case *ast.ConstStmt:
+		// Integer constants are folded into their uses; anything else is
+		// stored like a let
+		if !l.defineConst(s.Name, s.Value) {
+			val := l.lowerExpr(s.Value)
+			typ := l.typeOf(s.Value)
+			if s.Type != nil {
+				typ = l.lowerType(s.Type)
+			}
+
+			slot := l.declare(s.Name)
+			l.emit(&Alloca{Name: slot, Type: typ})
+			l.emit(&Store{Value: val, Dest: slot, Type: typ})
+		}
 	case *ast.ExprStmt:
 		// Expression statements (like println("hello"))
 		l.lowerExpr(s.Expr)
@@ -160,34 +346,118 @@ func (l *Lowerer) lowerStmt(stmt ast.Stmt) {
 func (l *Lowerer) lowerExpr(expr ast.Expr) string {
 	switch e := expr.(type) {
 	case *ast.BinaryExpr:
+		if e.Op == "&&" || e.Op == "||" {
+			return l.lowerLogicalExpr(e)
+		}
+
+		if typ, ok := l.operandType(e).(*EnumType); ok && (e.Op == "==" || e.Op == "!=") {
+			if layout, ok := l.enums[typ.Name]; ok && !hasPayload(layout) {
+				return l.lowerEnumCompare(e, typ)
+			}
+		}
+
 		left := l.lowerExpr(e.Left)
 		right := l.lowerExpr(e.Right)
+
+		if _, ok := l.operandType(e).(*StrType); ok && e.Op == "+" {
+			return l.strConcat(left, right)
+		}
+
 		result := l.newTemp()
 		op := l.binOpKind(e.Op)
-		l.emit(&BinOp{Dest: result, Op: op, Left: left, Right: right, Type: &PrimitiveType{Name: "i32"}})
+		l.emit(&BinOp{Dest: result, Op: op, Left: left, Right: right, Type: l.operandType(e)})
 
 		return result
+	case *ast.UnaryExpr:
+		return l.lowerUnaryExpr(e)
 	case *ast.Ident:
+		if v, ok := l.constant(e.Name); ok {
+			return strconv.FormatInt(v, 10)
+		}
+
+		if g, value, ok := l.fileConst(e.Name); ok {
+			if g == nil {
+				// The value names only other constants, so it is lowered
+				// in the file's scope, past any locals that shadow them
+				saved := l.scope
+				l.scope = l.globals
+				defer func() { l.scope = saved }()
+
+				return l.lowerExpr(value)
+			}
+
+			result := l.newTemp()
+			l.emit(&Load{Dest: result, Source: "@" + g.Name, Type: g.Type})
+
+			return result
+		}
+
+		if typ, name, ok := l.preludeVariant(e, e); ok {
+			result := l.newTemp()
+			l.emit(&Call{Dest: result, Callee: name, RetTy: typ})
+
+			return result
+		}
+
+		if ref, ok := l.lowerFuncRef(e.Name); ok {
+			return ref
+		}
+
 		// Load from stack
 		result := l.newTemp()
-		l.emit(&Load{Dest: result, Source: e.Name, Type: &PrimitiveType{Name: "i32"}})
+		l.emit(&Load{Dest: result, Source: l.slot(e.Name), Type: l.slotType(e.Name)})
 
 		return result
-	case *ast.IntLit:
-		return e.Value // Immediate value
+	case *ast.IntLit, *ast.FloatLit:
+		return e.String() // Immediate value
+	case *ast.BoolLit:
+		if e.Value {
+			return "1"
+		}
+
+		return "0"
 	case *ast.StringLit:
-		// Create a global string constant and return reference to it
-		l.strCounter++
-		globalName := fmt.Sprintf(".str.%d", l.strCounter)
+		// Equal literals share one global string constant
+		if name, ok := l.literals[e.Value]; ok {
+			return "@" + name
+		}
+
+		var globalName string
+		if l.currentFn != nil {
+			l.strCounter++
+			globalName = fmt.Sprintf(".str.%s.%d", l.currentFn.Name, l.strCounter)
+		} else {
+			l.fileStrCounter++
+			globalName = fmt.Sprintf(".str.%d", l.fileStrCounter)
+		}
 		l.module.Globals = append(l.module.Globals, &GlobalString{
 			Name:  globalName,
 			Value: e.Value,
 		})
+		l.literals[e.Value] = globalName
+
 		return "@" + globalName
 	case *ast.CallExpr:
 		return l.lowerCallExpr(e)
 	case *ast.PropagateExpr:
 		return l.lowerPropagateExpr(e)
+	case *ast.ConvExpr:
+		return l.lowerConvExpr(e)
+	case *ast.CastExpr:
+		return l.lowerCastExpr(e)
+	case *ast.TupleExpr:
+		typ, _ := l.typeOf(e).(*TupleType)
+		return l.lowerTupleExpr(e, typ)
+	case *ast.ArrayExpr:
+		return l.lowerArrayExpr(e)
+	case *ast.StructExpr:
+		return l.lowerStructExpr(e)
+	case *ast.FieldExpr:
+		return l.lowerFieldExpr(e)
+	case *ast.IndexExpr:
+		return l.lowerIndexExpr(e)
+	case *ast.PathExpr:
+		return l.lowerPathExpr(e)
 	// Add more expressions as needed
 	default:
 		return "undef"
@@ -195,49 +465,483 @@ func (l *Lowerer) lowerExpr(expr ast.Expr) string {
 }
 
 func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) string {
-	// Get function name from callee
-	var calleeName string
-	if ident, ok := call.Callee.(*ast.Ident); ok {
-		calleeName = ident.Name
-	} else {
-		// Handle more complex callees later (method calls, etc.)
+	if ident, ok := call.Callee.(*ast.Ident); ok && ident.Name == "memzero_explicit" {
+		l.lowerMemzero(call)
+		return ""
+	}
+
+	if s, ok := l.formatString(call); ok {
+		l.lowerFormat(s, call.Args[1:])
+		return ""
+	}
+
+	if field, ok := call.Callee.(*ast.FieldExpr); ok {
+		if _, slot, ok := l.dynMethod(field); ok {
+			return l.lowerDynCall(call, field, slot)
+		}
+
+		if elem, ok := l.vecElem(field.Expr); ok {
+			return l.lowerVecCall(call, field, elem)
+		}
+
+		if _, value, ok := l.mapTypes(field.Expr); ok {
+			return l.lowerMapCall(call, field, value)
+		}
+	}
+
+	if elem, ok := l.vecNew(call); ok {
+		return l.lowerVecNew(elem)
+	}
+
+	if key, value, ok := l.mapNew(call); ok {
+		return l.lowerMapNew(key, value)
+	}
+
+	if l.isCollection(call) {
+		l.errorf("%s needs its element types, which are not known", call.Callee.String())
 		return "undef"
 	}
 
+	if name, ok := l.osCall(call); ok {
+		return l.lowerOSCall(call, name)
+	}
+
+	if fn, ok := l.funcValue(call.Callee); ok {
+		return l.lowerIndirectCall(call, fn)
+	}
+
+	c, ok := l.buildCall(call)
+	if !ok {
+		return "undef"
+	}
+
+	// The checker knows the result types of builtins such as len
+	if typ, ok := l.fromChecker(l.types[call]); ok {
+		c.RetTy = typ
+	}
+
+	// println is void, other calls take a destination for their result
+	if c.Callee != "println" {
+		c.Dest = l.newTemp()
+	}
+
+	l.emit(c)
+
+	return c.Dest
+}
+
+// buildCall evaluates the callee's arguments, emitting their instructions,
+// and returns the call without emitting it or giving it a destination
+func (l *Lowerer) buildCall(call *ast.CallExpr) (*Call, bool) {
+	// Get function name from callee; Enum::Variant(...) calls the
+	// variant's constructor
+	var calleeName string
+
+	switch callee := call.Callee.(type) {
+	case *ast.Ident:
+		calleeName = callee.Name
+
+		if _, name, ok := l.preludeVariant(call, callee); ok {
+			calleeName = name
+		}
+	case *ast.PathExpr:
+		_, name, ok := l.variantOf(callee)
+		if !ok {
+			if _, name, ok = l.preludeVariant(call, callee); !ok {
+				// A module path such as std::io::println calls its last
+				// segment, as the checker resolves it, unless it names a
+				// function of a loaded standard library module
+				name = callee.Segments[len(callee.Segments)-1]
+				if fn, ok := l.stdFunc(callee); ok {
+					name = fn
+				}
+			}
+		}
+
+		calleeName = name
+	case *ast.FieldExpr:
+		return l.buildMethodCall(call, callee)
+	default:
+		l.errorf("calls to %s are not supported", call.Callee.String())
+		return nil, false
+	}
+
 	// Lower each argument
 	args := make([]string, len(call.Args))
 	for i, arg := range call.Args {
 		args[i] = l.lowerExpr(arg)
+
+		// Nothing types a literal passed to a function without a body here
+		if _, ok := l.signatures[calleeName]; (!ok || l.externs[calleeName]) && isImmediate(args[i]) && untypedAs(l.typeOf(arg)) {
+			val := l.newTemp()
+			l.emit(materialize(val, args[i], l.typeOf(arg)))
+			args[i] = val
+		}
 	}
 
-	// Determine return type by looking up the function
-	// For now, use a simple heuristic: println is void, others return i32
-	var (
-		retTy Type
-		dest  string
-	)
+	// A nested function is called by its lifted name, with the variables
+	// it captures passed after the declared arguments
+	var captures []Param
 
+	if fn, ok := l.nestedFunc(calleeName); ok {
+		calleeName = fn.sig.Name
+		captures = fn.captures
+	}
+
+	if l.variadic[calleeName] {
+		args = l.packVariadic(l.signatures[calleeName], args)
+	}
+
+	for _, p := range captures {
+		val := l.newTemp()
+		l.emit(&Load{Dest: val, Source: l.slot(p.Name), Type: p.Type})
+		args = append(args, val)
+	}
+
+	// Determine return type by looking up the function
+	var retTy Type
 	if calleeName == "println" {
 		retTy = &PrimitiveType{Name: "void"}
-		dest = "" // void calls don't have a destination
+
+		// Integers narrower than 32 bits are printed as i32s
+		if len(args) == 1 {
+			if prim, ok := l.typeOf(call.Args[0]).(*PrimitiveType); ok {
+				switch prim.Name {
+				case "i8", "i16":
+					args[0] = l.widen(args[0], prim, SExt, "i32")
+				case "u8", "u16":
+					args[0] = l.widen(args[0], prim, ZExt, "i32")
+				}
+			}
+		}
 	} else {
-		// Look up the function to get its return type
 		retTy = l.getFunctionReturnType(calleeName)
-		dest = l.newTemp()
 	}
 
-	l.emit(&Call{
-		Dest:   dest,
-		Callee: calleeName,
-		Args:   args,
-		RetTy:  retTy,
-	})
+	return &Call{Callee: calleeName, Args: args, RetTy: retTy}, true
+}
+
+// lowerAssignStmt stores into a variable, a field, or through a pointer.
+// A compound assignment such as x += v loads the target, applies the
+// operator and stores the result back.
+func (l *Lowerer) lowerAssignStmt(assign *ast.AssignStmt) {
+	val := l.lowerExpr(assign.Value)
+
+	addr, ok := l.lvalue(assign.Target)
+	if !ok {
+		l.errorf("assignment to %s cannot be lowered", assign.Target.String())
+		return
+	}
+
+	typ := l.typeOf(assign.Target)
+
+	if assign.Op != "=" {
+		cur := l.newTemp()
+		l.emit(&Load{Dest: cur, Source: addr, Type: typ})
+
+		if _, ok := typ.(*StrType); ok {
+			l.emit(&Store{Value: l.strConcat(cur, val), Dest: addr, Type: typ})
+			return
+		}
+
+		result := l.newTemp()
+		op := l.binOpKind(strings.TrimSuffix(assign.Op, "="))
+		l.emit(&BinOp{Dest: result, Op: op, Left: cur, Right: val, Type: typ})
+		val = result
+	}
+
+	l.emit(&Store{Value: val, Dest: addr, Type: typ})
+}
+
+// strConcat joins two strs with the runtime's str_concat, which copies
+// them into a new one
+func (l *Lowerer) strConcat(left, right string) string {
+	result := l.newTemp()
+	l.emit(&Call{Dest: result, Callee: "str_concat", Args: []string{left, right}, RetTy: &StrType{}})
+
+	return result
+}
+
+// lvalue emits the address an assignment to target stores to
+func (l *Lowerer) lvalue(target ast.Expr) (string, bool) {
+	switch t := target.(type) {
+	case *ast.Ident:
+		return l.slot(t.Name), true
+	case *ast.FieldExpr:
+		return l.fieldAddr(t)
+	case *ast.IndexExpr:
+		addr, _, ok := l.elemAddr(t)
+		return addr, ok
+	case *ast.UnaryExpr:
+		if t.Op == "*" {
+			return l.lowerExpr(t.Expr), true
+		}
+	}
+
+	return "", false
+}
 
-	return dest
+// lowerTupleExpr builds a tuple value. typ gives the element types when they
+// are known from context; otherwise elements are assumed to be i32.
+func (l *Lowerer) lowerTupleExpr(tuple *ast.TupleExpr, typ *TupleType) string {
+	if typ == nil || len(typ.Elems) != len(tuple.Elems) {
+		typ = &TupleType{}
+		for range tuple.Elems {
+			typ.Elems = append(typ.Elems, &PrimitiveType{Name: "i32"})
+		}
+	}
+
+	elems := make([]string, len(tuple.Elems))
+	for i, elem := range tuple.Elems {
+		elems[i] = l.lowerExpr(elem)
+	}
+
+	result := l.newTemp()
+	l.emit(&MakeTuple{Dest: result, Elems: elems, Type: typ})
+
+	return result
+}
+
+// lowerArrayExpr stores the elements of an array literal into a slot of
+// its own, and loads the array from there
+func (l *Lowerer) lowerArrayExpr(arr *ast.ArrayExpr) string {
+	typ, ok := l.typeOf(arr).(*ArrayType)
+	if !ok {
+		l.errorf("array literal %s cannot be lowered", arr.String())
+		return "undef"
+	}
+
+	slot := l.declare("array.lit")
+	l.emit(&Alloca{Name: slot, Type: typ})
+
+	for i, elem := range arr.Elems {
+		val := l.lowerExpr(elem)
+		addr := l.newTemp()
+		l.emit(&ElemAddr{Dest: addr, Base: slot, Index: strconv.Itoa(i), Type: typ})
+		l.emit(&Store{Value: val, Dest: addr, Type: typ.Elem})
+	}
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: slot, Type: typ})
+
+	return result
+}
+
+// lowerLetTupleStmt binds each element of a tuple value to its own local
+func (l *Lowerer) lowerLetTupleStmt(let *ast.LetTupleStmt) {
+	val := l.lowerExpr(let.Value)
+
+	tuple, _ := l.typeOf(let.Value).(*TupleType)
+
+	for i, name := range let.Names {
+		var elemTy Type = &PrimitiveType{Name: "i32"}
+		if tuple != nil && i < len(tuple.Elems) {
+			elemTy = tuple.Elems[i]
+		}
+
+		elem := l.newTemp()
+		slot := l.declare(name)
+		l.emit(&Alloca{Name: slot, Type: elemTy})
+		l.emit(&Extract{Dest: elem, Tuple: val, Index: i, Type: elemTy})
+		l.emit(&Store{Value: elem, Dest: slot, Type: elemTy})
+	}
+}
+
+// packVariadic replaces the trailing arguments of a call to sig with a single
+// slice holding them
+func (l *Lowerer) packVariadic(sig *Function, args []string) []string {
+	fixed := len(sig.Params) - 1
+	if len(args) < fixed {
+		return args
+	}
+
+	slice := l.newTemp()
+	elem := sig.Params[fixed].Type.(*SliceType).Elem
+	l.emit(&PackSlice{Dest: slice, Elems: args[fixed:], Elem: elem})
+
+	return append(args[:fixed:fixed], slice)
+}
+
+// lowerConvExpr emits the cast for an implicit widening conversion, or
+// makes the trait object a reference is converted to
+func (l *Lowerer) lowerConvExpr(conv *ast.ConvExpr) string {
+	val := l.lowerExpr(conv.Expr)
+	from := l.lowerType(conv.From)
+
+	if trait, ok := dynTrait(conv.To); ok {
+		return l.lowerUnsize(val, from, trait)
+	}
+
+	to := l.lowerType(conv.To)
+
+	kind := SExt
+
+	switch from.String() {
+	case "f32":
+		kind = FPExt
+	case "u8", "u16", "u32", "u64", "usize":
+		kind = ZExt
+	}
+
+	result := l.newTemp()
+	l.emit(&Cast{Dest: result, Kind: kind, Value: val, From: from, To: to})
+
+	return result
+}
+
+// lowerCastExpr emits the conversion for x as T. Integers of the same
+// width share a representation, so casting between them emits nothing.
+func (l *Lowerer) lowerCastExpr(cast *ast.CastExpr) string {
+	val := l.lowerExpr(cast.Expr)
+	from := l.lowerType(cast.From)
+	to := l.lowerType(cast.Type)
+
+	kind, ok := castKind(from.String(), to.String())
+	if !ok {
+		return val
+	}
+
+	result := l.newTemp()
+	l.emit(&Cast{Dest: result, Kind: kind, Value: val, From: from, To: to})
+
+	return result
+}
+
+// castKind picks the conversion between two numeric types, bool or char.
+// It reports false when none is needed.
+func castKind(from, to string) (CastKind, bool) {
+	fromBits, fromFloat := numericBits(from)
+	toBits, toFloat := numericBits(to)
+	signed := from[0] == 'i'
+
+	switch {
+	case fromFloat && toFloat:
+		if toBits > fromBits {
+			return FPExt, true
+		}
+
+		if toBits < fromBits {
+			return FPTrunc, true
+		}
+	case fromFloat:
+		if to[0] == 'i' {
+			return FPToSI, true
+		}
+
+		return FPToUI, true
+	case toFloat:
+		if signed {
+			return SIToFP, true
+		}
+
+		return UIToFP, true
+	case toBits > fromBits:
+		if signed {
+			return SExt, true
+		}
+
+		return ZExt, true
+	case toBits < fromBits:
+		return Trunc, true
+	}
+
+	return 0, false
+}
+
+// numericBits gives the width of a numeric, bool or char type and whether
+// it is a float
+func numericBits(name string) (int, bool) {
+	switch name {
+	case "bool":
+		return 1, false
+	case "i8", "u8":
+		return 8, false
+	case "i16", "u16":
+		return 16, false
+	case "f32":
+		return 32, true
+	case "f64":
+		return 64, true
+	case "i64", "u64", "isize", "usize":
+		return 64, false
+	default:
+		return 32, false
+	}
 }
 
 // getFunctionReturnType looks up the return type of a function in the module
+// lowerMemzero clears the borrowed variable with a volatile store, so
+// the write survives even when the variable is never read again
+func (l *Lowerer) lowerMemzero(call *ast.CallExpr) {
+	if len(call.Args) != 1 {
+		return
+	}
+
+	ref, ok := call.Args[0].(*ast.UnaryExpr)
+	if !ok {
+		return
+	}
+
+	ident, ok := ref.Expr.(*ast.Ident)
+	if !ok {
+		return
+	}
+
+	l.emit(&Store{
+		Value:    "0",
+		Dest:     l.slot(ident.Name),
+		Type:     l.slotType(ident.Name),
+		Volatile: true,
+	})
+}
+
+// runtimeReturns gives the return types of the builtins implemented by
+// the C runtime
+var runtimeReturns = map[string]Type{
+	"ct_eq":               &PrimitiveType{Name: "bool"},
+	"regex_match":         &PrimitiveType{Name: "bool"},
+	"regex_find":          &PrimitiveType{Name: "i32"},
+	"regex_replace":       &StrType{},
+	"path_join":           &StrType{},
+	"path_clean":          &StrType{},
+	"path_ext":            &StrType{},
+	"path_base":           &StrType{},
+	"fs_exists":           &PrimitiveType{Name: "bool"},
+	"fs_is_dir":           &PrimitiveType{Name: "bool"},
+	"fs_remove_all":       &PrimitiveType{Name: "bool"},
+	"fs_size":             &PrimitiveType{Name: "i64"},
+	"fs_temp_file":        &StrType{},
+	"fs_temp_dir":         &StrType{},
+	"process_new":         &PrimitiveType{Name: "i32"},
+	"process_arg":         &PrimitiveType{Name: "void"},
+	"process_env":         &PrimitiveType{Name: "void"},
+	"process_dir":         &PrimitiveType{Name: "void"},
+	"process_stdin":       &PrimitiveType{Name: "void"},
+	"process_run":         &PrimitiveType{Name: "i32"},
+	"process_stdout":      &StrType{},
+	"process_stderr":      &StrType{},
+	"str_compare":         &PrimitiveType{Name: "i32"},
+	"str_slice":           &StrType{},
+	"str_index":           &PrimitiveType{Name: "i64"},
+	"str_from_int":        &StrType{},
+	"str_to_int":          &PrimitiveType{Name: "i64"},
+	"arg_count":           &PrimitiveType{Name: "i64"},
+	"arg_at":              &StrType{},
+	"runtime_version":     &StrType{},
+	"runtime_allocs":      &PrimitiveType{Name: "i64"},
+	"runtime_alloc_bytes": &PrimitiveType{Name: "i64"},
+	"runtime_stack_size":  &PrimitiveType{Name: "i64"},
+}
+
 func (l *Lowerer) getFunctionReturnType(name string) Type {
+	if ret, ok := runtimeReturns[name]; ok {
+		return ret
+	}
+
+	if sig, ok := l.signatures[name]; ok {
+		return sig.RetTy
+	}
+
 	for _, fn := range l.module.Functions {
 		if fn.Name == name {
 			return fn.RetTy
@@ -255,21 +959,189 @@ func (l *Lowerer) lowerType(astType ast.Type) Type {
 
 	switch t := astType.(type) {
 	case *ast.TypePath:
+		if len(t.Path) == 1 && t.Path[0] == "str" {
+			return &StrType{}
+		}
+
+		if len(t.Path) == 1 && t.Path[0] == "Self" && l.self != nil {
+			return l.self
+		}
+
 		if len(t.Path) == 1 {
+			if _, ok := l.structs[t.Path[0]]; ok {
+				return &StructType{Name: t.Path[0]}
+			}
+
+			if _, ok := l.enums[t.Path[0]]; ok {
+				return &EnumType{Name: t.Path[0]}
+			}
+
+			switch {
+			case t.Path[0] == "Result" && len(t.Args) == 2:
+				return l.resultType(l.lowerType(t.Args[0]), l.lowerType(t.Args[1]))
+			case t.Path[0] == "Option" && len(t.Args) == 1:
+				return l.optionType(l.lowerType(t.Args[0]))
+			case t.Path[0] == "Vec" && len(t.Args) == 1, t.Path[0] == "Map" && len(t.Args) == 2:
+				return handleType()
+			}
+
 			return &PrimitiveType{Name: t.Path[0]}
 		}
 
 		return &PrimitiveType{Name: "i32"} // Default
 	case *ast.VoidType:
 		return &PrimitiveType{Name: "void"}
+	case *ast.SliceType:
+		return &SliceType{Elem: l.lowerType(t.Elem)}
+	case *ast.ArrayType:
+		n, _ := consteval.Eval(t.Len, l.constant)
+		return &ArrayType{Elem: l.lowerType(t.Elem), Len: int(n)}
+	case *ast.TupleType:
+		tuple := &TupleType{}
+		for _, elem := range t.Elems {
+			tuple.Elems = append(tuple.Elems, l.lowerType(elem))
+		}
+
+		return tuple
 	case *ast.PtrType:
 		elem := l.lowerType(t.Elem)
 		return &PtrType{Elem: elem}
+	case *ast.RefType:
+		if _, ok := dynTrait(t); ok {
+			return dynRefType()
+		}
+
+		return &PtrType{Elem: l.lowerType(t.Elem)}
+	case *ast.FuncType:
+		fn := &FuncType{Ret: l.lowerType(t.Return)}
+		for _, p := range t.Params {
+			fn.Params = append(fn.Params, l.lowerType(p))
+		}
+
+		return fn
 	default:
 		return &PrimitiveType{Name: "i32"}
 	}
 }
 
+// slotType is the type a variable is loaded and stored as
+func (l *Lowerer) slotType(name string) Type {
+	if typ, ok := l.localType(name); ok {
+		return typ
+	}
+
+	return &PrimitiveType{Name: "i32"}
+}
+
+// lowerAddrOf takes the address of a place: a variable, a field of one,
+// or what a pointer points to. Any other value is stored in a slot of its
+// own, which the reference then points to.
+func (l *Lowerer) lowerAddrOf(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if _, ok := l.constant(e.Name); !ok {
+			result := l.newTemp()
+			l.emit(&AddrOf{Dest: result, Source: l.slot(e.Name), Type: l.slotType(e.Name)})
+
+			return result
+		}
+	case *ast.FieldExpr:
+		if addr, ok := l.fieldAddr(e); ok {
+			return addr
+		}
+	case *ast.IndexExpr:
+		if addr, _, ok := l.elemAddr(e); ok {
+			return addr
+		}
+	case *ast.UnaryExpr:
+		if e.Op == "*" {
+			return l.lowerExpr(e.Expr)
+		}
+	}
+
+	typ := l.typeOf(expr)
+	val := l.lowerExpr(expr)
+	slot := l.declare("ref.tmp")
+	l.emit(&Alloca{Name: slot, Type: typ})
+	l.emit(&Store{Value: val, Dest: slot, Type: typ})
+
+	result := l.newTemp()
+	l.emit(&AddrOf{Dest: result, Source: slot, Type: typ})
+
+	return result
+}
+
+// lowerUnaryExpr lowers negation as a subtraction from zero and logical
+// not as an exclusive or with true
+func (l *Lowerer) lowerUnaryExpr(unary *ast.UnaryExpr) string {
+	var op OpKind
+
+	switch unary.Op {
+	case "-":
+		op = Sub
+	case "!":
+		op = Xor
+	case "&", "&mut":
+		return l.lowerAddrOf(unary.Expr)
+	case "*":
+		ptr := l.lowerExpr(unary.Expr)
+		result := l.newTemp()
+		l.emit(&Load{Dest: result, Source: ptr, Type: l.typeOf(unary)})
+
+		return result
+	default:
+		return "undef"
+	}
+
+	val := l.lowerExpr(unary.Expr)
+	typ := l.typeOf(unary.Expr)
+	result := l.newTemp()
+
+	if op == Sub {
+		l.emit(&BinOp{Dest: result, Op: Sub, Left: "0", Right: val, Type: typ})
+	} else {
+		l.emit(&BinOp{Dest: result, Op: Xor, Left: val, Right: "1", Type: typ})
+	}
+
+	return result
+}
+
+// lowerLogicalExpr lowers && and || to branches, so the right operand is
+// computed only when the left one does not decide the result. The result
+// goes through a slot, which mem2reg turns into a phi.
+func (l *Lowerer) lowerLogicalExpr(bin *ast.BinaryExpr) string {
+	boolTy := &PrimitiveType{Name: "bool"}
+
+	left := l.lowerExpr(bin.Left)
+	slot := l.declare("logic.val")
+	l.emit(&Alloca{Name: slot, Type: boolTy})
+	l.emit(&Store{Value: left, Dest: slot, Type: boolTy})
+
+	rightBlock := l.newBB("logic_rhs")
+	doneBlock := l.newBB("logic_done")
+
+	if bin.Op == "&&" {
+		l.emit(&CondBr{Cond: left, TrueLabel: rightBlock.Label, FalseLabel: doneBlock.Label})
+	} else {
+		l.emit(&CondBr{Cond: left, TrueLabel: doneBlock.Label, FalseLabel: rightBlock.Label})
+	}
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, rightBlock)
+	l.currentBB = rightBlock
+
+	right := l.lowerExpr(bin.Right)
+	l.emit(&Store{Value: right, Dest: slot, Type: boolTy})
+	l.emit(&Br{Label: doneBlock.Label})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, doneBlock)
+	l.currentBB = doneBlock
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: slot, Type: boolTy})
+
+	return result
+}
+
 func (l *Lowerer) binOpKind(op string) OpKind {
 	switch op {
 	case "+":
@@ -361,6 +1233,29 @@ func (l *Lowerer) lowerIfStmt(stmt *ast.IfStmt) {
 	l.currentBB = mergeBlock
 }
 
+// lowerGuardStmt branches to the else block when the condition fails and
+// otherwise continues in a fresh block. The checker guarantees the else block
+// diverges; one that ends in a call to panic is closed with unreachable.
+func (l *Lowerer) lowerGuardStmt(stmt *ast.GuardStmt) {
+	cond := l.lowerExpr(stmt.Cond)
+
+	elseBlock := l.newBB("guard_else")
+	contBlock := l.newBB("guard_ok")
+
+	l.emit(&CondBr{Cond: cond, TrueLabel: contBlock.Label, FalseLabel: elseBlock.Label})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, elseBlock)
+	l.currentBB = elseBlock
+	l.lowerBlock(stmt.Else)
+
+	if len(l.currentBB.Instrs) == 0 || !isTerminator(l.currentBB.Instrs[len(l.currentBB.Instrs)-1]) {
+		l.emit(&Unreachable{})
+	}
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, contBlock)
+	l.currentBB = contBlock
+}
+
 func (l *Lowerer) lowerWhileStmt(stmt *ast.WhileStmt) {
 	// Create basic blocks
 	condBlock := l.newBB("cond")
@@ -407,21 +1302,24 @@ func (l *Lowerer) lowerForStmt(stmt *ast.ForStmt) {
 	// Range is represented as BinaryExpr with ".." operator
 	rangeExpr, ok := stmt.Iter.(*ast.BinaryExpr)
 	if !ok || rangeExpr.Op != ".." {
-		// For now, only support range expressions (start..end)
+		l.lowerForEach(stmt)
 		return
 	}
 
-	// Create iterator variable
-	iterVar := stmt.Val
-	l.emit(&Alloca{Name: iterVar, Type: &PrimitiveType{Name: "i32"}})
-
-	// Initialize iterator to start value
+	// The bounds are evaluated before the iterator variable comes into
+	// scope, and only once
 	start := l.lowerExpr(rangeExpr.Left)
-	l.emit(&Store{Value: start, Dest: iterVar, Type: &PrimitiveType{Name: "i32"}})
-
-	// Lower end value once (may be expression)
 	endVal := l.lowerExpr(rangeExpr.Right)
 
+	// Create iterator variable, scoped to the loop
+	l.pushScope()
+	defer l.popScope()
+
+	iterTy := l.operandType(rangeExpr)
+	iterVar := l.declare(stmt.Val)
+	l.emit(&Alloca{Name: iterVar, Type: iterTy})
+	l.emit(&Store{Value: start, Dest: iterVar, Type: iterTy})
+
 	// Create basic blocks
 	condBlock := l.newBB("cond")
 	bodyBlock := l.newBB("body")
@@ -434,10 +1332,10 @@ func (l *Lowerer) lowerForStmt(stmt *ast.ForStmt) {
 	// Lower condition in condition block: i < end
 	l.currentBB = condBlock
 	iterVal := l.newTemp()
-	l.emit(&Load{Dest: iterVal, Source: iterVar, Type: &PrimitiveType{Name: "i32"}})
+	l.emit(&Load{Dest: iterVal, Source: iterVar, Type: iterTy})
 
 	condResult := l.newTemp()
-	l.emit(&BinOp{Dest: condResult, Op: Lt, Left: iterVal, Right: endVal, Type: &PrimitiveType{Name: "i32"}})
+	l.emit(&BinOp{Dest: condResult, Op: Lt, Left: iterVal, Right: endVal, Type: iterTy})
 	l.emit(&CondBr{Cond: condResult, TrueLabel: bodyBlock.Label, FalseLabel: exitBlock.Label})
 
 	// Lower body with loop context
@@ -458,10 +1356,10 @@ func (l *Lowerer) lowerForStmt(stmt *ast.ForStmt) {
 
 	// Increment iterator: i = i + 1
 	iterVal2 := l.newTemp()
-	l.emit(&Load{Dest: iterVal2, Source: iterVar, Type: &PrimitiveType{Name: "i32"}})
+	l.emit(&Load{Dest: iterVal2, Source: iterVar, Type: iterTy})
 	incResult := l.newTemp()
-	l.emit(&BinOp{Dest: incResult, Op: Add, Left: iterVal2, Right: "1", Type: &PrimitiveType{Name: "i32"}})
-	l.emit(&Store{Value: incResult, Dest: iterVar, Type: &PrimitiveType{Name: "i32"}})
+	l.emit(&BinOp{Dest: incResult, Op: Add, Left: iterVal2, Right: "1", Type: iterTy})
+	l.emit(&Store{Value: incResult, Dest: iterVar, Type: iterTy})
 
 	// Jump back to condition block
 	l.emit(&Br{Label: condBlock.Label})
@@ -471,134 +1369,229 @@ func (l *Lowerer) lowerForStmt(stmt *ast.ForStmt) {
 	l.currentBB = exitBlock
 }
 
-// isTerminator checks if an instruction is a terminator (Ret, Br, CondBr)
-func isTerminator(instr Instruction) bool {
-	switch instr.(type) {
-	case *Ret, *Br, *CondBr:
-		return true
-	default:
-		return false
+// lowerForEach lowers a loop over the elements of an array or slice to a
+// loop over their indices. The index is the loop's key when it has one. A
+// loop over a Map has a lowering of its own.
+func (l *Lowerer) lowerForEach(stmt *ast.ForStmt) {
+	if key, value, ok := l.mapTypes(stmt.Iter); ok {
+		l.lowerForMap(stmt, key, value)
+		return
 	}
-}
 
-// lowerDeferStmt lowers a defer statement to DeferPush instruction
-func (l *Lowerer) lowerDeferStmt(stmt *ast.DeferStmt) {
-	// The deferred expression must be a call expression
-	callExpr, ok := stmt.Expr.(*ast.CallExpr)
-	if !ok {
-		// For now, only support defer with call expressions
+	seqTy := l.typeOf(stmt.Iter)
+
+	var elemTy Type
+
+	switch t := seqTy.(type) {
+	case *ArrayType:
+		elemTy = t.Elem
+	case *SliceType:
+		elemTy = t.Elem
+	default:
+		l.errorf("cannot iterate over %s", seqTy.String())
 		return
 	}
 
-	// Get function name from callee
-	var calleeName string
-	if ident, ok := callExpr.Callee.(*ast.Ident); ok {
-		calleeName = ident.Name
+	l.pushScope()
+	defer l.popScope()
+
+	// A sequence held in a variable is read in place; any other is
+	// evaluated once into a slot of its own
+	var seq string
+
+	if ident, ok := stmt.Iter.(*ast.Ident); ok {
+		seq = l.slot(ident.Name)
 	} else {
-		// Handle more complex callees later
-		return
+		val := l.lowerExpr(stmt.Iter)
+		seq = l.declare("for.seq")
+		l.emit(&Alloca{Name: seq, Type: seqTy})
+		l.emit(&Store{Value: val, Dest: seq, Type: seqTy})
 	}
 
-	// Lower each argument
-	args := make([]string, len(callExpr.Args))
-	for i, arg := range callExpr.Args {
-		args[i] = l.lowerExpr(arg)
+	usize := &PrimitiveType{Name: "usize"}
+
+	var length string
+
+	if array, ok := seqTy.(*ArrayType); ok {
+		length = strconv.Itoa(array.Len)
+	} else {
+		val := l.newTemp()
+		l.emit(&Load{Dest: val, Source: seq, Type: seqTy})
+		length = l.newTemp()
+		l.emit(&Extract{Dest: length, Tuple: val, Index: 1, Type: usize})
+	}
+
+	key := stmt.Key
+	if key == "" {
+		key = "for.idx"
 	}
 
-	// Determine return type
-	retTy := l.getFunctionReturnType(calleeName)
+	idx := l.declare(key)
+	l.emit(&Alloca{Name: idx, Type: usize})
+	l.emit(&Store{Value: "0", Dest: idx, Type: usize})
 
-	// Create the Call instruction (but don't emit it directly)
-	call := &Call{
-		Dest:   "", // Deferred calls are always void (result is discarded)
-		Callee: calleeName,
-		Args:   args,
-		RetTy:  retTy,
+	condBlock := l.newBB("cond")
+	bodyBlock := l.newBB("body")
+	stepBlock := l.newBB("step")
+	exitBlock := l.newBB("exit")
+
+	l.emit(&Br{Label: condBlock.Label})
+	l.currentFn.Blocks = append(l.currentFn.Blocks, condBlock)
+	l.currentBB = condBlock
+
+	i := l.newTemp()
+	l.emit(&Load{Dest: i, Source: idx, Type: usize})
+	cond := l.newTemp()
+	l.emit(&BinOp{Dest: cond, Op: Lt, Left: i, Right: length, Type: usize})
+	l.emit(&CondBr{Cond: cond, TrueLabel: bodyBlock.Label, FalseLabel: exitBlock.Label})
+
+	// The element is copied into the loop variable at the top of the body
+	l.currentFn.Blocks = append(l.currentFn.Blocks, bodyBlock)
+	l.currentBB = bodyBlock
+
+	i = l.newTemp()
+	l.emit(&Load{Dest: i, Source: idx, Type: usize})
+	addr := l.newTemp()
+	l.emit(&ElemAddr{Dest: addr, Base: seq, Index: i, Type: seqTy})
+	elem := l.newTemp()
+	l.emit(&Load{Dest: elem, Source: addr, Type: elemTy})
+
+	val := l.declare(stmt.Val)
+	l.emit(&Alloca{Name: val, Type: elemTy})
+	l.emit(&Store{Value: elem, Dest: val, Type: elemTy})
+
+	prevExitLabel := l.loopExitLabel
+	prevContinueLabel := l.loopContinueLabel
+	l.loopExitLabel = exitBlock.Label
+	l.loopContinueLabel = stepBlock.Label
+
+	l.lowerBlock(stmt.Body)
+
+	l.loopExitLabel = prevExitLabel
+	l.loopContinueLabel = prevContinueLabel
+
+	// continue jumps to the step, so the index always advances
+	if len(l.currentBB.Instrs) == 0 || !isTerminator(l.currentBB.Instrs[len(l.currentBB.Instrs)-1]) {
+		l.emit(&Br{Label: stepBlock.Label})
 	}
 
-	// Emit DeferPush with the call
-	l.emit(&DeferPush{Call: call})
+	l.currentFn.Blocks = append(l.currentFn.Blocks, stepBlock)
+	l.currentBB = stepBlock
+
+	i = l.newTemp()
+	l.emit(&Load{Dest: i, Source: idx, Type: usize})
+	next := l.newTemp()
+	l.emit(&BinOp{Dest: next, Op: Add, Left: i, Right: "1", Type: usize})
+	l.emit(&Store{Value: next, Dest: idx, Type: usize})
+	l.emit(&Br{Label: condBlock.Label})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, exitBlock)
+	l.currentBB = exitBlock
 }
 
-// lowerPropagateExpr lowers ? operator for Result<T,E> error propagation
-// Following v0.4 spec section 11:
-//   t = X
-//   if is_err(t) { return Err(extract_err(t)) }
-//   v = extract_ok(t)
-func (l *Lowerer) lowerPropagateExpr(expr *ast.PropagateExpr) string {
-	// Lower the inner expression to get the Result<T,E> value
-	// This evaluates X and gets the result in current block
-	resultVal := l.lowerExpr(expr.Expr)
-
-	// Create basic blocks for control flow
-	checkBlock := l.newBB("check")
-	errorBlock := l.newBB("error")
-	okBlock := l.newBB("ok")
-
-	// In the current block, jump to check block
-	l.emit(&Br{Label: checkBlock.Label})
-
-	// Add check block to function
-	l.currentFn.Blocks = append(l.currentFn.Blocks, checkBlock)
-	l.currentBB = checkBlock
-
-	// TODO: For full implementation, need runtime support for:
-	// - is_err() function to check if Result is Err variant
-	// - extract_err() to get error value
-	// - extract_ok() to get ok value
-	// - Proper Result<T,E> enum type representation
-	//
-	// For v0.4, we generate the control flow structure with stub condition
-	// The actual runtime functions will be implemented when enum support is added
-
-	// Generate is_err check (stub: always false for now)
-	// In full implementation: %is_err = call i1 @is_err(%result_type %t)
-	isErrTemp := l.newTemp()
-	// For now, create a stub boolean check that always evaluates to false
-	// This shows the structure without breaking codegen
-	l.emit(&BinOp{
-		Dest:  isErrTemp,
-		Op:    Eq,
-		Left:  resultVal,
-		Right: resultVal,
-		Type:  &PrimitiveType{Name: "i1"},
-	})
+// elemAddr emits the address of an element of an array or slice, directly
+// or through a reference. A sequence that is not held in memory is stored
+// to a slot of its own first.
+func (l *Lowerer) elemAddr(index *ast.IndexExpr) (string, Type, bool) {
+	seqTy := l.typeOf(index.Expr)
 
-	// Conditional branch based on error check
-	l.emit(&CondBr{
-		Cond:       isErrTemp,
-		TrueLabel:  errorBlock.Label,
-		FalseLabel: okBlock.Label,
-	})
+	var elemTy Type
 
-	// Error block: extract error and return early
-	l.currentFn.Blocks = append(l.currentFn.Blocks, errorBlock)
-	l.currentBB = errorBlock
-
-	// TODO: Full implementation would be:
-	// %err = call %error_type @extract_err(%result_type %t)
-	// %result = call %result_type @make_err(%error_type %err)
-	// ret %result_type %result
-
-	// For now, emit stub early return
-	// Insert DeferRunAll before return
-	l.emit(&DeferRunAll{})
-	l.emit(&Ret{
-		Value: resultVal,
-		Type:  &PrimitiveType{Name: "i32"},
-	})
+	seq := seqTy
+	if ptr, ok := seq.(*PtrType); ok {
+		seq = ptr.Elem
+	}
+
+	switch t := seq.(type) {
+	case *ArrayType:
+		elemTy = t.Elem
+	case *SliceType:
+		elemTy = t.Elem
+	default:
+		return "", nil, false
+	}
+
+	base, ok := "", false
+
+	switch e := index.Expr.(type) {
+	case *ast.Ident:
+		if _, isConst := l.constant(e.Name); !isConst {
+			base, ok = l.slot(e.Name), true
+		}
+	case *ast.FieldExpr:
+		base, ok = l.fieldAddr(e)
+	case *ast.IndexExpr:
+		base, _, ok = l.elemAddr(e)
+	case *ast.UnaryExpr:
+		if e.Op == "*" {
+			base, ok = l.lowerExpr(e.Expr), true
+		}
+	}
+
+	if !ok {
+		val := l.lowerExpr(index.Expr)
+		base = l.declare("index.seq")
+		l.emit(&Alloca{Name: base, Type: seqTy})
+		l.emit(&Store{Value: val, Dest: base, Type: seqTy})
+	}
+
+	if ptr, ok := seqTy.(*PtrType); ok {
+		loaded := l.newTemp()
+		l.emit(&Load{Dest: loaded, Source: base, Type: ptr})
+		base = loaded
+	}
+
+	idx := l.lowerExpr(index.Index)
+	result := l.newTemp()
+	l.emit(&ElemAddr{Dest: result, Base: base, Index: idx, Type: seq})
+
+	return result, elemTy, true
+}
+
+// lowerIndexExpr loads an element of an array or slice
+func (l *Lowerer) lowerIndexExpr(index *ast.IndexExpr) string {
+	addr, elemTy, ok := l.elemAddr(index)
+	if !ok {
+		return "undef"
+	}
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: addr, Type: elemTy})
+
+	return result
+}
+
+// isTerminator checks if an instruction is a terminator (Ret, Br, CondBr)
+func isTerminator(instr Instruction) bool {
+	switch instr.(type) {
+	case *Ret, *Br, *CondBr, *Unreachable:
+		return true
+	default:
+		return false
+	}
+}
 
-	// Ok block: extract ok value and continue
-	l.currentFn.Blocks = append(l.currentFn.Blocks, okBlock)
-	l.currentBB = okBlock
+// lowerDeferStmt lowers a defer statement to DeferPush instruction. The
+// arguments are evaluated into temporaries where the defer appears, so the
+// deferred call sees their values at that point, not at function exit.
+func (l *Lowerer) lowerDeferStmt(stmt *ast.DeferStmt) {
+	// The checker only lets a call be deferred
+	callExpr, ok := stmt.Expr.(*ast.CallExpr)
+	if !ok {
+		return
+	}
 
-	// TODO: Full implementation would be:
-	// %ok_val = call %T @extract_ok(%result_type %t)
-	// return %ok_val
+	if _, ok := l.funcValue(callExpr.Callee); ok {
+		l.errorf("calls through function values cannot be deferred")
+		return
+	}
 
-	// For now, just return the result value as-is
-	// This represents the extracted ok value
-	okVal := resultVal
+	call, ok := l.buildCall(callExpr)
+	if !ok {
+		// Handle more complex callees later
+		return
+	}
 
-	return okVal
+	// Deferred calls are always void (result is discarded)
+	l.emit(&DeferPush{Call: call})
 }
@@ -1,6 +1,7 @@
 package mir
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/yarlson/yarlang/checker"
@@ -78,8 +79,8 @@ func TestLowerStringLiteral(t *testing.T) {
 		t.Fatalf("expected GlobalString, got %T", mod.Globals[0])
 	}
 
-	if globalStr.Name != ".str.1" {
-		t.Errorf("expected global name .str.1, got %s", globalStr.Name)
+	if globalStr.Name != ".str.main.1" {
+		t.Errorf("expected global name .str.main.1, got %s", globalStr.Name)
 	}
 
 	if globalStr.Value != "hello" {
@@ -119,7 +120,1461 @@ func TestLowerStringLiteral(t *testing.T) {
 		t.Fatalf("expected 1 argument, got %d", len(callInstr.Args))
 	}
 
-	if callInstr.Args[0] != "@.str.1" {
-		t.Errorf("expected argument '@.str.1', got %s", callInstr.Args[0])
+	if callInstr.Args[0] != "@.str.main.1" {
+		t.Errorf("expected argument '@.str.main.1', got %s", callInstr.Args[0])
+	}
+}
+
+func TestLowerSharesStringLiterals(t *testing.T) {
+	input := `fn greet() {
+		println("hi")
+		println("bye")
+	}
+
+	fn main() {
+		println("hi")
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	if len(mod.Globals) != 2 {
+		t.Fatalf("expected 2 globals, got %d:\n%s", len(mod.Globals), mod.String())
+	}
+
+	if out := mod.Functions[1].String(); !strings.Contains(out, "@println(%@.str.greet.1)") {
+		t.Errorf("expected main to use the literal of greet:\n%s", out)
+	}
+}
+
+func TestLowerNamingIsPerFunction(t *testing.T) {
+	lowerLast := func(input string) string {
+		l := lexer.New(input)
+		p := parser.New(l)
+		file := p.ParseFile()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		mod := NewLowerer().LowerFile(file)
+
+		return mod.Functions[len(mod.Functions)-1].String()
+	}
+
+	target := `fn abs(x i32) i32 {
+		if x < 0 {
+			return 0 - x
+		}
+		return x
+	}`
+
+	alone := lowerLast(target)
+	withOther := lowerLast(`fn other(a i32) i32 {
+		while a > 0 {
+			a = a - 1
+		}
+		return a + 1
+	}
+	` + target)
+
+	if alone != withOther {
+		t.Errorf("adding a function renamed temps or blocks in another:\n%s\nvs\n%s", alone, withOther)
+	}
+
+	if !strings.Contains(alone, "entry_1:") || !strings.Contains(alone, "%t1 =") {
+		t.Errorf("expected numbering to start at 1 in every function:\n%s", alone)
+	}
+}
+
+func TestLowerImplicitWidening(t *testing.T) {
+	input := `fn wide(a i64) {}
+	fn main() {
+		wide(5)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if err := checker.NewChecker().CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	mod := NewLowerer().LowerFile(file)
+	out := mod.Function("main").String()
+
+	if !strings.Contains(out, "%t1 = sext i32 %5 to i64") || !strings.Contains(out, "call void @wide(%t1)") {
+		t.Errorf("expected argument to be sign-extended before the call:\n%s", out)
+	}
+}
+
+func TestLowerTupleReturn(t *testing.T) {
+	input := `fn main() {
+		let (q, r) = divmod(7, 2)
+	}
+	fn divmod(a i32, b i32) (i32, i32) {
+		return (a / b, a % b)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	divmod := mod.Function("divmod").String()
+	for _, want := range []string{"define {i32, i32} @divmod", "= tuple {i32, i32} (%t3, %t6)", "ret {i32, i32} %t7"} {
+		if !strings.Contains(divmod, want) {
+			t.Errorf("expected %q in:\n%s", want, divmod)
+		}
+	}
+
+	// The call precedes the definition, so its type comes from the signature
+	main := mod.Function("main").String()
+	for _, want := range []string{"%t1 = call {i32, i32} @divmod(7, 2)", "%t2 = extract i32 %t1, 0", "%t3 = extract i32 %t1, 1"} {
+		if !strings.Contains(main, want) {
+			t.Errorf("expected %q in:\n%s", want, main)
+		}
+	}
+}
+
+func TestLowerVariadicCall(t *testing.T) {
+	input := `fn main() {
+		let a = sum(1, 2, 3)
+		let b = sum(4)
+	}
+	fn sum(base i32, nums ...i32) i32 {
+		return base
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	if sum := mod.Function("sum").String(); !strings.Contains(sum, "define i32 @sum(i32 %base, []i32 %nums)") {
+		t.Errorf("expected variadic parameter to be a slice:\n%s", sum)
+	}
+
+	main := mod.Function("main").String()
+	for _, want := range []string{
+		"%t1 = slice i32 [%2, %3]",
+		"%t2 = call i32 @sum(1, %t1)",
+		"%t3 = slice i32 []",
+		"%t4 = call i32 @sum(4, %t3)",
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("expected %q in:\n%s", want, main)
+		}
+	}
+}
+
+func TestLowerClosesDeadMergeBlock(t *testing.T) {
+	input := `fn sign(n i32) i32 {
+		if n < 0 {
+			return 0 - 1
+		} else {
+			return 1
+		}
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	fn := NewLowerer().LowerFile(file).Function("sign")
+
+	for _, bb := range fn.Blocks {
+		if len(bb.Instrs) == 0 || !isTerminator(bb.Instrs[len(bb.Instrs)-1]) {
+			t.Errorf("block %s has no terminator:\n%s", bb.Label, fn.String())
+		}
+	}
+
+	last := fn.Blocks[len(fn.Blocks)-1]
+	if _, ok := last.Instrs[len(last.Instrs)-1].(*Unreachable); !ok {
+		t.Errorf("expected merge block to end in unreachable:\n%s", fn.String())
+	}
+}
+
+func TestLowerNestedFunction(t *testing.T) {
+	input := `fn main() {
+		let base = 10
+		fn add(x i32) i32 {
+			return base + x
+		}
+		fn twice(x i32) i32 {
+			return add(add(x))
+		}
+		let y = twice(1)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	add := mod.Function("main.add")
+	if add == nil {
+		t.Fatalf("expected lifted function main.add in:\n%s", mod.String())
+	}
+
+	if !strings.Contains(add.String(), "define i32 @main.add(i32 %x, i32 %base)") {
+		t.Errorf("expected captured variable as trailing parameter:\n%s", add.String())
+	}
+
+	// twice captures base so that it can pass it on to add
+	twice := mod.Function("main.twice").String()
+	for _, want := range []string{"define i32 @main.twice(i32 %x, i32 %base)", "call i32 @main.add("} {
+		if !strings.Contains(twice, want) {
+			t.Errorf("expected %q in:\n%s", want, twice)
+		}
+	}
+
+	main := mod.Function("main").String()
+	if !strings.Contains(main, "= call i32 @main.twice(1, %t1)") {
+		t.Errorf("expected call passing the captured variable:\n%s", main)
+	}
+}
+
+func TestLowerShadowedVariablesGetOwnSlots(t *testing.T) {
+	input := `fn main() {
+		for i in 0..3 {
+			println(i)
+		}
+		for i in 0..4 {
+			println(i)
+		}
+		let x = 1
+		{
+			let x = x + 10
+			println(x)
+		}
+		println(x)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	main := NewLowerer().LowerFile(file).Function("main")
+
+	allocas := map[string]int{}
+
+	for _, bb := range main.Blocks {
+		for _, instr := range bb.Instrs {
+			if a, ok := instr.(*Alloca); ok {
+				allocas[a.Name]++
+			}
+		}
+	}
+
+	for _, slot := range []string{"i", "i.1", "x", "x.1"} {
+		if allocas[slot] != 1 {
+			t.Errorf("expected one slot %s, got %d:\n%s", slot, allocas[slot], main.String())
+		}
+	}
+
+	// The inner x is initialized from the outer one, and the outer one is
+	// what is printed after the block
+	out := main.String()
+	for _, want := range []string{"%t11 = load i32, i32* %x\n", "store i32 %t12, i32* %x.1", "%t14 = load i32, i32* %x\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerFoldsConstants(t *testing.T) {
+	input := `const KB: i32 = 1024
+
+	fn main() {
+		const HALF: i32 = KB / 2
+		let x = HALF + 1
+		fn show() {
+			println(HALF)
+		}
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	main := mod.Function("main").String()
+	if !strings.Contains(main, "add i32 %512, %1") {
+		t.Errorf("expected HALF to be folded to 512:\n%s", main)
+	}
+
+	show := mod.Function("main.show")
+	if len(show.Params) != 0 || !strings.Contains(show.String(), "call void @println(512)") {
+		t.Errorf("expected nested function to see the constant, not capture it:\n%s", show.String())
+	}
+}
+
+func TestLowerGlobalConstants(t *testing.T) {
+	input := `const NAME: str = "yar"
+	const RATE: f64 = 1.5
+	const DOUBLE: f64 = RATE * 2.0
+
+	fn main() {
+		println(NAME)
+		let RATE = 3.0
+		println(DOUBLE + RATE)
+	}`
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	globals := mod.String()
+	for _, want := range []string{
+		"@.str.1 = \"yar\"",
+		"@const.NAME = const str @.str.1",
+		"@const.RATE = const f64 1.5",
+	} {
+		if !strings.Contains(globals, want) {
+			t.Errorf("expected %q in module:\n%s", want, globals)
+		}
+	}
+
+	// DOUBLE is lowered where it is used, reading the constant RATE rather
+	// than the local that shadows it
+	main := mod.Function("main").String()
+	for _, want := range []string{
+		"load str, str* %@const.NAME",
+		"load f64, f64* %@const.RATE",
+		"load f64, f64* %RATE",
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("expected %q in main:\n%s", want, main)
+		}
+	}
+}
+
+func TestLowerGuardStmt(t *testing.T) {
+	input := `fn f(b i32) i32 {
+		guard b != 0 else {
+			panic("zero")
+		}
+		return 10 / b
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	out := NewLowerer().LowerFile(file).Function("f").String()
+
+	for _, want := range []string{"br i1 %t2, label %bb_f.guard_ok_3, label %bb_f.guard_else_2", "@panic(%@.str.f.1)\n  unreachable", "bb_f.guard_ok_3:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerMemzeroExplicit(t *testing.T) {
+	input := `fn f() {
+		let mut key: i32 = 42
+		memzero_explicit(&mut key)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	out := NewLowerer().LowerFile(file).Function("f").String()
+
+	if want := "store volatile i32 %0, i32* %key"; !strings.Contains(out, want) {
+		t.Errorf("expected %q in:\n%s", want, out)
+	}
+}
+
+func TestLowerStrLocals(t *testing.T) {
+	input := `fn greet(name str) {
+		let s = "hi"
+		let t: str = name
+		println(t)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	out := NewLowerer().LowerFile(file).Function("greet").String()
+
+	for _, want := range []string{
+		"define void @greet(str %name)",
+		"%s = alloca str",
+		"store str %@.str.greet.1, str* %s",
+		"%t1 = load str, str* %name",
+		"%t2 = load str, str* %t",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerStrConcat(t *testing.T) {
+	input := `fn greet(name str) str {
+		let mut s = "hi, " + name
+		s += "!"
+		return s
+	}`
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("greet").String()
+
+	for _, want := range []string{
+		"%t2 = call str @str_concat(%@.str.greet.1, %t1)",
+		"%t4 = call str @str_concat(%t3, %@.str.greet.2)",
+		"store str %t4, str* %s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerShortCircuit(t *testing.T) {
+	input := `fn f(a i32, b bool) bool {
+		return a > 1 && b
+	}
+
+	fn g(a i32, b bool) bool {
+		return a > 1 || b
+	}`
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mod := NewLowerer().LowerFile(file)
+
+	// The right operand is loaded only in the block the left one leads to
+	// when it does not decide the result
+	for fn, branch := range map[string]string{
+		"f": "br i1 %t2, label %bb_f.logic_rhs_2, label %bb_f.logic_done_3",
+		"g": "br i1 %t2, label %bb_g.logic_done_3, label %bb_g.logic_rhs_2",
+	} {
+		out := mod.Function(fn).String()
+
+		for _, want := range []string{
+			"%t2 = gt i32 %t1, %1",
+			"store bool %t2, bool* %logic.val",
+			branch,
+			"bb_" + fn + ".logic_rhs_2:\n  %t3 = load bool, bool* %b\n  store bool %t3, bool* %logic.val",
+			"bb_" + fn + ".logic_done_3:\n  %t4 = load bool, bool* %logic.val",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in:\n%s", want, out)
+			}
+		}
+	}
+}
+
+func TestLowerCastExpr(t *testing.T) {
+	input := `fn f(n i32, x f64) {
+		let a = n as u8
+		let b = n as i64
+		let c = n as u32
+		let d = x as i32
+		let e = true as i32
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if err := checker.NewChecker().CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	out := NewLowerer().LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"trunc i32 %t1 to u8",
+		"sext i32 %t3 to i64",
+		"store u32 %t5, u32* %c",
+		"fptosi f64 %t6 to i32",
+		"zext bool %1 to i32",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerCheckerTypes(t *testing.T) {
+	input := `fn f(x i64, ok bool, r f64) bool {
+		let big: i64 = 3000000000 * 2
+		let y = x + 1
+		let n = len("abc")
+		let h = r / 2.0
+		let flip = !ok
+		return y > big
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%t1 = mul i64 %3000000000, %2",
+		"%t2 = load i64, i64* %x",
+		"%y = alloca i64",
+		"call usize @len(",
+		"%n = alloca usize",
+		"%t7 = div f64 %t6, %2.0",
+		"%t8 = load bool, bool* %ok",
+		"%t9 = xor bool %t8, %1",
+		"gt i64",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerRuntimeCallLiterals(t *testing.T) {
+	input := `fn f() {
+		println(true)
+		println(7)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%t1 = add bool %1, %0\n  call void @println(%t1)",
+		"call void @println(7)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerStrPayload(t *testing.T) {
+	input := `fn g() Result<i32, str> {
+		return Ok(1)
+	}
+
+	fn h(s str) Option<str> {
+		return Some(s)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).String()
+
+	for _, want := range []string{
+		"%t1 = call %enum.Result.i32.str @Result.i32.str.Ok(1)",
+		"ret %enum.Result.i32.str %t1",
+		"%t2 = call %enum.Option.str @Option.str.Some(%t1)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerArrayLiteral(t *testing.T) {
+	input := `fn f() i64 {
+		let a: [i64; 2] = [4, 5]
+		return a[1]
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%array.lit = alloca [2 x i64]",
+		"%t2 = elemaddr [2 x i64]* %array.lit, %0\n  store i64 %t1, i64* %t2",
+		"%t4 = elemaddr [2 x i64]* %array.lit, %1\n  store i64 %t3, i64* %t4",
+		"%t5 = load [2 x i64], [2 x i64]* %array.lit\n  %a = alloca [2 x i64]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerStruct(t *testing.T) {
+	input := `struct Point { x: i32, y: i64 }
+	struct Line { a: Point, b: Point }
+
+	fn f(p Point) i64 {
+		let mut l = Line { a: p, b: Point { y: 2 } }
+		l.b.x = 7
+		return l.a.y + make().y
+	}
+
+	fn make() Point {
+		return Point { x: 1, y: 2 }
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	for _, want := range []string{
+		"%struct.Point = type { i32, i64 }",
+		"%struct.Line = type { %struct.Point, %struct.Point }",
+		"define i64 @f(%struct.Point %p)",
+		"%t2 = insert %struct.Line zeroinitializer, %struct.Point %t1, 0",
+		"%t3 = insert %struct.Point zeroinitializer, i64 %2, 1",
+		"%t5 = fieldaddr %struct.Line* %l, 1",
+		"%t6 = fieldaddr %struct.Point* %t5, 0",
+		"store i32 %7, i32* %t6",
+		"%t9 = load i64, i64* %t8",
+		"%t11 = extract i64 %t10, 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerEnum(t *testing.T) {
+	input := `enum Shape { Circle(f64), Rect(i32, i64), Empty }
+	enum Color { Red = 3, Green, Blue }
+
+	fn f(c Color) bool {
+		let s = Shape::Rect(2, 5)
+		let e = Shape::Empty
+		return c == Color::Green
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+
+	tests := []struct {
+		fn   string
+		want []string
+	}{
+		{"Shape.Rect", []string{
+			"define %enum.Shape @Shape.Rect(i32 %v0, i64 %v1)",
+			"%t1 = tagaddr %enum.Shape* %e",
+			"store i32 %1, i32* %t1",
+			"%t5 = payloadaddr %enum.Shape* %e, 1, 1",
+			"store i64 %t4, i64* %t5",
+		}},
+		{"Color.Green", []string{"store i32 %4, i32* %t1"}},
+		{"f", []string{
+			"%t2 = call %enum.Shape @Shape.Rect(2, %t1)",
+			"%t3 = call %enum.Shape @Shape.Empty()",
+			"%t5 = tag %enum.Color %t4",
+			"%t6 = call %enum.Color @Color.Green()",
+			"%t7 = tag %enum.Color %t6",
+			"%t8 = eq i32 %t5, %t7",
+		}},
+	}
+
+	for _, tt := range tests {
+		fn := mod.Function(tt.fn)
+		if fn == nil {
+			t.Fatalf("no function %s in:\n%s", tt.fn, mod.String())
+		}
+
+		for _, want := range tt.want {
+			if !strings.Contains(fn.String(), want) {
+				t.Errorf("expected %q in:\n%s", want, fn.String())
+			}
+		}
+	}
+
+	if !strings.Contains(mod.String(), "%enum.Color = enum { Red = 3 (), Green = 4 (), Blue = 5 () }") {
+		t.Errorf("expected Color layout in:\n%s", mod.String())
+	}
+}
+
+func TestLowerReferences(t *testing.T) {
+	input := `struct Point { x: i32, y: i32 }
+
+	fn bump(n &mut i32) {
+		*n = *n + 1
+	}
+
+	fn f(p &mut Point) i32 {
+		let mut a = 1
+		bump(&mut a)
+		bump(&mut p.x)
+		let r = &5
+		return *r + p.y
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+
+	tests := []struct {
+		fn   string
+		want []string
+	}{
+		{"bump", []string{
+			"define void @bump(*i32 %n)",
+			"%t1 = load *i32, *i32* %n",
+			"%t2 = load i32, i32* %t1",
+			"%t4 = load *i32, *i32* %n",
+			"store i32 %t3, i32* %t4",
+		}},
+		{"f", []string{
+			"%t1 = addrof i32* %a",
+			"call void @bump(%t1)",
+			"%t3 = load *%struct.Point, *%struct.Point* %p",
+			"%t4 = fieldaddr %struct.Point* %t3, 0",
+			"call void @bump(%t4)",
+			"%ref.tmp = alloca i32",
+			"store i32 %5, i32* %ref.tmp",
+			"%t6 = addrof i32* %ref.tmp",
+			"%t8 = load i32, i32* %t7",
+		}},
+	}
+
+	for _, tt := range tests {
+		out := mod.Function(tt.fn).String()
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in:\n%s", want, out)
+			}
+		}
+	}
+}
+
+func TestLowerTupleIndex(t *testing.T) {
+	input := `fn pair() (i32, i64) {
+		return (1, 5000000000)
+	}
+
+	fn f() i64 {
+		let mut t = pair()
+		t.0 = 9
+		let (a, b) = pair()
+		return t.1 + pair().1 + b
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%t2 = fieldaddr {i32, i64}* %t, 0",
+		"store i32 %9, i32* %t2",
+		"%t5 = extract i64 %t3, 1",
+		"%t6 = fieldaddr {i32, i64}* %t, 1",
+		"%t9 = extract i64 %t8, 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerCompoundAssign(t *testing.T) {
+	input := `struct P { x: i32, y: i64 }
+
+	fn f(n &mut i32) i64 {
+		k := 3
+		let mut y: i64 = 2
+		y <<= 4
+		let mut p = P { x: 1, y: 2 }
+		p.x *= k
+		*n -= 1
+		return y
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%k = alloca i32",
+		"store i32 %3, i32* %k",
+		"%t3 = load i64, i64* %y",
+		"%t4 = shl i64 %t3, %t2",
+		"store i64 %t4, i64* %y",
+		"%t8 = fieldaddr %struct.P* %p, 0",
+		"%t9 = load i32, i32* %t8",
+		"%t10 = mul i32 %t9, %t7",
+		"store i32 %t10, i32* %t8",
+		"%t11 = load *i32, *i32* %n",
+		"%t12 = load i32, i32* %t11",
+		"%t13 = sub i32 %t12, %1",
+		"store i32 %t13, i32* %t11",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerIndexAssign(t *testing.T) {
+	input := `fn f(s &mut []i32, xs ...i32) i32 {
+		xs[0] = 7
+		xs[1] += 2
+		(*s)[2] = xs[0]
+		return xs[1]
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).Function("f").String()
+
+	for _, want := range []string{
+		"%t1 = elemaddr []i32* %xs, %0",
+		"store i32 %7, i32* %t1",
+		"%t3 = load i32, i32* %t2",
+		"store i32 %t4, i32* %t2",
+		"%t7 = load *[]i32, *[]i32* %s",
+		"%t8 = elemaddr []i32* %t7, %2",
+		"store i32 %t6, i32* %t8",
+		"%t10 = load i32, i32* %t9",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerMethods(t *testing.T) {
+	input := `struct Counter { n: i32 }
+
+	impl Counter {
+		fn get(&self) i32 {
+			return self.n
+		}
+
+		fn bump(&mut self, by i32) {
+			self.n = self.n + by
+		}
+	}
+
+	fn run(r &Counter) i32 {
+		let mut c = Counter { n: 1 }
+		c.bump(2)
+		return c.get() + r.get()
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"define i32 @Counter.get(*%struct.Counter %self)",
+		"define void @Counter.bump(*%struct.Counter %self, i32 %by)",
+		"%t2 = addrof %struct.Counter* %c",
+		"@Counter.bump(%t2, 2)",
+		"%t5 = call i32 @Counter.get(%t4)",
+		"%t6 = load *%struct.Counter, *%struct.Counter* %r",
+		"%t7 = call i32 @Counter.get(%t6)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerFunctionValues(t *testing.T) {
+	input := `struct Op {
+		apply: fn(i32) i32,
+	}
+
+	fn neg(x i32) i32 { return 0 - x }
+
+	fn pick() fn(i32) i32 {
+		return neg
+	}
+
+	fn run(f fn(i32) i32, op Op) i32 {
+		return f(1) + op.apply(2)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"%struct.Op = type { fn(i32) i32 }",
+		"define fn(i32) i32 @pick()",
+		"%t1 = funcref fn(i32) i32 @neg",
+		"define i32 @run(fn(i32) i32 %f, %struct.Op %op)",
+		"%t1 = load fn(i32) i32, fn(i32) i32* %f\n  %t2 = icall fn(i32) i32 %t1(1)",
+		"%t4 = load fn(i32) i32, fn(i32) i32* %t3\n  %t5 = icall fn(i32) i32 %t4(2)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerVec(t *testing.T) {
+	input := `fn last(v &mut Vec<i64>) i64 {
+		return v.pop()
+	}
+
+	fn main() {
+		let mut v: Vec<i64> = Vec::new()
+		v.push(7)
+		v.set(0, 8)
+		let n = v.len() + v.get(0)
+		let m = last(&mut v)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"define i64 @last(**i8 %v)",
+		"%t2 = load *i8, *i8* %t1\n",
+		"call void @yar_vec_pop(%t2, %t4)",
+		"%t1 = sizeof i64\n",
+		"%t2 = call *i8 @yar_vec_new(%t1)",
+		"call void @yar_vec_push(",
+		"call void @yar_vec_set(",
+		"call i64 @yar_vec_len(",
+		"call void @yar_vec_get(",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerVecUnknownElem(t *testing.T) {
+	input := `fn main() {
+		let v = Vec::new()
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	// The checker rejects this; lowering it anyway must not call a
+	// function named new
+	c := checker.NewChecker()
+	_ = c.CheckFile(file)
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).String()
+
+	if len(lowerer.Errors()) == 0 {
+		t.Errorf("expected a lowering error for Vec::new() of an unknown element type")
+	}
+
+	if strings.Contains(out, "@new(") {
+		t.Errorf("unexpected call to new in:\n%s", out)
+	}
+}
+
+func TestLowerMap(t *testing.T) {
+	input := `fn find(m &Map<str, i64>, k str) Option<i64> {
+		return m.get(k)
+	}
+
+	fn sum(m Map<str, i64>) i64 {
+		let mut total: i64 = 0
+		for k, v in m {
+			total = total + v
+		}
+		return total
+	}
+
+	fn main() {
+		let mut m: Map<str, i64> = Map::new()
+		m.insert("a", 1)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"%option.value = alloca %enum.Option.i64",
+		"payloadaddr %enum.Option.i64* %option.value, 1, 0",
+		"%t7 = call bool @yar_map_get(%t2, %t4, %t6)",
+		"%t8 = zext bool %t7 to i32",
+		"= call i64 @yar_map_next(",
+		"%t1 = sizeof str\n  %t2 = sizeof i64\n  %t3 = call *i8 @yar_map_new(%t1, %t2, 1)",
+		"call void @yar_map_insert(",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerPrintlnSmallInts(t *testing.T) {
+	input := `fn main() {
+		let x: u8 = 250
+		println(x + 1)
+		let y: i16 = -7
+		println(y)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	out := lowerer.LowerFile(file).String()
+
+	for _, want := range []string{
+		"%t2 = add u8 %t1, %1",
+		"zext u8 %t2 to i32",
+		"sext i16 %t5 to i32",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerFormat(t *testing.T) {
+	input := `fn main() {
+		let x: i32 = 1
+		let s = "b"
+		println("x = {}, s = {}!", x, s)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		`@.str.main.2 = "x = "`,
+		`@.str.main.4 = "!"`,
+		"call void @yar_print_str(%@.str.main.2)",
+		"%t2 = sext i32 %t1 to i64\n  call void @yar_print_i64(%t2)",
+		"call void @yar_print_str(%@.str.main.3)",
+		"call void @yar_print_str(%t3)",
+		"call void @println(%@.str.main.4)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerOS(t *testing.T) {
+	input := `use std::os
+
+	fn home() Option<str> {
+		let h = std::os::env("HOME")?
+		return Some(h)
+	}
+
+	fn main() {
+		let args = os::args()
+		println(len(args))
+		let _ = home()
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"= call []str @os_args()",
+		"%option.value = alloca %enum.Option.str",
+		"= call bool @os_env(%@.str.home.1, ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLowerTraitObjects(t *testing.T) {
+	input := `trait Shape {
+		fn area(&self) i32;
+		fn scale(&mut self, k i64);
+	}
+
+	struct Square { side: i32 }
+
+	impl Shape for Square {
+		fn area(&self) i32 { return self.side * self.side }
+		fn scale(&mut self, k i64) {}
+	}
+
+	fn total(a &dyn Shape) i32 {
+		return a.area()
+	}
+
+	fn grow(a &mut dyn Shape) {
+		a.scale(3)
+	}
+
+	fn run(s &Square) i32 {
+		return total(s)
+	}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+	out := mod.String()
+
+	if errs := lowerer.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	for _, want := range []string{
+		"@vtable.Shape.Square = vtable [Square.area, Square.scale]",
+		"define i32 @total({*i8, **i8} %a)",
+		"%t2 = extract *i8 %t1, 0\n  %t3 = extract **i8 %t1, 1\n  %t4 = vcall i32 %t3[0](%t2)",
+		"vcall void %t3[1](%t2, i64 %t4)",
+		"%t2 = bitcast *%struct.Square %t1 to *i8\n  %t3 = tuple {*i8, **i8} (%t2, %@vtable.Shape.Square)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
 	}
 }
@@ -0,0 +1,39 @@
+package mir
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Mangle returns the symbol a function of the module at path is linked as.
+// The path's segments and those of the name, split at :: and at the dot of
+// a method, follow _Y, each prefixed with its length, as in _Y4main6helper
+// for helper in main and _Y4main5Point4area for Point.area. Symbols of two
+// modules cannot collide with each other, nor with those of C, which do
+// not start with _Y. main and extern functions keep their names.
+func Mangle(path, name string) string {
+	var b strings.Builder
+
+	b.WriteString("_Y")
+
+	for _, part := range append(splitPath(path), splitPath(name)...) {
+		b.WriteString(strconv.Itoa(len(part)))
+		b.WriteString(part)
+	}
+
+	return b.String()
+}
+
+func splitPath(s string) []string {
+	var parts []string
+
+	for _, seg := range strings.Split(s, "::") {
+		for _, part := range strings.Split(seg, ".") {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,20 @@
+package mir
+
+import "testing"
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		path, name, want string
+	}{
+		{"main", "helper", "_Y4main6helper"},
+		{"util", "helper", "_Y4util6helper"},
+		{"main", "Point.area", "_Y4main5Point4area"},
+		{"std::io", "write", "_Y3std2io5write"},
+	}
+
+	for _, tt := range tests {
+		if got := Mangle(tt.path, tt.name); got != tt.want {
+			t.Errorf("Mangle(%q, %q) = %q, want %q", tt.path, tt.name, got, tt.want)
+		}
+	}
+}
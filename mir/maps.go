@@ -0,0 +1,177 @@
+package mir
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/types"
+)
+
+// A Map<K, V> is lowered like a Vec: an opaque pointer to the runtime's
+// hash map, which Map::new() tells the sizes of K and V and whether keys
+// are strs. Keys and values pass through pointers, and get and remove have
+// the runtime copy the value straight into the payload of the Option they
+// return, whose tag is whether the key was found. A for loop over a Map
+// steps through its entries with yar_map_next.
+
+// mapTypes returns the key and value types of the Map that expr is, or
+// refers to
+func (l *Lowerer) mapTypes(expr ast.Expr) (Type, Type, bool) {
+	t := l.types[expr]
+	if ref, ok := t.(*types.RefType); ok {
+		t = ref.Elem
+	}
+
+	m, ok := t.(*types.MapType)
+	if !ok {
+		return nil, nil, false
+	}
+
+	key, ok := l.fromChecker(m.Key)
+	if !ok {
+		return nil, nil, false
+	}
+
+	value, ok := l.fromChecker(m.Value)
+
+	return key, value, ok
+}
+
+// mapNew returns the key and value types of the Map call makes, if it is
+// Map::new()
+func (l *Lowerer) mapNew(call *ast.CallExpr) (Type, Type, bool) {
+	path, ok := call.Callee.(*ast.PathExpr)
+	if !ok || len(path.Segments) != 2 || path.Segments[0] != "Map" || path.Segments[1] != "new" {
+		return nil, nil, false
+	}
+
+	return l.mapTypes(call)
+}
+
+// lowerMapNew makes an empty Map from key to value
+func (l *Lowerer) lowerMapNew(key, value Type) string {
+	keySize := l.newTemp()
+	l.emit(&SizeOf{Dest: keySize, Type: key})
+
+	valueSize := l.newTemp()
+	l.emit(&SizeOf{Dest: valueSize, Type: value})
+
+	strKeys := "0"
+	if _, ok := key.(*StrType); ok {
+		strKeys = "1"
+	}
+
+	result := l.newTemp()
+	l.emit(&Call{Dest: result, Callee: "yar_map_new", Args: []string{keySize, valueSize, strKeys}, RetTy: handleType()})
+
+	return result
+}
+
+// lowerMapCall lowers a call to a method of a Map with values of type
+// value. It returns the call's result, or "" for insert.
+func (l *Lowerer) lowerMapCall(call *ast.CallExpr, callee *ast.FieldExpr, value Type) string {
+	m := l.lowerHandle(callee.Expr)
+	name := "yar_map_" + callee.Field
+
+	switch callee.Field {
+	case "insert":
+		args := []string{m, l.runtimePtr(call.Args[0]), l.runtimePtr(call.Args[1])}
+		l.emit(&Call{Callee: name, Args: args, RetTy: &PrimitiveType{Name: "void"}})
+
+		return ""
+	case "get", "remove":
+		return l.optionOut(name, value, m, l.runtimePtr(call.Args[0]))
+	case "contains":
+		result := l.newTemp()
+		l.emit(&Call{Dest: result, Callee: name, Args: []string{m, l.runtimePtr(call.Args[0])}, RetTy: &PrimitiveType{Name: "bool"}})
+
+		return result
+	case "len":
+		result := l.newTemp()
+		l.emit(&Call{Dest: result, Callee: name, Args: []string{m}, RetTy: &PrimitiveType{Name: "i64"}})
+
+		return result
+	default:
+		l.errorf("method %s of Map cannot be lowered", callee.Field)
+		return "undef"
+	}
+}
+
+// lowerForMap lowers a loop over the entries of a Map, in the order they
+// were inserted. yar_map_next copies the entry at or after a position into
+// the loop variables and returns the position after it, or -1 at the end.
+func (l *Lowerer) lowerForMap(stmt *ast.ForStmt, keyTy, valueTy Type) {
+	m := l.lowerHandle(stmt.Iter)
+
+	l.pushScope()
+	defer l.popScope()
+
+	i64 := &PrimitiveType{Name: "i64"}
+
+	pos := l.declare("for.pos")
+	l.emit(&Alloca{Name: pos, Type: i64})
+	l.emit(&Store{Value: "0", Dest: pos, Type: i64})
+
+	keyName := stmt.Key
+	if keyName == "" {
+		keyName = "for.key"
+	}
+
+	key := l.declare(keyName)
+	l.emit(&Alloca{Name: key, Type: keyTy})
+
+	value := l.declare(stmt.Val)
+	l.emit(&Alloca{Name: value, Type: valueTy})
+
+	condBlock := l.newBB("cond")
+	bodyBlock := l.newBB("body")
+	exitBlock := l.newBB("exit")
+
+	l.emit(&Br{Label: condBlock.Label})
+	l.currentFn.Blocks = append(l.currentFn.Blocks, condBlock)
+	l.currentBB = condBlock
+
+	at := l.newTemp()
+	l.emit(&Load{Dest: at, Source: pos, Type: i64})
+
+	keyPtr := l.slotPtr(key, keyTy)
+	valuePtr := l.slotPtr(value, valueTy)
+
+	next := l.newTemp()
+	l.emit(&Call{Dest: next, Callee: "yar_map_next", Args: []string{m, at, keyPtr, valuePtr}, RetTy: i64})
+	l.emit(&Store{Value: next, Dest: pos, Type: i64})
+
+	done := l.newTemp()
+	l.emit(&BinOp{Dest: done, Op: Lt, Left: next, Right: "0", Type: i64})
+	l.emit(&CondBr{Cond: done, TrueLabel: exitBlock.Label, FalseLabel: bodyBlock.Label})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, bodyBlock)
+	l.currentBB = bodyBlock
+
+	prevExitLabel := l.loopExitLabel
+	prevContinueLabel := l.loopContinueLabel
+	l.loopExitLabel = exitBlock.Label
+	l.loopContinueLabel = condBlock.Label
+
+	l.lowerBlock(stmt.Body)
+
+	l.loopExitLabel = prevExitLabel
+	l.loopContinueLabel = prevContinueLabel
+
+	if len(l.currentBB.Instrs) == 0 || !isTerminator(l.currentBB.Instrs[len(l.currentBB.Instrs)-1]) {
+		l.emit(&Br{Label: condBlock.Label})
+	}
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, exitBlock)
+	l.currentBB = exitBlock
+}
+
+// slotPtr returns the address of slot, which holds a value of type t, as
+// an *i8
+func (l *Lowerer) slotPtr(slot string, t Type) string {
+	addr := l.newTemp()
+	l.emit(&AddrOf{Dest: addr, Source: slot, Type: t})
+
+	ptr := l.newTemp()
+	l.emit(&Cast{Dest: ptr, Kind: BitCast, Value: addr, From: &PtrType{Elem: t}, To: handleType()})
+
+	return ptr
+}
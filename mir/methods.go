@@ -0,0 +1,142 @@
+package mir
+
+import (
+	"fmt"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// Methods are lowered as functions named Type.method. A method that takes
+// &self or &mut self gets a pointer to the receiver as its first parameter,
+// named self, and a call passes the receiver's address there.
+
+// methodName names the function a method of typ is lowered as
+func methodName(typ, method string) string {
+	return fmt.Sprintf("%s.%s", typ, method)
+}
+
+// receiverName is the name methods of t are registered under: the name of
+// a struct or enum, or of a primitive type or str
+func receiverName(t Type) (string, bool) {
+	switch t := t.(type) {
+	case *StructType:
+		return t.Name, true
+	case *EnumType:
+		return t.Name, true
+	case *PrimitiveType:
+		return t.Name, t.Name != "void"
+	case *StrType:
+		return "str", true
+	default:
+		return "", false
+	}
+}
+
+// declareImpl records the signatures of the methods of impl, so that calls
+// to them are typed before their bodies are lowered
+func (l *Lowerer) declareImpl(impl *ast.ImplBlock) {
+	recv := l.lowerType(impl.For)
+
+	name, ok := receiverName(recv)
+	if !ok {
+		return
+	}
+
+	if l.methods[name] == nil {
+		l.methods[name] = make(map[string]*Function)
+	}
+
+	l.declareVTable(impl, name)
+
+	for _, fn := range impl.Fns {
+		sig := l.methodSignature(recv, name, fn)
+		l.methods[name][fn.Name] = sig
+		l.signatures[sig.Name] = sig
+	}
+}
+
+// lowerImpl lowers the bodies of the methods of impl
+func (l *Lowerer) lowerImpl(impl *ast.ImplBlock) {
+	recv := l.lowerType(impl.For)
+
+	name, ok := receiverName(recv)
+	if !ok {
+		return
+	}
+
+	for _, fn := range impl.Fns {
+		l.lowerFuncBody(l.methodSignature(recv, name, fn), fn.Body, newScope(l.globals))
+	}
+}
+
+// methodSignature lowers the signature of a method of recv, whose self
+// parameter, if it has one, is a pointer to the receiver
+func (l *Lowerer) methodSignature(recv Type, name string, fn *ast.FuncDecl) *Function {
+	decl := *fn
+	decl.Name = methodName(name, fn.Name)
+
+	saved := l.self
+	l.self = recv
+	defer func() { l.self = saved }()
+
+	sig := l.lowerSignature(&decl)
+
+	for i, p := range fn.Params {
+		if p.Name == "&self" || p.Name == "&mut self" {
+			sig.Params[i] = Param{Name: "self", Type: &PtrType{Elem: recv}}
+		}
+	}
+
+	return sig
+}
+
+// methodOf resolves the method callee calls, on a receiver or through a
+// reference to one
+func (l *Lowerer) methodOf(callee *ast.FieldExpr) (*Function, bool) {
+	recv := l.typeOf(callee.Expr)
+	if ptr, ok := recv.(*PtrType); ok {
+		recv = ptr.Elem
+	}
+
+	name, ok := receiverName(recv)
+	if !ok {
+		return nil, false
+	}
+
+	sig, ok := l.methods[name][callee.Field]
+
+	return sig, ok
+}
+
+// buildMethodCall evaluates the receiver and arguments of a call to a
+// method and returns the call to the function it is lowered as
+func (l *Lowerer) buildMethodCall(call *ast.CallExpr, callee *ast.FieldExpr) (*Call, bool) {
+	sig, ok := l.methodOf(callee)
+	if !ok {
+		l.errorf("method %s of %s cannot be lowered", callee.Field, l.typeOf(callee.Expr).String())
+		return nil, false
+	}
+
+	recvTy := l.typeOf(callee.Expr)
+
+	var args []string
+
+	// The receiver is passed by address; a reference is passed as it is
+	if len(sig.Params) > 0 && sig.Params[0].Name == "self" {
+		if _, isPtr := recvTy.(*PtrType); isPtr {
+			args = append(args, l.lowerExpr(callee.Expr))
+		} else {
+			args = append(args, l.lowerAddrOf(callee.Expr))
+		}
+	}
+
+	for _, arg := range call.Args {
+		args = append(args, l.lowerExpr(arg))
+	}
+
+	if l.variadic[sig.Name] {
+		args = l.packVariadic(sig, args)
+	}
+
+	return &Call{Callee: sig.Name, Args: args, RetTy: sig.RetTy}, true
+}
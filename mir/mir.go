@@ -1,6 +1,9 @@
 package mir
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Instruction represents a MIR instruction
 type Instruction interface {
@@ -34,7 +37,50 @@ func (p *PtrType) String() string {
 	return fmt.Sprintf("*%s", p.Elem.String())
 }
 
-// StructType represents struct types
+// TupleType is an anonymous aggregate. Functions return tuples by value.
+type TupleType struct {
+	Elems []Type
+}
+
+func (t *TupleType) isType() {}
+func (t *TupleType) String() string {
+	elems := make([]string, len(t.Elems))
+	for i, e := range t.Elems {
+		elems[i] = e.String()
+	}
+
+	return "{" + strings.Join(elems, ", ") + "}"
+}
+
+// SliceType is a pointer to the first element paired with a length
+type SliceType struct {
+	Elem Type
+}
+
+func (s *SliceType) isType() {}
+func (s *SliceType) String() string {
+	return fmt.Sprintf("[]%s", s.Elem.String())
+}
+
+// ArrayType is a fixed number of values stored inline
+type ArrayType struct {
+	Elem Type
+	Len  int
+}
+
+func (a *ArrayType) isType() {}
+func (a *ArrayType) String() string {
+	return fmt.Sprintf("[%d x %s]", a.Len, a.Elem.String())
+}
+
+// StrType is a pointer to UTF-8 bytes paired with their length
+type StrType struct{}
+
+func (s *StrType) isType()        {}
+func (s *StrType) String() string { return "str" }
+
+// StructType represents struct types. Fields is only filled in on the
+// module's definitions; other uses refer to the struct by name.
 type StructType struct {
 	Name   string
 	Fields []Type
@@ -45,6 +91,47 @@ func (s *StructType) String() string {
 	return fmt.Sprintf("%%struct.%s", s.Name)
 }
 
+// EnumType is a tagged union: an i32 tag saying which variant the value
+// holds, followed by room for the largest payload. Variants is only filled
+// in on the module's definitions; other uses refer to the enum by name.
+type EnumType struct {
+	Name     string
+	Variants []Variant
+}
+
+// Variant is one case of an enum
+type Variant struct {
+	Name    string
+	Tag     int64
+	Payload []Type
+}
+
+func (e *EnumType) isType() {}
+func (e *EnumType) String() string {
+	return fmt.Sprintf("%%enum.%s", e.Name)
+}
+
+// FuncType is a pointer to a function taking Params and returning Ret
+type FuncType struct {
+	Params []Type
+	Ret    Type
+}
+
+func (f *FuncType) isType() {}
+func (f *FuncType) String() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+
+	return fmt.Sprintf("fn(%s) %s", strings.Join(params, ", "), f.Ret.String())
+}
+
+// Constructor names the function that builds variant of enum
+func Constructor(enum, variant string) string {
+	return fmt.Sprintf("%s.%s", enum, variant)
+}
+
 // OpKind represents operation kinds
 type OpKind int
 
@@ -87,25 +174,46 @@ func (a *Alloca) String() string {
 
 // Load loads from memory
 type Load struct {
-	Dest   string
-	Source string
-	Type   Type
+	Dest     string
+	Source   string
+	Type     Type
+	Volatile bool // must not be elided or reordered by the optimizer
 }
 
 func (l *Load) isInstr() {}
 func (l *Load) String() string {
+	if l.Volatile {
+		return fmt.Sprintf("%%%s = load volatile %s, %s* %%%s", l.Dest, l.Type.String(), l.Type.String(), l.Source)
+	}
 	return fmt.Sprintf("%%%s = load %s, %s* %%%s", l.Dest, l.Type.String(), l.Type.String(), l.Source)
 }
 
+// AddrOf takes the address of the local Source. Loads and stores through
+// the result reach the local itself.
+type AddrOf struct {
+	Dest   string
+	Source string
+	Type   Type // type of the local
+}
+
+func (a *AddrOf) isInstr() {}
+func (a *AddrOf) String() string {
+	return fmt.Sprintf("%%%s = addrof %s* %%%s", a.Dest, a.Type.String(), a.Source)
+}
+
 // Store stores to memory
 type Store struct {
-	Value string
-	Dest  string
-	Type  Type
+	Value    string
+	Dest     string
+	Type     Type
+	Volatile bool // must not be elided or reordered by the optimizer
 }
 
 func (s *Store) isInstr() {}
 func (s *Store) String() string {
+	if s.Volatile {
+		return fmt.Sprintf("store volatile %s %%%s, %s* %%%s", s.Type.String(), s.Value, s.Type.String(), s.Dest)
+	}
 	return fmt.Sprintf("store %s %%%s, %s* %%%s", s.Type.String(), s.Value, s.Type.String(), s.Dest)
 }
 
@@ -115,7 +223,17 @@ type BinOp struct {
 	Op    OpKind
 	Left  string
 	Right string
-	Type  Type
+	Type  Type // type of the operands; see ResultType
+}
+
+// ResultType is the type of Dest: bool for a comparison, else the type of
+// the operands
+func (b *BinOp) ResultType() Type {
+	if b.Op >= Eq && b.Op <= Ge {
+		return &PrimitiveType{Name: "bool"}
+	}
+
+	return b.Type
 }
 
 func (b *BinOp) isInstr() {}
@@ -123,6 +241,223 @@ func (b *BinOp) String() string {
 	return fmt.Sprintf("%%%s = %s %s %%%s, %%%s", b.Dest, opNames[b.Op], b.Type.String(), b.Left, b.Right)
 }
 
+// materialize defines dest as the immediate value of type t. An immediate
+// passed where nothing else types it, such as an argument of a function
+// from the runtime, is read as an i32.
+func materialize(dest, value string, t Type) *BinOp {
+	return &BinOp{Dest: dest, Op: Add, Left: value, Right: "0", Type: t}
+}
+
+// untypedAs reports whether an immediate of type t would be read as
+// another type were it passed as it is
+func untypedAs(t Type) bool {
+	p, ok := t.(*PrimitiveType)
+	return !ok || p.Name != "i32"
+}
+
+// MakeTuple builds a tuple from its element values
+type MakeTuple struct {
+	Dest  string
+	Elems []string
+	Type  *TupleType
+}
+
+func (m *MakeTuple) isInstr() {}
+func (m *MakeTuple) String() string {
+	elems := make([]string, len(m.Elems))
+	for i, e := range m.Elems {
+		elems[i] = "%" + e
+	}
+
+	return fmt.Sprintf("%%%s = tuple %s (%s)", m.Dest, m.Type.String(), strings.Join(elems, ", "))
+}
+
+// PackSlice stores Elems in a fresh stack array and makes a slice of it.
+// Calls use it to pass the trailing arguments of a variadic function.
+type PackSlice struct {
+	Dest  string
+	Elems []string
+	Elem  Type
+}
+
+func (p *PackSlice) isInstr() {}
+func (p *PackSlice) String() string {
+	elems := make([]string, len(p.Elems))
+	for i, e := range p.Elems {
+		elems[i] = "%" + e
+	}
+
+	return fmt.Sprintf("%%%s = slice %s [%s]", p.Dest, p.Elem.String(), strings.Join(elems, ", "))
+}
+
+// Extract reads element Index of a tuple value
+type Extract struct {
+	Dest  string
+	Tuple string
+	Index int
+	Type  Type // element type
+}
+
+func (e *Extract) isInstr() {}
+func (e *Extract) String() string {
+	return fmt.Sprintf("%%%s = extract %s %%%s, %d", e.Dest, e.Type.String(), e.Tuple, e.Index)
+}
+
+// Insert returns a copy of the struct value Agg with field Index set to
+// Value. An empty Agg starts from a struct whose fields are all zero.
+type Insert struct {
+	Dest  string
+	Agg   string
+	Value string
+	Index int
+	Type  *StructType
+	Elem  Type // field type
+}
+
+func (i *Insert) isInstr() {}
+func (i *Insert) String() string {
+	agg := "zeroinitializer"
+	if i.Agg != "" {
+		agg = "%" + i.Agg
+	}
+
+	return fmt.Sprintf("%%%s = insert %s %s, %s %%%s, %d", i.Dest, i.Type.String(), agg, i.Elem.String(), i.Value, i.Index)
+}
+
+// FieldAddr computes the address of field Index of the struct or tuple
+// Base points to. Loads and stores take the result in place of a local.
+type FieldAddr struct {
+	Dest  string
+	Base  string
+	Index int
+	Type  Type // struct or tuple type
+}
+
+func (f *FieldAddr) isInstr() {}
+func (f *FieldAddr) String() string {
+	return fmt.Sprintf("%%%s = fieldaddr %s* %%%s, %d", f.Dest, f.Type.String(), f.Base, f.Index)
+}
+
+// ElemAddr computes the address of element Index of the array or slice
+// stored at Base. Type is the array or slice type.
+type ElemAddr struct {
+	Dest  string
+	Base  string
+	Index string
+	Type  Type
+}
+
+func (e *ElemAddr) isInstr() {}
+func (e *ElemAddr) String() string {
+	return fmt.Sprintf("%%%s = elemaddr %s* %%%s, %%%s", e.Dest, e.Type.String(), e.Base, e.Index)
+}
+
+// TagAddr computes the address of the tag of the enum Base points to
+type TagAddr struct {
+	Dest string
+	Base string
+	Type *EnumType
+}
+
+func (t *TagAddr) isInstr() {}
+func (t *TagAddr) String() string {
+	return fmt.Sprintf("%%%s = tagaddr %s* %%%s", t.Dest, t.Type.String(), t.Base)
+}
+
+// PayloadAddr computes the address of value Index of the payload of
+// variant Variant, in the enum Base points to
+type PayloadAddr struct {
+	Dest    string
+	Base    string
+	Variant int
+	Index   int
+	Type    *EnumType
+}
+
+func (p *PayloadAddr) isInstr() {}
+func (p *PayloadAddr) String() string {
+	return fmt.Sprintf("%%%s = payloadaddr %s* %%%s, %d, %d", p.Dest, p.Type.String(), p.Base, p.Variant, p.Index)
+}
+
+// EnumTag reads the tag of an enum value
+type EnumTag struct {
+	Dest  string
+	Value string
+	Type  *EnumType
+}
+
+func (e *EnumTag) isInstr() {}
+func (e *EnumTag) String() string {
+	return fmt.Sprintf("%%%s = tag %s %%%s", e.Dest, e.Type.String(), e.Value)
+}
+
+// Phi takes the value of the incoming edge control arrived by. Phis come
+// first in their block, with one incoming value per predecessor edge.
+type Phi struct {
+	Dest     string
+	Type     Type
+	Incoming []Incoming
+}
+
+// Incoming is the value a phi takes when control comes from block Label
+type Incoming struct {
+	Value string
+	Label string
+}
+
+func (p *Phi) isInstr() {}
+func (p *Phi) String() string {
+	incoming := make([]string, len(p.Incoming))
+	for i, in := range p.Incoming {
+		incoming[i] = fmt.Sprintf("[%%%s, %%bb_%s]", in.Value, in.Label)
+	}
+
+	return fmt.Sprintf("%%%s = phi %s %s", p.Dest, p.Type.String(), strings.Join(incoming, ", "))
+}
+
+// CastKind selects how Cast converts its operand
+type CastKind int
+
+const (
+	SExt    CastKind = iota // sign-extend a signed integer
+	ZExt                    // zero-extend an unsigned integer
+	FPExt                   // widen a float
+	Trunc                   // drop the high bits of an integer
+	FPTrunc                 // narrow a float
+	SIToFP                  // signed integer to float
+	UIToFP                  // unsigned integer to float
+	FPToSI                  // float to signed integer
+	FPToUI                  // float to unsigned integer
+	BitCast                 // view a pointer as a pointer to another type
+)
+
+var castNames = map[CastKind]string{
+	SExt:    "sext",
+	ZExt:    "zext",
+	FPExt:   "fpext",
+	Trunc:   "trunc",
+	FPTrunc: "fptrunc",
+	SIToFP:  "sitofp",
+	UIToFP:  "uitofp",
+	FPToSI:  "fptosi",
+	FPToUI:  "fptoui",
+	BitCast: "bitcast",
+}
+
+// Cast converts Value from one type to another
+type Cast struct {
+	Dest  string
+	Kind  CastKind
+	Value string
+	From  Type
+	To    Type
+}
+
+func (c *Cast) isInstr() {}
+func (c *Cast) String() string {
+	return fmt.Sprintf("%%%s = %s %s %%%s to %s", c.Dest, castNames[c.Kind], c.From.String(), c.Value, c.To.String())
+}
+
 // Call represents function call
 type Call struct {
 	Dest   string   // destination register (empty for void calls)
@@ -133,30 +468,109 @@ type Call struct {
 
 func (c *Call) isInstr() {}
 func (c *Call) String() string {
-	args := ""
+	args := callArgs(c.Args)
 
-	for i, arg := range c.Args {
+	if c.Dest == "" {
+		// Void call
+		return fmt.Sprintf("call %s @%s(%s)", c.RetTy.String(), c.Callee, args)
+	}
+
+	return fmt.Sprintf("%%%s = call %s @%s(%s)", c.Dest, c.RetTy.String(), c.Callee, args)
+}
+
+// callArgs prints the arguments of a call: registers with a leading %,
+// immediates and string literals as they are
+func callArgs(args []string) string {
+	out := ""
+
+	for i, arg := range args {
 		if i > 0 {
-			args += ", "
+			out += ", "
 		}
 		// Check if arg is a string literal (starts with quote)
 		if len(arg) > 0 && arg[0] == '"' {
-			args += arg
+			out += arg
 		} else if len(arg) > 0 && (arg[0] >= '0' && arg[0] <= '9' || arg[0] == '-') {
 			// Number (immediate)
-			args += arg
+			out += arg
 		} else {
 			// Register
-			args += "%" + arg
+			out += "%" + arg
 		}
 	}
 
+	return out
+}
+
+// VCall calls through slot Slot of VTable, passing Object as self followed
+// by Args. Object and VTable are the two halves of a trait object. The
+// method is not known until run time, so the call carries the types of its
+// arguments.
+type VCall struct {
+	Dest   string // destination register (empty for void calls)
+	VTable string
+	Slot   int
+	Object string
+	Args   []string
+	ArgTys []Type
+	RetTy  Type
+}
+
+func (v *VCall) isInstr() {}
+func (v *VCall) String() string {
+	args := "%" + v.Object
+	for i, arg := range v.Args {
+		args += fmt.Sprintf(", %s %%%s", v.ArgTys[i].String(), arg)
+	}
+
+	call := fmt.Sprintf("vcall %s %%%s[%d](%s)", v.RetTy.String(), v.VTable, v.Slot, args)
+	if v.Dest == "" {
+		return call
+	}
+
+	return fmt.Sprintf("%%%s = %s", v.Dest, call)
+}
+
+// FuncRef takes the address of the function Func
+type FuncRef struct {
+	Dest string
+	Func string
+	Type *FuncType
+}
+
+func (f *FuncRef) isInstr() {}
+func (f *FuncRef) String() string {
+	return fmt.Sprintf("%%%s = funcref %s @%s", f.Dest, f.Type.String(), f.Func)
+}
+
+// SizeOf gives the size in bytes of Type as an i64. Layouts are only
+// known once types are lowered to LLVM, so codegen fills it in.
+type SizeOf struct {
+	Dest string
+	Type Type
+}
+
+func (s *SizeOf) isInstr() {}
+func (s *SizeOf) String() string {
+	return fmt.Sprintf("%%%s = sizeof %s", s.Dest, s.Type.String())
+}
+
+// IndirectCall calls the function Callee points to, which has type Type
+type IndirectCall struct {
+	Dest   string // destination register (empty for void calls)
+	Callee string
+	Args   []string
+	Type   *FuncType
+}
+
+func (c *IndirectCall) isInstr() {}
+func (c *IndirectCall) String() string {
+	call := fmt.Sprintf("icall %s %%%s(%s)", c.Type.String(), c.Callee, callArgs(c.Args))
 	if c.Dest == "" {
-		// Void call
-		return fmt.Sprintf("call %s @%s(%s)", c.RetTy.String(), c.Callee, args)
+		return call
 	}
 
-	return fmt.Sprintf("%%%s = call %s @%s(%s)", c.Dest, c.RetTy.String(), c.Callee, args)
+	return fmt.Sprintf("%%%s = %s", c.Dest, call)
 }
 
 // Ret represents return
@@ -174,6 +588,14 @@ func (r *Ret) String() string {
 	return fmt.Sprintf("ret %s %%%s", r.Type.String(), r.Value)
 }
 
+// Unreachable marks the end of a block control can never reach
+type Unreachable struct{}
+
+func (u *Unreachable) isInstr() {}
+func (u *Unreachable) String() string {
+	return "unreachable"
+}
+
 // Br represents unconditional branch
 type Br struct {
 	Label string
@@ -235,6 +657,7 @@ type Function struct {
 	Params []Param
 	RetTy  Type
 	Blocks []*BasicBlock
+	Inline bool // marked #[inline]: inlined at calls whatever its size
 }
 
 type Param struct {
@@ -243,7 +666,27 @@ type Param struct {
 }
 
 func (f *Function) String() string {
-	return fmt.Sprintf("define %s @%s(...) { ... }", f.RetTy.String(), f.Name)
+	params := ""
+
+	for i, p := range f.Params {
+		if i > 0 {
+			params += ", "
+		}
+
+		params += fmt.Sprintf("%s %%%s", p.Type.String(), p.Name)
+	}
+
+	inline := ""
+	if f.Inline {
+		inline = "inline "
+	}
+
+	s := fmt.Sprintf("define %s%s @%s(%s) {\n", inline, f.RetTy.String(), f.Name, params)
+	for _, bb := range f.Blocks {
+		s += bb.String()
+	}
+
+	return s + "}\n"
 }
 
 // Global represents a global variable or constant
@@ -263,8 +706,101 @@ func (g *GlobalString) GlobalName() string {
 	return g.Name
 }
 
+// GlobalConst is a constant declared at the top of a file whose value is
+// not an integer, such as a float, bool or str. Value is an immediate, or
+// the @name of a GlobalString for a str.
+type GlobalConst struct {
+	Name  string // e.g., "const.PI"
+	Type  Type
+	Value string
+}
+
+func (g *GlobalConst) isGlobal() {}
+func (g *GlobalConst) GlobalName() string {
+	return g.Name
+}
+
+// GlobalVTable is the method table of one type's impl of a trait. Methods
+// holds function names in the order the trait declares them, so slot i of
+// every vtable for a trait refers to the same method.
+type GlobalVTable struct {
+	Name    string
+	Methods []string
+}
+
+func (g *GlobalVTable) isGlobal() {}
+func (g *GlobalVTable) GlobalName() string {
+	return g.Name
+}
+
+// VTableName names the vtable for typ's impl of trait
+func VTableName(trait, typ string) string {
+	return fmt.Sprintf("vtable.%s.%s", trait, typ)
+}
+
 // Module represents a MIR module
 type Module struct {
+	Structs   []*StructType // Struct layouts, fields in declaration order
+	Enums     []*EnumType   // Enum layouts, variants in declaration order
 	Globals   []Global
 	Functions []*Function
 }
+
+func (m *Module) String() string {
+	s := ""
+
+	for _, st := range m.Structs {
+		fields := make([]string, len(st.Fields))
+		for i, f := range st.Fields {
+			fields[i] = f.String()
+		}
+
+		s += fmt.Sprintf("%s = type { %s }\n", st.String(), strings.Join(fields, ", "))
+	}
+
+	for _, e := range m.Enums {
+		variants := make([]string, len(e.Variants))
+		for i, v := range e.Variants {
+			payload := make([]string, len(v.Payload))
+			for j, t := range v.Payload {
+				payload[j] = t.String()
+			}
+
+			variants[i] = fmt.Sprintf("%s = %d (%s)", v.Name, v.Tag, strings.Join(payload, ", "))
+		}
+
+		s += fmt.Sprintf("%s = enum { %s }\n", e.String(), strings.Join(variants, ", "))
+	}
+
+	for _, g := range m.Globals {
+		switch g := g.(type) {
+		case *GlobalString:
+			s += fmt.Sprintf("@%s = %q\n", g.Name, g.Value)
+		case *GlobalVTable:
+			s += fmt.Sprintf("@%s = vtable [%s]\n", g.Name, strings.Join(g.Methods, ", "))
+		case *GlobalConst:
+			s += fmt.Sprintf("@%s = const %s %s\n", g.Name, g.Type.String(), g.Value)
+		}
+	}
+
+	for _, fn := range m.Functions {
+		if s != "" {
+			s += "\n"
+		}
+
+		s += fn.String()
+	}
+
+	return s
+}
+
+// Function returns the function with the given name, or nil
+func (m *Module) Function(name string) *Function {
+	for _, fn := range m.Functions {
+		if fn.Name == name {
+			return fn
+		}
+	}
+
+	return nil
+}
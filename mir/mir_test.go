@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/yarlson/yarlang/checker"
 	"github.com/yarlson/yarlang/lexer"
 	"github.com/yarlson/yarlang/parser"
 )
@@ -59,7 +60,7 @@ fn main() {
 }`,
 			contains: []string{
 				"call void @println",
-				`@.str.1`, // String literals are now lowered to global references
+				`@.str.main.1`, // String literals are now lowered to global references
 			},
 		},
 	}
@@ -243,8 +244,8 @@ fn main() {
 				"alloca i32",
 				"gt i32",
 				"br i1",
-				"label %bb_then",
-				"label %bb_merge",
+				"label %bb_main.then",
+				"label %bb_main.merge",
 			},
 			blockCount: 3, // entry, then, merge
 			blockLabels: []string{"entry", "then", "merge"},
@@ -264,9 +265,9 @@ fn main() {
 				"alloca i32",
 				"gt i32",
 				"br i1",
-				"label %bb_then",
-				"label %bb_else",
-				"label %bb_merge",
+				"label %bb_main.then",
+				"label %bb_main.else",
+				"label %bb_main.merge",
 			},
 			blockCount: 4, // entry, then, else, merge
 			blockLabels: []string{"entry", "then", "else", "merge"},
@@ -372,10 +373,10 @@ fn main() {
 				"alloca i32",
 				"lt i32",
 				"br i1",
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_cond", // loop back
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.cond", // loop back
 			},
 			blockCount: 4, // entry, cond, body, exit
 			blockLabels: []string{"entry", "cond", "body", "exit"},
@@ -397,8 +398,8 @@ fn main() {
 				"alloca i32",
 				"lt i32",
 				"br i1",
-				"label %bb_cond",
-				"label %bb_body",
+				"label %bb_main.cond",
+				"label %bb_main.body",
 			},
 			blockCount: 7, // entry, cond1, body1, cond2, body2, exit2, exit1
 		},
@@ -484,14 +485,49 @@ fn main() {
 				"store i32",
 				"lt i32",
 				"br i1",
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
 				"add i32", // iterator increment
 			},
 			blockCount: 4, // entry, cond, body, exit
 			blockLabels: []string{"entry", "cond", "body", "exit"},
 		},
+		{
+			name: "for loop over slice with index",
+			input: `
+fn main(xs ...i64) {
+	for i, x in (xs) {
+		continue
+	}
+}`,
+			contains: []string{
+				"%t2 = extract usize %t1, 1",
+				"%i = alloca usize",
+				"lt usize %t3, %t2",
+				"%t6 = elemaddr []i64* %xs, %t5",
+				"%t7 = load i64, i64* %t6",
+				"br label %bb_main.step_4",
+				"add usize",
+			},
+			blockCount:  5, // entry, cond, body, step, exit
+			blockLabels: []string{"entry", "cond", "body", "step", "exit"},
+		},
+		{
+			name: "for loop over array",
+			input: `
+fn main(a [i32; 3]) {
+	for x in (a) {
+		let y = x
+	}
+}`,
+			contains: []string{
+				"%for.idx = alloca usize",
+				"lt usize %t1, %3",
+				"%t4 = elemaddr [3 x i32]* %a, %t3",
+			},
+			blockCount: 5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -573,10 +609,10 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_exit", // break jumps to exit
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.exit", // break jumps to exit
 			},
 		},
 		{
@@ -590,10 +626,10 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_exit", // break jumps to exit
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.exit", // break jumps to exit
 			},
 		},
 		{
@@ -613,10 +649,10 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_exit", // break in inner loop jumps to inner exit
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.exit", // break in inner loop jumps to inner exit
 			},
 		},
 	}
@@ -682,10 +718,10 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_cond", // continue jumps to condition
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.cond", // continue jumps to condition
 			},
 		},
 		{
@@ -700,10 +736,10 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"label %bb_exit",
-				"br label %bb_cond", // continue jumps to condition
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"label %bb_main.exit",
+				"br label %bb_main.cond", // continue jumps to condition
 			},
 		},
 		{
@@ -723,9 +759,9 @@ fn main() {
 	}
 }`,
 			contains: []string{
-				"label %bb_cond",
-				"label %bb_body",
-				"br label %bb_cond", // continue in inner loop jumps to inner condition
+				"label %bb_main.cond",
+				"label %bb_main.body",
+				"br label %bb_main.cond", // continue in inner loop jumps to inner condition
 			},
 		},
 	}
@@ -844,6 +880,22 @@ fn main() {
 				"defer_run_all",
 			},
 		},
+		{
+			name: "deferred arguments are evaluated at the defer",
+			input: `
+fn show(n i32) {
+}
+
+fn main() {
+	let mut x = 1
+	defer show(x)
+	x = 2
+}`,
+			contains: []string{
+				"%t1 = load i32, i32* %x",
+				"defer_push call void @show(%t1)\n  store i32 %2, i32* %x",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -892,67 +944,54 @@ func TestPropagateExprLowering(t *testing.T) {
 		name        string
 		input       string
 		contains    []string
-		blockCount  int
 		blockLabels []string
 	}{
 		{
-			name: "basic ? operator generates control flow",
+			name: "? on a Result returns the Err in the caller's type",
 			input: `
-fn may_fail() i32 {
-	return 42
+fn may_fail() Result<i32, i64> {
+	return Ok(42)
 }
 
-fn main() {
+fn caller() Result<bool, i64> {
 	let x = may_fail()?
+	return Ok(x == 42)
 }`,
 			contains: []string{
-				"call i32 @may_fail",
-				"label %bb_check",
-				"label %bb_error",
-				"label %bb_ok",
-				"br label %bb_check",
-			},
-			blockCount: 4, // entry, check, error, ok
-			blockLabels: []string{"entry", "check", "error", "ok"},
-		},
-		{
-			name: "? operator with early return",
-			input: `
-fn may_fail() i32 {
-	return 1
-}
-
-fn caller() i32 {
-	let result = may_fail()?
-	return result
-}`,
-			contains: []string{
-				"call i32 @may_fail",
-				"label %bb_check",
-				"label %bb_error",
-				"label %bb_ok",
-				"ret i32",
+				"%t1 = call %enum.Result.i32.i64 @may_fail()",
+				"%try.val = alloca %enum.Result.i32.i64",
+				"%t2 = tag %enum.Result.i32.i64 %t1",
+				"%t3 = eq i32 %t2, %1",
+				"br i1 %t3, label %bb_caller.error_2, label %bb_caller.ok_3",
+				"%t4 = payloadaddr %enum.Result.i32.i64* %try.val, 1, 0",
+				"%t5 = load i64, i64* %t4",
+				"%t6 = call %enum.Result.bool.i64 @Result.bool.i64.Err(%t5)",
+				"ret %enum.Result.bool.i64 %t6",
+				"%t7 = payloadaddr %enum.Result.i32.i64* %try.val, 0, 0",
+				"%t8 = load i32, i32* %t7",
+				"store i32 %t8, i32* %x",
 			},
-			blockCount: 4, // entry, check, error, ok
+			blockLabels: []string{"entry", "error", "ok"},
 		},
 		{
-			name: "? operator extracts value on success path",
+			name: "? on an Option returns None",
 			input: `
-fn get_value() i32 {
-	return 10
+fn first() Option<i32> {
+	return None
 }
 
-fn use_value() {
-	let val = get_value()?
-	let doubled = val + val
+fn caller() Option<i32> {
+	let x = first()?
+	return Some(x + 1)
 }`,
 			contains: []string{
-				"call i32 @get_value",
-				"label %bb_check",
-				"label %bb_error",
-				"label %bb_ok",
-				"add i32",
+				"%t2 = tag %enum.Option.i32 %t1",
+				"%t3 = eq i32 %t2, %0",
+				"%t4 = call %enum.Option.i32 @Option.i32.None()",
+				"%t5 = payloadaddr %enum.Option.i32* %try.val, 1, 0",
+				"@Option.i32.Some(",
 			},
+			blockLabels: []string{"entry", "error", "ok"},
 		},
 	}
 
@@ -966,47 +1005,35 @@ fn use_value() {
 				t.Fatalf("parser errors: %v", p.Errors())
 			}
 
-			lowerer := NewLowerer()
-			module := lowerer.LowerFile(file)
-
-			// Find the function that uses ? operator
-			var testFunc *Function
-			for _, fn := range module.Functions {
-				if fn.Name == "main" || fn.Name == "caller" || fn.Name == "use_value" {
-					testFunc = fn
-					break
-				}
+			c := checker.NewChecker()
+			if err := c.CheckFile(file); err != nil {
+				t.Fatalf("checker error: %v", err)
 			}
 
+			lowerer := NewLowerer()
+			lowerer.SetTypes(c.Types())
+			testFunc := lowerer.LowerFile(file).Function("caller")
+
 			if testFunc == nil {
 				t.Fatal("test function not found")
 			}
 
-			// Check block count
-			if tt.blockCount > 0 && len(testFunc.Blocks) != tt.blockCount {
-				t.Errorf("expected %d blocks, got %d", tt.blockCount, len(testFunc.Blocks))
+			if len(testFunc.Blocks) != len(tt.blockLabels) {
+				t.Errorf("expected %d blocks, got %d", len(tt.blockLabels), len(testFunc.Blocks))
 			}
 
-			// Check block labels if specified
-			if len(tt.blockLabels) > 0 {
-				for i, expectedLabel := range tt.blockLabels {
-					if i >= len(testFunc.Blocks) {
-						t.Errorf("expected block %d with label containing %q, but only %d blocks exist", i, expectedLabel, len(testFunc.Blocks))
-						continue
-					}
-					if !strings.Contains(testFunc.Blocks[i].Label, expectedLabel) {
-						t.Errorf("expected block %d label to contain %q, got %q", i, expectedLabel, testFunc.Blocks[i].Label)
-					}
+			for i, expectedLabel := range tt.blockLabels {
+				if i >= len(testFunc.Blocks) {
+					break
 				}
-			}
 
-			// Convert all blocks to string
-			var output string
-			for _, block := range testFunc.Blocks {
-				output += block.String()
+				if !strings.Contains(testFunc.Blocks[i].Label, expectedLabel) {
+					t.Errorf("expected block %d label to contain %q, got %q", i, expectedLabel, testFunc.Blocks[i].Label)
+				}
 			}
 
-			// Check for expected substrings
+			output := testFunc.String()
+
 			for _, substr := range tt.contains {
 				if !strings.Contains(output, substr) {
 					t.Errorf("output missing expected substring %q\nGot:\n%s", substr, output)
@@ -1015,3 +1042,39 @@ fn use_value() {
 		})
 	}
 }
+
+func TestFunctionString(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	fn := &Function{
+		Name:   "id",
+		Params: []Param{{Name: "n", Type: i32}},
+		RetTy:  i32,
+		Blocks: []*BasicBlock{{Label: "entry_1", Instrs: []Instruction{&Ret{Value: "n", Type: i32}}}},
+	}
+
+	expected := "define i32 @id(i32 %n) {\nbb_entry_1:\n  ret i32 %n\n}\n"
+	if fn.String() != expected {
+		t.Errorf("wrong string:\ngot:\n%s\nwant:\n%s", fn.String(), expected)
+	}
+
+	mod := &Module{Functions: []*Function{fn}}
+	if mod.Function("id") != fn || mod.Function("missing") != nil {
+		t.Error("Module.Function lookup failed")
+	}
+}
+
+func TestVTableString(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	mod := &Module{
+		Globals: []Global{&GlobalVTable{Name: VTableName("Shape", "Square"), Methods: []string{"Square.area", "Square.grow"}}},
+	}
+
+	if got, want := mod.String(), "@vtable.Shape.Square = vtable [Square.area, Square.grow]\n"; got != want {
+		t.Errorf("wrong module string: got %q, want %q", got, want)
+	}
+
+	call := &VCall{Dest: "t2", VTable: "vt", Slot: 1, Object: "obj", Args: []string{"t1"}, ArgTys: []Type{i32}, RetTy: i32}
+	if got, want := call.String(), "%t2 = vcall i32 %vt[1](%obj, i32 %t1)"; got != want {
+		t.Errorf("wrong vcall string: got %q, want %q", got, want)
+	}
+}
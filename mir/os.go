@@ -0,0 +1,38 @@
+package mir
+
+import "github.com/yarlson/yarlang/ast"
+
+// The functions of std::os are runtime calls: os_args returns the
+// command-line arguments as a []str, and os_env copies the value of an
+// environment variable into the payload of the Option env returns.
+
+// osCall returns the name of the function of std::os that call calls, by
+// its full path or by the name std::os is imported under, if it calls one
+func (l *Lowerer) osCall(call *ast.CallExpr) (string, bool) {
+	path, ok := call.Callee.(*ast.PathExpr)
+	if !ok {
+		return "", false
+	}
+
+	module, name, ok := l.stdPath(path)
+
+	return name, ok && module == "os"
+}
+
+// lowerOSCall lowers a call of the function name of std::os
+func (l *Lowerer) lowerOSCall(call *ast.CallExpr, name string) string {
+	str := &StrType{}
+
+	switch name {
+	case "args":
+		result := l.newTemp()
+		l.emit(&Call{Dest: result, Callee: "os_args", RetTy: &SliceType{Elem: str}})
+
+		return result
+	case "env":
+		return l.optionOut("os_env", str, l.lowerExpr(call.Args[0]))
+	default:
+		l.errorf("std::os::%s cannot be lowered", name)
+		return "undef"
+	}
+}
@@ -0,0 +1,672 @@
+package mir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a module in the text form Module.String prints, so that MIR
+// written by hand or saved from a build can be compiled or fed to passes
+func Parse(src string) (*Module, error) {
+	m := &Module{Globals: []Global{}, Functions: []*Function{}}
+	lines := strings.Split(src, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		s := &scanner{src: line, line: i + 1}
+
+		switch {
+		case strings.HasPrefix(line, "define "):
+			fn, next, err := parseFunction(lines, i)
+			if err != nil {
+				return nil, err
+			}
+
+			m.Functions = append(m.Functions, fn)
+			i = next
+		case strings.HasPrefix(line, "%struct."):
+			m.Structs = append(m.Structs, s.structDef())
+		case strings.HasPrefix(line, "%enum."):
+			m.Enums = append(m.Enums, s.enumDef())
+		case strings.HasPrefix(line, "@"):
+			m.Globals = append(m.Globals, s.global())
+		default:
+			s.fail("expected a type, global or function")
+		}
+
+		if s.err != nil {
+			return nil, s.err
+		}
+	}
+
+	return m, nil
+}
+
+// parseFunction reads the function whose define line is lines[start] and
+// returns the index of its closing brace
+func parseFunction(lines []string, start int) (*Function, int, error) {
+	s := &scanner{src: lines[start], line: start + 1}
+	fn := s.signature()
+
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+
+	var bb *BasicBlock
+
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		s := &scanner{src: line, line: i + 1}
+
+		switch {
+		case line == "}":
+			return fn, i, nil
+		case strings.HasPrefix(line, "bb_"):
+			s.expect("bb_")
+			bb = &BasicBlock{Label: s.name()}
+			s.expect(":")
+			s.end()
+			fn.Blocks = append(fn.Blocks, bb)
+		case strings.TrimSpace(line) == "":
+			continue
+		case bb == nil:
+			s.fail("instruction outside a block")
+		default:
+			if instr := s.instr(); s.err == nil {
+				s.end()
+				bb.Instrs = append(bb.Instrs, instr)
+			}
+		}
+
+		if s.err != nil {
+			return nil, 0, s.err
+		}
+	}
+
+	return nil, 0, fmt.Errorf("line %d: function %s has no closing brace", start+1, fn.Name)
+}
+
+// scanner reads one line. The first error sticks: later reads return zero
+// values, so callers check err once they are done.
+type scanner struct {
+	src  string
+	pos  int
+	line int
+	err  error
+}
+
+func (s *scanner) fail(format string, args ...any) {
+	if s.err == nil {
+		s.err = fmt.Errorf("line %d: %s", s.line, fmt.Sprintf(format, args...))
+	}
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// accept consumes lit if the line continues with it
+func (s *scanner) accept(lit string) bool {
+	s.skipSpace()
+
+	if s.err != nil || !strings.HasPrefix(s.src[s.pos:], lit) {
+		return false
+	}
+
+	s.pos += len(lit)
+
+	return true
+}
+
+func (s *scanner) expect(lit string) {
+	if !s.accept(lit) {
+		s.fail("expected %q at %q", lit, s.rest())
+	}
+}
+
+func (s *scanner) rest() string {
+	return s.src[s.pos:]
+}
+
+func (s *scanner) end() {
+	if s.skipSpace(); s.pos < len(s.src) {
+		s.fail("unexpected %q", s.rest())
+	}
+}
+
+// name reads a run of characters that are not spaces or punctuation:
+// names, labels, numbers and constants such as undef
+func (s *scanner) name() string {
+	s.skipSpace()
+
+	start := s.pos
+	for s.pos < len(s.src) && !strings.ContainsRune(" \t,()[]{}*=:", rune(s.src[s.pos])) {
+		s.pos++
+	}
+
+	if start == s.pos {
+		s.fail("expected a name at %q", s.rest())
+	}
+
+	return s.src[start:s.pos]
+}
+
+// value reads an operand, which is printed with a leading %
+func (s *scanner) value() string {
+	s.expect("%")
+	return s.name()
+}
+
+func (s *scanner) int() int64 {
+	text := s.name()
+
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		s.fail("expected an integer, got %q", text)
+	}
+
+	return n
+}
+
+func (s *scanner) label() string {
+	s.expect("label")
+	s.expect("%bb_")
+
+	return s.name()
+}
+
+// list reads items separated by commas up to close, the opening bracket
+// having been read
+func (s *scanner) list(close string, item func()) {
+	if s.accept(close) {
+		return
+	}
+
+	for s.err == nil {
+		item()
+
+		if !s.accept(",") {
+			break
+		}
+	}
+
+	s.expect(close)
+}
+
+func (s *scanner) typ() Type {
+	switch {
+	case s.accept("*"):
+		return &PtrType{Elem: s.typ()}
+	case s.accept("{"):
+		tuple := &TupleType{}
+		s.list("}", func() { tuple.Elems = append(tuple.Elems, s.typ()) })
+
+		return tuple
+	case s.accept("[]"):
+		return &SliceType{Elem: s.typ()}
+	case s.accept("["):
+		n := s.int()
+		s.expect("x")
+		elem := s.typ()
+		s.expect("]")
+
+		return &ArrayType{Elem: elem, Len: int(n)}
+	case s.accept("fn("):
+		fn := &FuncType{}
+		s.list(")", func() { fn.Params = append(fn.Params, s.typ()) })
+		fn.Ret = s.typ()
+
+		return fn
+	case s.accept("%struct."):
+		return &StructType{Name: s.name()}
+	case s.accept("%enum."):
+		return &EnumType{Name: s.name()}
+	}
+
+	name := s.name()
+	if name == "str" {
+		return &StrType{}
+	}
+
+	return &PrimitiveType{Name: name}
+}
+
+// ptrTo reads T* and returns T
+func (s *scanner) ptrTo() Type {
+	t := s.typ()
+	s.expect("*")
+
+	return t
+}
+
+func (s *scanner) structType() *StructType {
+	t, ok := s.typ().(*StructType)
+	if !ok && s.err == nil {
+		s.fail("expected a struct type")
+	}
+
+	return t
+}
+
+func (s *scanner) enumType() *EnumType {
+	t, ok := s.typ().(*EnumType)
+	if !ok && s.err == nil {
+		s.fail("expected an enum type")
+	}
+
+	return t
+}
+
+// structDef reads %struct.N = type { T, ... }
+func (s *scanner) structDef() *StructType {
+	st := s.structType()
+	if st == nil {
+		return nil
+	}
+
+	s.expect("=")
+	s.expect("type")
+	s.expect("{")
+	s.list("}", func() { st.Fields = append(st.Fields, s.typ()) })
+	s.end()
+
+	return st
+}
+
+// enumDef reads %enum.N = enum { V = tag (T, ...), ... }
+func (s *scanner) enumDef() *EnumType {
+	e := s.enumType()
+	if e == nil {
+		return nil
+	}
+
+	s.expect("=")
+	s.expect("enum")
+	s.expect("{")
+	s.list("}", func() {
+		v := Variant{Name: s.name()}
+		s.expect("=")
+		v.Tag = s.int()
+		s.expect("(")
+		s.list(")", func() { v.Payload = append(v.Payload, s.typ()) })
+		e.Variants = append(e.Variants, v)
+	})
+	s.end()
+
+	return e
+}
+
+// global reads @name = "string", @name = vtable [fn, ...] or
+// @name = const T value
+func (s *scanner) global() Global {
+	s.expect("@")
+	name := s.name()
+	s.expect("=")
+
+	if s.accept("vtable") {
+		vt := &GlobalVTable{Name: name}
+		s.expect("[")
+		s.list("]", func() { vt.Methods = append(vt.Methods, s.name()) })
+		s.end()
+
+		return vt
+	}
+
+	if s.accept("const") {
+		c := &GlobalConst{Name: name, Type: s.typ()}
+		c.Value = s.name()
+		s.end()
+
+		return c
+	}
+
+	s.skipSpace()
+
+	value, err := strconv.Unquote(s.rest())
+	if err != nil {
+		s.fail("expected a quoted string, got %q", s.rest())
+	}
+
+	return &GlobalString{Name: name, Value: value}
+}
+
+// signature reads define T @name(T %p, ...) {
+func (s *scanner) signature() *Function {
+	s.expect("define")
+	fn := &Function{Inline: s.accept("inline "), Params: []Param{}, Blocks: []*BasicBlock{}}
+	fn.RetTy = s.typ()
+	s.expect("@")
+	fn.Name = s.name()
+	s.expect("(")
+	s.list(")", func() {
+		typ := s.typ()
+		fn.Params = append(fn.Params, Param{Name: s.value(), Type: typ})
+	})
+	s.expect("{")
+	s.end()
+
+	return fn
+}
+
+var (
+	opKinds   = reverse(opNames)
+	castKinds = reverse(castNames)
+)
+
+func reverse[K comparable](names map[K]string) map[string]K {
+	kinds := make(map[string]K, len(names))
+	for k, name := range names {
+		kinds[name] = k
+	}
+
+	return kinds
+}
+
+// instr reads one instruction
+func (s *scanner) instr() Instruction {
+	if s.accept("%") {
+		dest := s.name()
+		s.expect("=")
+
+		return s.assign(dest)
+	}
+
+	op := s.name()
+
+	switch op {
+	case "store":
+		st := &Store{Volatile: s.accept("volatile ")}
+		st.Type = s.typ()
+		st.Value = s.value()
+		s.expect(",")
+		s.ptrTo()
+		st.Dest = s.value()
+
+		return st
+	case "call":
+		return s.call("")
+	case "vcall":
+		return s.vcall("")
+	case "icall":
+		return s.icall("")
+	case "ret":
+		if s.accept("void") {
+			return &Ret{Type: &PrimitiveType{Name: "void"}}
+		}
+
+		typ := s.typ()
+
+		return &Ret{Type: typ, Value: s.value()}
+	case "unreachable":
+		return &Unreachable{}
+	case "br":
+		if s.accept("i1") {
+			br := &CondBr{Cond: s.value()}
+			s.expect(",")
+			br.TrueLabel = s.label()
+			s.expect(",")
+			br.FalseLabel = s.label()
+
+			return br
+		}
+
+		return &Br{Label: s.label()}
+	case "defer_push":
+		var call *Call
+
+		if s.accept("%") {
+			dest := s.name()
+			s.expect("=")
+			s.expect("call")
+			call = s.call(dest)
+		} else {
+			s.expect("call")
+			call = s.call("")
+		}
+
+		return &DeferPush{Call: call}
+	case "defer_run_all":
+		return &DeferRunAll{}
+	}
+
+	s.fail("unknown instruction %q", op)
+
+	return nil
+}
+
+// assign reads the rest of an instruction that defines dest
+func (s *scanner) assign(dest string) Instruction {
+	op := s.name()
+
+	if kind, ok := opKinds[op]; ok {
+		b := &BinOp{Dest: dest, Op: kind, Type: s.typ()}
+		b.Left = s.value()
+		s.expect(",")
+		b.Right = s.value()
+
+		return b
+	}
+
+	if kind, ok := castKinds[op]; ok {
+		c := &Cast{Dest: dest, Kind: kind, From: s.typ()}
+		c.Value = s.value()
+		s.expect("to")
+		c.To = s.typ()
+
+		return c
+	}
+
+	switch op {
+	case "alloca":
+		return &Alloca{Name: dest, Type: s.typ()}
+	case "load":
+		l := &Load{Dest: dest, Volatile: s.accept("volatile ")}
+		l.Type = s.typ()
+		s.expect(",")
+		s.ptrTo()
+		l.Source = s.value()
+
+		return l
+	case "addrof":
+		a := &AddrOf{Dest: dest, Type: s.ptrTo()}
+		a.Source = s.value()
+
+		return a
+	case "tuple":
+		t := &MakeTuple{Dest: dest}
+		if tuple, ok := s.typ().(*TupleType); ok {
+			t.Type = tuple
+		} else {
+			s.fail("expected a tuple type")
+		}
+
+		s.expect("(")
+		s.list(")", func() { t.Elems = append(t.Elems, s.value()) })
+
+		return t
+	case "slice":
+		p := &PackSlice{Dest: dest, Elem: s.typ()}
+		s.expect("[")
+		s.list("]", func() { p.Elems = append(p.Elems, s.value()) })
+
+		return p
+	case "extract":
+		e := &Extract{Dest: dest, Type: s.typ()}
+		e.Tuple = s.value()
+		s.expect(",")
+		e.Index = int(s.int())
+
+		return e
+	case "insert":
+		i := &Insert{Dest: dest, Type: s.structType()}
+		if !s.accept("zeroinitializer") {
+			i.Agg = s.value()
+		}
+
+		s.expect(",")
+		i.Elem = s.typ()
+		i.Value = s.value()
+		s.expect(",")
+		i.Index = int(s.int())
+
+		return i
+	case "fieldaddr":
+		f := &FieldAddr{Dest: dest, Type: s.ptrTo()}
+		f.Base = s.value()
+		s.expect(",")
+		f.Index = int(s.int())
+
+		return f
+	case "elemaddr":
+		e := &ElemAddr{Dest: dest, Type: s.ptrTo()}
+		e.Base = s.value()
+		s.expect(",")
+		e.Index = s.value()
+
+		return e
+	case "tagaddr":
+		t := &TagAddr{Dest: dest, Type: s.enumType()}
+		s.expect("*")
+		t.Base = s.value()
+
+		return t
+	case "payloadaddr":
+		p := &PayloadAddr{Dest: dest, Type: s.enumType()}
+		s.expect("*")
+		p.Base = s.value()
+		s.expect(",")
+		p.Variant = int(s.int())
+		s.expect(",")
+		p.Index = int(s.int())
+
+		return p
+	case "tag":
+		t := &EnumTag{Dest: dest, Type: s.enumType()}
+		t.Value = s.value()
+
+		return t
+	case "phi":
+		p := &Phi{Dest: dest, Type: s.typ()}
+
+		for ok := true; ok; ok = s.accept(",") {
+			in := Incoming{}
+			s.expect("[")
+			in.Value = s.value()
+			s.expect(",")
+			s.expect("%bb_")
+			in.Label = s.name()
+			s.expect("]")
+			p.Incoming = append(p.Incoming, in)
+		}
+
+		return p
+	case "call":
+		return s.call(dest)
+	case "vcall":
+		return s.vcall(dest)
+	case "icall":
+		return s.icall(dest)
+	case "funcref":
+		f := &FuncRef{Dest: dest, Type: s.funcType()}
+		s.expect("@")
+		f.Func = s.name()
+
+		return f
+	case "sizeof":
+		return &SizeOf{Dest: dest, Type: s.typ()}
+	}
+
+	s.fail("unknown instruction %q", op)
+
+	return nil
+}
+
+// call reads T @callee(args) after the call keyword. Arguments are
+// registers, immediates or quoted strings, as Call.String prints them.
+func (s *scanner) call(dest string) *Call {
+	c := &Call{Dest: dest, RetTy: s.typ()}
+	s.expect("@")
+	c.Callee = s.name()
+	c.Args = s.args()
+
+	return c
+}
+
+// args reads the parenthesized arguments of a call
+func (s *scanner) args() []string {
+	var args []string
+
+	s.expect("(")
+	s.list(")", func() {
+		switch {
+		case s.accept("%"):
+			args = append(args, s.name())
+		case strings.HasPrefix(s.rest(), `"`):
+			args = append(args, s.quoted())
+		default:
+			args = append(args, s.name())
+		}
+	})
+
+	return args
+}
+
+// funcType reads a function type
+func (s *scanner) funcType() *FuncType {
+	fn, ok := s.typ().(*FuncType)
+	if !ok {
+		s.fail("expected a function type")
+		return &FuncType{Ret: &PrimitiveType{Name: "void"}}
+	}
+
+	return fn
+}
+
+// icall reads fn(T) R %callee(args) after the icall keyword
+func (s *scanner) icall(dest string) *IndirectCall {
+	c := &IndirectCall{Dest: dest, Type: s.funcType()}
+	c.Callee = s.value()
+	c.Args = s.args()
+
+	return c
+}
+
+// quoted reads a Go string literal and returns it with its quotes
+func (s *scanner) quoted() string {
+	lit, err := strconv.QuotedPrefix(s.rest())
+	if err != nil {
+		s.fail("bad string %q", s.rest())
+		return ""
+	}
+
+	s.pos += len(lit)
+
+	return lit
+}
+
+// vcall reads T %vtable[slot](%object, T arg, ...) after the vcall keyword
+func (s *scanner) vcall(dest string) *VCall {
+	v := &VCall{Dest: dest, RetTy: s.typ()}
+	v.VTable = s.value()
+	s.expect("[")
+	v.Slot = int(s.int())
+	s.expect("]")
+	s.expect("(")
+	v.Object = s.value()
+
+	for s.accept(",") {
+		v.ArgTys = append(v.ArgTys, s.typ())
+		v.Args = append(v.Args, s.value())
+	}
+
+	s.expect(")")
+
+	return v
+}
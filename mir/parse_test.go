@@ -0,0 +1,226 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "control flow and calls",
+			input: `fn fib(n i32) i32 {
+				if n < 2 {
+					return n
+				}
+				return fib(n - 1) + fib(n - 2)
+			}
+
+			fn main() {
+				let mut i = 0
+				while i < 10 {
+					println(fib(i))
+					i += 1
+				}
+				println("done")
+			}`,
+		},
+		{
+			name: "structs, enums and tuples",
+			input: `struct P { x: i32, y: i64 }
+			enum Shape { Dot, Rect(i32, i64) }
+
+			fn f(n &mut i32, xs ...i32) (i32, i64) {
+				let mut p = P { x: 1, y: 2 }
+				p.x *= xs[0]
+				*n -= 1
+				let s = Shape::Rect(p.x, p.y)
+				let t = (p.x, p.y)
+				return (t.0, p.y)
+			}`,
+		},
+		{
+			name: "defer and propagation",
+			input: `fn half(n i32) Result<i32, i64> {
+				defer println(n)
+				if n % 2 == 1 {
+					return Err(n as i64)
+				}
+				return Ok(n / 2)
+			}
+
+			fn quarter(n i32) Result<i32, i64> {
+				let h = half(n)?
+				return half(h)
+			}`,
+		},
+		{
+			name: "file-level constants",
+			input: `const NAME: str = "yar"
+			const RATE: f64 = -0.5
+			const ON: bool = true
+
+			fn main() {
+				if ON {
+					println(NAME)
+					println(RATE)
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.input))
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := checker.NewChecker()
+			if err := c.CheckFile(file); err != nil {
+				t.Fatalf("checker error: %v", err)
+			}
+
+			lowerer := NewLowerer()
+			lowerer.SetTypes(c.Types())
+			want := lowerer.LowerFile(file).String()
+
+			m, err := Parse(want)
+			if err != nil {
+				t.Fatalf("parse error: %v\n%s", err, want)
+			}
+
+			if got := m.String(); got != want {
+				t.Errorf("round trip changed the module\nwant:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestParseInstructions(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	i64 := &PrimitiveType{Name: "i64"}
+	ptr := &PtrType{Elem: &PrimitiveType{Name: "i8"}}
+
+	m := &Module{
+		Globals: []Global{
+			&GlobalString{Name: ".str.1", Value: "a \"quoted\"\nline"},
+			&GlobalVTable{Name: VTableName("Show", "P"), Methods: []string{"P.show", "P.size"}},
+		},
+		Functions: []*Function{{
+			Name:   "g",
+			Params: []Param{{Name: "obj", Type: ptr}, {Name: "vt", Type: &PtrType{Elem: ptr}}},
+			RetTy:  &PrimitiveType{Name: "void"},
+			Inline: true,
+			Blocks: []*BasicBlock{{
+				Label: "entry_1",
+				Instrs: []Instruction{
+					&Alloca{Name: "x", Type: &ArrayType{Elem: i32, Len: 4}},
+					&Load{Dest: "t1", Source: "x", Type: i32, Volatile: true},
+					&Store{Value: "0", Dest: "x", Type: i32, Volatile: true},
+					&Cast{Dest: "t2", Kind: SExt, Value: "t1", From: i32, To: i64},
+					&Phi{Dest: "p", Type: i32, Incoming: []Incoming{{Value: "t1", Label: "entry_1"}, {Value: "0", Label: "loop_2"}}},
+					&PackSlice{Dest: "t3", Elems: []string{"t1", "7"}, Elem: i32},
+					&VCall{Dest: "t4", VTable: "vt", Slot: 1, Object: "obj", Args: []string{"t2"}, ArgTys: []Type{i64}, RetTy: i64},
+					&VCall{VTable: "vt", Slot: 0, Object: "obj", RetTy: &PrimitiveType{Name: "void"}},
+					&FuncRef{Dest: "t6", Func: "f", Type: &FuncType{Params: []Type{i32, ptr}, Ret: i64}},
+					&IndirectCall{Dest: "t7", Callee: "t6", Args: []string{"t1", "obj"}, Type: &FuncType{Params: []Type{i32, ptr}, Ret: i64}},
+					&IndirectCall{Callee: "t8", Type: &FuncType{Ret: &PrimitiveType{Name: "void"}}},
+					&SizeOf{Dest: "t9", Type: &TupleType{Elems: []Type{i32, i64}}},
+					&DeferPush{Call: &Call{Dest: "t5", Callee: "puts", Args: []string{"@.str.1", "-1", `"a, (b)"`}, RetTy: i32}},
+					&Call{Callee: "println", Args: []string{"t1"}, RetTy: &PrimitiveType{Name: "void"}},
+					&DeferRunAll{},
+					&Ret{Type: &PrimitiveType{Name: "void"}},
+				},
+			}},
+		}},
+	}
+
+	want := m.String()
+
+	got, err := Parse(want)
+	if err != nil {
+		t.Fatalf("parse error: %v\n%s", err, want)
+	}
+
+	if got.String() != want {
+		t.Errorf("round trip changed the module\nwant:\n%s\ngot:\n%s", want, got.String())
+	}
+}
+
+func TestParseFixture(t *testing.T) {
+	src := `
+define i32 @inc(i32 %n) {
+bb_entry_1:
+  %t1 = add i32 %n, %1
+  ret i32 %t1
+}
+`
+
+	m, err := Parse(src)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	fn := m.Function("inc")
+	if fn == nil || len(fn.Blocks) != 1 || len(fn.Blocks[0].Instrs) != 2 {
+		t.Fatalf("unexpected module:\n%s", m)
+	}
+
+	add, ok := fn.Blocks[0].Instrs[0].(*BinOp)
+	if !ok || add.Op != Add || add.Left != "n" || add.Right != "1" || add.Dest != "t1" {
+		t.Errorf("unexpected instruction %s", fn.Blocks[0].Instrs[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "unknown instruction",
+			input: "define void @f() {\nbb_entry_1:\n  %t1 = frob i32 %x\n}",
+			want:  `line 3: unknown instruction "frob"`,
+		},
+		{
+			name:  "instruction outside a block",
+			input: "define void @f() {\n  ret void\n}",
+			want:  "line 2: instruction outside a block",
+		},
+		{
+			name:  "missing brace",
+			input: "define void @f() {\nbb_entry_1:\n  ret void",
+			want:  "line 1: function f has no closing brace",
+		},
+		{
+			name:  "trailing text",
+			input: "define void @f() {\nbb_entry_1:\n  ret void void\n}",
+			want:  `line 3: unexpected "void"`,
+		},
+		{
+			name:  "not a declaration",
+			input: "hello",
+			want:  "line 1: expected a type, global or function",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
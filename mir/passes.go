@@ -0,0 +1,219 @@
+package mir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pass is a named transformation of a module
+type Pass struct {
+	Name  string // as written in a pipeline, with its argument if any
+	Run   func(m *Module)
+	After []string // passes that must run earlier in the pipeline
+}
+
+// PassTiming records how long one pass of a pipeline ran
+type PassTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// PassManager runs passes over a module in the order they were added
+type PassManager struct {
+	passes  []Pass
+	timings []PassTiming
+	dump    func(stage string, m *Module)
+}
+
+// NewPassManager returns a pipeline of the given passes
+func NewPassManager(passes ...Pass) *PassManager {
+	return &PassManager{passes: passes}
+}
+
+// Add appends a pass to the pipeline
+func (pm *PassManager) Add(p Pass) {
+	pm.passes = append(pm.passes, p)
+}
+
+// Has reports whether the pipeline runs the pass of the given base name,
+// whatever its argument
+func (pm *PassManager) Has(name string) bool {
+	for _, p := range pm.passes {
+		if baseName(p.Name) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String spells the pipeline as ParsePipeline reads it
+func (pm *PassManager) String() string {
+	names := make([]string, len(pm.passes))
+	for i, p := range pm.passes {
+		names[i] = p.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Verify checks that each pass comes after the passes it needs
+func (pm *PassManager) Verify() error {
+	ran := make(map[string]bool)
+
+	for _, p := range pm.passes {
+		for _, before := range p.After {
+			if !ran[before] {
+				return fmt.Errorf("pass %s must run after %s", baseName(p.Name), before)
+			}
+		}
+
+		ran[baseName(p.Name)] = true
+	}
+
+	return nil
+}
+
+// SetDump has Run show the module to dump before and after each pass, with
+// the stage named as "before dce" or "after dce"
+func (pm *PassManager) SetDump(dump func(stage string, m *Module)) {
+	pm.dump = dump
+}
+
+// Run applies the passes to m in order, timing each
+func (pm *PassManager) Run(m *Module) {
+	pm.timings = pm.timings[:0]
+
+	for _, p := range pm.passes {
+		if pm.dump != nil {
+			pm.dump("before "+p.Name, m)
+		}
+
+		start := time.Now()
+		p.Run(m)
+		pm.timings = append(pm.timings, PassTiming{Name: p.Name, Duration: time.Since(start)})
+
+		if pm.dump != nil {
+			pm.dump("after "+p.Name, m)
+		}
+	}
+}
+
+// Timings returns how long each pass took in the last Run
+func (pm *PassManager) Timings() []PassTiming {
+	return pm.timings
+}
+
+// defaultInlineThreshold is the size in instructions up to which the inline
+// pass inlines a function when the pipeline gives no threshold
+const defaultInlineThreshold = 10
+
+// namedPasses are the passes a pipeline can name. Each takes the argument
+// written after = in the pipeline, or "" when there is none.
+var namedPasses = map[string]func(arg string) (Pass, error){
+	"dce": func(arg string) (Pass, error) {
+		return Pass{Name: "dce", Run: EliminateDeadCode}, noArg("dce", arg)
+	},
+	"inline": func(arg string) (Pass, error) {
+		threshold := defaultInlineThreshold
+
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return Pass{}, fmt.Errorf("inline threshold must be a non-negative integer, not %q", arg)
+			}
+
+			threshold = n
+		}
+
+		return inlinePass(threshold), nil
+	},
+	"mem2reg": func(arg string) (Pass, error) {
+		return Pass{Name: "mem2reg", Run: Mem2Reg, After: []string{"dce"}}, noArg("mem2reg", arg)
+	},
+}
+
+// inlinePass inlines functions of at most threshold instructions
+func inlinePass(threshold int) Pass {
+	return Pass{
+		Name:  fmt.Sprintf("inline=%d", threshold),
+		Run:   func(m *Module) { Inline(m, threshold) },
+		After: []string{"dce"},
+	}
+}
+
+func noArg(name, arg string) error {
+	if arg != "" {
+		return fmt.Errorf("pass %s takes no argument", name)
+	}
+
+	return nil
+}
+
+// LookupPass returns the pass spelled name or name=arg
+func LookupPass(spec string) (Pass, error) {
+	name, arg, _ := strings.Cut(spec, "=")
+
+	newPass, ok := namedPasses[name]
+	if !ok {
+		return Pass{}, fmt.Errorf("unknown pass %q", name)
+	}
+
+	return newPass(arg)
+}
+
+// ParsePipeline reads passes separated by commas, as in
+// "dce,inline=40,mem2reg", and checks their order
+func ParsePipeline(spec string) (*PassManager, error) {
+	pm := NewPassManager()
+
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		p, err := LookupPass(name)
+		if err != nil {
+			return nil, err
+		}
+
+		pm.Add(p)
+	}
+
+	if err := pm.Verify(); err != nil {
+		return nil, err
+	}
+
+	return pm, nil
+}
+
+// Pipeline returns the passes of an optimization level. Every level removes
+// dead code; -O1 and -O2 inline small functions, -O2 larger ones too.
+func Pipeline(level int) *PassManager {
+	switch level {
+	case 0:
+		return mustParse("dce")
+	case 1:
+		return mustParse("dce,inline=10")
+	case 2:
+		return mustParse("dce,inline=40")
+	default:
+		panic(fmt.Sprintf("no optimization level %d", level))
+	}
+}
+
+func mustParse(spec string) *PassManager {
+	pm, err := ParsePipeline(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	return pm
+}
+
+func baseName(name string) string {
+	base, _, _ := strings.Cut(name, "=")
+	return base
+}
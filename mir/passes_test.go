@@ -0,0 +1,136 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string // the pipeline as it prints, or the error
+	}{
+		{"dce", "dce"},
+		{"dce,inline,mem2reg", "dce,inline=10,mem2reg"},
+		{" dce , inline=40 ,", "dce,inline=40"},
+		{"", ""},
+		{"dce,unroll", `unknown pass "unroll"`},
+		{"dce,inline=big", `inline threshold must be a non-negative integer, not "big"`},
+		{"dce=1", "pass dce takes no argument"},
+		{"inline,dce", "pass inline must run after dce"},
+		{"mem2reg", "pass mem2reg must run after dce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			pm, err := ParsePipeline(tt.spec)
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = pm.String()
+			}
+
+			if got != tt.want {
+				t.Errorf("ParsePipeline(%q) = %q, want %q", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelinePresets(t *testing.T) {
+	want := map[int]string{0: "dce", 1: "dce,inline=10", 2: "dce,inline=40"}
+
+	for level, spec := range want {
+		if got := Pipeline(level).String(); got != spec {
+			t.Errorf("-O%d runs %q, want %q", level, got, spec)
+		}
+	}
+}
+
+func TestPassManagerRun(t *testing.T) {
+	var order []string
+
+	record := func(name string) Pass {
+		return Pass{Name: name, Run: func(*Module) { order = append(order, name) }}
+	}
+
+	pm := NewPassManager(record("a"), record("b"))
+	pm.Add(record("c"))
+
+	if pm.Has("d") || !pm.Has("c") {
+		t.Fatalf("Has is wrong for pipeline %s", pm)
+	}
+
+	for range 2 {
+		order = nil
+		pm.Run(&Module{})
+
+		if strings.Join(order, ",") != "a,b,c" {
+			t.Errorf("passes ran in order %v", order)
+		}
+
+		timings := pm.Timings()
+		if len(timings) != 3 || timings[0].Name != "a" || timings[2].Name != "c" {
+			t.Errorf("timings of the last run are %v", timings)
+		}
+	}
+}
+
+func TestPassManagerDump(t *testing.T) {
+	var stages []string
+
+	pm := NewPassManager(Pass{Name: "a", Run: func(*Module) {}}, Pass{Name: "b", Run: func(*Module) {}})
+	pm.SetDump(func(stage string, _ *Module) { stages = append(stages, stage) })
+	pm.Run(&Module{})
+
+	if got := strings.Join(stages, ","); got != "before a,after a,before b,after b" {
+		t.Errorf("dumped stages %s", got)
+	}
+}
+
+func TestPipelineOptimizes(t *testing.T) {
+	src := `
+fn one() i32 {
+	return 1
+}
+
+fn main() {
+	x := one()
+	println(x)
+	return
+	println(2)
+}
+`
+
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	m := lowerer.LowerFile(file)
+	Pipeline(2).Run(m)
+
+	out := m.String()
+	if strings.Contains(out, "call i32 @one") {
+		t.Errorf("-O2 left the call to one:\n%s", out)
+	}
+
+	if strings.Contains(out, "%2") {
+		t.Errorf("-O2 left code after the return:\n%s", out)
+	}
+}
@@ -0,0 +1,192 @@
+package mir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// preludeVariants maps each variant of the prelude's Option and Result to
+// its type
+var preludeVariants = map[string]string{
+	"Some": "Option",
+	"None": "Option",
+	"Ok":   "Result",
+	"Err":  "Result",
+}
+
+// resultType is the enum Result<ok, err> is laid out as: Ok(ok) or Err(err),
+// named Result.ok.err
+func (l *Lowerer) resultType(ok, err Type) *EnumType {
+	return l.instance("Result."+typeName(ok)+"."+typeName(err), []Variant{
+		{Name: "Ok", Tag: 0, Payload: []Type{ok}},
+		{Name: "Err", Tag: 1, Payload: []Type{err}},
+	})
+}
+
+// optionType is the enum Option<elem> is laid out as: None or Some(elem)
+func (l *Lowerer) optionType(elem Type) *EnumType {
+	return l.instance("Option."+typeName(elem), []Variant{
+		{Name: "None", Tag: 0},
+		{Name: "Some", Tag: 1, Payload: []Type{elem}},
+	})
+}
+
+// typeName spells t for an instance name, which becomes a symbol and so
+// sticks to letters, digits and underscores
+func typeName(t Type) string {
+	switch t := t.(type) {
+	case *StructType:
+		return t.Name
+	case *EnumType:
+		return t.Name
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, t.String())
+}
+
+// instance returns the enum for one instantiation of Option or Result,
+// adding it and its constructors to the module the first time it is used
+func (l *Lowerer) instance(name string, variants []Variant) *EnumType {
+	if _, ok := l.enums[name]; !ok {
+		e := &EnumType{Name: name, Variants: variants}
+		l.enums[name] = e
+		l.module.Enums = append(l.module.Enums, e)
+
+		for i := range variants {
+			fn := constructor(e, i)
+			l.signatures[fn.Name] = fn
+			l.module.Functions = append(l.module.Functions, fn)
+		}
+	}
+
+	return &EnumType{Name: name}
+}
+
+// preludeVariant resolves Some, None, Ok or Err, bare or as a path such as
+// Result::Ok, to the constructor for the type the checker found for expr.
+// It reports false for names a local or function shadows, and for values
+// whose type is still open.
+func (l *Lowerer) preludeVariant(expr ast.Expr, name ast.Expr) (*EnumType, string, bool) {
+	var variant string
+
+	switch n := name.(type) {
+	case *ast.Ident:
+		if _, ok := l.localType(n.Name); ok {
+			return nil, "", false
+		}
+
+		if _, ok := l.signatures[n.Name]; ok {
+			return nil, "", false
+		}
+
+		variant = n.Name
+	case *ast.PathExpr:
+		if len(n.Segments) != 2 || preludeVariants[n.Segments[1]] != n.Segments[0] {
+			return nil, "", false
+		}
+
+		variant = n.Segments[1]
+	default:
+		return nil, "", false
+	}
+
+	if _, ok := preludeVariants[variant]; !ok {
+		return nil, "", false
+	}
+
+	typ, ok := l.fromChecker(l.types[expr])
+	if !ok {
+		return nil, "", false
+	}
+
+	e, ok := typ.(*EnumType)
+	if !ok {
+		return nil, "", false
+	}
+
+	return e, Constructor(e.Name, variant), true
+}
+
+// lowerPropagateExpr lowers x? on a Result or an Option. The tag picks the
+// path: Err and None return from the function, rebuilt in its return type,
+// and Ok and Some continue with their payload.
+func (l *Lowerer) lowerPropagateExpr(expr *ast.PropagateExpr) string {
+	typ, ok := l.typeOf(expr.Expr).(*EnumType)
+	if !ok {
+		return "undef"
+	}
+
+	ret, ok := l.currentFn.RetTy.(*EnumType)
+	if !ok {
+		return "undef"
+	}
+
+	e := l.enums[typ.Name]
+
+	okIdx, failIdx := 0, 1
+	if e.Variants[0].Name == "None" {
+		okIdx, failIdx = 1, 0
+	}
+
+	// The value is kept in memory so its payloads can be read in place
+	val := l.lowerExpr(expr.Expr)
+	slot := l.declare("try.val")
+	l.emit(&Alloca{Name: slot, Type: typ})
+	l.emit(&Store{Value: val, Dest: slot, Type: typ})
+
+	tag := l.newTemp()
+	l.emit(&EnumTag{Dest: tag, Value: val, Type: typ})
+
+	failed := l.newTemp()
+	l.emit(&BinOp{
+		Dest:  failed,
+		Op:    Eq,
+		Left:  tag,
+		Right: fmt.Sprint(e.Variants[failIdx].Tag),
+		Type:  &PrimitiveType{Name: "i32"},
+	})
+
+	errorBlock := l.newBB("error")
+	okBlock := l.newBB("ok")
+	l.emit(&CondBr{Cond: failed, TrueLabel: errorBlock.Label, FalseLabel: okBlock.Label})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, errorBlock)
+	l.currentBB = errorBlock
+
+	fail := e.Variants[failIdx]
+	args := make([]string, len(fail.Payload))
+
+	for i, t := range fail.Payload {
+		args[i] = l.loadPayload(slot, typ, failIdx, i, t)
+	}
+
+	result := l.newTemp()
+	l.emit(&Call{Dest: result, Callee: Constructor(ret.Name, fail.Name), Args: args, RetTy: ret})
+	l.emit(&DeferRunAll{})
+	l.emit(&Ret{Value: result, Type: ret})
+
+	l.currentFn.Blocks = append(l.currentFn.Blocks, okBlock)
+	l.currentBB = okBlock
+
+	return l.loadPayload(slot, typ, okIdx, 0, e.Variants[okIdx].Payload[0])
+}
+
+// loadPayload loads value index of variant from an enum held in slot
+func (l *Lowerer) loadPayload(slot string, typ *EnumType, variant, index int, elem Type) string {
+	addr := l.newTemp()
+	l.emit(&PayloadAddr{Dest: addr, Base: slot, Variant: variant, Index: index, Type: typ})
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: addr, Type: elem})
+
+	return result
+}
@@ -0,0 +1,177 @@
+package mir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/consteval"
+)
+
+// scope maps source names to the stack slots and nested functions they
+// denote in one lexical block of the function being lowered
+type scope struct {
+	vars   map[string]string
+	fns    map[string]*lifted
+	consts map[string]int64
+	global map[string]*GlobalConst // file-level constants that are not integers
+	folded map[string]ast.Expr     // file-level constants lowered at each use
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		vars:   make(map[string]string),
+		fns:    make(map[string]*lifted),
+		consts: make(map[string]int64),
+		global: make(map[string]*GlobalConst),
+		folded: make(map[string]ast.Expr),
+		parent: parent,
+	}
+}
+
+func (l *Lowerer) pushScope() {
+	l.scope = newScope(l.scope)
+}
+
+func (l *Lowerer) popScope() {
+	l.scope = l.scope.parent
+}
+
+// declare introduces a variable in the innermost scope and returns its slot.
+// The first variable with a given name in a function keeps that name; later
+// ones, which shadow it or live in sibling blocks, get a numbered suffix.
+func (l *Lowerer) declare(name string) string {
+	slot := name
+	if n := l.slots[name]; n > 0 {
+		slot = fmt.Sprintf("%s.%d", name, n)
+	}
+
+	l.slots[name]++
+	l.scope.vars[name] = slot
+
+	return slot
+}
+
+// slot resolves a variable to its stack slot. Names with no declaration in
+// scope are left alone.
+func (l *Lowerer) slot(name string) string {
+	for s := l.scope; s != nil; s = s.parent {
+		if slot, ok := s.vars[name]; ok {
+			return slot
+		}
+	}
+
+	return name
+}
+
+// nestedFunc resolves a name to a nested function in scope
+func (l *Lowerer) nestedFunc(name string) (*lifted, bool) {
+	for s := l.scope; s != nil; s = s.parent {
+		if fn, ok := s.fns[name]; ok {
+			return fn, true
+		}
+	}
+
+	return nil, false
+}
+
+// constant resolves a name to the value of an integer constant in scope
+func (l *Lowerer) constant(name string) (int64, bool) {
+	for s := l.scope; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return 0, false
+		}
+
+		if v, ok := s.consts[name]; ok {
+			return v, true
+		}
+	}
+
+	return 0, false
+}
+
+// defineConst records an integer constant in the innermost scope. It
+// reports false if value is not a constant expression.
+func (l *Lowerer) defineConst(name string, value ast.Expr) bool {
+	v, err := consteval.Eval(value, l.constant)
+	if err != nil {
+		return false
+	}
+
+	l.scope.consts[name] = v
+
+	return true
+}
+
+// defineGlobalConst lowers a file-level constant that is not an integer. A
+// literal value becomes a GlobalConst; any other value is lowered again
+// wherever the constant is used.
+func (l *Lowerer) defineGlobalConst(d *ast.ConstDecl) {
+	typ := l.lowerType(d.Type)
+
+	value, ok := "", true
+
+	switch v := d.Value.(type) {
+	case *ast.StringLit:
+		value = l.lowerExpr(v)
+	default:
+		value, ok = immediate(d.Value)
+	}
+
+	if !ok {
+		l.globals.folded[d.Name] = d.Value
+		return
+	}
+
+	g := &GlobalConst{Name: "const." + d.Name, Type: typ, Value: value}
+	l.module.Globals = append(l.module.Globals, g)
+	l.globals.global[d.Name] = g
+}
+
+// immediate returns the MIR operand of a literal, which may be negated
+func immediate(e ast.Expr) (string, bool) {
+	switch e := e.(type) {
+	case *ast.IntLit, *ast.FloatLit:
+		return e.String(), true
+	case *ast.BoolLit:
+		if e.Value {
+			return "1", true
+		}
+
+		return "0", true
+	case *ast.UnaryExpr:
+		if v, ok := immediate(e.Expr); ok && e.Op == "-" && !strings.HasPrefix(v, "-") {
+			if _, isBool := e.Expr.(*ast.BoolLit); !isBool {
+				return "-" + v, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// fileConst resolves a name to a file-level constant that is not an
+// integer, unless a variable in scope shadows it. It returns the global
+// holding the constant, or else the expression to lower in its place.
+func (l *Lowerer) fileConst(name string) (*GlobalConst, ast.Expr, bool) {
+	for s := l.scope; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return nil, nil, false
+		}
+
+		if _, ok := s.consts[name]; ok {
+			return nil, nil, false
+		}
+
+		if g, ok := s.global[name]; ok {
+			return g, nil, true
+		}
+
+		if e, ok := s.folded[name]; ok {
+			return nil, e, true
+		}
+	}
+
+	return nil, nil, false
+}
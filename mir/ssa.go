@@ -0,0 +1,401 @@
+package mir
+
+import "fmt"
+
+// Mem2Reg turns the locals and parameters of each function that are only
+// ever loaded and stored whole into SSA values. Loads become the value last
+// stored, and where paths with different stores meet a phi picks between
+// them. Locals whose address is taken, or that are reached through a field
+// or element address, stay in memory.
+func Mem2Reg(m *Module) {
+	for _, fn := range m.Functions {
+		mem2reg(fn)
+	}
+}
+
+func mem2reg(fn *Function) {
+	if len(fn.Blocks) == 0 {
+		return
+	}
+
+	g := fn.CFG()
+	g.removeUnreachable(fn)
+
+	vars := promotable(fn)
+	if len(vars) == 0 {
+		return
+	}
+
+	r := &renamer{
+		cfg:    g,
+		vars:   vars,
+		stacks: make(map[string][]string),
+		repl:   make(map[string]string),
+		phis:   placePhis(fn, g, vars),
+	}
+
+	for name, v := range vars {
+		r.stacks[name] = []string{v.initial}
+	}
+
+	r.rename(fn.Blocks[0].Label)
+	removeDeadPhis(fn)
+
+	// A parameter's slot is read once on entry for the argument
+	used := make(map[string]bool)
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			for _, op := range operands(instr) {
+				used[*op] = true
+			}
+		}
+	}
+
+	var args []Instruction
+
+	for _, p := range fn.Params {
+		if v, ok := vars[p.Name]; ok && used[v.initial] {
+			args = append(args, &Load{Dest: v.initial, Source: p.Name, Type: p.Type})
+		}
+	}
+
+	entry := fn.Blocks[0]
+	entry.Instrs = append(args, entry.Instrs...)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// variable is a local or parameter being promoted, with the value it holds
+// before any store: the argument for a parameter, or undef for a local
+type variable struct {
+	typ     Type
+	initial string
+}
+
+// promotable finds the locals and parameters that are only loaded and
+// stored, never volatile, and always as the type they were declared with
+func promotable(fn *Function) map[string]*variable {
+	vars := make(map[string]*variable)
+
+	for _, p := range fn.Params {
+		vars[p.Name] = &variable{typ: p.Type, initial: p.Name + ".arg"}
+	}
+
+	declared := make(map[string]bool)
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			if a, ok := instr.(*Alloca); ok {
+				if declared[a.Name] {
+					delete(vars, a.Name)
+					continue
+				}
+
+				declared[a.Name] = true
+				vars[a.Name] = &variable{typ: a.Type, initial: "undef"}
+			}
+		}
+	}
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			for _, op := range operands(instr) {
+				v, ok := vars[*op]
+				if !ok {
+					continue
+				}
+
+				switch i := instr.(type) {
+				case *Load:
+					if op == &i.Source && !i.Volatile && i.Type.String() == v.typ.String() {
+						continue
+					}
+				case *Store:
+					if op == &i.Dest && !i.Volatile && i.Type.String() == v.typ.String() {
+						continue
+					}
+				}
+
+				delete(vars, *op)
+			}
+		}
+	}
+
+	return vars
+}
+
+// placePhis adds a phi for each variable at the iterated dominance frontier
+// of the blocks that store to it, and returns the variable each phi is for
+func placePhis(fn *Function, g *CFG, vars map[string]*variable) map[*Phi]string {
+	phis := make(map[*Phi]string)
+
+	stores := make(map[string][]string)
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			if st, ok := instr.(*Store); ok {
+				if _, ok := vars[st.Dest]; ok && !contains(stores[st.Dest], bb.Label) {
+					stores[st.Dest] = append(stores[st.Dest], bb.Label)
+				}
+			}
+		}
+	}
+
+	// Visit variables in block order so phis are placed the same way on
+	// every run
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			name := ""
+
+			switch i := instr.(type) {
+			case *Alloca:
+				name = i.Name
+			case *Store:
+				name = i.Dest
+			}
+
+			v, ok := vars[name]
+			if !ok || stores[name] == nil {
+				continue
+			}
+
+			work := stores[name]
+			delete(stores, name)
+
+			placed := make(map[string]bool)
+
+			for len(work) > 0 {
+				b := work[0]
+				work = work[1:]
+
+				for _, f := range g.Frontier[b] {
+					if placed[f] {
+						continue
+					}
+
+					placed[f] = true
+
+					phi := &Phi{Dest: fmt.Sprintf("%s.%s", name, f), Type: v.typ}
+					join := g.Blocks[f]
+					join.Instrs = append([]Instruction{phi}, join.Instrs...)
+					phis[phi] = name
+
+					work = append(work, f)
+				}
+			}
+		}
+	}
+
+	return phis
+}
+
+// renamer walks the dominator tree, keeping for each variable a stack of
+// the values it holds on the way down
+type renamer struct {
+	cfg    *CFG
+	vars   map[string]*variable
+	stacks map[string][]string
+	repl   map[string]string // value each removed load stands for
+	phis   map[*Phi]string
+}
+
+func (r *renamer) top(name string) string {
+	stack := r.stacks[name]
+	return stack[len(stack)-1]
+}
+
+func (r *renamer) rename(label string) {
+	bb := r.cfg.Blocks[label]
+	pushed := make(map[string]int)
+
+	push := func(name, val string) {
+		r.stacks[name] = append(r.stacks[name], val)
+		pushed[name]++
+	}
+
+	kept := bb.Instrs[:0]
+
+	for _, instr := range bb.Instrs {
+		if phi, ok := instr.(*Phi); ok {
+			if name, ok := r.phis[phi]; ok {
+				push(name, phi.Dest)
+				kept = append(kept, instr)
+
+				continue
+			}
+		}
+
+		for _, op := range operands(instr) {
+			if val, ok := r.repl[*op]; ok {
+				*op = val
+			}
+		}
+
+		switch i := instr.(type) {
+		case *Alloca:
+			if _, ok := r.vars[i.Name]; ok {
+				continue
+			}
+		case *Load:
+			if _, ok := r.vars[i.Source]; ok {
+				// A constant other than an i32 keeps its type in a value
+				// of its own, or a call to the runtime would lose it
+				if val := r.top(i.Source); isImmediate(val) && untypedAs(i.Type) {
+					kept = append(kept, materialize(i.Dest, val, i.Type))
+					continue
+				}
+
+				r.repl[i.Dest] = r.top(i.Source)
+				continue
+			}
+		case *Store:
+			if _, ok := r.vars[i.Dest]; ok {
+				push(i.Dest, i.Value)
+				continue
+			}
+		}
+
+		kept = append(kept, instr)
+	}
+
+	bb.Instrs = kept
+
+	for _, s := range r.cfg.Succs[label] {
+		join, ok := r.cfg.Blocks[s]
+		if !ok {
+			continue
+		}
+
+		for _, instr := range join.Instrs {
+			if phi, ok := instr.(*Phi); ok {
+				if name, ok := r.phis[phi]; ok {
+					phi.Incoming = append(phi.Incoming, Incoming{Value: r.top(name), Label: label})
+				}
+			}
+		}
+	}
+
+	for _, child := range r.cfg.Children[label] {
+		r.rename(child)
+	}
+
+	for name, n := range pushed {
+		r.stacks[name] = r.stacks[name][:len(r.stacks[name])-n]
+	}
+}
+
+// removeDeadPhis drops phis whose value is never used, which includes
+// those only other dead phis use
+func removeDeadPhis(fn *Function) {
+	for changed := true; changed; {
+		changed = false
+
+		used := make(map[string]bool)
+
+		for _, bb := range fn.Blocks {
+			for _, instr := range bb.Instrs {
+				phi, isPhi := instr.(*Phi)
+
+				for _, op := range operands(instr) {
+					// A phi feeding itself around a loop does not keep it alive
+					if !isPhi || *op != phi.Dest {
+						used[*op] = true
+					}
+				}
+			}
+		}
+
+		for _, bb := range fn.Blocks {
+			kept := bb.Instrs[:0]
+
+			for _, instr := range bb.Instrs {
+				if phi, ok := instr.(*Phi); ok && !used[phi.Dest] {
+					changed = true
+					continue
+				}
+
+				kept = append(kept, instr)
+			}
+
+			bb.Instrs = kept
+		}
+	}
+}
+
+// operands returns pointers to the values an instruction reads, including
+// the addresses loads and stores go through, so passes can rewrite them
+func operands(instr Instruction) []*string {
+	var ops []*string
+
+	args := func(values []string) {
+		for i := range values {
+			ops = append(ops, &values[i])
+		}
+	}
+
+	switch i := instr.(type) {
+	case *Load:
+		ops = append(ops, &i.Source)
+	case *Store:
+		ops = append(ops, &i.Value, &i.Dest)
+	case *AddrOf:
+		ops = append(ops, &i.Source)
+	case *BinOp:
+		ops = append(ops, &i.Left, &i.Right)
+	case *MakeTuple:
+		args(i.Elems)
+	case *PackSlice:
+		args(i.Elems)
+	case *Extract:
+		ops = append(ops, &i.Tuple)
+	case *Insert:
+		if i.Agg != "" {
+			ops = append(ops, &i.Agg)
+		}
+
+		ops = append(ops, &i.Value)
+	case *FieldAddr:
+		ops = append(ops, &i.Base)
+	case *ElemAddr:
+		ops = append(ops, &i.Base, &i.Index)
+	case *TagAddr:
+		ops = append(ops, &i.Base)
+	case *PayloadAddr:
+		ops = append(ops, &i.Base)
+	case *EnumTag:
+		ops = append(ops, &i.Value)
+	case *Cast:
+		ops = append(ops, &i.Value)
+	case *Phi:
+		for j := range i.Incoming {
+			ops = append(ops, &i.Incoming[j].Value)
+		}
+	case *Call:
+		args(i.Args)
+	case *VCall:
+		ops = append(ops, &i.VTable, &i.Object)
+		args(i.Args)
+	case *IndirectCall:
+		ops = append(ops, &i.Callee)
+		args(i.Args)
+	case *DeferPush:
+		args(i.Call.Args)
+	case *Ret:
+		if i.Value != "" {
+			ops = append(ops, &i.Value)
+		}
+	case *CondBr:
+		ops = append(ops, &i.Cond)
+	}
+
+	return ops
+}
@@ -0,0 +1,174 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestMem2Reg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "straight-line locals become values",
+			input: `fn f(a i32) i32 {
+				let b = a + 1
+				let mut c = b * 2
+				c += a
+				return c
+			}`,
+			contains: []string{
+				"%a.arg = load i32, i32* %a",
+				"%t2 = add i32 %a.arg, %1",
+				"%t4 = mul i32 %t2, %2",
+				"%t7 = add i32 %t4, %a.arg",
+				"ret i32 %t7",
+			},
+			excludes: []string{"alloca", "store", "phi"},
+		},
+		{
+			name: "stores on both arms meet in a phi",
+			input: `fn f(a i32) i32 {
+				let mut x = 0
+				if a > 0 {
+					x = 1
+				} else {
+					x = 2
+				}
+				return x
+			}`,
+			contains: []string{
+				"%x.f.merge_3 = phi i32 [%2, %bb_f.else_4], [%1, %bb_f.then_2]",
+				"ret i32 %x.f.merge_3",
+			},
+			excludes: []string{"alloca"},
+		},
+		{
+			name: "loop variables get phis at the loop header",
+			input: `fn f(n i32) i32 {
+				let mut total = 0
+				let mut i = 0
+				while i < (n) {
+					total += i
+					i += 1
+				}
+				return total
+			}`,
+			contains: []string{
+				"%i.f.cond_2 = phi i32 [%0, %bb_f.entry_1], [%t8, %bb_f.body_3]",
+				"%total.f.cond_2 = phi i32 [%0, %bb_f.entry_1], [%t6, %bb_f.body_3]",
+				"%t6 = add i32 %total.f.cond_2, %i.f.cond_2",
+				"ret i32 %total.f.cond_2",
+			},
+			excludes: []string{"alloca", "store"},
+		},
+		{
+			name: "locals whose address is taken stay in memory",
+			input: `fn g(p &mut i32) {
+				*p = 3
+			}
+
+			fn f() i32 {
+				let mut x = 1
+				let y = 2
+				g(&mut x)
+				return x + y
+			}`,
+			contains: []string{
+				"%x = alloca i32",
+				"store i32 %1, i32* %x",
+				"%t3 = load i32, i32* %x",
+				"%t5 = add i32 %t3, %2",
+			},
+			excludes: []string{"%y = alloca"},
+		},
+		{
+			name: "constants other than i32 keep their type",
+			input: `fn f() {
+				let big: i64 = 3000000000
+				let b = true
+				println(big)
+				println(b)
+			}`,
+			contains: []string{
+				"%t1 = add i64 %3000000000, %0",
+				"call void @println(%t1)",
+				"%t2 = add bool %1, %0",
+				"call void @println(%t2)",
+			},
+			excludes: []string{"alloca", "println(3000000000)", "println(1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.input))
+			file := p.ParseFile()
+
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			c := checker.NewChecker()
+			if err := c.CheckFile(file); err != nil {
+				t.Fatalf("checker error: %v", err)
+			}
+
+			lowerer := NewLowerer()
+			lowerer.SetTypes(c.Types())
+			module := lowerer.LowerFile(file)
+			Mem2Reg(module)
+
+			out := module.Function("f").String()
+
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected %q in:\n%s", want, out)
+				}
+			}
+
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("expected no %q in:\n%s", unwanted, out)
+				}
+			}
+		})
+	}
+}
+
+func TestMem2RegDropsUnreachableBlocks(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	fn := &Function{
+		Name:  "f",
+		RetTy: i32,
+		Blocks: []*BasicBlock{
+			{Label: "entry", Instrs: []Instruction{
+				&Alloca{Name: "x", Type: i32},
+				&Store{Value: "1", Dest: "x", Type: i32},
+				&Br{Label: "exit"},
+			}},
+			{Label: "dead", Instrs: []Instruction{
+				&Store{Value: "2", Dest: "x", Type: i32},
+				&Br{Label: "exit"},
+			}},
+			{Label: "exit", Instrs: []Instruction{
+				&Load{Dest: "t1", Source: "x", Type: i32},
+				&Ret{Value: "t1", Type: i32},
+			}},
+		},
+	}
+
+	Mem2Reg(&Module{Functions: []*Function{fn}})
+
+	want := "define i32 @f() {\nbb_entry:\n  br label %bb_exit\nbb_exit:\n  ret i32 %1\n}\n"
+	if got := fn.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
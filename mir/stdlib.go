@@ -0,0 +1,55 @@
+package mir
+
+import (
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// A function f of a standard library module written in YarLang is part of
+// the file as std::module::f, and std::module::f and module::f, through
+// the module's import, call it.
+
+// declareUse records the name a use declaration imports its module under
+func (l *Lowerer) declareUse(decl *ast.UseDecl) {
+	name := decl.Alias
+	if name == "" {
+		name = decl.Path[len(decl.Path)-1]
+	}
+
+	l.imports[name] = strings.Join(decl.Path, "::")
+}
+
+// stdPath splits path into a module of the standard library and a name in
+// it, if path is std::module::name, or alias::name where alias is what
+// use std::module imports the module as
+func (l *Lowerer) stdPath(path *ast.PathExpr) (string, string, bool) {
+	segs := path.Segments
+
+	switch {
+	case len(segs) == 3 && segs[0] == "std":
+		return segs[1], segs[2], true
+	case len(segs) == 2:
+		if module, ok := strings.CutPrefix(l.imports[segs[0]], "std::"); ok && !strings.Contains(module, "::") {
+			return module, segs[1], true
+		}
+	}
+
+	return "", "", false
+}
+
+// stdFunc returns the name of the standard library function path calls,
+// if its module has been loaded into the file
+func (l *Lowerer) stdFunc(path *ast.PathExpr) (string, bool) {
+	module, name, ok := l.stdPath(path)
+	if !ok {
+		return "", false
+	}
+
+	qualified := "std::" + module + "::" + name
+	if _, ok := l.signatures[qualified]; !ok {
+		return "", false
+	}
+
+	return qualified, true
+}
@@ -0,0 +1,186 @@
+package mir
+
+import (
+	"strconv"
+
+	"github.com/yarlson/yarlang/ast"
+)
+
+// layout is a struct as the lowerer sees it: its module definition and
+// the field names in the same order as the definition's fields
+type layout struct {
+	typ   *StructType
+	names []string
+}
+
+func (s *layout) index(field string) (int, bool) {
+	for i, name := range s.names {
+		if name == field {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// declareStruct records that name is a struct, so that types can refer to
+// it before its fields are lowered. Generic structs have no single layout
+// and are left out.
+func (l *Lowerer) declareStruct(decl *ast.StructDecl) {
+	if len(decl.TParams) > 0 {
+		return
+	}
+
+	st := &StructType{Name: decl.Name}
+	l.structs[decl.Name] = &layout{typ: st}
+	l.module.Structs = append(l.module.Structs, st)
+}
+
+// defineStruct lowers the fields of a struct declared by declareStruct
+func (l *Lowerer) defineStruct(decl *ast.StructDecl) {
+	s, ok := l.structs[decl.Name]
+	if !ok {
+		return
+	}
+
+	for _, field := range decl.Fields {
+		s.typ.Fields = append(s.typ.Fields, l.lowerType(field.Type))
+		s.names = append(s.names, field.Name)
+	}
+}
+
+// lowerStructExpr builds a struct value one field at a time. Fields the
+// literal leaves out are zero.
+func (l *Lowerer) lowerStructExpr(expr *ast.StructExpr) string {
+	st, ok := l.lowerType(expr.Type).(*StructType)
+	if !ok {
+		return "undef"
+	}
+
+	s := l.structs[st.Name]
+	agg := ""
+
+	for _, init := range expr.Inits {
+		idx, ok := s.index(init.Name)
+		if !ok {
+			continue
+		}
+
+		val := l.lowerExpr(init.Val)
+		result := l.newTemp()
+		l.emit(&Insert{Dest: result, Agg: agg, Value: val, Index: idx, Type: st, Elem: s.typ.Fields[idx]})
+		agg = result
+	}
+
+	if agg == "" {
+		return "zeroinitializer"
+	}
+
+	return agg
+}
+
+// lowerFieldExpr reads a field in place when the struct lives in memory,
+// and otherwise extracts it from the struct value
+func (l *Lowerer) lowerFieldExpr(field *ast.FieldExpr) string {
+	_, idx, elem, ok := l.fieldOf(field)
+	if !ok {
+		return "undef"
+	}
+
+	if addr, ok := l.fieldAddr(field); ok {
+		result := l.newTemp()
+		l.emit(&Load{Dest: result, Source: addr, Type: elem})
+
+		return result
+	}
+
+	val := l.lowerExpr(field.Expr)
+	result := l.newTemp()
+	l.emit(&Extract{Dest: result, Tuple: val, Index: idx, Type: elem})
+
+	return result
+}
+
+// fieldOf resolves the struct or tuple field.Expr refers to, directly or
+// through a pointer, and the index and type of the field in it. Tuple
+// fields are named by their index.
+func (l *Lowerer) fieldOf(field *ast.FieldExpr) (Type, int, Type, bool) {
+	typ := l.typeOf(field.Expr)
+	if ptr, ok := typ.(*PtrType); ok {
+		typ = ptr.Elem
+	}
+
+	switch t := typ.(type) {
+	case *StructType:
+		s, ok := l.structs[t.Name]
+		if !ok {
+			return nil, 0, nil, false
+		}
+
+		idx, ok := s.index(field.Field)
+		if !ok {
+			return nil, 0, nil, false
+		}
+
+		return t, idx, s.typ.Fields[idx], true
+	case *TupleType:
+		idx, err := strconv.Atoi(field.Field)
+		if err != nil || idx >= len(t.Elems) {
+			return nil, 0, nil, false
+		}
+
+		return t, idx, t.Elems[idx], true
+	default:
+		return nil, 0, nil, false
+	}
+}
+
+// fieldAddr emits the address of a field of a struct or tuple held in a
+// local, in a field of one, or behind a pointer one of those holds. It reports false
+// for fields of temporary values, which have no address.
+func (l *Lowerer) fieldAddr(field *ast.FieldExpr) (string, bool) {
+	agg, idx, _, ok := l.fieldOf(field)
+	if !ok {
+		return "", false
+	}
+
+	var base string
+
+	switch e := field.Expr.(type) {
+	case *ast.Ident:
+		if _, ok := l.constant(e.Name); ok {
+			return "", false
+		}
+
+		base = l.slot(e.Name)
+	case *ast.FieldExpr:
+		if base, ok = l.fieldAddr(e); !ok {
+			return "", false
+		}
+	case *ast.IndexExpr:
+		if base, _, ok = l.elemAddr(e); !ok {
+			return "", false
+		}
+	case *ast.UnaryExpr:
+		// (*p).x is p.x
+		if e.Op != "*" {
+			return "", false
+		}
+
+		base = l.lowerExpr(e.Expr)
+	default:
+		return "", false
+	}
+
+	// A pointer to the struct is loaded and followed
+	if ptr, ok := l.typeOf(field.Expr).(*PtrType); ok {
+		loaded := l.newTemp()
+		l.emit(&Load{Dest: loaded, Source: base, Type: ptr})
+		base = loaded
+	}
+
+	result := l.newTemp()
+	l.emit(&FieldAddr{Dest: result, Base: base, Index: idx, Type: agg})
+
+	return result, true
+}
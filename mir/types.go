@@ -0,0 +1,230 @@
+package mir
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/types"
+)
+
+// SetTypes gives the lowerer the checker's type of each expression. Without
+// them, types are worked out from declarations and literals.
+func (l *Lowerer) SetTypes(t map[ast.Expr]types.Type) {
+	l.types = t
+}
+
+// fromChecker lowers a type found by the checker. It reports false for types
+// that are still open, such as the payload of a bare None.
+func (l *Lowerer) fromChecker(t types.Type) (Type, bool) {
+	switch t := t.(type) {
+	case *types.PrimitiveType:
+		return &PrimitiveType{Name: t.Name}, true
+	case *types.StringType:
+		return &StrType{}, true
+	case *types.PtrType:
+		elem, ok := l.fromChecker(t.Elem)
+		return &PtrType{Elem: elem}, ok
+	case *types.RefType:
+		if _, ok := t.Elem.(*types.DynType); ok {
+			return dynRefType(), true
+		}
+
+		elem, ok := l.fromChecker(t.Elem)
+		return &PtrType{Elem: elem}, ok
+	case *types.SliceType:
+		elem, ok := l.fromChecker(t.Elem)
+		return &SliceType{Elem: elem}, ok
+	case *types.ArrayType:
+		elem, ok := l.fromChecker(t.Elem)
+		return &ArrayType{Elem: elem, Len: t.Len}, ok
+	case *types.TupleType:
+		tuple := &TupleType{}
+		for _, e := range t.Elems {
+			elem, ok := l.fromChecker(e)
+			if !ok {
+				return nil, false
+			}
+
+			tuple.Elems = append(tuple.Elems, elem)
+		}
+
+		return tuple, true
+	case *types.StructType:
+		// Layouts are kept in the module, by name
+		return &StructType{Name: t.Name}, true
+	case *types.EnumType:
+		if len(t.TParams) > 0 {
+			return nil, false
+		}
+
+		return &EnumType{Name: t.Name}, true
+	case *types.ResultType:
+		okTy, known := l.fromChecker(t.Ok)
+		if !known {
+			return nil, false
+		}
+
+		errTy, known := l.fromChecker(t.Err)
+		if !known {
+			return nil, false
+		}
+
+		return l.resultType(okTy, errTy), true
+	case *types.OptionType:
+		elem, ok := l.fromChecker(t.Elem)
+		if !ok {
+			return nil, false
+		}
+
+		return l.optionType(elem), true
+	case *types.VecType:
+		if _, ok := l.fromChecker(t.Elem); !ok {
+			return nil, false
+		}
+
+		return handleType(), true
+	case *types.MapType:
+		if _, ok := l.fromChecker(t.Key); !ok {
+			return nil, false
+		}
+
+		if _, ok := l.fromChecker(t.Value); !ok {
+			return nil, false
+		}
+
+		return handleType(), true
+	case *types.FuncType:
+		if t.Variadic {
+			return nil, false
+		}
+
+		ret, ok := l.fromChecker(t.Return)
+		if !ok {
+			return nil, false
+		}
+
+		fn := &FuncType{Ret: ret}
+		for _, p := range t.Params {
+			param, ok := l.fromChecker(p)
+			if !ok {
+				return nil, false
+			}
+
+			fn.Params = append(fn.Params, param)
+		}
+
+		return fn, true
+	default:
+		return nil, false
+	}
+}
+
+// typeOf is the type expr is lowered as: the checker's type when known,
+// otherwise one worked out from the expression, defaulting to i32
+func (l *Lowerer) typeOf(expr ast.Expr) Type {
+	// Conversions inserted by the checker carry their own type
+	switch e := expr.(type) {
+	case *ast.ConvExpr:
+		return l.lowerType(e.To)
+	case *ast.CastExpr:
+		return l.lowerType(e.Type)
+	}
+
+	if t, ok := l.types[expr]; ok {
+		if typ, ok := l.fromChecker(t); ok {
+			return typ
+		}
+	}
+
+	switch e := expr.(type) {
+	case *ast.StringLit:
+		return &StrType{}
+	case *ast.BoolLit:
+		return &PrimitiveType{Name: "bool"}
+	case *ast.FloatLit:
+		return &PrimitiveType{Name: "f64"}
+	case *ast.Ident:
+		return l.slotType(e.Name)
+	case *ast.StructExpr:
+		return l.lowerType(e.Type)
+	case *ast.ArrayExpr:
+		array := &ArrayType{Elem: &PrimitiveType{Name: "i32"}, Len: len(e.Elems)}
+		if len(e.Elems) > 0 {
+			array.Elem = l.typeOf(e.Elems[0])
+		}
+
+		return array
+	case *ast.TupleExpr:
+		tuple := &TupleType{}
+		for _, elem := range e.Elems {
+			tuple.Elems = append(tuple.Elems, l.typeOf(elem))
+		}
+
+		return tuple
+	case *ast.PathExpr:
+		if enum, _, ok := l.variantOf(e); ok {
+			return &EnumType{Name: enum.Name}
+		}
+	case *ast.FieldExpr:
+		if _, _, elem, ok := l.fieldOf(e); ok {
+			return elem
+		}
+	case *ast.IndexExpr:
+		seq := l.typeOf(e.Expr)
+		if ptr, ok := seq.(*PtrType); ok {
+			seq = ptr.Elem
+		}
+
+		switch t := seq.(type) {
+		case *ArrayType:
+			return t.Elem
+		case *SliceType:
+			return t.Elem
+		}
+	case *ast.CallExpr:
+		if fn, ok := l.funcValue(e.Callee); ok {
+			return fn.Ret
+		}
+
+		switch callee := e.Callee.(type) {
+		case *ast.Ident:
+			return l.getFunctionReturnType(callee.Name)
+		case *ast.PathExpr:
+			return l.typeOf(callee)
+		case *ast.FieldExpr:
+			if sig, ok := l.methodOf(callee); ok {
+				return sig.RetTy
+			}
+		}
+	case *ast.UnaryExpr:
+		switch e.Op {
+		case "!":
+			return &PrimitiveType{Name: "bool"}
+		case "&", "&mut":
+			return &PtrType{Elem: l.typeOf(e.Expr)}
+		case "*":
+			if ptr, ok := l.typeOf(e.Expr).(*PtrType); ok {
+				return ptr.Elem
+			}
+		}
+
+		return l.typeOf(e.Expr)
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+			return &PrimitiveType{Name: "bool"}
+		}
+
+		return l.operandType(e)
+	}
+
+	return &PrimitiveType{Name: "i32"}
+}
+
+// operandType is the type both sides of bin are computed in. A literal
+// takes the type of the other side.
+func (l *Lowerer) operandType(bin *ast.BinaryExpr) Type {
+	if _, ok := bin.Left.(*ast.IntLit); ok {
+		return l.typeOf(bin.Right)
+	}
+
+	return l.typeOf(bin.Left)
+}
@@ -0,0 +1,185 @@
+package mir
+
+import (
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/types"
+)
+
+// A Vec<T> is lowered as an opaque pointer to the runtime's vector, which
+// holds its elements as bytes. Vec::new() passes the runtime the size of T,
+// and elements are copied in and out through pointers to them cast to *i8.
+// Each method is a call to the runtime function yar_vec_<method>.
+
+// handleType is the type every Vec and Map is lowered as, and the type of
+// the pointers values pass to the runtime through
+func handleType() *PtrType {
+	return &PtrType{Elem: &PrimitiveType{Name: "i8"}}
+}
+
+// vecElem returns the element type of the Vec that expr is, or refers to
+func (l *Lowerer) vecElem(expr ast.Expr) (Type, bool) {
+	t := l.types[expr]
+	if ref, ok := t.(*types.RefType); ok {
+		t = ref.Elem
+	}
+
+	vec, ok := t.(*types.VecType)
+	if !ok {
+		return nil, false
+	}
+
+	return l.fromChecker(vec.Elem)
+}
+
+// vecNew returns the element type of the Vec call makes, if it is
+// Vec::new()
+func (l *Lowerer) vecNew(call *ast.CallExpr) (Type, bool) {
+	path, ok := call.Callee.(*ast.PathExpr)
+	if !ok || len(path.Segments) != 2 || path.Segments[0] != "Vec" || path.Segments[1] != "new" {
+		return nil, false
+	}
+
+	return l.vecElem(call)
+}
+
+// isCollection reports whether call makes a built-in Vec or Map, which has
+// no function of its own to call
+func (l *Lowerer) isCollection(call *ast.CallExpr) bool {
+	switch l.types[call].(type) {
+	case *types.VecType, *types.MapType:
+		_, ok := call.Callee.(*ast.PathExpr)
+		return ok
+	default:
+		return false
+	}
+}
+
+// lowerVecNew makes an empty Vec of elem
+func (l *Lowerer) lowerVecNew(elem Type) string {
+	size := l.newTemp()
+	l.emit(&SizeOf{Dest: size, Type: elem})
+
+	result := l.newTemp()
+	l.emit(&Call{Dest: result, Callee: "yar_vec_new", Args: []string{size}, RetTy: handleType()})
+
+	return result
+}
+
+// lowerVecCall lowers a call to a method of a Vec of elem. It returns the
+// call's result, or "" for push and set.
+func (l *Lowerer) lowerVecCall(call *ast.CallExpr, callee *ast.FieldExpr, elem Type) string {
+	vec := l.lowerHandle(callee.Expr)
+	void := &PrimitiveType{Name: "void"}
+	name := "yar_vec_" + callee.Field
+
+	switch callee.Field {
+	case "push":
+		l.emit(&Call{Callee: name, Args: []string{vec, l.runtimePtr(call.Args[0])}, RetTy: void})
+		return ""
+	case "set":
+		index := l.vecIndex(call.Args[0])
+		l.emit(&Call{Callee: name, Args: []string{vec, index, l.runtimePtr(call.Args[1])}, RetTy: void})
+
+		return ""
+	case "pop":
+		return l.vecElemOut(name, elem, vec)
+	case "get":
+		index := l.vecIndex(call.Args[0])
+		return l.vecElemOut(name, elem, vec, index)
+	case "len":
+		result := l.newTemp()
+		l.emit(&Call{Dest: result, Callee: name, Args: []string{vec}, RetTy: &PrimitiveType{Name: "i64"}})
+
+		return result
+	default:
+		l.errorf("method %s of Vec cannot be lowered", callee.Field)
+		return "undef"
+	}
+}
+
+// lowerHandle evaluates the Vec or Map a method is called on, loading it
+// first when expr is a reference to one
+func (l *Lowerer) lowerHandle(expr ast.Expr) string {
+	handle := l.lowerExpr(expr)
+
+	if _, ok := l.types[expr].(*types.RefType); ok {
+		val := l.newTemp()
+		l.emit(&Load{Dest: val, Source: handle, Type: handleType()})
+		handle = val
+	}
+
+	return handle
+}
+
+// runtimePtr evaluates a value passed to the runtime and returns its
+// address as an *i8
+func (l *Lowerer) runtimePtr(arg ast.Expr) string {
+	addr := l.lowerAddrOf(arg)
+
+	ptr := l.newTemp()
+	l.emit(&Cast{Dest: ptr, Kind: BitCast, Value: addr, From: &PtrType{Elem: l.typeOf(arg)}, To: handleType()})
+
+	return ptr
+}
+
+// vecIndex evaluates an index, which the runtime takes as an i64
+func (l *Lowerer) vecIndex(arg ast.Expr) string {
+	index := l.lowerExpr(arg)
+	if isImmediate(index) {
+		val := l.newTemp()
+		l.emit(materialize(val, index, &PrimitiveType{Name: "i64"}))
+		index = val
+	}
+
+	return index
+}
+
+// vecElemOut calls the runtime function name with args followed by the
+// address it copies an element of type elem to, and returns the element
+func (l *Lowerer) vecElemOut(name string, elem Type, args ...string) string {
+	slot := l.declare("vec.elem")
+	l.emit(&Alloca{Name: slot, Type: elem})
+
+	out := l.slotPtr(slot, elem)
+	l.emit(&Call{Callee: name, Args: append(args, out), RetTy: &PrimitiveType{Name: "void"}})
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: slot, Type: elem})
+
+	return result
+}
+
+// optionOut calls the runtime function name with args followed by the
+// address it copies a value of type value to, if it has one, and returns
+// the Option<value> holding what it copied. The call's bool result tells
+// whether it did.
+func (l *Lowerer) optionOut(name string, value Type, args ...string) string {
+	opt := l.optionType(value)
+
+	slot := l.declare("option.value")
+	l.emit(&Alloca{Name: slot, Type: opt})
+
+	// Some is variant 1, with tag 1, of every Option
+	payload := l.newTemp()
+	l.emit(&PayloadAddr{Dest: payload, Base: slot, Variant: 1, Index: 0, Type: opt})
+
+	out := l.newTemp()
+	l.emit(&Cast{Dest: out, Kind: BitCast, Value: payload, From: &PtrType{Elem: value}, To: handleType()})
+
+	found := l.newTemp()
+	l.emit(&Call{Dest: found, Callee: name, Args: append(args, out), RetTy: &PrimitiveType{Name: "bool"}})
+
+	i32 := &PrimitiveType{Name: "i32"}
+
+	tag := l.newTemp()
+	l.emit(&Cast{Dest: tag, Kind: ZExt, Value: found, From: &PrimitiveType{Name: "bool"}, To: i32})
+
+	tagAddr := l.newTemp()
+	l.emit(&TagAddr{Dest: tagAddr, Base: slot, Type: opt})
+	l.emit(&Store{Value: tag, Dest: tagAddr, Type: i32})
+
+	result := l.newTemp()
+	l.emit(&Load{Dest: result, Source: slot, Type: opt})
+
+	return result
+}
@@ -0,0 +1,91 @@
+package mir
+
+import "fmt"
+
+// Verify checks the module for mistakes in the MIR itself, which a checked
+// program cannot cause. For now that is a conditional branch on a value
+// that is not a bool.
+func (m *Module) Verify() error {
+	for _, fn := range m.Functions {
+		if err := fn.Verify(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verify checks that every conditional branch of fn tests a bool: the
+// result of a comparison or another bool value, or the literal 0 or 1
+func (fn *Function) Verify() error {
+	types := fn.valueTypes()
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			br, ok := instr.(*CondBr)
+			if !ok {
+				continue
+			}
+
+			if typ, ok := types[br.Cond]; ok && !isBool(typ) {
+				return fmt.Errorf("%s: bb_%s: branch condition %%%s is %s, not bool", fn.Name, bb.Label, br.Cond, typ.String())
+			}
+
+			if isImmediate(br.Cond) && br.Cond != "0" && br.Cond != "1" {
+				return fmt.Errorf("%s: bb_%s: branch condition %s is not bool", fn.Name, bb.Label, br.Cond)
+			}
+		}
+	}
+
+	return nil
+}
+
+// valueTypes finds the type of each value of fn whose instruction gives it
+func (fn *Function) valueTypes() map[string]Type {
+	types := make(map[string]Type)
+
+	for _, p := range fn.Params {
+		types[p.Name] = p.Type
+	}
+
+	for _, bb := range fn.Blocks {
+		for _, instr := range bb.Instrs {
+			switch i := instr.(type) {
+			case *Load:
+				types[i.Dest] = i.Type
+			case *BinOp:
+				types[i.Dest] = i.ResultType()
+			case *Call:
+				if i.Dest != "" {
+					types[i.Dest] = i.RetTy
+				}
+			case *Phi:
+				types[i.Dest] = i.Type
+			case *Cast:
+				types[i.Dest] = i.To
+			case *Extract:
+				types[i.Dest] = i.Type
+			case *FuncRef:
+				types[i.Dest] = i.Type
+			case *SizeOf:
+				types[i.Dest] = &PrimitiveType{Name: "i64"}
+			case *IndirectCall:
+				if i.Dest != "" {
+					types[i.Dest] = i.Type.Ret
+				}
+			}
+		}
+	}
+
+	return types
+}
+
+func isBool(t Type) bool {
+	p, ok := t.(*PrimitiveType)
+	return ok && p.Name == "bool"
+}
+
+// isImmediate reports whether a value is a numeric literal
+func isImmediate(value string) bool {
+	return value != "" && (value[0] == '-' || value[0] >= '0' && value[0] <= '9')
+}
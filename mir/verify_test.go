@@ -0,0 +1,121 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+func TestVerify(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32"}
+	boolTy := &PrimitiveType{Name: "bool"}
+
+	tests := []struct {
+		name    string
+		entry   []Instruction
+		wantErr string
+	}{
+		{
+			name: "comparison",
+			entry: []Instruction{
+				&BinOp{Dest: "t1", Op: Lt, Left: "x", Right: "3", Type: i32},
+				&CondBr{Cond: "t1", TrueLabel: "done", FalseLabel: "done"},
+			},
+		},
+		{
+			name: "loaded bool",
+			entry: []Instruction{
+				&Load{Dest: "t1", Source: "flag", Type: boolTy},
+				&CondBr{Cond: "t1", TrueLabel: "done", FalseLabel: "done"},
+			},
+		},
+		{
+			name:  "bool literal",
+			entry: []Instruction{&CondBr{Cond: "1", TrueLabel: "done", FalseLabel: "done"}},
+		},
+		{
+			name: "arithmetic result",
+			entry: []Instruction{
+				&BinOp{Dest: "t1", Op: Add, Left: "x", Right: "3", Type: i32},
+				&CondBr{Cond: "t1", TrueLabel: "done", FalseLabel: "done"},
+			},
+			wantErr: "f: bb_entry: branch condition %t1 is i32, not bool",
+		},
+		{
+			name: "integer parameter",
+			entry: []Instruction{
+				&CondBr{Cond: "x", TrueLabel: "done", FalseLabel: "done"},
+			},
+			wantErr: "branch condition %x is i32, not bool",
+		},
+		{
+			name:    "integer literal",
+			entry:   []Instruction{&CondBr{Cond: "7", TrueLabel: "done", FalseLabel: "done"}},
+			wantErr: "branch condition 7 is not bool",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := &Function{
+				Name:   "f",
+				Params: []Param{{Name: "x", Type: i32}},
+				RetTy:  &PrimitiveType{Name: "void"},
+				Blocks: []*BasicBlock{
+					{Label: "entry", Instrs: tt.entry},
+					{Label: "done", Instrs: []Instruction{&Ret{Type: &PrimitiveType{Name: "void"}}}},
+				},
+			}
+
+			err := fn.Verify()
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyLoweredLogic(t *testing.T) {
+	input := `fn f(a i32, b i64, c bool) bool {
+		while a < 3 && b != 0 || !c {
+			if c || a == 1 && b > 2 {
+				return true
+			}
+		}
+		return a > 1 && c
+	}`
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	lowerer := NewLowerer()
+	lowerer.SetTypes(c.Types())
+	mod := lowerer.LowerFile(file)
+
+	if err := mod.Verify(); err != nil {
+		t.Fatalf("lowered MIR does not verify: %v\n%s", err, mod)
+	}
+
+	Pipeline(2).Run(mod)
+	Mem2Reg(mod)
+
+	if err := mod.Verify(); err != nil {
+		t.Errorf("optimized MIR does not verify: %v\n%s", err, mod)
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/lexer"
+)
+
+// largeFile generates a file of n functions, each with a struct, loops,
+// branches, a tuple and a call
+func largeFile(n int) string {
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "struct P%d { x: i32, y: i64 }\n\n", i)
+		fmt.Fprintf(&b, "fn f%d(n i32, p &P%d) i64 {\n", i, i)
+		b.WriteString("\tlet mut total: i64 = 0\n")
+		b.WriteString("\tfor i in 0..n {\n")
+		b.WriteString("\t\tif i % 3 == 0 && p.x > 1 {\n")
+		b.WriteString("\t\t\ttotal += (i as i64) * p.y\n")
+		b.WriteString("\t\t} else {\n")
+		b.WriteString("\t\t\ttotal -= 1\n")
+		b.WriteString("\t\t}\n")
+		b.WriteString("\t}\n")
+		b.WriteString("\tlet t = (n, total)\n")
+		b.WriteString("\twhile total > 100 {\n\t\ttotal = total / 2\n\t}\n")
+		fmt.Fprintf(&b, "\treturn t.1 + f%d(n - 1, p)\n", i)
+		b.WriteString("}\n\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkParseLargeFile(b *testing.B) {
+	input := largeFile(2000)
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(input))
+		p.ParseFile()
+
+		if len(p.Errors()) != 0 {
+			b.Fatalf("parser errors: %v", p.Errors()[:1])
+		}
+	}
+}
@@ -2,25 +2,34 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/diag"
 	"github.com/yarlson/yarlang/lexer"
 )
 
 // Parser parses tokens into AST
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []diag.Diagnostic
 
 	curToken  lexer.Token
 	peekToken lexer.Token
+	lastToken lexer.Token // last token before curToken that is not a newline
+
+	// noStruct is set while parsing the condition before a block, where a
+	// name followed by { is the name and the start of the block
+	noStruct bool
+
+	locale string // Language of the messages
 }
 
 // New creates a new Parser
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		locale: diag.DefaultLocale,
 	}
 
 	// Read two tokens to initialize curToken and peekToken
@@ -30,16 +39,88 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// SetLocale sets the language of the messages, as diag.Locale picks it
+func (p *Parser) SetLocale(locale string) {
+	p.locale = locale
+}
+
 // Errors returns parser errors
 func (p *Parser) Errors() []string {
+	out := make([]string, len(p.errors))
+	for i, d := range p.errors {
+		out[i] = fmt.Sprintf("line %d: %s", d.Line, d.Message)
+	}
+
+	return out
+}
+
+// Diagnostics returns the parser errors with the tokens they point at
+func (p *Parser) Diagnostics() []diag.Diagnostic {
 	return p.errors
 }
 
+// error reports msg at the current token. An error at the end of a line
+// or of the file points at the token before it, where what is missing
+// belongs.
 func (p *Parser) error(msg string) {
-	p.errors = append(p.errors, fmt.Sprintf("line %d: %s", p.curToken.Line, msg))
+	at := p.curToken
+	if (at.Type == lexer.NEWLINE || at.Type == lexer.EOF) && p.lastToken.Line > 0 {
+		at = p.lastToken
+	}
+
+	p.errors = append(p.errors, diag.Diagnostic{
+		Severity: diag.SeverityError,
+		Message:  msg,
+		Line:     at.Line,
+		Column:   at.Column,
+		Length:   len(at.Literal),
+	})
+}
+
+// span records on node where it starts, at start, and how many columns of
+// that line it covers up to the token the parser is on, its last. A node
+// that goes on past its first line covers only its first token. A node
+// that already has a span, such as a parenthesized expression, keeps it.
+func (p *Parser) span(node interface{ Pos() *ast.Span }, start lexer.Token) {
+	if v := reflect.ValueOf(node); !v.IsValid() || v.Kind() == reflect.Pointer && v.IsNil() {
+		return
+	}
+
+	pos := node.Pos()
+	if pos.Line > 0 || start.Line == 0 {
+		return
+	}
+
+	end := p.curToken
+	if end.Type == lexer.NEWLINE || end.Type == lexer.SEMICOLON || end.Type == lexer.EOF {
+		end = p.lastToken
+	}
+
+	*pos = ast.Span{Line: start.Line, Column: start.Column, Length: width(start)}
+	if end.Line == start.Line && end.Column > start.Column {
+		pos.Length = end.Column + width(end) - start.Column
+	}
+}
+
+// width returns how many columns tok takes up in the source
+func width(tok lexer.Token) int {
+	if tok.Type == lexer.STRING || tok.Type == lexer.CHAR {
+		return len(tok.Literal) + 2 // the quotes
+	}
+
+	return len(tok.Literal)
+}
+
+// errorf reports the error of code with its message filled in from args
+func (p *Parser) errorf(code diag.Code, args ...any) {
+	p.error(diag.Message(p.locale, code, args...))
 }
 
 func (p *Parser) nextToken() {
+	if p.curToken.Type != lexer.NEWLINE && p.curToken.Line > 0 {
+		p.lastToken = p.curToken
+	}
+
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
 
@@ -57,13 +138,33 @@ func (p *Parser) peekTokenIs(t lexer.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
+// allowStructs lifts noStruct inside brackets, where a struct literal
+// cannot be mistaken for a block. Call the result to restore it.
+func (p *Parser) allowStructs() func() {
+	saved := p.noStruct
+	p.noStruct = false
+
+	return func() { p.noStruct = saved }
+}
+
+// parseCondition parses the expression before the block of an if, while or
+// for
+func (p *Parser) parseCondition() ast.Expr {
+	saved := p.noStruct
+	p.noStruct = true
+	expr := p.parseExpression(LOWEST)
+	p.noStruct = saved
+
+	return expr
+}
+
 func (p *Parser) expectPeek(t lexer.TokenType) bool {
 	if p.peekTokenIs(t) {
 		p.nextToken()
 		return true
 	}
 
-	p.error(fmt.Sprintf("expected next token to be %v, got %v instead", t, p.peekToken.Type))
+	p.errorf(diag.ParseExpectedToken, t, p.peekToken.Type)
 
 	return false
 }
@@ -74,6 +175,11 @@ func (p *Parser) parseType() ast.Type {
 	switch p.curToken.Type {
 	case lexer.AMP:
 		return p.parseRefType()
+	case lexer.AND:
+		// && is two references, as in &&T: the inner one starts at the second &
+		p.curToken = lexer.Token{Type: lexer.AMP, Literal: "&", Line: p.curToken.Line, Column: p.curToken.Column + 1}
+
+		return &ast.RefType{Elem: p.parseRefType()}
 	case lexer.STAR:
 		return p.parsePtrType()
 	case lexer.LBRACKET:
@@ -84,8 +190,12 @@ func (p *Parser) parseType() ast.Type {
 		return p.parseTypePath()
 	case lexer.VOID:
 		return &ast.VoidType{}
+	case lexer.DYN:
+		return p.parseDynType()
+	case lexer.FN:
+		return p.parseFuncType()
 	default:
-		p.error(fmt.Sprintf("unexpected token in type: %v", p.curToken.Type))
+		p.errorf(diag.ParseTypeToken, p.curToken.Type)
 		return nil
 	}
 }
@@ -106,6 +216,22 @@ func (p *Parser) parseRefType() ast.Type {
 	return &ast.RefType{Mut: mut, Elem: elem}
 }
 
+func (p *Parser) parseDynType() ast.Type {
+	p.nextToken() // consume dyn
+
+	if !p.curTokenIs(lexer.IDENT) {
+		p.errorf(diag.ParseDynTrait)
+		return nil
+	}
+
+	trait, ok := p.parseTypePath().(*ast.TypePath)
+	if !ok {
+		return nil
+	}
+
+	return &ast.DynType{Trait: trait}
+}
+
 func (p *Parser) parsePtrType() ast.Type {
 	p.nextToken() // consume *
 	elem := p.parseType()
@@ -140,6 +266,39 @@ func (p *Parser) parseArrayOrSliceType() ast.Type {
 	return &ast.ArrayType{Elem: elem, Len: len}
 }
 
+// parseFuncType parses fn(T1, T2) R, whose return type is optional
+func (p *Parser) parseFuncType() ast.Type {
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+
+	fn := &ast.FuncType{}
+
+	p.nextToken() // consume (
+
+	for !p.curTokenIs(lexer.RPAREN) {
+		fn.Params = append(fn.Params, p.parseType())
+
+		p.nextToken() // consume the parameter type
+
+		if p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+		} else if !p.curTokenIs(lexer.RPAREN) {
+			p.errorf(diag.ParseFuncTypeSep)
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(lexer.IDENT) || p.peekTokenIs(lexer.AMP) || p.peekTokenIs(lexer.AND) ||
+		p.peekTokenIs(lexer.STAR) || p.peekTokenIs(lexer.LBRACKET) ||
+		p.peekTokenIs(lexer.LPAREN) || p.peekTokenIs(lexer.VOID) || p.peekTokenIs(lexer.FN) {
+		p.nextToken() // consume )
+		fn.Return = p.parseType()
+	}
+
+	return fn
+}
+
 func (p *Parser) parseTupleType() ast.Type {
 	p.nextToken() // consume (
 
@@ -178,7 +337,7 @@ func (p *Parser) parseTypePath() ast.Type {
 		p.nextToken() // consume ::
 
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected identifier after ::")
+			p.errorf(diag.ParsePathSegment)
 			return nil
 		}
 
@@ -199,7 +358,12 @@ func (p *Parser) parseTypePath() ast.Type {
 			args = append(args, p.parseType())
 		}
 
-		if !p.expectPeek(lexer.GT) {
+		if p.peekTokenIs(lexer.SHR) {
+			// The >> of Vec<Vec<T>> closes this list and the enclosing one:
+			// take the first > and leave the second as the next token
+			p.curToken = lexer.Token{Type: lexer.GT, Literal: ">", Line: p.peekToken.Line, Column: p.peekToken.Column}
+			p.peekToken = lexer.Token{Type: lexer.GT, Literal: ">", Line: p.peekToken.Line, Column: p.peekToken.Column + 1}
+		} else if !p.expectPeek(lexer.GT) {
 			return nil
 		}
 		// curToken is now GT - leave it there (last token of type)
@@ -224,6 +388,7 @@ const (
 	SHIFT       // << >>
 	SUM         // + -
 	PRODUCT     // * / %
+	CAST        // as
 	PREFIX      // -X !X &X *X
 	POSTFIX     // X() X[] X. X?
 )
@@ -261,6 +426,7 @@ var precedences = map[lexer.TokenType]int{
 	lexer.STAR:     PRODUCT,
 	lexer.SLASH:    PRODUCT,
 	lexer.PERCENT:  PRODUCT,
+	lexer.AS:       CAST,
 	lexer.LPAREN:   POSTFIX,
 	lexer.LBRACKET: POSTFIX,
 	lexer.DOT:      POSTFIX,
@@ -283,13 +449,36 @@ func (p *Parser) curPrecedence() int {
 	return LOWEST
 }
 
+// parsePathExpr parses a::b::c in expression position
+func (p *Parser) parsePathExpr() ast.Expr {
+	path := &ast.PathExpr{Segments: []string{p.curToken.Literal}}
+
+	for p.peekTokenIs(lexer.COLONCOLON) {
+		p.nextToken() // consume previous segment
+		p.nextToken() // consume ::
+
+		if !p.curTokenIs(lexer.IDENT) {
+			p.errorf(diag.ParsePathSegment)
+			return nil
+		}
+
+		path.Segments = append(path.Segments, p.curToken.Literal)
+	}
+
+	return path
+}
+
 func (p *Parser) parseExpression(precedence int) ast.Expr {
+	start := p.curToken
+
 	// Parse prefix expression
 	prefix := p.parsePrefixExpression()
 	if prefix == nil {
 		return nil
 	}
 
+	p.span(prefix, start)
+
 	// Parse infix expressions with precedence
 	for !p.peekTokenIs(lexer.SEMICOLON) && !p.peekTokenIs(lexer.NEWLINE) && precedence < p.peekPrecedence() {
 		infix := p.parseInfixExpression(prefix)
@@ -297,6 +486,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expr {
 			return prefix
 		}
 
+		p.span(infix, start)
 		prefix = infix
 	}
 
@@ -307,10 +497,14 @@ func (p *Parser) parsePrefixExpression() ast.Expr {
 	switch p.curToken.Type {
 	case lexer.IDENT:
 		// Check if it's a struct literal
-		if p.peekTokenIs(lexer.LBRACE) {
+		if p.peekTokenIs(lexer.LBRACE) && !p.noStruct {
 			return p.parseStructLiteral()
 		}
 
+		if p.peekTokenIs(lexer.COLONCOLON) {
+			return p.parsePathExpr()
+		}
+
 		return &ast.Ident{Name: p.curToken.Literal}
 	case lexer.LBRACKET:
 		return p.parseArrayLiteral()
@@ -344,7 +538,7 @@ func (p *Parser) parsePrefixExpression() ast.Expr {
 	case lexer.MINUS, lexer.BANG, lexer.TILDE, lexer.STAR:
 		return p.parseUnaryExpression()
 	default:
-		p.error(fmt.Sprintf("no prefix parse function for %v", p.curToken.Type))
+		p.errorf(diag.ParseNoPrefix, p.curToken.Type)
 		return nil
 	}
 }
@@ -359,6 +553,8 @@ func (p *Parser) parseInfixExpression(left ast.Expr) ast.Expr {
 		return p.parseFieldExpression(left)
 	case lexer.QUESTION:
 		return p.parsePropagateExpression(left)
+	case lexer.AS:
+		return p.parseCastExpression(left)
 	default:
 		// Binary operator
 		p.nextToken() // move to operator
@@ -379,6 +575,8 @@ func (p *Parser) parseInfixExpression(left ast.Expr) ast.Expr {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expr {
+	defer p.allowStructs()()
+
 	p.nextToken() // consume (
 
 	expr := p.parseExpression(LOWEST)
@@ -386,19 +584,21 @@ func (p *Parser) parseGroupedExpression() ast.Expr {
 	// Check for tuple
 	if p.peekTokenIs(lexer.COMMA) {
 		elems := []ast.Expr{expr}
+		trailing := false
 
 		for p.peekTokenIs(lexer.COMMA) {
 			p.nextToken() // consume current
 			p.nextToken() // consume comma
 
 			if p.curTokenIs(lexer.RPAREN) {
-				break // trailing comma
+				trailing = true
+				break
 			}
 
 			elems = append(elems, p.parseExpression(LOWEST))
 		}
 
-		if !p.expectPeek(lexer.RPAREN) {
+		if !trailing && !p.expectPeek(lexer.RPAREN) {
 			return nil
 		}
 
@@ -421,6 +621,8 @@ func (p *Parser) parseUnaryExpression() ast.Expr {
 }
 
 func (p *Parser) parseCallExpression(callee ast.Expr) ast.Expr {
+	defer p.allowStructs()()
+
 	p.nextToken() // consume (
 
 	args := []ast.Expr{}
@@ -446,6 +648,8 @@ func (p *Parser) parseCallExpression(callee ast.Expr) ast.Expr {
 }
 
 func (p *Parser) parseIndexExpression(expr ast.Expr) ast.Expr {
+	defer p.allowStructs()()
+
 	p.nextToken() // consume [
 	p.nextToken() // move to index expression
 
@@ -461,6 +665,12 @@ func (p *Parser) parseIndexExpression(expr ast.Expr) ast.Expr {
 func (p *Parser) parseFieldExpression(expr ast.Expr) ast.Expr {
 	p.nextToken() // consume .
 
+	// Tuple elements are selected by index, as in t.0
+	if p.peekTokenIs(lexer.INT) {
+		p.nextToken()
+		return &ast.FieldExpr{Expr: expr, Field: p.curToken.Literal}
+	}
+
 	if !p.expectPeek(lexer.IDENT) {
 		return nil
 	}
@@ -476,7 +686,16 @@ func (p *Parser) parsePropagateExpression(expr ast.Expr) ast.Expr {
 	return &ast.PropagateExpr{Expr: expr}
 }
 
+func (p *Parser) parseCastExpression(expr ast.Expr) ast.Expr {
+	p.nextToken() // move to as
+	p.nextToken() // move to type
+
+	return &ast.CastExpr{Expr: expr, Type: p.parseType()}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expr {
+	defer p.allowStructs()()
+
 	p.nextToken() // consume [
 
 	elems := []ast.Expr{}
@@ -510,11 +729,12 @@ func (p *Parser) parseStructLiteral() ast.Expr {
 	p.nextToken() // consume {
 
 	inits := []ast.FieldInit{}
+	trailing := false
 
 	if !p.curTokenIs(lexer.RBRACE) {
 		// Parse field: value
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected field name")
+			p.errorf(diag.ParseFieldName)
 			return nil
 		}
 
@@ -534,7 +754,8 @@ func (p *Parser) parseStructLiteral() ast.Expr {
 			p.nextToken() // consume comma
 
 			if p.curTokenIs(lexer.RBRACE) {
-				break // trailing comma
+				trailing = true
+				break
 			}
 
 			fieldName := p.curToken.Literal
@@ -546,10 +767,10 @@ func (p *Parser) parseStructLiteral() ast.Expr {
 			value := p.parseExpression(LOWEST)
 			inits = append(inits, ast.FieldInit{Name: fieldName, Val: value})
 		}
-	}
 
-	if !p.expectPeek(lexer.RBRACE) {
-		return nil
+		if !trailing && !p.expectPeek(lexer.RBRACE) {
+			return nil
+		}
 	}
 
 	return &ast.StructExpr{Type: typePath, Inits: inits}
@@ -561,11 +782,17 @@ func (p *Parser) parseStructLiteral() ast.Expr {
 func (p *Parser) parseStatement() ast.Stmt {
 	switch p.curToken.Type {
 	case lexer.LET:
+		if p.peekTokenIs(lexer.LPAREN) {
+			return p.parseLetTupleStmt()
+		}
+
 		return p.parseLetStmt()
 	case lexer.RETURN:
 		return p.parseReturnStmt()
 	case lexer.IF:
 		return p.parseIfStmt()
+	case lexer.GUARD:
+		return p.parseGuardStmt()
 	case lexer.WHILE:
 		return p.parseWhileStmt()
 	case lexer.FOR:
@@ -580,12 +807,53 @@ func (p *Parser) parseStatement() ast.Stmt {
 		return p.parseUnsafeBlock()
 	case lexer.LBRACE:
 		return p.parseBlock()
+	case lexer.FN, lexer.STRUCT:
+		return p.parseDeclStmt()
+	case lexer.CONST:
+		return p.parseConstStmt()
 	default:
 		// Try assignment or expression statement
 		return p.parseAssignOrExprStmt()
 	}
 }
 
+// parseDeclStmt parses a function or struct declared inside a block
+func (p *Parser) parseDeclStmt() ast.Stmt {
+	var decl ast.Decl
+
+	start := p.curToken
+
+	if p.curTokenIs(lexer.FN) {
+		fn := p.parseFuncDecl(false)
+		if fn == nil {
+			return nil
+		}
+
+		decl = fn
+	} else {
+		st := p.parseStructDecl(false)
+		if st == nil {
+			return nil
+		}
+
+		decl = st
+	}
+
+	p.span(decl, start)
+
+	return &ast.DeclStmt{Decl: decl}
+}
+
+// parseConstStmt parses a const declared inside a block
+func (p *Parser) parseConstStmt() ast.Stmt {
+	decl := p.parseConstDecl()
+	if decl == nil {
+		return nil
+	}
+
+	return &ast.ConstStmt{Name: decl.Name, Type: decl.Type, Value: decl.Value}
+}
+
 func (p *Parser) parseLetStmt() *ast.LetStmt {
 	stmt := &ast.LetStmt{}
 
@@ -600,7 +868,7 @@ func (p *Parser) parseLetStmt() *ast.LetStmt {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected identifier after let")
+		p.errorf(diag.ParseLetName)
 		return nil
 	}
 
@@ -631,6 +899,48 @@ func (p *Parser) parseLetStmt() *ast.LetStmt {
 	return stmt
 }
 
+func (p *Parser) parseLetTupleStmt() *ast.LetTupleStmt {
+	stmt := &ast.LetTupleStmt{}
+
+	p.nextToken() // consume let
+	p.nextToken() // consume (
+
+	for !p.curTokenIs(lexer.RPAREN) {
+		if !p.curTokenIs(lexer.IDENT) {
+			p.errorf(diag.ParseTupleName)
+			return nil
+		}
+
+		stmt.Names = append(stmt.Names, p.curToken.Literal)
+
+		if p.peekTokenIs(lexer.COMMA) {
+			p.nextToken() // consume name
+			p.nextToken() // consume comma
+		} else if p.peekTokenIs(lexer.RPAREN) {
+			p.nextToken() // consume name, move to )
+		} else {
+			p.errorf(diag.ParseTupleSep)
+			return nil
+		}
+	}
+
+	// Expect =
+	if !p.expectPeek(lexer.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken() // consume =
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	// Skip optional semicolon or newline
+	if p.peekTokenIs(lexer.SEMICOLON) || p.peekTokenIs(lexer.NEWLINE) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // Placeholder stubs for other statement types
 func (p *Parser) parseReturnStmt() *ast.ReturnStmt {
 	stmt := &ast.ReturnStmt{}
@@ -657,7 +967,7 @@ func (p *Parser) parseIfStmt() *ast.IfStmt {
 	p.nextToken() // consume if
 
 	// Parse condition
-	stmt.Cond = p.parseExpression(LOWEST)
+	stmt.Cond = p.parseCondition()
 
 	// Parse then block
 	if !p.expectPeek(lexer.LBRACE) {
@@ -678,7 +988,7 @@ func (p *Parser) parseIfStmt() *ast.IfStmt {
 			// else block
 			stmt.Else = p.parseBlock()
 		} else {
-			p.error("expected if or { after else")
+			p.errorf(diag.ParseElse)
 			return nil
 		}
 	}
@@ -686,13 +996,35 @@ func (p *Parser) parseIfStmt() *ast.IfStmt {
 	return stmt
 }
 
+func (p *Parser) parseGuardStmt() *ast.GuardStmt {
+	stmt := &ast.GuardStmt{}
+
+	p.nextToken() // consume guard
+
+	// Parse condition
+	stmt.Cond = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(lexer.ELSE) {
+		return nil
+	}
+
+	// Parse else block
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	stmt.Else = p.parseBlock()
+
+	return stmt
+}
+
 func (p *Parser) parseWhileStmt() *ast.WhileStmt {
 	stmt := &ast.WhileStmt{}
 
 	p.nextToken() // consume while
 
 	// Parse condition
-	stmt.Cond = p.parseExpression(LOWEST)
+	stmt.Cond = p.parseCondition()
 
 	// Parse body
 	if !p.expectPeek(lexer.LBRACE) {
@@ -711,7 +1043,7 @@ func (p *Parser) parseForStmt() *ast.ForStmt {
 
 	// Parse key (optional) and val
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected identifier after for")
+		p.errorf(diag.ParseForName)
 		return nil
 	}
 
@@ -725,7 +1057,7 @@ func (p *Parser) parseForStmt() *ast.ForStmt {
 		p.nextToken() // consume comma
 
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected identifier after comma")
+			p.errorf(diag.ParseForSecond)
 			return nil
 		}
 
@@ -736,14 +1068,14 @@ func (p *Parser) parseForStmt() *ast.ForStmt {
 
 	// Expect in
 	if !p.expectPeek(lexer.IDENT) || p.curToken.Literal != "in" {
-		p.error("expected 'in' after for variable")
+		p.errorf(diag.ParseForIn)
 		return nil
 	}
 
 	p.nextToken() // consume in
 
 	// Parse iterator expression
-	stmt.Iter = p.parseExpression(LOWEST)
+	stmt.Iter = p.parseCondition()
 
 	// Parse body
 	if !p.expectPeek(lexer.LBRACE) {
@@ -800,7 +1132,7 @@ func (p *Parser) parseUnsafeBlock() *ast.UnsafeBlock {
 
 	// Expect block
 	if !p.curTokenIs(lexer.LBRACE) {
-		p.error("expected { after unsafe")
+		p.errorf(diag.ParseUnsafeBlock)
 		return nil
 	}
 
@@ -821,7 +1153,9 @@ func (p *Parser) parseBlock() *ast.Block {
 			continue
 		}
 
+		start := p.curToken
 		stmt := p.parseStatement()
+		p.span(stmt, start)
 		block.Stmts = append(block.Stmts, stmt)
 
 		p.nextToken()
@@ -910,20 +1244,56 @@ func (p *Parser) parseDeclaration() ast.Decl {
 		return p.parseConstDecl()
 	case lexer.USE:
 		return p.parseUseDecl()
+	case lexer.EXTERN:
+		return p.parseExternDecl(pub)
 	default:
-		p.error(fmt.Sprintf("unexpected token in declaration: %v", p.curToken.Type))
+		p.errorf(diag.ParseDeclToken, p.curToken.Type)
 		return nil
 	}
 }
 
 func (p *Parser) parseFuncDecl(pub bool) *ast.FuncDecl {
+	decl := p.parseFuncSignature(pub)
+	if decl == nil {
+		return nil
+	}
+
+	// Parse body
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	decl.Body = p.parseBlock()
+
+	return decl
+}
+
+// parseExternDecl parses extern fn name(params) ret, a function that is
+// implemented outside yarlang and linked in
+func (p *Parser) parseExternDecl(pub bool) *ast.FuncDecl {
+	if !p.expectPeek(lexer.FN) {
+		return nil
+	}
+
+	decl := p.parseFuncSignature(pub)
+	if decl == nil {
+		return nil
+	}
+
+	decl.Extern = true
+
+	return decl
+}
+
+// parseFuncSignature parses a function from fn up to its return type
+func (p *Parser) parseFuncSignature(pub bool) *ast.FuncDecl {
 	decl := &ast.FuncDecl{Pub: pub}
 
 	p.nextToken() // consume fn
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected function name")
+		p.errorf(diag.ParseFuncName)
 		return nil
 	}
 
@@ -936,7 +1306,7 @@ func (p *Parser) parseFuncDecl(pub bool) *ast.FuncDecl {
 
 		for !p.curTokenIs(lexer.GT) && !p.curTokenIs(lexer.EOF) {
 			if !p.curTokenIs(lexer.IDENT) {
-				p.error("expected type parameter name")
+				p.errorf(diag.ParseTypeParam)
 				return nil
 			}
 
@@ -990,13 +1360,20 @@ func (p *Parser) parseFuncDecl(pub bool) *ast.FuncDecl {
 		} else {
 			// Parse name
 			if !p.curTokenIs(lexer.IDENT) {
-				p.error("expected parameter name")
+				p.errorf(diag.ParseParamName)
 				return nil
 			}
 
 			param.Name = p.curToken.Literal
 			p.nextToken()
 
+			// A variadic parameter is spelled name ...T
+			if p.curTokenIs(lexer.ELLIPSIS) {
+				param.Variadic = true
+
+				p.nextToken()
+			}
+
 			// Parse type
 			param.Type = p.parseType()
 		}
@@ -1010,32 +1387,25 @@ func (p *Parser) parseFuncDecl(pub bool) *ast.FuncDecl {
 			p.nextToken() // consume type/self, move to )
 			break
 		} else {
-			p.error("expected comma or ) after parameter")
+			p.errorf(diag.ParseParamSep)
 			return nil
 		}
 	}
 
 	// curToken should now be RPAREN
 	if !p.curTokenIs(lexer.RPAREN) {
-		p.error("expected )")
+		p.errorf(diag.ParseCloseParen)
 		return nil
 	}
 
 	// Check for return type
-	if p.peekTokenIs(lexer.IDENT) || p.peekTokenIs(lexer.AMP) ||
+	if p.peekTokenIs(lexer.IDENT) || p.peekTokenIs(lexer.AMP) || p.peekTokenIs(lexer.AND) ||
 		p.peekTokenIs(lexer.STAR) || p.peekTokenIs(lexer.LBRACKET) ||
-		p.peekTokenIs(lexer.LPAREN) || p.peekTokenIs(lexer.VOID) {
+		p.peekTokenIs(lexer.LPAREN) || p.peekTokenIs(lexer.VOID) || p.peekTokenIs(lexer.FN) {
 		p.nextToken() // consume )
 		decl.ReturnType = p.parseType()
 	}
 
-	// Parse body
-	if !p.expectPeek(lexer.LBRACE) {
-		return nil
-	}
-
-	decl.Body = p.parseBlock()
-
 	return decl
 }
 
@@ -1047,7 +1417,7 @@ func (p *Parser) parseStructDecl(pub bool) *ast.StructDecl {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected struct name")
+		p.errorf(diag.ParseStructName)
 		return nil
 	}
 
@@ -1060,7 +1430,7 @@ func (p *Parser) parseStructDecl(pub bool) *ast.StructDecl {
 
 		for !p.curTokenIs(lexer.GT) && !p.curTokenIs(lexer.EOF) {
 			if !p.curTokenIs(lexer.IDENT) {
-				p.error("expected type parameter name")
+				p.errorf(diag.ParseTypeParam)
 				return nil
 			}
 
@@ -1094,7 +1464,7 @@ func (p *Parser) parseStructDecl(pub bool) *ast.StructDecl {
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
 		// Parse field name
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected field name")
+			p.errorf(diag.ParseFieldName)
 			return nil
 		}
 
@@ -1124,7 +1494,7 @@ func (p *Parser) parseStructDecl(pub bool) *ast.StructDecl {
 			p.nextToken() // consume type
 			break
 		} else {
-			p.error("expected comma or } after field")
+			p.errorf(diag.ParseFieldSep)
 			return nil
 		}
 	}
@@ -1147,7 +1517,7 @@ func (p *Parser) parseEnumDecl(pub bool) *ast.EnumDecl {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected enum name")
+		p.errorf(diag.ParseEnumName)
 		return nil
 	}
 
@@ -1160,7 +1530,7 @@ func (p *Parser) parseEnumDecl(pub bool) *ast.EnumDecl {
 
 		for !p.curTokenIs(lexer.GT) && !p.curTokenIs(lexer.EOF) {
 			if !p.curTokenIs(lexer.IDENT) {
-				p.error("expected type parameter name")
+				p.errorf(diag.ParseTypeParam)
 				return nil
 			}
 
@@ -1189,7 +1559,7 @@ func (p *Parser) parseEnumDecl(pub bool) *ast.EnumDecl {
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
 		// Parse variant name
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected variant name")
+			p.errorf(diag.ParseVariantName)
 			return nil
 		}
 
@@ -1217,6 +1587,14 @@ func (p *Parser) parseEnumDecl(pub bool) *ast.EnumDecl {
 			}
 		}
 
+		// Check for explicit discriminant
+		if p.peekTokenIs(lexer.ASSIGN) {
+			p.nextToken() // consume name
+			p.nextToken() // consume =
+
+			variant.Value = p.parseExpression(LOWEST)
+		}
+
 		decl.Variants = append(decl.Variants, variant)
 
 		// Check for comma or }
@@ -1227,7 +1605,7 @@ func (p *Parser) parseEnumDecl(pub bool) *ast.EnumDecl {
 			p.nextToken() // consume variant/paren
 			break
 		} else {
-			p.error("expected comma or } after variant")
+			p.errorf(diag.ParseVariantSep)
 			return nil
 		}
 	}
@@ -1250,7 +1628,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected trait name")
+		p.errorf(diag.ParseTraitName)
 		return nil
 	}
 
@@ -1263,7 +1641,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 		for !p.curTokenIs(lexer.GT) && !p.curTokenIs(lexer.EOF) {
 			if !p.curTokenIs(lexer.IDENT) {
-				p.error("expected type parameter name")
+				p.errorf(diag.ParseTypeParam)
 				return nil
 			}
 
@@ -1298,7 +1676,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 		// Parse fn signature
 		if !p.curTokenIs(lexer.FN) {
-			p.error("expected fn in trait")
+			p.errorf(diag.ParseTraitFn)
 			return nil
 		}
 
@@ -1308,7 +1686,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 		// Parse name
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected method name")
+			p.errorf(diag.ParseMethodName)
 			return nil
 		}
 
@@ -1333,7 +1711,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 			// Parse name
 			if !p.curTokenIs(lexer.IDENT) && !p.curTokenIs(lexer.AMP) {
-				p.error("expected parameter name")
+				p.errorf(diag.ParseParamName)
 				return nil
 			}
 
@@ -1355,6 +1733,12 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 			} else {
 				param.Name = p.curToken.Literal
 				p.nextToken()
+				if p.curTokenIs(lexer.ELLIPSIS) {
+					param.Variadic = true
+
+					p.nextToken()
+				}
+
 				param.Type = p.parseType()
 			}
 
@@ -1367,21 +1751,21 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 				p.nextToken() // consume type/self, move to )
 				break
 			} else {
-				p.error("expected comma or ) after parameter")
+				p.errorf(diag.ParseParamSep)
 				return nil
 			}
 		}
 
 		// curToken should now be RPAREN
 		if !p.curTokenIs(lexer.RPAREN) {
-			p.error("expected )")
+			p.errorf(diag.ParseCloseParen)
 			return nil
 		}
 
 		// Parse return type
-		if p.peekTokenIs(lexer.IDENT) || p.peekTokenIs(lexer.AMP) ||
+		if p.peekTokenIs(lexer.IDENT) || p.peekTokenIs(lexer.AMP) || p.peekTokenIs(lexer.AND) ||
 			p.peekTokenIs(lexer.STAR) || p.peekTokenIs(lexer.LBRACKET) ||
-			p.peekTokenIs(lexer.LPAREN) || p.peekTokenIs(lexer.VOID) {
+			p.peekTokenIs(lexer.LPAREN) || p.peekTokenIs(lexer.VOID) || p.peekTokenIs(lexer.FN) {
 			p.nextToken() // consume )
 			sig.Return = p.parseType()
 		}
@@ -1399,7 +1783,7 @@ func (p *Parser) parseTraitDecl(pub bool) *ast.TraitDecl {
 
 	// curToken should be at } from loop termination
 	if !p.curTokenIs(lexer.RBRACE) {
-		p.error("expected } at end of trait")
+		p.errorf(diag.ParseTraitEnd)
 		return nil
 	}
 
@@ -1441,6 +1825,8 @@ func (p *Parser) parseImplBlock() *ast.ImplBlock {
 			continue
 		}
 
+		start := p.curToken
+
 		// Parse function (can be pub or not)
 		pub := false
 		if p.curTokenIs(lexer.PUB) {
@@ -1450,12 +1836,13 @@ func (p *Parser) parseImplBlock() *ast.ImplBlock {
 		}
 
 		if !p.curTokenIs(lexer.FN) {
-			p.error("expected fn in impl block")
+			p.errorf(diag.ParseImplFn)
 			return nil
 		}
 
 		fn := p.parseFuncDecl(pub)
 		if fn != nil {
+			p.span(fn, start)
 			impl.Fns = append(impl.Fns, fn)
 		}
 
@@ -1464,7 +1851,7 @@ func (p *Parser) parseImplBlock() *ast.ImplBlock {
 
 	// curToken should be at } from loop termination
 	if !p.curTokenIs(lexer.RBRACE) {
-		p.error("expected } at end of impl block")
+		p.errorf(diag.ParseImplEnd)
 		return nil
 	}
 
@@ -1478,7 +1865,7 @@ func (p *Parser) parseTypeAlias() *ast.TypeAlias {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected type name")
+		p.errorf(diag.ParseTypeName)
 		return nil
 	}
 
@@ -1504,7 +1891,7 @@ func (p *Parser) parseConstDecl() *ast.ConstDecl {
 
 	// Parse name
 	if !p.curTokenIs(lexer.IDENT) {
-		p.error("expected const name")
+		p.errorf(diag.ParseConstName)
 		return nil
 	}
 
@@ -1556,7 +1943,7 @@ func (p *Parser) parseUseDecl() *ast.UseDecl {
 		p.nextToken() // consume as
 
 		if !p.curTokenIs(lexer.IDENT) {
-			p.error("expected alias name")
+			p.errorf(diag.ParseAliasName)
 			return nil
 		}
 
@@ -1608,8 +1995,17 @@ func (p *Parser) ParseFile() *ast.File {
 			continue
 		}
 
+		attrs := p.parseAttributes()
+
+		start := p.curToken
 		decl := p.parseDeclaration()
+		p.span(decl, start)
+
 		if decl != nil {
+			if len(attrs) > 0 {
+				p.attach(decl, attrs)
+			}
+
 			file.Items = append(file.Items, decl)
 		}
 
@@ -1618,3 +2014,71 @@ func (p *Parser) ParseFile() *ast.File {
 
 	return file
 }
+
+// parseAttributes parses the #[...] lines before an item, leaving the
+// current token on the item itself
+func (p *Parser) parseAttributes() []*ast.Attribute {
+	var attrs []*ast.Attribute
+
+	for p.curTokenIs(lexer.HASH) {
+		if !p.expectPeek(lexer.LBRACKET) || !p.expectPeek(lexer.IDENT) {
+			return attrs
+		}
+
+		attr := &ast.Attribute{Name: p.curToken.Literal}
+
+		if p.peekTokenIs(lexer.LPAREN) {
+			p.nextToken() // consume name
+
+			for p.peekTokenIs(lexer.IDENT) {
+				p.nextToken()
+				attr.Args = append(attr.Args, p.curToken.Literal)
+
+				if !p.peekTokenIs(lexer.COMMA) {
+					break
+				}
+
+				p.nextToken() // consume ,
+			}
+
+			if !p.expectPeek(lexer.RPAREN) {
+				return attrs
+			}
+		}
+
+		if !p.expectPeek(lexer.RBRACKET) {
+			return attrs
+		}
+
+		attrs = append(attrs, attr)
+
+		p.nextToken() // consume ]
+
+		for p.curTokenIs(lexer.NEWLINE) {
+			p.nextToken()
+		}
+	}
+
+	return attrs
+}
+
+// attach gives attrs to decl, for the kinds of item that take attributes.
+// decl may be a typed nil if the item failed to parse.
+func (p *Parser) attach(decl ast.Decl, attrs []*ast.Attribute) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d != nil {
+			d.Attrs = attrs
+		}
+	case *ast.ImplBlock:
+		if d != nil {
+			d.Attrs = attrs
+		}
+	case *ast.UseDecl:
+		if d != nil {
+			d.Attrs = attrs
+		}
+	default:
+		p.errorf(diag.ParseAttrTarget, attrs[0].String())
+	}
+}
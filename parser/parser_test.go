@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/yarlson/yarlang/ast"
 	"github.com/yarlson/yarlang/lexer"
 )
 
@@ -64,6 +65,18 @@ func TestParseTypes(t *testing.T) {
 		{"Result<Vec<i32>, std::io::Error>", "Result<Vec<i32>, std::io::Error>"},
 		{"&mut Vec<String>", "&mut Vec<String>"},
 		{"HashMap<String, Vec<i32> >", "HashMap<String, Vec<i32>>"},
+
+		// >> and && split where a type needs two tokens
+		{"Vec<Vec<i32>>", "Vec<Vec<i32>>"},
+		{"A<B<C<i32>>, D>", "A<B<C<i32>>, D>"},
+		{"&&T", "&&T"},
+		{"&&mut T", "&&mut T"},
+
+		// Function types
+		{"fn(i32, f64) bool", "fn(i32, f64) bool"},
+		{"fn()", "fn()"},
+		{"fn(fn(i32) i32) fn() i32", "fn(fn(i32) i32) fn() i32"},
+		{"[]fn(&T)", "[]fn(&T)"},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +109,9 @@ func TestParseBinaryExpr(t *testing.T) {
 		{"a < b && c > d", "((a < b) && (c > d))"},
 		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
 		{"1 << 2 + 3", "(1 << (2 + 3))"},
+		{"a * b as i64", "(a * (b as i64))"},
+		{"x as u8 as i32", "((x as u8) as i32)"},
+		{"-x as f64 + 1.5", "(((-x) as f64) + 1.5)"},
 		// NOTE: Assignment is a statement, not an expression in YarLang
 		// {"a = b = c", "(a = (b = c))"},
 		// {"x += y += z", "(x += (y += z))"},
@@ -160,6 +176,16 @@ func TestParsePostfixExpr(t *testing.T) {
 		{"p.x.y", "p.x.y"},
 		{"arr[i][j]", "arr[i][j]"},
 		{"f().g()", "f().g()"},
+		{"t.0", "t.0"},
+		{"t.1.0", "t.1.0"},
+		{"Color::Red", "Color::Red"},
+		{"Option::Some(5)", "Option::Some(5)"},
+		{"std::io::println(x)", "std::io::println(x)"},
+		{"f()?.0", "f()?.0"},
+		{"P{}.x", "P{  }.x"},
+		{"P { x: 1, }", "P{ x: 1 }"},
+		{"(a,)", "(a,)"},
+		{"(a, b,)[0]", "(a, b)[0]"},
 	}
 
 	for _, tt := range tests {
@@ -218,6 +244,8 @@ func TestParseLetStmt(t *testing.T) {
 		{"let x: i32 = 5", "let x: i32 = 5"},
 		{"let mut x: i32 = 5", "let mut x: i32 = 5"},
 		{"let x = 1 + 2", "let x = (1 + 2)"},
+		{"let (q, r) = divmod(7, 2)", "let (q, r) = divmod(7, 2)"},
+		{"let (a, b, c) = t", "let (a, b, c) = t"},
 	}
 
 	for _, tt := range tests {
@@ -235,6 +263,92 @@ func TestParseLetStmt(t *testing.T) {
 	}
 }
 
+func TestParseLetTupleNeedsCommas(t *testing.T) {
+	for _, input := range []string{"let (a b) = t", "let (a, b c) = t"} {
+		l := lexer.New(input)
+		p := New(l)
+		p.parseStatement()
+
+		want := "expected comma or ) after name in tuple pattern"
+		if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], want) {
+			t.Errorf("%q: expected %q, got %v", input, want, p.Errors())
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"fn main() {\n    let x = )\n}", 2, 13},
+		{"fn main() {\n\tlet x =\n}", 2, 8},
+		{"fn main() {\n\tlet y = x +\n\n}", 2, 12},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseFile()
+
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+
+		if d := diags[0]; d.Line != tt.line || d.Column != tt.column {
+			t.Errorf("%q: expected an error at %d:%d, got %d:%d: %s", tt.input, tt.line, tt.column, d.Line, d.Column, d.Message)
+		}
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	p := New(lexer.New("fn main() {\n\tlet = 1\n}"))
+	p.SetLocale("de")
+	p.ParseFile()
+
+	want := "line 2: Bezeichner nach let erwartet"
+	if errs := p.Errors(); len(errs) == 0 || errs[0] != want {
+		t.Errorf("expected %q first, got %v", want, errs)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "use std::os\n\nfn main() {\n\tlet x = f(a, \"b\") + 1\n\tif x > 0 {\n\t}\n}"
+
+	p := New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	fn := file.Items[1].(*ast.FuncDecl)
+	let := fn.Body.Stmts[0].(*ast.LetStmt)
+	sum := let.Value.(*ast.BinaryExpr)
+	call := sum.Left.(*ast.CallExpr)
+
+	tests := []struct {
+		node ast.Node
+		want ast.Span
+	}{
+		{file.Items[0], ast.Span{Line: 1, Column: 1, Length: 11}},
+		{fn, ast.Span{Line: 3, Column: 1, Length: 2}}, // spans lines, so only fn
+		{let, ast.Span{Line: 4, Column: 2, Length: 21}},
+		{sum, ast.Span{Line: 4, Column: 10, Length: 13}},
+		{call, ast.Span{Line: 4, Column: 10, Length: 9}},
+		{call.Args[1], ast.Span{Line: 4, Column: 15, Length: 3}}, // with its quotes
+		{fn.Body.Stmts[1], ast.Span{Line: 5, Column: 2, Length: 2}},
+	}
+
+	for _, tt := range tests {
+		if got := *tt.node.(interface{ Pos() *ast.Span }).Pos(); got != tt.want {
+			t.Errorf("%s: expected span %+v, got %+v", tt.node, tt.want, got)
+		}
+	}
+}
+
 func TestParseAssignStmt(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -317,6 +431,57 @@ func TestParseIfStmt(t *testing.T) {
 	}
 }
 
+func TestParseGuardStmt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"guard b != 0 else { return 0 }", "guard (b != 0) else { return 0 }"},
+		{"guard ok else { continue }", "guard ok else { continue }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		stmt := p.parseStatement()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong stmt. expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestParseConditionStructLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while i < n { i += 1 }", "while (i < n) { i += 1 }"},
+		{"for v in values { println(v) }", "for v in values { println(v) }"},
+		{"if (P { x: 1 }).x == 1 { y }", "if (P{ x: 1 }.x == 1) { y }"},
+		{"if f(P { x: 1 }) { y }", "if f(P{ x: 1 }) { y }"},
+		{"if ok { P { x: 1 } }", "if ok { P{ x: 1 } }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		stmt := p.parseStatement()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong stmt. expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
 func TestParseLoops(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -400,6 +565,80 @@ func TestParseDeferAndUnsafe(t *testing.T) {
 	}
 }
 
+func TestParseDeclStmt(t *testing.T) {
+	tests := []struct {
+		input    string
+		contains []string
+	}{
+		{"{ fn sq(x i32) i32 { return x * x } }", []string{"fn sq(x i32) i32"}},
+		{"{ struct Point { x: i32, y: i32 } }", []string{"struct Point"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		stmt := p.parseStatement()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		block, ok := stmt.(*ast.Block)
+		if !ok || len(block.Stmts) != 1 {
+			t.Fatalf("expected block with one statement, got %q", stmt.String())
+		}
+
+		if _, ok := block.Stmts[0].(*ast.DeclStmt); !ok {
+			t.Fatalf("expected *ast.DeclStmt, got %T", block.Stmts[0])
+		}
+
+		result := stmt.String()
+		for _, str := range tt.contains {
+			if !strings.Contains(result, str) {
+				t.Errorf("expected %q to contain %q", result, str)
+			}
+		}
+	}
+}
+
+func TestParseEnumDiscriminants(t *testing.T) {
+	l := lexer.New("enum Level { Low = 1, Mid, High = 1 << 4 }")
+	p := New(l)
+	decl := p.parseDeclaration()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	enum := decl.(*ast.EnumDecl)
+
+	want := []string{"1", "", "(1 << 4)"}
+	for i, v := range enum.Variants {
+		got := ""
+		if v.Value != nil {
+			got = v.Value.String()
+		}
+
+		if got != want[i] {
+			t.Errorf("variant %s: expected discriminant %q, got %q", v.Name, want[i], got)
+		}
+	}
+}
+
+func TestParseConstStmt(t *testing.T) {
+	l := lexer.New("const SIZE: i32 = 4 * 1024")
+	p := New(l)
+	stmt := p.parseStatement()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if got := stmt.String(); got != "const SIZE: i32 = (4 * 1024)" {
+		t.Errorf("wrong stmt. got=%q", got)
+	}
+}
+
 func TestParseFuncDecl(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -409,6 +648,7 @@ func TestParseFuncDecl(t *testing.T) {
 		{"pub fn mul(x i32, y i32) i32 { return x * y }", []string{"pub", "fn", "mul"}},
 		{"fn generic<T>(x T) T { return x }", []string{"fn", "generic", "<T>"}},
 		{"fn void_fn() { println(\"hi\") }", []string{"fn", "void_fn"}},
+		{"fn sum(base i32, nums ...i32) i32 { return base }", []string{"fn sum(base i32, nums ...i32) i32"}},
 	}
 
 	for _, tt := range tests {
@@ -567,3 +807,77 @@ func TestParseSimpleDecls(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAttributes(t *testing.T) {
+	input := `#[allow(unused_variables, dead_code)]
+#[deny(shadowing)]
+fn f() {}
+
+#[allow(unused_imports)] use std::io
+
+fn g() {}`
+
+	l := lexer.New(input)
+	p := New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if len(file.Items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(file.Items))
+	}
+
+	var got []string
+	for _, attr := range file.Items[0].(*ast.FuncDecl).Attrs {
+		got = append(got, attr.String())
+	}
+
+	want := "#[allow(unused_variables, dead_code)] #[deny(shadowing)]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("wrong fn attributes. expected=%q, got=%q", want, strings.Join(got, " "))
+	}
+
+	if attrs := file.Items[1].(*ast.UseDecl).Attrs; len(attrs) != 1 || attrs[0].String() != "#[allow(unused_imports)]" {
+		t.Errorf("wrong use attributes: %v", attrs)
+	}
+
+	if attrs := file.Items[2].(*ast.FuncDecl).Attrs; len(attrs) != 0 {
+		t.Errorf("expected no attributes on g, got %v", attrs)
+	}
+}
+
+func TestParseAttributeOnStruct(t *testing.T) {
+	l := lexer.New("#[allow(dead_code)]\nstruct S { x: i32 }")
+	p := New(l)
+	p.ParseFile()
+
+	want := "attribute #[allow(dead_code)] can only be applied to fn, impl and use declarations"
+	if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], want) {
+		t.Errorf("expected %q, got %v", want, p.Errors())
+	}
+}
+
+func TestParseExternDecl(t *testing.T) {
+	l := lexer.New("extern fn write(fd i32, buf *u8, n usize) isize\n\nfn main() {}")
+	p := New(l)
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	fn, ok := file.Items[0].(*ast.FuncDecl)
+	if !ok || !fn.Extern || fn.Body != nil {
+		t.Fatalf("expected a bodiless extern fn, got %#v", file.Items[0])
+	}
+
+	if want := "extern fn write(fd i32, buf *u8, n usize) isize"; fn.String() != want {
+		t.Errorf("wrong decl. expected=%q, got=%q", want, fn.String())
+	}
+
+	if len(file.Items) != 2 {
+		t.Errorf("expected 2 items, got %d", len(file.Items))
+	}
+}
@@ -1,24 +1,1107 @@
 //go:build ignore
 // +build ignore
 
+#define _XOPEN_SOURCE 700
+
+#include <ctype.h>
+#include <errno.h>
+#include <fcntl.h>
+#include <ftw.h>
+#include <poll.h>
+#include <signal.h>
 #include <stdbool.h>
+#include <regex.h>
+#include <stdarg.h>
 #include <stdint.h>
 #include <stdio.h>
 #include <stdlib.h>
+#include <string.h>
+#include <sys/resource.h>
+#include <sys/stat.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+// A str is passed as a pointer and a byte length; the bytes need not be
+// NUL-terminated. Functions returning a str return this pair.
+typedef struct {
+    const char *ptr;
+    int64_t len;
+} yar_str;
+
+// The compiler passes its version with -DYAR_VERSION when it builds the
+// runtime.
+#ifndef YAR_VERSION
+#define YAR_VERSION "dev"
+#endif
+
+// Settings read from the environment before main runs:
+//   YAR_LOG         comma-separated runtime events to trace on stderr:
+//                   alloc, process, or all
+//   YAR_STACK_SIZE  soft stack limit in bytes, with an optional K, M or G
+//                   suffix; raised at most to the hard limit
+//   YAR_GC          accepted for forward compatibility; only "off" is
+//                   meaningful, since memory is never collected
+enum { LOG_ALLOC = 1, LOG_PROCESS = 2 };
+
+static int log_mask;
+static int64_t alloc_count;
+static int64_t alloc_bytes;
+
+static void trace(int event, const char *format, ...) {
+    if (!(log_mask & event)) {
+        return;
+    }
+    va_list args;
+    va_start(args, format);
+    fprintf(stderr, "yar: ");
+    vfprintf(stderr, format, args);
+    fputc('\n', stderr);
+    va_end(args);
+}
+
+static void *yar_alloc(size_t n) {
+    alloc_count++;
+    alloc_bytes += (int64_t)n;
+    trace(LOG_ALLOC, "alloc %zu bytes", n);
+    return malloc(n);
+}
+
+static void *yar_realloc(void *p, size_t n) {
+    alloc_count++;
+    alloc_bytes += (int64_t)n;
+    trace(LOG_ALLOC, "realloc %zu bytes", n);
+    return realloc(p, n);
+}
+
+static void parse_log(const char *spec) {
+    char *list = strdup(spec);
+    for (char *name = strtok(list, ","); name != NULL; name = strtok(NULL, ",")) {
+        if (strcmp(name, "alloc") == 0) {
+            log_mask |= LOG_ALLOC;
+        } else if (strcmp(name, "process") == 0) {
+            log_mask |= LOG_PROCESS;
+        } else if (strcmp(name, "all") == 0) {
+            log_mask = ~0;
+        } else {
+            fprintf(stderr, "yar: YAR_LOG: unknown event %s\n", name);
+        }
+    }
+    free(list);
+}
+
+static void set_stack_size(const char *spec) {
+    char *end;
+    unsigned long long size = strtoull(spec, &end, 10);
+    switch (*end) {
+    case 'K': case 'k': size <<= 10; end++; break;
+    case 'M': case 'm': size <<= 20; end++; break;
+    case 'G': case 'g': size <<= 30; end++; break;
+    }
+    if (end == spec || *end != '\0' || size == 0) {
+        fprintf(stderr, "yar: YAR_STACK_SIZE: invalid size %s\n", spec);
+        return;
+    }
 
-void println(const char *msg) {
-    printf("%s\n", msg);
+    struct rlimit rl;
+    if (getrlimit(RLIMIT_STACK, &rl) != 0) {
+        return;
+    }
+    rl.rlim_cur = (rlim_t)size;
+    if (rl.rlim_max != RLIM_INFINITY && rl.rlim_cur > rl.rlim_max) {
+        rl.rlim_cur = rl.rlim_max;
+    }
+    if (setrlimit(RLIMIT_STACK, &rl) != 0) {
+        fprintf(stderr, "yar: YAR_STACK_SIZE: cannot set the stack limit\n");
+    }
+}
+
+__attribute__((constructor)) static void configure(void) {
+    const char *v;
+    if ((v = getenv("YAR_LOG")) != NULL) {
+        parse_log(v);
+    }
+    if ((v = getenv("YAR_STACK_SIZE")) != NULL) {
+        set_stack_size(v);
+    }
+    if ((v = getenv("YAR_GC")) != NULL && strcmp(v, "off") != 0) {
+        fprintf(stderr, "yar: YAR_GC=%s ignored: the runtime has no garbage collector\n", v);
+    }
+}
+
+// The command line, as the C main the compiler generates receives it.
+static int program_argc;
+static char **program_argv;
+
+void yar_set_args(int32_t argc, char **argv) {
+    program_argc = argc;
+    program_argv = argv;
+}
+
+// Number of command-line arguments, counting the program name.
+int64_t arg_count(void) {
+    return program_argc;
+}
+
+// Command-line argument i; argument 0 is the program name.
+yar_str arg_at(int64_t i) {
+    if (i < 0 || i >= program_argc) {
+        fprintf(stderr, "panic: argument index %lld out of range for %d arguments\n", (long long)i, program_argc);
+        exit(1);
+    }
+    return (yar_str){program_argv[i], (int64_t)strlen(program_argv[i])};
+}
+
+// A []str, as os_args returns it.
+typedef struct {
+    yar_str *ptr;
+    int64_t len;
+} yar_strs;
+
+// The command-line arguments, program name first, made on the first call.
+yar_strs os_args(void) {
+    static yar_str *args;
+    if (args == NULL && program_argc > 0) {
+        args = yar_alloc(sizeof(yar_str) * (size_t)program_argc);
+        for (int i = 0; i < program_argc; i++) {
+            args[i] = (yar_str){program_argv[i], (int64_t)strlen(program_argv[i])};
+        }
+    }
+    return (yar_strs){args, program_argc};
+}
+
+yar_str runtime_version(void) {
+    return (yar_str){YAR_VERSION, (int64_t)strlen(YAR_VERSION)};
+}
+
+// Number of heap allocations the runtime has made, counting each resize.
+int64_t runtime_allocs(void) {
+    return alloc_count;
+}
+
+// Total bytes requested by those allocations.
+int64_t runtime_alloc_bytes(void) {
+    return alloc_bytes;
+}
+
+// The soft stack limit in bytes, or -1 if it is unlimited.
+int64_t runtime_stack_size(void) {
+    struct rlimit rl;
+    if (getrlimit(RLIMIT_STACK, &rl) != 0 || rl.rlim_cur == RLIM_INFINITY) {
+        return -1;
+    }
+    return (int64_t)rl.rlim_cur;
+}
+
+// Copies a str into a new NUL-terminated string for C APIs.
+static char *cstr(const char *s, int64_t len) {
+    char *out = yar_alloc((size_t)len + 1);
+    memcpy(out, s, (size_t)len);
+    out[len] = '\0';
+    return out;
+}
+
+void println(const char *msg, int64_t len) {
+    printf("%.*s\n", (int)len, msg);
 }
 
 void println_i32(int32_t value) {
     printf("%d\n", value);
 }
 
+void println_i64(int64_t value) {
+    printf("%lld\n", (long long)value);
+}
+
+// Writes the shortest decimal form that reads back as the same double.
+static void shortest_f64(char buf[32], double value) {
+    for (int prec = 1; prec <= 17; prec++) {
+        snprintf(buf, 32, "%.*g", prec, value);
+        if (strtod(buf, NULL) == value) {
+            break;
+        }
+    }
+}
+
+// Like shortest_f64, for the shortest form that reads back as the same float.
+static void shortest_f32(char buf[32], float value) {
+    for (int prec = 1; prec <= 9; prec++) {
+        snprintf(buf, 32, "%.*g", prec, value);
+        if (strtof(buf, NULL) == value) {
+            break;
+        }
+    }
+}
+
+void println_f64(double value) {
+    char buf[32];
+    shortest_f64(buf, value);
+    printf("%s\n", buf);
+}
+
+void println_f32(float value) {
+    char buf[32];
+    shortest_f32(buf, value);
+    printf("%s\n", buf);
+}
+
 void println_bool(bool value) {
     printf(value ? "true\n" : "false\n");
 }
 
-void panic(const char *msg) {
-    fprintf(stderr, "panic: %s\n", msg);
+// The pieces of println with a format string. Each prints one value with no
+// newline; the compiler ends the line by printing the last piece of text
+// with println.
+void yar_print_str(const char *s, int64_t len) {
+    printf("%.*s", (int)len, s);
+}
+
+void yar_print_i64(int64_t value) {
+    printf("%lld", (long long)value);
+}
+
+void yar_print_u64(uint64_t value) {
+    printf("%llu", (unsigned long long)value);
+}
+
+void yar_print_f64(double value) {
+    char buf[32];
+    shortest_f64(buf, value);
+    fputs(buf, stdout);
+}
+
+void yar_print_f32(float value) {
+    char buf[32];
+    shortest_f32(buf, value);
+    fputs(buf, stdout);
+}
+
+void yar_print_bool(bool value) {
+    fputs(value ? "true" : "false", stdout);
+}
+
+// Prints a char as UTF-8.
+void yar_print_char(int32_t c) {
+    char buf[4];
+    int n;
+    if (c < 0x80) {
+        buf[0] = (char)c;
+        n = 1;
+    } else if (c < 0x800) {
+        buf[0] = (char)(0xC0 | (c >> 6));
+        buf[1] = (char)(0x80 | (c & 0x3F));
+        n = 2;
+    } else if (c < 0x10000) {
+        buf[0] = (char)(0xE0 | (c >> 12));
+        buf[1] = (char)(0x80 | ((c >> 6) & 0x3F));
+        buf[2] = (char)(0x80 | (c & 0x3F));
+        n = 3;
+    } else {
+        buf[0] = (char)(0xF0 | (c >> 18));
+        buf[1] = (char)(0x80 | ((c >> 12) & 0x3F));
+        buf[2] = (char)(0x80 | ((c >> 6) & 0x3F));
+        buf[3] = (char)(0x80 | (c & 0x3F));
+        n = 4;
+    }
+    fwrite(buf, 1, (size_t)n, stdout);
+}
+
+void panic(const char *msg, int64_t len) {
+    fprintf(stderr, "panic: %.*s\n", (int)len, msg);
     exit(1);
 }
+
+bool str_eq(const char *a, int64_t alen, const char *b, int64_t blen) {
+    return alen == blen && memcmp(a, b, (size_t)alen) == 0;
+}
+
+// Joins two strs into a new one. Like the other strs the runtime makes, it
+// lives until the program exits.
+yar_str str_concat(const char *a, int64_t alen, const char *b, int64_t blen) {
+    char *out = yar_alloc((size_t)(alen + blen));
+    memcpy(out, a, (size_t)alen);
+    memcpy(out + alen, b, (size_t)blen);
+    return (yar_str){out, alen + blen};
+}
+
+// Orders two strs by their bytes, returning -1, 0 or 1.
+int32_t str_compare(const char *a, int64_t alen, const char *b, int64_t blen) {
+    int64_t n = alen < blen ? alen : blen;
+    int c = memcmp(a, b, (size_t)n);
+    if (c == 0) {
+        c = alen < blen ? -1 : alen > blen;
+    }
+    return c < 0 ? -1 : c > 0;
+}
+
+// The bytes of s from start up to end, as a view into s.
+yar_str str_slice(const char *s, int64_t n, int64_t start, int64_t end) {
+    if (start < 0 || start > end || end > n) {
+        fprintf(stderr, "panic: str slice [%lld:%lld] out of range for length %lld\n", (long long)start, (long long)end, (long long)n);
+        exit(1);
+    }
+    return (yar_str){s + start, end - start};
+}
+
+// Byte offset of the first occurrence of sub in s, or -1.
+int64_t str_index(const char *s, int64_t n, const char *sub, int64_t subn) {
+    for (int64_t i = 0; i + subn <= n; i++) {
+        if (memcmp(s + i, sub, (size_t)subn) == 0) {
+            return i;
+        }
+    }
+    return -1;
+}
+
+// Formats v in decimal.
+yar_str str_from_int(int64_t v) {
+    char buf[24];
+    int n = snprintf(buf, sizeof buf, "%lld", (long long)v);
+    char *out = yar_alloc((size_t)n);
+    memcpy(out, buf, (size_t)n);
+    return (yar_str){out, n};
+}
+
+// Parses a decimal integer with an optional sign. Anything else, or a value
+// that does not fit an i64, panics.
+int64_t str_to_int(const char *s, int64_t n) {
+    char *text = cstr(s, n);
+    char *end;
+    errno = 0;
+    long long v = strtoll(text, &end, 10);
+    if (n == 0 || *end != '\0' || errno == ERANGE || isspace((unsigned char)text[0])) {
+        fprintf(stderr, "panic: invalid integer %.*s\n", (int)n, s);
+        exit(1);
+    }
+    return v;
+}
+
+// Compares two strings in time that depends only on their lengths.
+// The accumulator is volatile so the loop cannot exit early.
+bool ct_eq(const char *a, int64_t alen, const char *b, int64_t blen) {
+    size_t la = (size_t)alen;
+    size_t lb = (size_t)blen;
+    size_t n = la > lb ? la : lb;
+    volatile uint8_t diff = la != lb;
+    for (size_t i = 0; i < n; i++) {
+        uint8_t x = i < la ? (uint8_t)a[i] : 0;
+        uint8_t y = i < lb ? (uint8_t)b[i] : 0;
+        diff |= x ^ y;
+    }
+    return diff == 0;
+}
+
+// Compiles pattern as a POSIX extended regular expression, or panics.
+static void compile_regex(regex_t *re, const char *pattern, int64_t plen) {
+    char *p = cstr(pattern, plen);
+    if (regcomp(re, p, REG_EXTENDED) != 0) {
+        fprintf(stderr, "panic: invalid regex: %s\n", p);
+        exit(1);
+    }
+    free(p);
+}
+
+bool regex_match(const char *pattern, int64_t plen, const char *s, int64_t slen) {
+    regex_t re;
+    compile_regex(&re, pattern, plen);
+    char *text = cstr(s, slen);
+    bool found = regexec(&re, text, 0, NULL, 0) == 0;
+    free(text);
+    regfree(&re);
+    return found;
+}
+
+// Returns the byte offset of the first match, or -1.
+int32_t regex_find(const char *pattern, int64_t plen, const char *s, int64_t slen) {
+    regex_t re;
+    regmatch_t m;
+    compile_regex(&re, pattern, plen);
+    char *text = cstr(s, slen);
+    int32_t at = regexec(&re, text, 1, &m, 0) == 0 ? (int32_t)m.rm_so : -1;
+    free(text);
+    regfree(&re);
+    return at;
+}
+
+// Length of the UTF-8 character that starts with the byte c, at most left.
+static size_t utf8_len(unsigned char c, size_t left) {
+    size_t n = c >= 0xF0 ? 4 : c >= 0xE0 ? 3 : c >= 0xC0 ? 2 : 1;
+    return n < left ? n : left;
+}
+
+// Returns a new string with every match replaced by repl.
+yar_str regex_replace(const char *pattern, int64_t plen, const char *str, int64_t slen,
+                      const char *repl, int64_t replen) {
+    regex_t re;
+    regmatch_t m;
+    compile_regex(&re, pattern, plen);
+
+    char *text = cstr(str, slen);
+    const char *s = text;
+    size_t rlen = (size_t)replen;
+    size_t cap = strlen(s) + 1;
+    size_t len = 0;
+    char *out = yar_alloc(cap);
+    const char *stop = s + strlen(s);
+    int flags = 0;
+    bool after = false; // whether a non-empty match ended at s
+
+    while (regexec(&re, s, 1, &m, flags) == 0) {
+        size_t start = (size_t)m.rm_so;
+        size_t end = (size_t)m.rm_eo;
+        size_t left = (size_t)(stop - s) - end;
+        bool empty = end == start;
+        // An empty match right after a match is not replaced
+        size_t with = empty && after && start == 0 ? 0 : rlen;
+        // An empty match copies the character after it so the scan moves
+        // forward; at the end of the input there is none
+        size_t skip = empty ? utf8_len((unsigned char)s[end], left) : 0;
+
+        if (len + end + with + skip + 1 > cap) {
+            cap = (len + end + with + skip + 1) * 2;
+            out = yar_realloc(out, cap);
+        }
+
+        memcpy(out + len, s, start);
+        len += start;
+        memcpy(out + len, repl, with);
+        len += with;
+        memcpy(out + len, s + end, skip);
+        len += skip;
+
+        s += end + skip;
+        flags = REG_NOTBOL;
+        after = !empty;
+
+        if (empty && skip == 0) {
+            break;
+        }
+    }
+
+    size_t rest = strlen(s);
+    if (len + rest + 1 > cap) {
+        out = yar_realloc(out, len + rest + 1);
+    }
+    memcpy(out + len, s, rest + 1);
+    len += rest;
+
+    free(text);
+    regfree(&re);
+    return (yar_str){out, (int64_t)len};
+}
+
+// Returns the shortest path equivalent to p, processing it lexically:
+// repeated slashes collapse, . elements go, and .. removes the element
+// before it where there is one. An empty result is ".".
+static yar_str clean_path(const char *p, int64_t n) {
+    char *out = yar_alloc((size_t)n + 2);
+    bool rooted = n > 0 && p[0] == '/';
+    int64_t len = 0;
+    int64_t dotdot = 0; // out[:dotdot] holds .. elements that cannot be removed
+    int64_t i = 0;
+
+    if (rooted) {
+        out[len++] = '/';
+        i = 1;
+        dotdot = 1;
+    }
+
+    while (i < n) {
+        if (p[i] == '/') {
+            i++;
+        } else if (p[i] == '.' && (i + 1 == n || p[i + 1] == '/')) {
+            i++;
+        } else if (p[i] == '.' && i + 1 < n && p[i + 1] == '.' && (i + 2 == n || p[i + 2] == '/')) {
+            i += 2;
+            if (len > dotdot) {
+                len--;
+                while (len > dotdot && out[len] != '/') {
+                    len--;
+                }
+            } else if (!rooted) {
+                if (len > 0) {
+                    out[len++] = '/';
+                }
+                out[len++] = '.';
+                out[len++] = '.';
+                dotdot = len;
+            }
+        } else {
+            if ((rooted && len != 1) || (!rooted && len != 0)) {
+                out[len++] = '/';
+            }
+            while (i < n && p[i] != '/') {
+                out[len++] = p[i++];
+            }
+        }
+    }
+
+    if (len == 0) {
+        out[len++] = '.';
+    }
+
+    return (yar_str){out, len};
+}
+
+yar_str path_clean(const char *p, int64_t n) {
+    return clean_path(p, n);
+}
+
+// Joins two paths with a slash and cleans the result. An empty element
+// is ignored.
+yar_str path_join(const char *a, int64_t alen, const char *b, int64_t blen) {
+    if (alen == 0) {
+        return clean_path(b, blen);
+    }
+    if (blen == 0) {
+        return clean_path(a, alen);
+    }
+
+    char *joined = yar_alloc((size_t)(alen + blen + 1));
+    memcpy(joined, a, (size_t)alen);
+    joined[alen] = '/';
+    memcpy(joined + alen + 1, b, (size_t)blen);
+
+    yar_str out = clean_path(joined, alen + blen + 1);
+    free(joined);
+    return out;
+}
+
+// Returns the extension of the last element of p, from its final dot, or
+// an empty string.
+yar_str path_ext(const char *p, int64_t n) {
+    for (int64_t i = n - 1; i >= 0 && p[i] != '/'; i--) {
+        if (p[i] == '.') {
+            return (yar_str){p + i, n - i};
+        }
+    }
+    return (yar_str){p + n, 0};
+}
+
+// Returns the last element of p, ignoring trailing slashes. An empty path
+// gives "." and a path of only slashes gives "/".
+yar_str path_base(const char *p, int64_t n) {
+    if (n == 0) {
+        return (yar_str){".", 1};
+    }
+    while (n > 0 && p[n - 1] == '/') {
+        n--;
+    }
+    if (n == 0) {
+        return (yar_str){"/", 1};
+    }
+
+    int64_t start = n;
+    while (start > 0 && p[start - 1] != '/') {
+        start--;
+    }
+    return (yar_str){p + start, n - start};
+}
+
+// Copies the value of the environment variable name to out and reports
+// whether it is set.
+bool os_env(const char *name, int64_t n, yar_str *out) {
+    char *p = cstr(name, n);
+    const char *v = getenv(p);
+    free(p);
+    if (v == NULL) {
+        return false;
+    }
+    *out = (yar_str){v, (int64_t)strlen(v)};
+    return true;
+}
+
+bool fs_exists(const char *path, int64_t n) {
+    char *p = cstr(path, n);
+    struct stat st;
+    bool ok = lstat(p, &st) == 0;
+    free(p);
+    return ok;
+}
+
+bool fs_is_dir(const char *path, int64_t n) {
+    char *p = cstr(path, n);
+    struct stat st;
+    bool ok = stat(p, &st) == 0 && S_ISDIR(st.st_mode);
+    free(p);
+    return ok;
+}
+
+// Returns the size of a file in bytes, or -1 if it cannot be read.
+int64_t fs_size(const char *path, int64_t n) {
+    char *p = cstr(path, n);
+    struct stat st;
+    int64_t size = stat(p, &st) == 0 ? (int64_t)st.st_size : -1;
+    free(p);
+    return size;
+}
+
+static int remove_entry(const char *path, const struct stat *st, int flag, struct FTW *ftw) {
+    (void)st;
+    (void)flag;
+    (void)ftw;
+    return remove(path);
+}
+
+// Removes path and everything below it. A path that does not exist is
+// already removed.
+bool fs_remove_all(const char *path, int64_t n) {
+    char *p = cstr(path, n);
+    struct stat st;
+    bool ok = lstat(p, &st) != 0 || nftw(p, remove_entry, 16, FTW_DEPTH | FTW_PHYS) == 0;
+    free(p);
+    return ok;
+}
+
+// Builds the template for a new temporary file or directory under $TMPDIR.
+static char *temp_template(void) {
+    const char *dir = getenv("TMPDIR");
+    if (dir == NULL || *dir == '\0') {
+        dir = "/tmp";
+    }
+
+    size_t len = strlen(dir) + sizeof("/yar-XXXXXX");
+    char *tmpl = yar_alloc(len);
+    snprintf(tmpl, len, "%s/yar-XXXXXX", dir);
+    return tmpl;
+}
+
+// Creates a new empty file and returns its path, or panics.
+yar_str fs_temp_file(void) {
+    char *tmpl = temp_template();
+    int fd = mkstemp(tmpl);
+    if (fd < 0) {
+        fprintf(stderr, "panic: cannot create temporary file\n");
+        exit(1);
+    }
+    close(fd);
+    return (yar_str){tmpl, (int64_t)strlen(tmpl)};
+}
+
+// Creates a new empty directory and returns its path, or panics.
+yar_str fs_temp_dir(void) {
+    char *tmpl = temp_template();
+    if (mkdtemp(tmpl) == NULL) {
+        fprintf(stderr, "panic: cannot create temporary directory\n");
+        exit(1);
+    }
+    return (yar_str){tmpl, (int64_t)strlen(tmpl)};
+}
+
+// A command built by process_new. yarlang code refers to it by its index
+// in the commands table.
+typedef struct {
+    char **argv;
+    int argc;
+    char **env; // alternating names and values
+    int envc;
+    char *dir;
+    char *input;
+    int64_t input_len;
+    char *out;
+    int64_t out_len;
+    char *err;
+    int64_t err_len;
+} yar_command;
+
+static yar_command *commands;
+static int32_t ncommands;
+
+static yar_command *command(int32_t id) {
+    if (id < 0 || id >= ncommands) {
+        fprintf(stderr, "panic: invalid command %d\n", id);
+        exit(1);
+    }
+    return &commands[id];
+}
+
+// Appends s to a NULL-terminated array of n strings.
+static void push_string(char ***list, int *n, char *s) {
+    *list = yar_realloc(*list, sizeof(char *) * (size_t)(*n + 2));
+    (*list)[(*n)++] = s;
+    (*list)[*n] = NULL;
+}
+
+static void append_bytes(char **buf, int64_t *len, const char *data, size_t n) {
+    *buf = yar_realloc(*buf, (size_t)*len + n);
+    memcpy(*buf + *len, data, n);
+    *len += (int64_t)n;
+}
+
+// Starts a command that runs program, found on $PATH, with no arguments.
+int32_t process_new(const char *program, int64_t n) {
+    commands = yar_realloc(commands, sizeof(yar_command) * (size_t)(ncommands + 1));
+    yar_command *c = &commands[ncommands];
+    memset(c, 0, sizeof(*c));
+    push_string(&c->argv, &c->argc, cstr(program, n));
+    return ncommands++;
+}
+
+void process_arg(int32_t id, const char *arg, int64_t n) {
+    yar_command *c = command(id);
+    push_string(&c->argv, &c->argc, cstr(arg, n));
+}
+
+// Sets an environment variable for the child on top of the inherited ones.
+void process_env(int32_t id, const char *key, int64_t klen, const char *value, int64_t vlen) {
+    yar_command *c = command(id);
+    push_string(&c->env, &c->envc, cstr(key, klen));
+    push_string(&c->env, &c->envc, cstr(value, vlen));
+}
+
+void process_dir(int32_t id, const char *dir, int64_t n) {
+    yar_command *c = command(id);
+    free(c->dir);
+    c->dir = cstr(dir, n);
+}
+
+void process_stdin(int32_t id, const char *input, int64_t n) {
+    yar_command *c = command(id);
+    free(c->input);
+    c->input = cstr(input, n);
+    c->input_len = n;
+}
+
+// Writes the command's input to fds[0] while reading fds[1] and fds[2] into
+// its output buffers, until the child closes both, so that no pipe can fill
+// up while the child waits on another.
+static void pump(yar_command *c, int fds[3]) {
+    int64_t written = 0;
+    char buf[4096];
+
+    if (c->input_len == 0) {
+        close(fds[0]);
+        fds[0] = -1;
+    }
+
+    while (fds[0] >= 0 || fds[1] >= 0 || fds[2] >= 0) {
+        struct pollfd pfd[3] = {
+            {fds[0], POLLOUT, 0},
+            {fds[1], POLLIN, 0},
+            {fds[2], POLLIN, 0},
+        };
+        if (poll(pfd, 3, -1) < 0) {
+            break;
+        }
+
+        if (fds[0] >= 0 && pfd[0].revents) {
+            ssize_t w = write(fds[0], c->input + written, (size_t)(c->input_len - written));
+            if (w > 0) {
+                written += w;
+            }
+            if (w < 0 || written == c->input_len) {
+                close(fds[0]);
+                fds[0] = -1;
+            }
+        }
+
+        for (int i = 1; i < 3; i++) {
+            if (fds[i] < 0 || !pfd[i].revents) {
+                continue;
+            }
+            ssize_t r = read(fds[i], buf, sizeof(buf));
+            if (r <= 0) {
+                close(fds[i]);
+                fds[i] = -1;
+            } else if (i == 1) {
+                append_bytes(&c->out, &c->out_len, buf, (size_t)r);
+            } else {
+                append_bytes(&c->err, &c->err_len, buf, (size_t)r);
+            }
+        }
+    }
+}
+
+// Runs the command to completion, capturing its stdout and stderr. Returns
+// its exit status, 128 plus the signal number if a signal killed it, 127 if
+// it could not be started, or -1 if the pipes or process could not be
+// created.
+int32_t process_run(int32_t id) {
+    yar_command *c = command(id);
+    int in[2], out[2], err[2];
+
+    if (pipe(in) != 0 || pipe(out) != 0 || pipe(err) != 0) {
+        return -1;
+    }
+
+    c->out_len = 0;
+    c->err_len = 0;
+
+    trace(LOG_PROCESS, "run %s", c->argv[0]);
+    pid_t pid = fork();
+    if (pid < 0) {
+        return -1;
+    }
+
+    if (pid == 0) {
+        dup2(in[0], 0);
+        dup2(out[1], 1);
+        dup2(err[1], 2);
+        close(in[0]);
+        close(in[1]);
+        close(out[0]);
+        close(out[1]);
+        close(err[0]);
+        close(err[1]);
+
+        if (c->dir != NULL && chdir(c->dir) != 0) {
+            _exit(127);
+        }
+        for (int i = 0; i + 1 < c->envc; i += 2) {
+            setenv(c->env[i], c->env[i + 1], 1);
+        }
+        execvp(c->argv[0], c->argv);
+        _exit(127);
+    }
+
+    close(in[0]);
+    close(out[1]);
+    close(err[1]);
+    fcntl(in[1], F_SETFL, O_NONBLOCK);
+
+    // A child that exits without reading its input must not kill us
+    void (*prev)(int) = signal(SIGPIPE, SIG_IGN);
+    int fds[3] = {in[1], out[0], err[0]};
+    pump(c, fds);
+    signal(SIGPIPE, prev);
+
+    int status;
+    if (waitpid(pid, &status, 0) < 0) {
+        return -1;
+    }
+    int32_t code = WIFSIGNALED(status) ? 128 + WTERMSIG(status) : WEXITSTATUS(status);
+    trace(LOG_PROCESS, "%s exited with %d", c->argv[0], code);
+    return code;
+}
+
+// Output captured by the last process_run of the command.
+yar_str process_stdout(int32_t id) {
+    yar_command *c = command(id);
+    return (yar_str){c->out != NULL ? c->out : "", c->out_len};
+}
+
+yar_str process_stderr(int32_t id) {
+    yar_command *c = command(id);
+    return (yar_str){c->err != NULL ? c->err : "", c->err_len};
+}
+
+// A Vec holds elements of the size the compiler passes to yar_vec_new, in
+// a buffer that doubles as it fills. Elements are copied in and out through
+// pointers to them.
+typedef struct {
+    char *data;
+    int64_t len;
+    int64_t cap;
+    int64_t elem;
+} yar_vec;
+
+yar_vec *yar_vec_new(int64_t elem) {
+    yar_vec *v = yar_alloc(sizeof(yar_vec));
+    *v = (yar_vec){NULL, 0, 0, elem};
+    return v;
+}
+
+static char *vec_at(yar_vec *v, int64_t i) {
+    if (i < 0 || i >= v->len) {
+        fprintf(stderr, "panic: index %lld out of range for Vec of length %lld\n", (long long)i, (long long)v->len);
+        exit(1);
+    }
+    return v->data + i * v->elem;
+}
+
+void yar_vec_push(yar_vec *v, const void *elem) {
+    if (v->len == v->cap) {
+        v->cap = v->cap == 0 ? 4 : v->cap * 2;
+        v->data = yar_realloc(v->data, (size_t)(v->cap * v->elem));
+    }
+    memcpy(v->data + v->len * v->elem, elem, (size_t)v->elem);
+    v->len++;
+}
+
+// Removes the last element, copying it to out.
+void yar_vec_pop(yar_vec *v, void *out) {
+    if (v->len == 0) {
+        fprintf(stderr, "panic: pop from empty Vec\n");
+        exit(1);
+    }
+    v->len--;
+    memcpy(out, v->data + v->len * v->elem, (size_t)v->elem);
+}
+
+void yar_vec_get(yar_vec *v, int64_t i, void *out) {
+    memcpy(out, vec_at(v, i), (size_t)v->elem);
+}
+
+void yar_vec_set(yar_vec *v, int64_t i, const void *elem) {
+    memcpy(vec_at(v, i), elem, (size_t)v->elem);
+}
+
+int64_t yar_vec_len(yar_vec *v) {
+    return v->len;
+}
+
+// A Map keeps its entries in insertion order, in arrays of keys and values
+// of the sizes the compiler passes to yar_map_new, and finds them through
+// an open-addressed table of entry indices. A removed entry leaves a hole
+// until the table is next rebuilt. Integer keys are hashed and compared by
+// their bytes, str keys by their contents.
+typedef struct {
+    char *keys;
+    char *values;
+    bool *live;
+    int64_t *slots; // entry index plus one, 0 if empty, -1 if removed
+    int64_t nslots;
+    int64_t entries; // entries used, holes included
+    int64_t cap;
+    int64_t len;
+    int64_t ksize;
+    int64_t vsize;
+    bool str_keys;
+} yar_map;
+
+static uint64_t map_hash(yar_map *m, const void *key) {
+    const unsigned char *p = key;
+    size_t n = (size_t)m->ksize;
+    if (m->str_keys) {
+        const yar_str *s = key;
+        p = (const unsigned char *)s->ptr;
+        n = (size_t)s->len;
+    }
+    uint64_t h = 14695981039346656037ULL;
+    for (size_t i = 0; i < n; i++) {
+        h = (h ^ p[i]) * 1099511628211ULL;
+    }
+    return h;
+}
+
+static bool map_key_eq(yar_map *m, const void *a, const void *b) {
+    if (m->str_keys) {
+        const yar_str *x = a;
+        const yar_str *y = b;
+        return x->len == y->len && memcmp(x->ptr, y->ptr, (size_t)x->len) == 0;
+    }
+    return memcmp(a, b, (size_t)m->ksize) == 0;
+}
+
+// The slot holding key, or the free slot it would be inserted in. The table
+// is kept at most half full, so the probe always ends.
+static int64_t map_find(yar_map *m, const void *key) {
+    int64_t mask = m->nslots - 1;
+    int64_t free_slot = -1;
+    for (int64_t i = (int64_t)(map_hash(m, key) & (uint64_t)mask);; i = (i + 1) & mask) {
+        int64_t s = m->slots[i];
+        if (s == 0) {
+            return free_slot >= 0 ? free_slot : i;
+        }
+        if (s < 0) {
+            if (free_slot < 0) {
+                free_slot = i;
+            }
+        } else if (map_key_eq(m, m->keys + (s - 1) * m->ksize, key)) {
+            return i;
+        }
+    }
+}
+
+// Closes the holes left by removed entries and makes room for at least
+// twice the live ones, then indexes them again.
+static void map_rebuild(yar_map *m) {
+    int64_t n = 0;
+    for (int64_t i = 0; i < m->entries; i++) {
+        if (!m->live[i]) {
+            continue;
+        }
+        if (n != i) {
+            memcpy(m->keys + n * m->ksize, m->keys + i * m->ksize, (size_t)m->ksize);
+            memcpy(m->values + n * m->vsize, m->values + i * m->vsize, (size_t)m->vsize);
+            m->live[n] = true;
+        }
+        n++;
+    }
+    m->entries = n;
+
+    int64_t cap = 8;
+    while (cap < 2 * (n + 1)) {
+        cap *= 2;
+    }
+    if (cap > m->cap) {
+        m->keys = yar_realloc(m->keys, (size_t)(cap * m->ksize));
+        m->values = yar_realloc(m->values, (size_t)(cap * m->vsize));
+        m->live = yar_realloc(m->live, (size_t)cap);
+        m->cap = cap;
+    }
+
+    m->nslots = 2 * m->cap;
+    m->slots = yar_realloc(m->slots, sizeof(int64_t) * (size_t)m->nslots);
+    memset(m->slots, 0, sizeof(int64_t) * (size_t)m->nslots);
+    for (int64_t i = 0; i < n; i++) {
+        m->slots[map_find(m, m->keys + i * m->ksize)] = i + 1;
+    }
+}
+
+yar_map *yar_map_new(int64_t ksize, int64_t vsize, int32_t str_keys) {
+    yar_map *m = yar_alloc(sizeof(yar_map));
+    *m = (yar_map){.ksize = ksize, .vsize = vsize, .str_keys = str_keys != 0};
+    map_rebuild(m);
+    return m;
+}
+
+// Sets the value of key, adding the key if it is new.
+void yar_map_insert(yar_map *m, const void *key, const void *value) {
+    int64_t i = map_find(m, key);
+    if (m->slots[i] <= 0) {
+        if (m->entries == m->cap) {
+            map_rebuild(m);
+            i = map_find(m, key);
+        }
+        memcpy(m->keys + m->entries * m->ksize, key, (size_t)m->ksize);
+        m->live[m->entries] = true;
+        m->slots[i] = ++m->entries;
+        m->len++;
+    }
+    memcpy(m->values + (m->slots[i] - 1) * m->vsize, value, (size_t)m->vsize);
+}
+
+// Copies the value of key to out and reports whether the key was there.
+bool yar_map_get(yar_map *m, const void *key, void *out) {
+    int64_t s = m->slots[map_find(m, key)];
+    if (s <= 0) {
+        return false;
+    }
+    memcpy(out, m->values + (s - 1) * m->vsize, (size_t)m->vsize);
+    return true;
+}
+
+// Like yar_map_get, and removes the key.
+bool yar_map_remove(yar_map *m, const void *key, void *out) {
+    int64_t i = map_find(m, key);
+    int64_t s = m->slots[i];
+    if (s <= 0) {
+        return false;
+    }
+    memcpy(out, m->values + (s - 1) * m->vsize, (size_t)m->vsize);
+    m->live[s - 1] = false;
+    m->slots[i] = -1;
+    m->len--;
+    return true;
+}
+
+bool yar_map_contains(yar_map *m, const void *key) {
+    return m->slots[map_find(m, key)] > 0;
+}
+
+int64_t yar_map_len(yar_map *m) {
+    return m->len;
+}
+
+// Copies the first entry at or after position pos to key and value and
+// returns the position after it, or -1 if there is none.
+int64_t yar_map_next(yar_map *m, int64_t pos, void *key, void *value) {
+    for (int64_t i = pos; i < m->entries; i++) {
+        if (m->live[i]) {
+            memcpy(key, m->keys + i * m->ksize, (size_t)m->ksize);
+            memcpy(value, m->values + i * m->vsize, (size_t)m->vsize);
+            return i + 1;
+        }
+    }
+    return -1;
+}
@@ -0,0 +1,51 @@
+#!/bin/sh
+# benchgate.sh compares the compiler benchmarks of the working tree with
+# those of a base revision and fails if any got slower by more than
+# MAX_SLOWDOWN percent.
+#
+#   scripts/benchgate.sh [base-ref]
+#
+# base-ref defaults to HEAD. Each benchmark runs COUNT times on both trees
+# and the fastest run is compared, which keeps noise from failing the gate.
+set -eu
+
+BASE=${1:-HEAD}
+COUNT=${COUNT:-5}
+MAX_SLOWDOWN=${MAX_SLOWDOWN:-10}
+BENCH=${BENCH:-'ParseLargeFile|CheckManyFunctions|LowerDeepLoops'}
+PKGS="./parser ./checker ./mir"
+
+root=$(git rev-parse --show-toplevel)
+tmp=$(mktemp -d)
+trap 'git -C "$root" worktree remove --force "$tmp/base" >/dev/null 2>&1; rm -rf "$tmp"' EXIT
+
+git -C "$root" worktree add --detach "$tmp/base" "$BASE" >/dev/null 2>&1
+
+run() {
+	(cd "$1" && go test -run '^$' -bench "$BENCH" -count "$COUNT" $PKGS) |
+		awk '/^Benchmark/ { sub(/-[0-9]+$/, "", $1); print $1, $3 }'
+}
+
+# The benchmarks are new in some revisions; a base without them has
+# nothing to compare against
+run "$tmp/base" >"$tmp/old" || true
+run "$root" >"$tmp/new"
+
+awk -v max="$MAX_SLOWDOWN" -v oldfile="$tmp/old" '
+	FILENAME == oldfile { if (!($1 in old) || $2 < old[$1]) old[$1] = $2; next }
+	{ if (!($1 in new) || $2 < new[$1]) new[$1] = $2 }
+	END {
+		failed = 0
+		for (name in new) {
+			if (!(name in old)) {
+				printf "%-32s %14.0f ns/op  (new)\n", name, new[name]
+				continue
+			}
+			delta = (new[name] - old[name]) * 100 / old[name]
+			mark = ""
+			if (delta > max) { mark = "  REGRESSION"; failed = 1 }
+			printf "%-32s %14.0f -> %14.0f ns/op  %+6.1f%%%s\n", name, old[name], new[name], delta, mark
+		}
+		exit failed
+	}
+' "$tmp/old" "$tmp/new"
@@ -0,0 +1,104 @@
+// Package stdlib holds the standard library modules written in YarLang and
+// loads those a file imports.
+package stdlib
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/yarlson/yarlang/ast"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/parser"
+)
+
+//go:embed *.yar
+var modules embed.FS
+
+// Load adds to file the functions of each standard library module it
+// imports with use std::name. A function f of the module is added as
+// std::name::f, which is what name::f and std::name::f call, and the
+// module's calls to its own functions are renamed to match. A use of a
+// module that is not written in YarLang, such as std::os, adds nothing.
+func Load(file *ast.File) error {
+	loaded := make(map[string]bool)
+
+	for _, item := range file.Items {
+		use, ok := item.(*ast.UseDecl)
+		if !ok || len(use.Path) != 2 || use.Path[0] != "std" || loaded[use.Path[1]] {
+			continue
+		}
+
+		name := use.Path[1]
+
+		src, err := modules.ReadFile(name + ".yar")
+		if err != nil {
+			continue
+		}
+
+		fns, err := parse(name, string(src))
+		if err != nil {
+			return err
+		}
+
+		for _, fn := range fns {
+			file.Items = append(file.Items, fn)
+		}
+
+		loaded[name] = true
+	}
+
+	return nil
+}
+
+// parse parses the module name and qualifies its functions
+func parse(name, src string) ([]*ast.FuncDecl, error) {
+	p := parser.New(lexer.New(src))
+	file := p.ParseFile()
+
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("std::%s: %s", name, strings.Join(errs, "; "))
+	}
+
+	own := make(map[string]bool)
+
+	var fns []*ast.FuncDecl
+
+	for _, item := range file.Items {
+		fn, ok := item.(*ast.FuncDecl)
+		if !ok {
+			return nil, fmt.Errorf("std::%s: only functions can be declared in a module, not %s", name, item.String())
+		}
+
+		own[fn.Name] = true
+		fns = append(fns, fn)
+	}
+
+	// Positions in the module would point into the file that imports it
+	for _, fn := range fns {
+		*fn.Pos() = ast.Span{}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if n, ok := n.(interface{ Pos() *ast.Span }); ok {
+				*n.Pos() = ast.Span{}
+			}
+
+			if call, ok := n.(*ast.CallExpr); ok {
+				if ident, ok := call.Callee.(*ast.Ident); ok && own[ident.Name] {
+					ident.Name = Qualify(name, ident.Name)
+				}
+			}
+
+			return true
+		})
+
+		fn.Name = Qualify(name, fn.Name)
+	}
+
+	return fns, nil
+}
+
+// Qualify is the name the function fn of the module name is added as
+func Qualify(module, fn string) string {
+	return "std::" + module + "::" + fn
+}
@@ -0,0 +1,180 @@
+package stdlib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yarlson/yarlang/checker"
+	"github.com/yarlson/yarlang/lexer"
+	"github.com/yarlson/yarlang/mir"
+	"github.com/yarlson/yarlang/parser"
+)
+
+// lower loads the modules input imports, then checks and lowers it, and
+// returns the MIR
+func lower(t *testing.T, input string) string {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	file := p.ParseFile()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if err := Load(file); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+
+	c := checker.NewChecker()
+	if err := c.CheckFile(file); err != nil {
+		t.Fatalf("checker error: %v", err)
+	}
+
+	if w := c.Warnings(); len(w) != 0 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	l := mir.NewLowerer()
+	l.SetTypes(c.Types())
+	mod := l.LowerFile(file)
+
+	if errs := l.Errors(); len(errs) != 0 {
+		t.Fatalf("lowering errors: %v", errs)
+	}
+
+	return mod.String()
+}
+
+// expectCalls fails unless out calls each of the functions
+func expectCalls(t *testing.T, out string, fns ...string) {
+	t.Helper()
+
+	for _, fn := range fns {
+		if !strings.Contains(out, "@"+fn+"(") {
+			t.Errorf("expected a call to %s in:\n%s", fn, out)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	out := lower(t, `use std::path
+
+fn main() {
+	println(path::join("a", "b"))
+	println(path::clean("a//b/.."))
+	println(std::path::ext("x.go"))
+	println(path::base("a/b"))
+}`)
+
+	expectCalls(t, out, "std::path::join", "std::path::clean", "std::path::ext", "std::path::base", "path_join")
+}
+
+func TestFS(t *testing.T) {
+	out := lower(t, `use std::fs
+
+fn main() {
+	let dir = fs::temp_dir()
+	println(fs::exists(dir) && fs::is_dir(dir))
+	println(fs::size(fs::temp_file()))
+	println(fs::remove_all(dir))
+}`)
+
+	expectCalls(t, out, "std::fs::temp_dir", "std::fs::exists", "std::fs::is_dir", "std::fs::size",
+		"std::fs::temp_file", "std::fs::remove_all")
+}
+
+func TestRegex(t *testing.T) {
+	out := lower(t, `use std::regex as re
+
+fn main() {
+	println(re::is_match("a+", "aa"))
+	println(re::find("b", "ab"))
+	println(re::replace_all("a", "aa", "b"))
+}`)
+
+	expectCalls(t, out, "std::regex::is_match", "std::regex::find", "std::regex::replace_all")
+}
+
+func TestDatetime(t *testing.T) {
+	out := lower(t, `use std::datetime
+
+fn main() {
+	let (y, m, d) = datetime::civil_from_days(datetime::days_from_civil(2024, 2, 29))
+	println(y + m + d)
+	println(datetime::weekday(datetime::local_days(0, 0, 60)))
+}`)
+
+	expectCalls(t, out, "std::datetime::civil_from_days", "std::datetime::days_from_civil",
+		"std::datetime::weekday", "std::datetime::local_days")
+
+	// The module's calls to its own functions are renamed with them
+	expectCalls(t, out, "std::datetime::is_leap_year")
+}
+
+func TestCryptoUtil(t *testing.T) {
+	out := lower(t, `use std::crypto_util
+
+fn main() {
+	println(crypto_util::secrets_equal("a", "b"))
+}`)
+
+	expectCalls(t, out, "std::crypto_util::secrets_equal", "ct_eq")
+}
+
+func TestFmt(t *testing.T) {
+	out := lower(t, `use std::fmt
+
+fn main() {
+	fmt::println_all(1, 2, 3)
+	fmt::println_labeled("n", 4)
+}`)
+
+	expectCalls(t, out, "std::fmt::println_all", "std::fmt::println_labeled")
+}
+
+func TestProcess(t *testing.T) {
+	out := lower(t, `use std::process
+
+fn main() {
+	let cmd = process::command("cat")
+	process::arg(cmd, "-")
+	process::env(cmd, "K", "V")
+	process::dir(cmd, "/")
+	process::stdin(cmd, "hi")
+	println(process::run(cmd))
+	println(process::stdout(cmd))
+	println(process::stderr(cmd))
+}`)
+
+	expectCalls(t, out, "std::process::command", "std::process::arg", "std::process::env", "std::process::dir",
+		"std::process::stdin", "std::process::run", "std::process::stdout", "std::process::stderr")
+}
+
+func TestRuntime(t *testing.T) {
+	out := lower(t, `use std::runtime
+
+fn main() {
+	println(runtime::version())
+	println(runtime::allocs() + runtime::alloc_bytes() + runtime::stack_size())
+}`)
+
+	expectCalls(t, out, "std::runtime::version", "std::runtime::allocs", "std::runtime::alloc_bytes",
+		"std::runtime::stack_size")
+}
+
+func TestLoadOnlyImportedModules(t *testing.T) {
+	p := parser.New(lexer.New(`use std::os
+use std::io
+
+fn main() {}`))
+	file := p.ParseFile()
+
+	if err := Load(file); err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+
+	if len(file.Items) != 3 {
+		t.Errorf("expected no functions to be added, got %d items", len(file.Items))
+	}
+}
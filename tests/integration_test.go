@@ -3,6 +3,8 @@ package tests
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +44,281 @@ func TestTypeCheckErrors(t *testing.T) {
 		t.Error("Expected type error, but check passed")
 	}
 }
+
+func TestGermanMessages(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		// A syntax error
+		{"fn main() {\n\tlet = 1\n}\n", []string{"Bezeichner nach let erwartet", "1 Fehler\n"}},
+		// And the count of the checker's errors
+		{"fn main() {\n\tprintln(x)\n\tprintln(y)\n}\n", []string{"undefinierte Variable: x", "2 Fehler\n"}},
+	}
+
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "main.yar")
+		if err := os.WriteFile(file, []byte(tt.source), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command("../yar", "check", "--color=never", file)
+		cmd.Env = append(os.Environ(), "YAR_LANG=de")
+
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("%q: expected the check to fail", tt.source)
+		}
+
+		for _, want := range tt.want {
+			if !strings.Contains(string(output), want) {
+				t.Errorf("%q: expected %q in:\n%s", tt.source, want, output)
+			}
+		}
+	}
+}
+
+func TestDeferRunsInReverseOrder(t *testing.T) {
+	source := `fn show(n i32) {
+	println(n)
+}
+
+fn work(k i32) i32 {
+	defer println("first")
+	let mut i = 0
+	while i < k {
+		defer show(i)
+		i += 1
+	}
+	if k > 5 {
+		return 0
+	}
+	defer println("last")
+	return k
+}
+
+fn main() {
+	println(work(3))
+	println(work(7))
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_defer")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	// Defers in a loop run once per iteration, and an early return runs
+	// only those pushed before it
+	want := "Built: " + exe + "\n" + "last\n2\n1\n0\nfirst\n3\n6\n5\n4\n3\n2\n1\n0\nfirst\n0\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestRegexReplaceEmptyMatches(t *testing.T) {
+	source := `fn main() {
+	println(regex_replace("$", "abc", "X"))
+	println(regex_replace("^", "abc", "X"))
+	println(regex_replace("x*", "abc", "X"))
+	println(regex_replace("x*", "xax", "X"))
+	println(regex_replace("x*", "", "X"))
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_regex")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	// An empty match is replaced where it is and never copies past the
+	// end of the input, nor right after another match
+	want := "Built: " + exe + "\n" + "abcX\nXabc\nXaXbXcX\nXaX\nX\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestPrimitiveMethods(t *testing.T) {
+	source := `impl i32 {
+	fn double(&self) i32 {
+		return *self * 2
+	}
+}
+
+impl str {
+	fn twice(&self) str {
+		return *self + *self
+	}
+}
+
+impl bool {
+	fn flip(&self) bool {
+		return !*self
+	}
+}
+
+fn main() {
+	let x: i32 = 21
+	println(x.double())
+	let s = "ab"
+	println(s.twice())
+	println(true.flip())
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_prim")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	want := "Built: " + exe + "\n" + "42\nabab\nfalse\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestPrimitiveTraitObjects(t *testing.T) {
+	source := `trait Show {
+	fn show(&self) str
+}
+
+impl Show for i64 {
+	fn show(&self) str {
+		return str_from_int(*self)
+	}
+}
+
+impl Show for str {
+	fn show(&self) str {
+		return *self
+	}
+}
+
+fn print(s &dyn Show) {
+	println(s.show())
+}
+
+fn main() {
+	let n: i64 = 7
+	print(&n)
+	let s = "seven"
+	print(&s)
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_dyn_prim")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	want := "Built: " + exe + "\n" + "7\nseven\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestStdModules(t *testing.T) {
+	source := `use std::path
+use std::regex as re
+use std::datetime
+use std::fmt
+
+fn main() {
+	println(path::join("a/b", "../c"))
+	println(std::path::ext("x.tar.gz"))
+	println(re::is_match("a+", "caat"))
+	let days = datetime::days_from_civil(2024, 2, 29)
+	println(datetime::weekday(days))
+	let (y, m, d) = datetime::civil_from_days(days)
+	fmt::println_all(y, m, d)
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_std")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	want := "Built: " + exe + "\n" + "a/c\n.gz\ntrue\n4\n2024\n2\n29\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestResultAndOption(t *testing.T) {
+	source := `fn parse(x i32) Result<i32, str> {
+	if x < 0 {
+		return Err("negative")
+	}
+	return Ok(x)
+}
+
+fn half(x i32) Option<i32> {
+	if x % 2 != 0 {
+		return None
+	}
+	return Some(x / 2)
+}
+
+fn report(x i32) Result<i32, str> {
+	let v = parse(x)?
+	println(v * 2)
+	return Ok(v)
+}
+
+fn show(x i32) Option<i32> {
+	let h = half(x)?
+	println(h)
+	return Some(h)
+}
+
+fn main() {
+	let _a = report(4)
+	let _b = report(-1)
+	let _c = show(6)
+	let _d = show(3)
+}
+`
+
+	exe := filepath.Join(t.TempDir(), "test_result")
+	if err := os.WriteFile(exe+".yar", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("../yar", "run", exe+".yar").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+
+	// ? returns early on Err and None, so only the first call of each prints
+	want := "Built: " + exe + "\n" + "8\n3\n"
+	if string(output) != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", output, want)
+	}
+}
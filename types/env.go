@@ -2,15 +2,17 @@ package types
 
 // Symbol represents a variable or function
 type Symbol struct {
-	Name string
-	Type Type
-	Mut  bool // Mutable?
+	Name    string
+	Type    Type
+	Mut     bool    // Mutable?
+	Shadows *Symbol // Earlier symbol with the same name that this one hides, if any
 }
 
 // Scope represents a lexical scope
 type Scope struct {
 	symbols map[string]*Symbol
 	parent  *Scope
+	fn      bool // Outermost scope of a function body
 }
 
 func NewScope(parent *Scope) *Scope {
@@ -20,8 +22,12 @@ func NewScope(parent *Scope) *Scope {
 	}
 }
 
+// Define adds a symbol to the scope. A name that is already visible, from
+// this scope or an enclosing one, is shadowed: the new symbol hides the old
+// one, which stays alive for anything that still refers to it.
 func (s *Scope) Define(name string, typ Type, mut bool) {
-	s.symbols[name] = &Symbol{Name: name, Type: typ, Mut: mut}
+	prev, _ := s.Lookup(name)
+	s.symbols[name] = &Symbol{Name: name, Type: typ, Mut: mut, Shadows: prev}
 }
 
 func (s *Scope) Lookup(name string) (*Symbol, bool) {
@@ -39,7 +45,9 @@ func (s *Scope) Lookup(name string) (*Symbol, bool) {
 // Env is the type environment
 type Env struct {
 	currentScope *Scope
-	typeVarID    int // Counter for type variables
+	typeVarID    int                           // Counter for type variables
+	methods      map[string]map[string]*Method // Type name -> method name -> method
+	impls        map[string]map[string]bool    // Trait name -> implementing type names
 }
 
 func NewEnv() *Env {
@@ -60,6 +68,7 @@ func NewEnv() *Env {
 		"usize": &PrimitiveType{Name: "usize", Kind: USize},
 		"f32":   &PrimitiveType{Name: "f32", Kind: Float32},
 		"f64":   &PrimitiveType{Name: "f64", Kind: Float64},
+		"str":   &StringType{},
 		"bool":  &PrimitiveType{Name: "bool", Kind: Bool},
 		"char":  &PrimitiveType{Name: "char", Kind: Char},
 		"void":  &PrimitiveType{Name: "void", Kind: Void},
@@ -71,17 +80,33 @@ func NewEnv() *Env {
 
 	// Define builtin functions
 	voidType := &PrimitiveType{Name: "void", Kind: Void}
-	stringType := &SliceType{Elem: &PrimitiveType{Name: "u8", Kind: UInt8}}
+	stringType := &StringType{}
+	bytesType := &SliceType{Elem: &PrimitiveType{Name: "u8", Kind: UInt8}}
 
 	// println(msg string) - accepts any type for now (variadic-like)
-	env := &Env{currentScope: root, typeVarID: 0}
+	env := &Env{
+		currentScope: root,
+		typeVarID:    0,
+		methods:      make(map[string]map[string]*Method),
+		impls:        make(map[string]map[string]bool),
+	}
 	anyType := env.NewTypeVar()
 	root.Define("println", &FuncType{
 		Params: []Type{anyType},
 		Return: voidType,
 	}, false)
 
-	// panic(msg string)
+	// The prelude types Option<T> and Result<T, E>. The checker types their
+	// variants Some, None, Ok and Err.
+	root.Define("Option", &OptionType{Elem: env.NewTypeVar()}, false)
+	root.Define("Result", &ResultType{Ok: env.NewTypeVar(), Err: env.NewTypeVar()}, false)
+
+	// Vec<T>, a growable array, and Map<K, V>, a hash map; Vec::new() and
+	// Map::new() make empty ones
+	root.Define("Vec", &VecType{Elem: env.NewTypeVar()}, false)
+	root.Define("Map", &MapType{Key: env.NewTypeVar(), Value: env.NewTypeVar()}, false)
+
+	// panic(msg str)
 	root.Define("panic", &FuncType{
 		Params: []Type{stringType},
 		Return: voidType,
@@ -95,6 +120,151 @@ func NewEnv() *Env {
 		Return: usizeType,
 	}, false)
 
+	// memzero_explicit<T>(p &mut T) - clears *p with a volatile store
+	root.Define("memzero_explicit", &FuncType{
+		Params: []Type{&RefType{Mut: true, Elem: env.NewTypeVar()}},
+		Return: voidType,
+	}, false)
+
+	// ct_eq(a []u8, b []u8) bool - compares in time independent of contents
+	root.Define("ct_eq", &FuncType{
+		Params: []Type{bytesType, bytesType},
+		Return: &PrimitiveType{Name: "bool", Kind: Bool},
+	}, false)
+
+	// regex_match(pattern str, s str) bool - whether s contains a match
+	root.Define("regex_match", &FuncType{
+		Params: []Type{stringType, stringType},
+		Return: &PrimitiveType{Name: "bool", Kind: Bool},
+	}, false)
+
+	// regex_find(pattern str, s str) i32 - offset of the first match, or -1
+	root.Define("regex_find", &FuncType{
+		Params: []Type{stringType, stringType},
+		Return: &PrimitiveType{Name: "i32", Kind: Int32},
+	}, false)
+
+	// regex_replace(pattern str, s str, repl str) str - replaces every match
+	root.Define("regex_replace", &FuncType{
+		Params: []Type{stringType, stringType, stringType},
+		Return: stringType,
+	}, false)
+
+	// str_compare(a str, b str) i32 - -1, 0 or 1 as a orders before, with
+	// or after b
+	root.Define("str_compare", &FuncType{
+		Params: []Type{stringType, stringType},
+		Return: &PrimitiveType{Name: "i32", Kind: Int32},
+	}, false)
+
+	// str_slice(s str, start i64, end i64) str - a view of bytes start to end
+	root.Define("str_slice", &FuncType{
+		Params: []Type{stringType, &PrimitiveType{Name: "i64", Kind: Int64}, &PrimitiveType{Name: "i64", Kind: Int64}},
+		Return: stringType,
+	}, false)
+
+	// str_index(s str, sub str) i64 - offset of the first sub in s, or -1
+	root.Define("str_index", &FuncType{
+		Params: []Type{stringType, stringType},
+		Return: &PrimitiveType{Name: "i64", Kind: Int64},
+	}, false)
+
+	// str_from_int(n i64) str and str_to_int(s str) i64 - decimal text
+	root.Define("str_from_int", &FuncType{
+		Params: []Type{&PrimitiveType{Name: "i64", Kind: Int64}},
+		Return: stringType,
+	}, false)
+	root.Define("str_to_int", &FuncType{
+		Params: []Type{stringType},
+		Return: &PrimitiveType{Name: "i64", Kind: Int64},
+	}, false)
+
+	// path_join, path_clean, path_ext and path_base work on paths as text,
+	// without touching the filesystem
+	root.Define("path_join", &FuncType{
+		Params: []Type{stringType, stringType},
+		Return: stringType,
+	}, false)
+
+	for _, name := range []string{"path_clean", "path_ext", "path_base"} {
+		root.Define(name, &FuncType{
+			Params: []Type{stringType},
+			Return: stringType,
+		}, false)
+	}
+
+	// fs_exists, fs_is_dir and fs_remove_all(path str) bool
+	for _, name := range []string{"fs_exists", "fs_is_dir", "fs_remove_all"} {
+		root.Define(name, &FuncType{
+			Params: []Type{stringType},
+			Return: &PrimitiveType{Name: "bool", Kind: Bool},
+		}, false)
+	}
+
+	// fs_size(path str) i64 - size in bytes, or -1
+	root.Define("fs_size", &FuncType{
+		Params: []Type{stringType},
+		Return: &PrimitiveType{Name: "i64", Kind: Int64},
+	}, false)
+
+	// fs_temp_file() str and fs_temp_dir() str - create a new empty file or
+	// directory under $TMPDIR and return its path
+	for _, name := range []string{"fs_temp_file", "fs_temp_dir"} {
+		root.Define(name, &FuncType{Return: stringType}, false)
+	}
+
+	// A command for process_run is built up by the process_* builtins and
+	// named by the i32 handle process_new returns
+	i32Type := &PrimitiveType{Name: "i32", Kind: Int32}
+	root.Define("process_new", &FuncType{
+		Params: []Type{stringType},
+		Return: i32Type,
+	}, false)
+
+	for _, name := range []string{"process_arg", "process_dir", "process_stdin"} {
+		root.Define(name, &FuncType{
+			Params: []Type{i32Type, stringType},
+			Return: voidType,
+		}, false)
+	}
+
+	root.Define("process_env", &FuncType{
+		Params: []Type{i32Type, stringType, stringType},
+		Return: voidType,
+	}, false)
+
+	// process_run(cmd i32) i32 - runs cmd to completion and returns its exit
+	// status, or -1 if it could not be started
+	root.Define("process_run", &FuncType{
+		Params: []Type{i32Type},
+		Return: i32Type,
+	}, false)
+
+	// process_stdout and process_stderr(cmd i32) str - output captured by
+	// the last run
+	for _, name := range []string{"process_stdout", "process_stderr"} {
+		root.Define(name, &FuncType{
+			Params: []Type{i32Type},
+			Return: stringType,
+		}, false)
+	}
+
+	// arg_count() i64 and arg_at(i i64) str - the command-line arguments,
+	// the first of which is the program name
+	i64Type := &PrimitiveType{Name: "i64", Kind: Int64}
+	root.Define("arg_count", &FuncType{Return: i64Type}, false)
+	root.Define("arg_at", &FuncType{Params: []Type{i64Type}, Return: stringType}, false)
+
+	// runtime_version() str - the compiler version the program was built with
+	root.Define("runtime_version", &FuncType{Return: stringType}, false)
+
+	// runtime_allocs, runtime_alloc_bytes and runtime_stack_size() i64 -
+	// heap allocations and bytes requested by the runtime so far, and the
+	// stack limit in bytes, or -1 if unlimited
+	for _, name := range []string{"runtime_allocs", "runtime_alloc_bytes", "runtime_stack_size"} {
+		root.Define(name, &FuncType{Return: i64Type}, false)
+	}
+
 	return env
 }
 
@@ -115,10 +285,111 @@ func (e *Env) LookupSymbol(name string) (*Symbol, bool) {
 	return e.currentScope.Lookup(name)
 }
 
+// Builtin returns the type of the builtin function or type called name,
+// whether or not a declaration of the program shadows it
+func (e *Env) Builtin(name string) (Type, bool) {
+	s := e.currentScope
+	for s.parent != nil {
+		s = s.parent
+	}
+
+	sym, ok := s.symbols[name]
+	if !ok {
+		return nil, false
+	}
+
+	return sym.Type, true
+}
+
+// DeclaredHere reports whether name is declared in the innermost scope
+// itself, not just visible from an enclosing one
+func (e *Env) DeclaredHere(name string) bool {
+	_, ok := e.currentScope.symbols[name]
+	return ok
+}
+
+// DefineMethod registers m on the receiver type. It reports false if the type
+// already has a method with that name.
+func (e *Env) DefineMethod(recv Type, m *Method) bool {
+	name := TypeName(recv)
+	if e.methods[name] == nil {
+		e.methods[name] = make(map[string]*Method)
+	}
+
+	if _, exists := e.methods[name][m.Name]; exists {
+		return false
+	}
+
+	e.methods[name][m.Name] = m
+
+	return true
+}
+
+// LookupMethod finds a method on the receiver type. Methods of a trait
+// object are those of its trait; Vec<T> and Map<K, V> have built-in ones.
+func (e *Env) LookupMethod(recv Type, name string) (*Method, bool) {
+	if ref, ok := recv.(*RefType); ok {
+		recv = ref.Elem
+	}
+
+	if dyn, ok := recv.(*DynType); ok {
+		m := dyn.Trait.Method(name)
+		return m, m != nil
+	}
+
+	switch t := recv.(type) {
+	case *VecType:
+		return t.Method(name)
+	case *MapType:
+		return t.Method(name)
+	}
+
+	m, ok := e.methods[TypeName(recv)][name]
+
+	return m, ok
+}
+
+// DefineImpl records that recv implements trait
+func (e *Env) DefineImpl(trait string, recv Type) {
+	if e.impls[trait] == nil {
+		e.impls[trait] = make(map[string]bool)
+	}
+
+	e.impls[trait][TypeName(recv)] = true
+}
+
+// Implements reports whether recv has an impl of trait
+func (e *Env) Implements(trait string, recv Type) bool {
+	return e.impls[trait][TypeName(recv)]
+}
+
 func (e *Env) PushScope() {
 	e.currentScope = NewScope(e.currentScope)
 }
 
+// PushFuncScope opens the scope of a function body. Locals of enclosing
+// functions stay visible through it but count as captured.
+func (e *Env) PushFuncScope() {
+	e.PushScope()
+	e.currentScope.fn = true
+}
+
+// Captured reports whether name resolves to a local of an enclosing
+// function rather than of the function being checked
+func (e *Env) Captured(name string) bool {
+	crossed := false
+
+	for s := e.currentScope; s.parent != nil; s = s.parent {
+		if _, ok := s.symbols[name]; ok {
+			return crossed
+		}
+
+		crossed = crossed || s.fn
+	}
+
+	return false
+}
+
 func (e *Env) PopScope() {
 	if e.currentScope.parent != nil {
 		e.currentScope = e.currentScope.parent
@@ -44,3 +44,62 @@ func TestTypeEnv(t *testing.T) {
 		t.Error("expected y to be out of scope")
 	}
 }
+
+func TestMethods(t *testing.T) {
+	env := NewEnv()
+	point := &StructType{Name: "Point", Fields: map[string]Type{}}
+	i32 := &PrimitiveType{Name: "i32", Kind: Int32}
+
+	m := &Method{Name: "len", Receiver: RefReceiver, Type: &FuncType{Return: i32}}
+	if !env.DefineMethod(point, m) {
+		t.Fatal("expected first definition to succeed")
+	}
+
+	if env.DefineMethod(point, m) {
+		t.Error("expected duplicate method to be rejected")
+	}
+
+	// Methods are found through references to the receiver type
+	got, ok := env.LookupMethod(&RefType{Mut: true, Elem: point}, "len")
+	if !ok || got != m {
+		t.Fatalf("expected to find len through &mut Point")
+	}
+
+	if _, ok := env.LookupMethod(point, "missing"); ok {
+		t.Error("expected missing method lookup to fail")
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	env := NewEnv()
+	i32 := &PrimitiveType{Name: "i32", Kind: Int32}
+	boolType := &PrimitiveType{Name: "bool", Kind: Bool}
+
+	env.Define("x", i32, false)
+	outer, _ := env.LookupSymbol("x")
+
+	env.PushScope()
+	env.Define("x", boolType, true)
+
+	inner, _ := env.LookupSymbol("x")
+	if inner == outer || inner.Shadows != outer {
+		t.Fatalf("expected inner x to shadow outer x")
+	}
+
+	env.Define("x", i32, false)
+
+	again, _ := env.LookupSymbol("x")
+	if again.Shadows != inner {
+		t.Errorf("expected redeclaration in the same scope to shadow the previous x")
+	}
+
+	env.PopScope()
+
+	if sym, _ := env.LookupSymbol("x"); sym != outer {
+		t.Errorf("expected outer x to be visible again")
+	}
+
+	if outer.Shadows != nil {
+		t.Errorf("expected outer x to shadow nothing")
+	}
+}
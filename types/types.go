@@ -1,6 +1,9 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Type represents a YarLang type
 type Type interface {
@@ -73,6 +76,19 @@ func (s *SliceType) String() string {
 	return fmt.Sprintf("[]%s", s.Elem.String())
 }
 
+// StringType represents str, immutable UTF-8 text held as a pointer and a
+// byte length
+type StringType struct{}
+
+func (s *StringType) isType()        {}
+func (s *StringType) String() string { return "str" }
+
+// IsString reports whether t is str
+func IsString(t Type) bool {
+	_, ok := t.(*StringType)
+	return ok
+}
+
 // ArrayType represents [T; N]
 type ArrayType struct {
 	Elem Type
@@ -122,9 +138,10 @@ func (s *StructType) String() string {
 
 // EnumType represents user-defined enums
 type EnumType struct {
-	Name     string
-	Variants map[string][]Type // Variant name -> payload types
-	TParams  []string
+	Name          string
+	Variants      map[string][]Type // Variant name -> payload types
+	TParams       []string
+	Discriminants map[string]int64 // Variant name -> discriminant, for enums without payloads
 }
 
 func (e *EnumType) isType() {}
@@ -138,13 +155,199 @@ func (e *EnumType) String() string {
 
 // FuncType represents function types
 type FuncType struct {
-	Params []Type
-	Return Type
+	Params   []Type
+	Return   Type
+	Variadic bool // The last parameter is a []T filled from the trailing arguments
+	Extern   bool // Implemented outside yarlang; calls need an unsafe block
 }
 
 func (f *FuncType) isType() {}
 func (f *FuncType) String() string {
-	return fmt.Sprintf("fn(%v) %s", f.Params, f.Return.String())
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+
+	return fmt.Sprintf("fn(%s) %s", strings.Join(params, ", "), f.Return.String())
+}
+
+// Receiver describes how a method takes self
+type Receiver int
+
+const (
+	NoReceiver     Receiver = iota // associated function without self
+	RefReceiver                    // &self
+	MutRefReceiver                 // &mut self
+)
+
+// Method is a function defined in an impl block
+type Method struct {
+	Name     string
+	Receiver Receiver
+	Type     *FuncType // Parameters exclude self
+}
+
+// String renders the method signature, e.g. "fn area(&self, i32) f64"
+func (m *Method) String() string {
+	params := []string{}
+
+	switch m.Receiver {
+	case RefReceiver:
+		params = append(params, "&self")
+	case MutRefReceiver:
+		params = append(params, "&mut self")
+	}
+
+	for i, p := range m.Type.Params {
+		if slice, ok := p.(*SliceType); ok && m.Type.Variadic && i == len(m.Type.Params)-1 {
+			params = append(params, "..."+slice.Elem.String())
+			continue
+		}
+
+		params = append(params, p.String())
+	}
+
+	return fmt.Sprintf("fn %s(%s) %s", m.Name, strings.Join(params, ", "), m.Type.Return.String())
+}
+
+// OptionType is the built-in Option<T>: Some(T) or None
+type OptionType struct {
+	Elem Type
+}
+
+func (o *OptionType) isType() {}
+func (o *OptionType) String() string {
+	return fmt.Sprintf("Option<%s>", o.Elem.String())
+}
+
+// ResultType is the built-in Result<T, E>: Ok(T) or Err(E)
+type ResultType struct {
+	Ok  Type
+	Err Type
+}
+
+func (r *ResultType) isType() {}
+func (r *ResultType) String() string {
+	return fmt.Sprintf("Result<%s, %s>", r.Ok.String(), r.Err.String())
+}
+
+// VecType is the built-in Vec<T>, a growable array owned by the runtime
+type VecType struct {
+	Elem Type
+}
+
+func (v *VecType) isType() {}
+func (v *VecType) String() string {
+	return fmt.Sprintf("Vec<%s>", v.Elem.String())
+}
+
+// Method returns the built-in method of Vec<T> called name. Elements are
+// copied in by push and set and copied out by get and pop.
+func (v *VecType) Method(name string) (*Method, bool) {
+	i64 := &PrimitiveType{Name: "i64", Kind: Int64}
+	void := &PrimitiveType{Name: "void", Kind: Void}
+
+	switch name {
+	case "push":
+		return &Method{Name: name, Receiver: MutRefReceiver, Type: &FuncType{Params: []Type{v.Elem}, Return: void}}, true
+	case "pop":
+		return &Method{Name: name, Receiver: MutRefReceiver, Type: &FuncType{Return: v.Elem}}, true
+	case "get":
+		return &Method{Name: name, Receiver: RefReceiver, Type: &FuncType{Params: []Type{i64}, Return: v.Elem}}, true
+	case "set":
+		return &Method{Name: name, Receiver: MutRefReceiver, Type: &FuncType{Params: []Type{i64, v.Elem}, Return: void}}, true
+	case "len":
+		return &Method{Name: name, Receiver: RefReceiver, Type: &FuncType{Return: i64}}, true
+	default:
+		return nil, false
+	}
+}
+
+// MapType is the built-in Map<K, V>, a hash map owned by the runtime. Its
+// keys are integers or strs.
+type MapType struct {
+	Key   Type
+	Value Type
+}
+
+func (m *MapType) isType() {}
+func (m *MapType) String() string {
+	return fmt.Sprintf("Map<%s, %s>", m.Key.String(), m.Value.String())
+}
+
+// Method returns the built-in method of Map<K, V> called name. Keys and
+// values are copied in by insert and values copied out by get and remove.
+func (m *MapType) Method(name string) (*Method, bool) {
+	boolType := &PrimitiveType{Name: "bool", Kind: Bool}
+	void := &PrimitiveType{Name: "void", Kind: Void}
+	value := &OptionType{Elem: m.Value}
+
+	switch name {
+	case "insert":
+		return &Method{Name: name, Receiver: MutRefReceiver, Type: &FuncType{Params: []Type{m.Key, m.Value}, Return: void}}, true
+	case "get":
+		return &Method{Name: name, Receiver: RefReceiver, Type: &FuncType{Params: []Type{m.Key}, Return: value}}, true
+	case "remove":
+		return &Method{Name: name, Receiver: MutRefReceiver, Type: &FuncType{Params: []Type{m.Key}, Return: value}}, true
+	case "contains":
+		return &Method{Name: name, Receiver: RefReceiver, Type: &FuncType{Params: []Type{m.Key}, Return: boolType}}, true
+	case "len":
+		return &Method{Name: name, Receiver: RefReceiver, Type: &FuncType{Return: &PrimitiveType{Name: "i64", Kind: Int64}}}, true
+	default:
+		return nil, false
+	}
+}
+
+// IsMapKey reports whether t can be the key of a Map
+func IsMapKey(t Type) bool {
+	_, isStr := t.(*StringType)
+	return isStr || IsInteger(t)
+}
+
+// TraitType represents a trait and the methods an impl must provide
+type TraitType struct {
+	Name    string
+	Methods []*Method
+	TParams []string
+	Self    *TypeVar // Stands for the implementing type in Methods
+}
+
+func (t *TraitType) isType()        {}
+func (t *TraitType) String() string { return t.Name }
+
+// Method returns the trait method called name, or nil
+func (t *TraitType) Method(name string) *Method {
+	for _, m := range t.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// DynType represents dyn Trait. Its size is unknown, so it only appears
+// behind a reference, which carries the vtable of the concrete type.
+type DynType struct {
+	Trait *TraitType
+}
+
+func (d *DynType) isType()        {}
+func (d *DynType) String() string { return "dyn " + d.Trait.Name }
+
+// TypeName returns the name methods are registered under for t. References
+// resolve to their element so methods are found through &T and &mut T.
+func TypeName(t Type) string {
+	switch t := t.(type) {
+	case *StructType:
+		return t.Name
+	case *EnumType:
+		return t.Name
+	case *RefType:
+		return TypeName(t.Elem)
+	default:
+		return t.String()
+	}
 }
 
 // TypeVar represents a type variable for inference
@@ -172,6 +375,9 @@ func TypesEqual(t1, t2 Type) bool {
 	case *SliceType:
 		t2, ok := t2.(*SliceType)
 		return ok && TypesEqual(t1.Elem, t2.Elem)
+	case *StringType:
+		_, ok := t2.(*StringType)
+		return ok
 	case *ArrayType:
 		t2, ok := t2.(*ArrayType)
 		return ok && t1.Len == t2.Len && TypesEqual(t1.Elem, t2.Elem)
@@ -194,9 +400,24 @@ func TypesEqual(t1, t2 Type) bool {
 	case *EnumType:
 		t2, ok := t2.(*EnumType)
 		return ok && t1.Name == t2.Name
+	case *OptionType:
+		t2, ok := t2.(*OptionType)
+		return ok && TypesEqual(t1.Elem, t2.Elem)
+	case *ResultType:
+		t2, ok := t2.(*ResultType)
+		return ok && TypesEqual(t1.Ok, t2.Ok) && TypesEqual(t1.Err, t2.Err)
+	case *VecType:
+		t2, ok := t2.(*VecType)
+		return ok && TypesEqual(t1.Elem, t2.Elem)
+	case *MapType:
+		t2, ok := t2.(*MapType)
+		return ok && TypesEqual(t1.Key, t2.Key) && TypesEqual(t1.Value, t2.Value)
+	case *DynType:
+		t2, ok := t2.(*DynType)
+		return ok && t1.Trait.Name == t2.Trait.Name
 	case *FuncType:
 		t2, ok := t2.(*FuncType)
-		if !ok || len(t1.Params) != len(t2.Params) {
+		if !ok || len(t1.Params) != len(t2.Params) || t1.Variadic != t2.Variadic {
 			return false
 		}
 
@@ -212,6 +433,136 @@ func TypesEqual(t1, t2 Type) bool {
 	}
 }
 
+// Widens reports whether a value of type from converts implicitly to type to
+// without loss: integers to wider integers of the same signedness, unsigned
+// integers to strictly wider signed ones, and f32 to f64
+func Widens(from, to Type) bool {
+	f, ok := from.(*PrimitiveType)
+	if !ok {
+		return false
+	}
+
+	t, ok := to.(*PrimitiveType)
+	if !ok {
+		return false
+	}
+
+	if f.Kind == Float32 {
+		return t.Kind == Float64
+	}
+
+	fromBits, fromSigned, ok := intInfo(f.Kind)
+	if !ok {
+		return false
+	}
+
+	toBits, toSigned, ok := intInfo(t.Kind)
+	if !ok {
+		return false
+	}
+
+	if fromSigned == toSigned {
+		return toBits > fromBits
+	}
+
+	return !fromSigned && toBits > fromBits
+}
+
+// intInfo returns the width and signedness of an integer kind. Pointer-sized
+// integers are assumed to be 64 bits wide.
+func intInfo(k TypeKind) (int, bool, bool) {
+	switch k {
+	case Int8:
+		return 8, true, true
+	case Int16:
+		return 16, true, true
+	case Int32:
+		return 32, true, true
+	case Int64, ISize:
+		return 64, true, true
+	case UInt8:
+		return 8, false, true
+	case UInt16:
+		return 16, false, true
+	case UInt32:
+		return 32, false, true
+	case UInt64, USize:
+		return 64, false, true
+	default:
+		return 0, false, false
+	}
+}
+
+// IsInteger reports whether t is a signed or unsigned integer type
+func IsInteger(t Type) bool {
+	prim, ok := t.(*PrimitiveType)
+	if !ok {
+		return false
+	}
+
+	_, _, ok = intInfo(prim.Kind)
+
+	return ok
+}
+
+// Instantiates reports whether t becomes u once the type variables in t
+// are replaced. Each variable matches on its own, so Ok(1), a
+// Result<i32, ?T>, instantiates any Result with an i32 payload.
+func Instantiates(t, u Type) bool {
+	switch t := t.(type) {
+	case *TypeVar:
+		return true
+	case *OptionType:
+		u, ok := u.(*OptionType)
+		return ok && Instantiates(t.Elem, u.Elem)
+	case *ResultType:
+		u, ok := u.(*ResultType)
+		return ok && Instantiates(t.Ok, u.Ok) && Instantiates(t.Err, u.Err)
+	case *VecType:
+		u, ok := u.(*VecType)
+		return ok && Instantiates(t.Elem, u.Elem)
+	case *MapType:
+		u, ok := u.(*MapType)
+		return ok && Instantiates(t.Key, u.Key) && Instantiates(t.Value, u.Value)
+	case *RefType:
+		u, ok := u.(*RefType)
+		return ok && t.Mut == u.Mut && Instantiates(t.Elem, u.Elem)
+	case *SliceType:
+		u, ok := u.(*SliceType)
+		return ok && Instantiates(t.Elem, u.Elem)
+	default:
+		return TypesEqual(t, u)
+	}
+}
+
+// IsFloat reports whether t is f32 or f64
+func IsFloat(t Type) bool {
+	prim, ok := t.(*PrimitiveType)
+	return ok && (prim.Kind == Float32 || prim.Kind == Float64)
+}
+
+// IsNumeric reports whether t is an integer or floating-point type
+func IsNumeric(t Type) bool {
+	return IsInteger(t) || IsFloat(t)
+}
+
+// Casts reports whether a value of type from may be converted to type to
+// with as. Any numeric type converts to any other, truncating or rounding
+// when to is narrower, and bool and char convert to integers.
+func Casts(from, to Type) bool {
+	if !IsNumeric(to) {
+		return false
+	}
+
+	if IsNumeric(from) {
+		return true
+	}
+
+	prim, ok := from.(*PrimitiveType)
+
+	return ok && (prim.Kind == Bool || prim.Kind == Char) && IsInteger(to)
+}
+
 // IsCopy returns true if type is Copy (doesn't need move semantics)
 func IsCopy(t Type) bool {
 	switch t := t.(type) {
@@ -221,6 +572,14 @@ func IsCopy(t Type) bool {
 		return true // References are Copy
 	case *PtrType:
 		return true // Raw pointers are Copy
+	case *StringType:
+		return true // str is an immutable view
+	case *FuncType:
+		return true // Function pointers are Copy
+	case *OptionType:
+		return IsCopy(t.Elem)
+	case *ResultType:
+		return IsCopy(t.Ok) && IsCopy(t.Err)
 	case *TupleType:
 		// Tuple is Copy if all elements are Copy
 		for _, elem := range t.Elems {
@@ -21,3 +21,29 @@ func TestPrimitiveTypes(t *testing.T) {
 		t.Error("expected i32 and i64 to be different")
 	}
 }
+
+func TestCasts(t *testing.T) {
+	i32 := &PrimitiveType{Name: "i32", Kind: Int32}
+	u8 := &PrimitiveType{Name: "u8", Kind: UInt8}
+	f64 := &PrimitiveType{Name: "f64", Kind: Float64}
+	boolean := &PrimitiveType{Name: "bool", Kind: Bool}
+
+	tests := []struct {
+		from, to Type
+		want     bool
+	}{
+		{i32, u8, true},
+		{u8, f64, true},
+		{f64, i32, true},
+		{boolean, i32, true},
+		{boolean, f64, false},
+		{i32, boolean, false},
+		{&StringType{}, i32, false},
+	}
+
+	for _, tt := range tests {
+		if got := Casts(tt.from, tt.to); got != tt.want {
+			t.Errorf("Casts(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}